@@ -0,0 +1,218 @@
+package main
+
+import (
+	"strings"
+)
+
+// Node is an element of the parsed query tree.
+type Node interface {
+	// String renders the node back into canonical query syntax.
+	String() string
+	precedence() int
+}
+
+// Operator precedence, lowest first. Leaves bind tightest.
+const (
+	precOr = iota + 1
+	precAnd
+	precNot
+	precLeaf
+)
+
+// TermNode matches a single value, optionally scoped to a field. An empty
+// Field means the value is matched against every text field.
+type TermNode struct {
+	Field  string
+	Value  string
+	Phrase bool // value was quoted and must match as a whole
+	Pos    int
+}
+
+// RangeNode matches values between Low and High. Either bound may be "*"
+// to leave that side open.
+type RangeNode struct {
+	Field       string
+	Low, High   string
+	IncludeLow  bool
+	IncludeHigh bool
+	Pos         int
+}
+
+// AndNode requires both sides to match.
+type AndNode struct {
+	Left, Right Node
+}
+
+// OrNode requires at least one side to match.
+type OrNode struct {
+	Left, Right Node
+}
+
+// NotNode inverts the match of Expr.
+type NotNode struct {
+	Expr Node
+}
+
+func (n *TermNode) precedence() int  { return precLeaf }
+func (n *RangeNode) precedence() int { return precLeaf }
+func (n *AndNode) precedence() int   { return precAnd }
+func (n *OrNode) precedence() int    { return precOr }
+func (n *NotNode) precedence() int   { return precNot }
+
+func (n *TermNode) String() string {
+	var sb strings.Builder
+	if n.Field != "" {
+		sb.WriteString(fieldString(n.Field))
+		sb.WriteByte(':')
+	}
+	quoted := needsQuoting(n.Value)
+	if n.Field != "" {
+		quoted = valueNeedsQuoting(n.Value)
+	}
+	if n.Phrase || quoted {
+		sb.WriteString(quote(n.Value))
+	} else {
+		sb.WriteString(n.Value)
+	}
+	return sb.String()
+}
+
+func (n *RangeNode) String() string {
+	var sb strings.Builder
+	sb.WriteString(fieldString(n.Field))
+	sb.WriteByte(':')
+	if n.IncludeLow {
+		sb.WriteByte('[')
+	} else {
+		sb.WriteByte('{')
+	}
+	sb.WriteString(boundString(n.Low))
+	sb.WriteString(" TO ")
+	sb.WriteString(boundString(n.High))
+	if n.IncludeHigh {
+		sb.WriteByte(']')
+	} else {
+		sb.WriteByte('}')
+	}
+	return sb.String()
+}
+
+func (n *AndNode) String() string {
+	return operand(n.Left, precAnd) + " & " + operand(n.Right, precAnd+1)
+}
+
+func (n *OrNode) String() string {
+	return operand(n.Left, precOr) + " | " + operand(n.Right, precOr+1)
+}
+
+func (n *NotNode) String() string {
+	return "!" + operand(n.Expr, precNot)
+}
+
+// operand renders child, adding parentheses when it binds more loosely than
+// the surrounding operator requires. Right operands pass min+1 so that the
+// left-associative shape of the tree survives a round trip.
+func operand(child Node, min int) string {
+	if child.precedence() < min {
+		return "(" + child.String() + ")"
+	}
+	return child.String()
+}
+
+func boundString(v string) string {
+	// after '[' or TO a leading '-' is part of the bound, as in [-5 TO 5]
+	if !valueNeedsQuoting(v) {
+		return v
+	}
+	return quote(v)
+}
+
+// fieldString renders a field name, quoting it when it would not lex back
+// as a single word in front of the colon.
+func fieldString(f string) string {
+	if needsQuoting(f) {
+		return quote(f)
+	}
+	return f
+}
+
+// needsQuoting reports whether v would not lex back as a single bare word
+// where an operand is expected. A leading '-' there reads as negation.
+func needsQuoting(v string) bool {
+	return strings.HasPrefix(v, "-") || valueNeedsQuoting(v)
+}
+
+// valueNeedsQuoting is needsQuoting for a value directly after "field:",
+// where a leading '-' is part of the word, as in price:-5.
+func valueNeedsQuoting(v string) bool {
+	if v == "" {
+		return true
+	}
+	switch v {
+	case "AND", "OR", "NOT", "TO":
+		return true
+	}
+	for _, r := range v {
+		if !isWordRune(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func quote(v string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(v); i++ {
+		if v[i] == '"' || v[i] == '\\' {
+			sb.WriteByte('\\')
+		}
+		sb.WriteByte(v[i])
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// Walk calls fn for every node in the tree in depth-first order.
+func Walk(n Node, fn func(Node)) {
+	if n == nil {
+		return
+	}
+	fn(n)
+	switch n := n.(type) {
+	case *AndNode:
+		Walk(n.Left, fn)
+		Walk(n.Right, fn)
+	case *OrNode:
+		Walk(n.Left, fn)
+		Walk(n.Right, fn)
+	case *NotNode:
+		Walk(n.Expr, fn)
+	}
+}
+
+// Dump renders the tree as an indented outline for debugging.
+func Dump(n Node) string {
+	var sb strings.Builder
+	dump(&sb, n, 0)
+	return sb.String()
+}
+
+func dump(sb *strings.Builder, n Node, depth int) {
+	indent := strings.Repeat("  ", depth)
+	switch n := n.(type) {
+	case *AndNode:
+		sb.WriteString(indent + "AND\n")
+		dump(sb, n.Left, depth+1)
+		dump(sb, n.Right, depth+1)
+	case *OrNode:
+		sb.WriteString(indent + "OR\n")
+		dump(sb, n.Left, depth+1)
+		dump(sb, n.Right, depth+1)
+	case *NotNode:
+		sb.WriteString(indent + "NOT\n")
+		dump(sb, n.Expr, depth+1)
+	default:
+		sb.WriteString(indent + n.String() + "\n")
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func assertRoundTrip(t *testing.T, tree Node) {
+	t.Helper()
+	text := tree.String()
+	back, err := Parse(text)
+	if err != nil {
+		t.Errorf("Parse(%q): %v", text, err)
+		return
+	}
+	if !reflect.DeepEqual(stripPos(back), stripPos(tree)) {
+		t.Errorf("round trip through %q changed the tree:\n%s\nbecame\n%s", text, Dump(tree), Dump(back))
+	}
+}
+
+func TestParseStringRoundTrip(t *testing.T) {
+	queries := []string{
+		"name:John | age:30 & (location:New York | location:Los Angeles)",
+		`title:laptop & price:[400 TO 1000]`,
+		`categories:electronics -categories:refurbished stock:{0 TO *]`,
+		`"wireless headphones" | attributes.brand:XYZ`,
+		`NOT (location:"New York" OR rating:[* TO 4.2})`,
+		`a:-5 & b:-x`,
+		`temp:[-10 TO -2} | delta:-`,
+		`"list price":[10 TO 20] & "brand name":"Dell Inc"`,
+		`"AND":x | "-neg":y | note:"say \"hi\""`,
+		`!(a | b) & c | d & !!e`,
+		`a & (b & c)`,
+		`a | (b | c)`,
+		`title:"AND" & q:"TO"`,
+	}
+	for _, q := range queries {
+		tree, err := Parse(q)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", q, err)
+			continue
+		}
+		assertRoundTrip(t, tree)
+	}
+}
+
+func TestStringQuotesWhatTheLexerWouldSplit(t *testing.T) {
+	cases := []struct {
+		tree Node
+		want string
+	}{
+		{&TermNode{Field: "a", Value: "-5"}, `a:-5`},
+		{&TermNode{Value: "-5", Phrase: true}, `"-5"`},
+		{&TermNode{Field: "list price", Value: "10"}, `"list price":10`},
+		{&RangeNode{Field: "my field", Low: "-5", High: "*", IncludeLow: true}, `"my field":[-5 TO *}`},
+		{&TermNode{Field: "a", Value: "x:y", Phrase: true}, `a:"x:y"`},
+	}
+	for _, c := range cases {
+		if got := c.tree.String(); got != c.want {
+			t.Errorf("String() = %s, want %s", got, c.want)
+		}
+		assertRoundTrip(t, c.tree)
+	}
+}
+
+func TestQuotedFieldErrors(t *testing.T) {
+	if _, err := Parse(`"":x`); err == nil {
+		t.Error("empty quoted field accepted")
+	}
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Evaluator runs a query tree against Go values. Fields are resolved by
+// their json tag (or Go name), case-insensitively; map fields such as
+// Attributes are addressed with a dotted path, e.g. attributes.brand.
+type Evaluator struct {
+	// DefaultFields are searched by terms without a field. When empty, every
+	// string field of the value is searched.
+	DefaultFields []string
+}
+
+// Match reports whether v satisfies the query. v must be a struct or a
+// pointer to one.
+func (e *Evaluator) Match(n Node, v any) (bool, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return false, fmt.Errorf("cannot evaluate query against %T", v)
+	}
+	return e.eval(n, rv)
+}
+
+// Filter returns the elements of items that satisfy the query.
+func Filter[T any](e *Evaluator, n Node, items []T) ([]T, error) {
+	var out []T
+	for _, item := range items {
+		ok, err := e.Match(n, item)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out = append(out, item)
+		}
+	}
+	return out, nil
+}
+
+func (e *Evaluator) eval(n Node, rv reflect.Value) (bool, error) {
+	switch n := n.(type) {
+	case *AndNode:
+		ok, err := e.eval(n.Left, rv)
+		if err != nil || !ok {
+			return false, err
+		}
+		return e.eval(n.Right, rv)
+	case *OrNode:
+		ok, err := e.eval(n.Left, rv)
+		if err != nil || ok {
+			return ok, err
+		}
+		return e.eval(n.Right, rv)
+	case *NotNode:
+		ok, err := e.eval(n.Expr, rv)
+		return !ok, err
+	case *TermNode:
+		values, err := e.termValues(n.Field, rv)
+		if err != nil {
+			return false, err
+		}
+		for _, fv := range values {
+			if matchTerm(n, fv) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case *RangeNode:
+		fv, err := lookupField(rv, n.Field)
+		if err != nil {
+			return false, err
+		}
+		for _, leaf := range flatten(fv) {
+			if matchRange(n, leaf) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return false, fmt.Errorf("unsupported node %T", n)
+}
+
+// termValues returns the leaf values a term should be compared with.
+func (e *Evaluator) termValues(field string, rv reflect.Value) ([]reflect.Value, error) {
+	if field != "" {
+		fv, err := lookupField(rv, field)
+		if err != nil {
+			return nil, err
+		}
+		return flatten(fv), nil
+	}
+
+	var out []reflect.Value
+	if len(e.DefaultFields) > 0 {
+		for _, name := range e.DefaultFields {
+			if fv, err := lookupField(rv, name); err == nil {
+				out = append(out, flatten(fv)...)
+			}
+		}
+		return out, nil
+	}
+	for i := 0; i < rv.NumField(); i++ {
+		if !rv.Type().Field(i).IsExported() {
+			continue
+		}
+		for _, leaf := range flatten(rv.Field(i)) {
+			if leaf.Kind() == reflect.String {
+				out = append(out, leaf)
+			}
+		}
+	}
+	return out, nil
+}
+
+// lookupField resolves a dotted path such as "attributes.brand". A path that
+// names no struct field is an error; a missing map key simply yields the zero
+// Value so that products without that attribute do not match.
+func lookupField(rv reflect.Value, path string) (reflect.Value, error) {
+	cur := rv
+	for _, part := range strings.Split(path, ".") {
+		cur = reflect.Indirect(cur)
+		if cur.Kind() == reflect.Interface {
+			cur = cur.Elem()
+		}
+		switch cur.Kind() {
+		case reflect.Struct:
+			found := false
+			for i := 0; i < cur.NumField(); i++ {
+				sf := cur.Type().Field(i)
+				if sf.IsExported() && strings.EqualFold(fieldName(sf), part) {
+					cur, found = cur.Field(i), true
+					break
+				}
+			}
+			if !found {
+				return reflect.Value{}, fmt.Errorf("unknown field %q", path)
+			}
+		case reflect.Map:
+			if cur.Type().Key().Kind() != reflect.String {
+				return reflect.Value{}, fmt.Errorf("field %q is not addressable by name", path)
+			}
+			found := false
+			iter := cur.MapRange()
+			for iter.Next() {
+				if strings.EqualFold(iter.Key().String(), part) {
+					cur, found = iter.Value(), true
+					break
+				}
+			}
+			if !found {
+				return reflect.Value{}, nil
+			}
+		case reflect.Invalid:
+			return reflect.Value{}, nil
+		default:
+			return reflect.Value{}, fmt.Errorf("unknown field %q", path)
+		}
+	}
+	return cur, nil
+}
+
+func fieldName(sf reflect.StructField) string {
+	if tag, ok := sf.Tag.Lookup("json"); ok {
+		if name, _, _ := strings.Cut(tag, ","); name != "" && name != "-" {
+			return name
+		}
+	}
+	return sf.Name
+}
+
+// flatten expands slices and interfaces into their scalar elements.
+func flatten(v reflect.Value) []reflect.Value {
+	if !v.IsValid() {
+		return nil
+	}
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		var out []reflect.Value
+		for i := 0; i < v.Len(); i++ {
+			out = append(out, flatten(v.Index(i))...)
+		}
+		return out
+	case reflect.Map, reflect.Struct:
+		return nil
+	}
+	return []reflect.Value{v}
+}
+
+// matchTerm compares a term with one scalar. Strings match case-insensitively:
+// phrases must equal the field or appear in it as a run of whole words, bare
+// words match any word of the field, and a trailing '*' makes a prefix match.
+// Numbers and booleans are compared by value.
+func matchTerm(t *TermNode, v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String:
+		s := strings.ToLower(v.String())
+		want := strings.ToLower(t.Value)
+		if prefix, ok := strings.CutSuffix(want, "*"); ok && !t.Phrase {
+			for _, w := range strings.Fields(s) {
+				if strings.HasPrefix(w, prefix) {
+					return true
+				}
+			}
+			return strings.HasPrefix(s, prefix)
+		}
+		if s == want {
+			return true
+		}
+		return containsWords(strings.Fields(s), strings.Fields(want))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		want, err := strconv.ParseFloat(t.Value, 64)
+		return err == nil && toFloat(v) == want
+	case reflect.Bool:
+		want, err := strconv.ParseBool(t.Value)
+		return err == nil && v.Bool() == want
+	}
+	return false
+}
+
+// containsWords reports whether needle occurs as a contiguous run in words.
+func containsWords(words, needle []string) bool {
+	if len(needle) == 0 {
+		return false
+	}
+	for i := 0; i+len(needle) <= len(words); i++ {
+		match := true
+		for j := range needle {
+			if strings.Trim(words[i+j], ".,;!?") != needle[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRange compares numerically when the field holds a number (or a
+// numeric string such as "15.6") and as case-insensitive strings otherwise.
+func matchRange(r *RangeNode, v reflect.Value) bool {
+	var cmpLow, cmpHigh int
+	switch v.Kind() {
+	case reflect.String:
+		if f, err := strconv.ParseFloat(v.String(), 64); err == nil {
+			return matchNumericRange(r, f)
+		}
+		s := strings.ToLower(v.String())
+		cmpLow = strings.Compare(s, strings.ToLower(r.Low))
+		cmpHigh = strings.Compare(s, strings.ToLower(r.High))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return matchNumericRange(r, toFloat(v))
+	default:
+		return false
+	}
+	return inRange(r, cmpLow, cmpHigh)
+}
+
+func matchNumericRange(r *RangeNode, f float64) bool {
+	cmpLow, ok := compareBound(f, r.Low)
+	if !ok {
+		return false
+	}
+	cmpHigh, ok := compareBound(f, r.High)
+	if !ok {
+		return false
+	}
+	return inRange(r, cmpLow, cmpHigh)
+}
+
+// inRange applies the bound inclusivity to the comparison results.
+func inRange(r *RangeNode, cmpLow, cmpHigh int) bool {
+	if r.Low != "*" && (cmpLow < 0 || cmpLow == 0 && !r.IncludeLow) {
+		return false
+	}
+	if r.High != "*" && (cmpHigh > 0 || cmpHigh == 0 && !r.IncludeHigh) {
+		return false
+	}
+	return true
+}
+
+func compareBound(f float64, bound string) (int, bool) {
+	if bound == "*" {
+		return 0, true
+	}
+	b, err := strconv.ParseFloat(bound, 64)
+	if err != nil {
+		return 0, false
+	}
+	switch {
+	case f < b:
+		return -1, true
+	case f > b:
+		return 1, true
+	}
+	return 0, true
+}
+
+func toFloat(v reflect.Value) float64 {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint())
+	}
+	return v.Float()
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TokenKind identifies the type of a lexical token.
+type TokenKind int
+
+const (
+	TokenEOF TokenKind = iota
+	TokenWord
+	TokenPhrase
+	TokenColon
+	TokenAnd
+	TokenOr
+	TokenNot
+	TokenTo
+	TokenLParen
+	TokenRParen
+	TokenLBracket
+	TokenRBracket
+	TokenLBrace
+	TokenRBrace
+)
+
+var tokenNames = map[TokenKind]string{
+	TokenEOF:      "end of query",
+	TokenWord:     "word",
+	TokenPhrase:   "quoted phrase",
+	TokenColon:    "':'",
+	TokenAnd:      "AND",
+	TokenOr:       "OR",
+	TokenNot:      "NOT",
+	TokenTo:       "TO",
+	TokenLParen:   "'('",
+	TokenRParen:   "')'",
+	TokenLBracket: "'['",
+	TokenRBracket: "']'",
+	TokenLBrace:   "'{'",
+	TokenRBrace:   "'}'",
+}
+
+func (k TokenKind) String() string {
+	if name, ok := tokenNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("token(%d)", int(k))
+}
+
+// Token is a single lexical unit with its byte offset in the input.
+type Token struct {
+	Kind  TokenKind
+	Text  string
+	Pos   int
+	Space bool // whitespace preceded the token
+}
+
+// SyntaxError reports a problem at a precise position in the query.
+type SyntaxError struct {
+	Query string
+	Pos   int
+	Msg   string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at column %d: %s", e.Column(), e.Msg)
+}
+
+// Column returns the 1-based rune column of the error.
+func (e *SyntaxError) Column() int {
+	if e.Pos > len(e.Query) {
+		return utf8.RuneCountInString(e.Query) + 1
+	}
+	return utf8.RuneCountInString(e.Query[:e.Pos]) + 1
+}
+
+// Context renders the query with a caret under the offending position.
+func (e *SyntaxError) Context() string {
+	return e.Query + "\n" + strings.Repeat(" ", e.Column()-1) + "^"
+}
+
+// isWordRune reports whether r may appear inside a bare word.
+func isWordRune(r rune) bool {
+	if unicode.IsSpace(r) {
+		return false
+	}
+	switch r {
+	case '(', ')', '[', ']', '{', '}', ':', '"', '|', '&', '!':
+		return false
+	}
+	return true
+}
+
+// Lex splits the query into tokens. Keywords AND, OR, NOT and TO are only
+// recognised in upper case so that lower-case values are left untouched.
+func Lex(query string) ([]Token, error) {
+	var tokens []Token
+	pos := 0
+	for {
+		start := pos
+		for pos < len(query) {
+			r, size := utf8.DecodeRuneInString(query[pos:])
+			if !unicode.IsSpace(r) {
+				break
+			}
+			pos += size
+		}
+		space := pos > start
+		if pos >= len(query) {
+			tokens = append(tokens, Token{Kind: TokenEOF, Pos: pos, Space: space})
+			return tokens, nil
+		}
+
+		r, size := utf8.DecodeRuneInString(query[pos:])
+		tok := Token{Pos: pos, Space: space}
+		switch {
+		case r == '(':
+			tok.Kind, tok.Text = TokenLParen, "("
+			pos += size
+		case r == ')':
+			tok.Kind, tok.Text = TokenRParen, ")"
+			pos += size
+		case r == '[':
+			tok.Kind, tok.Text = TokenLBracket, "["
+			pos += size
+		case r == ']':
+			tok.Kind, tok.Text = TokenRBracket, "]"
+			pos += size
+		case r == '{':
+			tok.Kind, tok.Text = TokenLBrace, "{"
+			pos += size
+		case r == '}':
+			tok.Kind, tok.Text = TokenRBrace, "}"
+			pos += size
+		case r == ':':
+			tok.Kind, tok.Text = TokenColon, ":"
+			pos += size
+		case r == '!':
+			tok.Kind, tok.Text = TokenNot, "!"
+			pos += size
+		case r == '|':
+			tok.Kind, tok.Text = TokenOr, "|"
+			pos += size
+			if strings.HasPrefix(query[pos:], "|") {
+				pos++
+			}
+		case r == '&':
+			tok.Kind, tok.Text = TokenAnd, "&"
+			pos += size
+			if strings.HasPrefix(query[pos:], "&") {
+				pos++
+			}
+		case r == '-' && isNegation(query[pos+size:], tokens, space):
+			// A leading minus negates the following term, e.g. -category:refurbished.
+			tok.Kind, tok.Text = TokenNot, "-"
+			pos += size
+		case r == '"':
+			text, end, err := lexPhrase(query, pos)
+			if err != nil {
+				return nil, err
+			}
+			tok.Kind, tok.Text = TokenPhrase, text
+			pos = end
+		default:
+			for pos < len(query) {
+				r, size := utf8.DecodeRuneInString(query[pos:])
+				if !isWordRune(r) {
+					break
+				}
+				pos += size
+			}
+			tok.Kind, tok.Text = TokenWord, query[tok.Pos:pos]
+			switch tok.Text {
+			case "AND":
+				tok.Kind = TokenAnd
+			case "OR":
+				tok.Kind = TokenOr
+			case "NOT":
+				tok.Kind = TokenNot
+			case "TO":
+				tok.Kind = TokenTo
+			}
+		}
+		tokens = append(tokens, tok)
+	}
+}
+
+// isNegation reports whether a '-' followed by rest starts a negated operand.
+// It must sit where an operand is expected and be directly attached to it.
+func isNegation(rest string, tokens []Token, space bool) bool {
+	if len(tokens) > 0 {
+		switch prev := tokens[len(tokens)-1].Kind; {
+		case prev == TokenTo || prev == TokenLBracket || prev == TokenLBrace:
+			// range bounds such as [-5 TO 5] are values, not negations
+			return false
+		case !space && !startsOperand(prev):
+			return false
+		}
+	}
+	next, _ := utf8.DecodeRuneInString(rest)
+	return next == '(' || next == '"' || (next != utf8.RuneError && isWordRune(next))
+}
+
+// startsOperand reports whether a token of kind k may be directly followed by
+// a new operand, which is where a '-' is read as negation.
+func startsOperand(k TokenKind) bool {
+	switch k {
+	case TokenAnd, TokenOr, TokenNot, TokenLParen:
+		return true
+	}
+	return false
+}
+
+// lexPhrase reads a double-quoted phrase starting at pos and returns its
+// unescaped text and the offset just past the closing quote.
+func lexPhrase(query string, pos int) (string, int, error) {
+	var sb strings.Builder
+	i := pos + 1
+	for i < len(query) {
+		c := query[i]
+		switch c {
+		case '\\':
+			if i+1 >= len(query) {
+				return "", 0, &SyntaxError{Query: query, Pos: i, Msg: "dangling escape in phrase"}
+			}
+			sb.WriteByte(query[i+1])
+			i += 2
+		case '"':
+			return sb.String(), i + 1, nil
+		default:
+			sb.WriteByte(c)
+			i++
+		}
+	}
+	return "", 0, &SyntaxError{Query: query, Pos: pos, Msg: "unterminated quoted phrase"}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Parser turns a token stream into a query tree.
+//
+// Grammar, lowest precedence first:
+//
+//	query   = or EOF
+//	or      = and { ("|" | "OR") and }
+//	and     = unary { ["&" | "AND"] unary }
+//	unary   = ("!" | "-" | "NOT") unary | primary
+//	primary = "(" or ")" | field ":" value | word | phrase
+//	field   = word | phrase
+//	value   = phrase | word { word } | range
+//	range   = ("[" | "{") bound "TO" bound ("]" | "}")
+//
+// Adjacent terms without an operator are joined with AND, except that bare
+// words following a field value extend it, so "location:New York" is the
+// single term location:"New York".
+type Parser struct {
+	query  string
+	tokens []Token
+	pos    int
+}
+
+// Parse parses a query string into its tree.
+func Parse(query string) (Node, error) {
+	tokens, err := Lex(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &Parser{query: query, tokens: tokens}
+	if p.peek().Kind == TokenEOF {
+		return nil, p.errorf(p.peek(), "empty query")
+	}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.Kind != TokenEOF {
+		if tok.Kind == TokenRParen {
+			return nil, p.errorf(tok, "unmatched ')'")
+		}
+		return nil, p.errorf(tok, "unexpected %s", describe(tok))
+	}
+	return n, nil
+}
+
+// MustParse is like Parse but panics on error. It is meant for constant queries.
+func MustParse(query string) Node {
+	n, err := Parse(query)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+func (p *Parser) peek() Token {
+	return p.tokens[p.pos]
+}
+
+func (p *Parser) peekAt(offset int) Token {
+	if p.pos+offset >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.pos+offset]
+}
+
+func (p *Parser) next() Token {
+	tok := p.tokens[p.pos]
+	if tok.Kind != TokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *Parser) errorf(tok Token, format string, args ...any) error {
+	return &SyntaxError{Query: p.query, Pos: tok.Pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func describe(tok Token) string {
+	switch tok.Kind {
+	case TokenWord:
+		return fmt.Sprintf("word %q", tok.Text)
+	case TokenPhrase:
+		return fmt.Sprintf("phrase %q", tok.Text)
+	}
+	return tok.Kind.String()
+}
+
+func (p *Parser) parseOr() (Node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().Kind == TokenOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &OrNode{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *Parser) parseAnd() (Node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.peek().Kind {
+		case TokenAnd:
+			p.next()
+		case TokenWord, TokenPhrase, TokenNot, TokenLParen:
+			// implicit AND
+		default:
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &AndNode{Left: left, Right: right}
+	}
+}
+
+func (p *Parser) parseUnary() (Node, error) {
+	if p.peek().Kind == TokenNot {
+		p.next()
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &NotNode{Expr: expr}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *Parser) parsePrimary() (Node, error) {
+	tok := p.peek()
+	switch tok.Kind {
+	case TokenLParen:
+		p.next()
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().Kind != TokenRParen {
+			return nil, p.errorf(p.peek(), "expected ')' to close '(' at column %d, found %s",
+				(&SyntaxError{Query: p.query, Pos: tok.Pos}).Column(), describe(p.peek()))
+		}
+		p.next()
+		return n, nil
+	case TokenWord:
+		p.next()
+		if p.peek().Kind == TokenColon {
+			p.next()
+			return p.parseFieldValue(tok)
+		}
+		return &TermNode{Value: tok.Text, Pos: tok.Pos}, nil
+	case TokenPhrase:
+		p.next()
+		if p.peek().Kind == TokenColon {
+			// a quoted field name, as in "list price":[10 TO 20]
+			if tok.Text == "" {
+				return nil, p.errorf(tok, "empty field name")
+			}
+			p.next()
+			return p.parseFieldValue(tok)
+		}
+		return &TermNode{Value: tok.Text, Phrase: true, Pos: tok.Pos}, nil
+	case TokenEOF:
+		return nil, p.errorf(tok, "unexpected end of query, expected a term")
+	}
+	return nil, p.errorf(tok, "unexpected %s, expected a term", describe(tok))
+}
+
+func (p *Parser) parseFieldValue(field Token) (Node, error) {
+	tok := p.peek()
+	switch tok.Kind {
+	case TokenPhrase:
+		p.next()
+		return &TermNode{Field: field.Text, Value: tok.Text, Phrase: true, Pos: field.Pos}, nil
+	case TokenWord:
+		p.next()
+		words := []string{tok.Text}
+		for p.peek().Kind == TokenWord && p.peekAt(1).Kind != TokenColon {
+			words = append(words, p.next().Text)
+		}
+		return &TermNode{
+			Field:  field.Text,
+			Value:  strings.Join(words, " "),
+			Phrase: len(words) > 1,
+			Pos:    field.Pos,
+		}, nil
+	case TokenLBracket, TokenLBrace:
+		return p.parseRange(field)
+	}
+	return nil, p.errorf(tok, "expected a value after %q, found %s", field.Text+":", describe(tok))
+}
+
+func (p *Parser) parseRange(field Token) (Node, error) {
+	open := p.next()
+	n := &RangeNode{Field: field.Text, IncludeLow: open.Kind == TokenLBracket, Pos: field.Pos}
+
+	low, err := p.parseBound()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().Kind != TokenTo {
+		return nil, p.errorf(p.peek(), "expected TO in range, found %s", describe(p.peek()))
+	}
+	p.next()
+	high, err := p.parseBound()
+	if err != nil {
+		return nil, err
+	}
+
+	switch p.peek().Kind {
+	case TokenRBracket:
+		n.IncludeHigh = true
+	case TokenRBrace:
+		n.IncludeHigh = false
+	default:
+		return nil, p.errorf(p.peek(), "expected ']' or '}' to close range, found %s", describe(p.peek()))
+	}
+	p.next()
+	n.Low, n.High = low, high
+	return n, nil
+}
+
+func (p *Parser) parseBound() (string, error) {
+	tok := p.peek()
+	switch tok.Kind {
+	case TokenWord, TokenPhrase:
+		p.next()
+		return tok.Text, nil
+	}
+	return "", p.errorf(tok, "expected a range bound, found %s", describe(tok))
+}
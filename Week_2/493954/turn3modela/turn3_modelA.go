@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+)
+
+// Product mirrors the catalogue document used in turn1.
+type Product struct {
+	ID          string         `json:"id"`
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	Categories  []string       `json:"categories"`
+	Price       float64        `json:"price"`
+	Rating      float64        `json:"rating"`
+	Stock       int            `json:"stock"`
+	Location    string         `json:"location"`
+	Attributes  map[string]any `json:"attributes"`
+}
+
+var products = []Product{
+	{
+		ID: "p1", Title: "Dell XPS 15 Laptop", Description: "Thin and light laptop with OLED display",
+		Categories: []string{"Electronics", "Computers"}, Price: 1499.99, Rating: 4.6, Stock: 12,
+		Location:   "New York",
+		Attributes: map[string]any{"brand": "Dell", "screen_size": "15.6"},
+	},
+	{
+		ID: "p2", Title: "Lenovo IdeaPad Laptop", Description: "Budget laptop for students",
+		Categories: []string{"Electronics", "Computers", "Refurbished"}, Price: 449.00, Rating: 4.1, Stock: 0,
+		Location:   "Los Angeles",
+		Attributes: map[string]any{"brand": "Lenovo", "screen_size": "14"},
+	},
+	{
+		ID: "p3", Title: "Smartphone XYZ", Description: "Rear 12MP camera and 5000mAh battery",
+		Categories: []string{"Electronics", "Phones"}, Price: 799.99, Rating: 4.3, Stock: 40,
+		Location:   "Chicago",
+		Attributes: map[string]any{"brand": "XYZ", "memory": "8GB"},
+	},
+	{
+		ID: "p4", Title: "Noise Cancelling Headphones", Description: "Over-ear wireless headphones",
+		Categories: []string{"Electronics", "Audio"}, Price: 299.50, Rating: 4.8, Stock: 7,
+		Location:   "New York",
+		Attributes: map[string]any{"brand": "Sony"},
+	},
+}
+
+func main() {
+	queries := []string{
+		"name:John | age:30 & (location:New York | location:Los Angeles)",
+		`title:laptop & price:[400 TO 1000]`,
+		`categories:electronics -categories:refurbished stock:{0 TO *]`,
+		`"wireless headphones" | attributes.brand:XYZ`,
+		`NOT (location:"New York" OR rating:[* TO 4.2})`,
+		`title:lap* AND attributes.screen_size:[14 TO 15]`,
+	}
+
+	eval := &Evaluator{DefaultFields: []string{"title", "description"}}
+	for _, q := range queries {
+		fmt.Println("Query:", q)
+
+		ast, err := Parse(q)
+		if err != nil {
+			log.Fatalf("Error parsing query: %v", err)
+		}
+		fmt.Print("AST:\n", Dump(ast))
+
+		encoded := ToURL("/search", ast)
+		fmt.Println("URL:", encoded)
+
+		decoded, err := FromQueryString(encoded[len("/search?"):])
+		if err != nil {
+			log.Fatalf("Error decoding URL: %v", err)
+		}
+		fmt.Println("Round trip equal:", reflect.DeepEqual(stripPos(ast), stripPos(decoded)))
+
+		matches, err := Filter(eval, ast, products)
+		if err != nil {
+			// The first query targets a people index, not products.
+			fmt.Println("Evaluation error:", err)
+			fmt.Println()
+			continue
+		}
+		for _, p := range matches {
+			fmt.Printf("  match: %s %s ($%.2f)\n", p.ID, p.Title, p.Price)
+		}
+		fmt.Println()
+	}
+
+	// Syntax errors point at the offending column.
+	for _, bad := range []string{`title:laptop & (price:[10 TO 50]`, `price:[10 50]`, `title:"unterminated`} {
+		_, err := Parse(bad)
+		var syntaxErr *SyntaxError
+		if errors.As(err, &syntaxErr) {
+			fmt.Println(syntaxErr)
+			fmt.Println(syntaxErr.Context())
+		}
+	}
+
+	// Older flat links still decode.
+	legacy, err := FromQueryString("q=Laptop&categories=Electronics,Computers&attributes[Brand]=Dell")
+	if err != nil {
+		log.Fatalf("Error decoding legacy URL: %v", err)
+	}
+	fmt.Println("\nLegacy URL as query:", legacy)
+}
+
+// stripPos clears source positions so that trees parsed from different
+// texts can be compared structurally.
+func stripPos(n Node) Node {
+	Walk(n, func(n Node) {
+		switch n := n.(type) {
+		case *TermNode:
+			n.Pos = 0
+		case *RangeNode:
+			n.Pos = 0
+		}
+	})
+	return n
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// QueryParam is the URL parameter carrying the canonical query text.
+const QueryParam = "q"
+
+// ToQueryString encodes the tree as a URL query string. Nesting is kept by
+// storing the canonical query text, so FromQueryString(ToQueryString(n))
+// yields a tree equal to n.
+func ToQueryString(n Node) string {
+	values := url.Values{}
+	values.Set(QueryParam, n.String())
+	return values.Encode()
+}
+
+// ToURL appends the encoded query to a path such as "/search".
+func ToURL(path string, n Node) string {
+	return path + "?" + ToQueryString(n)
+}
+
+// FromQueryString decodes a query string produced by ToQueryString. Any other
+// parameters are treated as flat field filters and ANDed with the main
+// query, which keeps links in the older "field=value" style working:
+// attributes[Brand]=Dell becomes attributes.Brand:Dell and a comma-separated
+// value becomes an OR of its parts.
+func FromQueryString(raw string) (Node, error) {
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query string: %w", err)
+	}
+
+	var root Node
+	if q := values.Get(QueryParam); q != "" {
+		if root, err = Parse(q); err != nil {
+			return nil, err
+		}
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		if key != QueryParam {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		field := paramField(key)
+		for _, raw := range values[key] {
+			var alt Node
+			for _, part := range strings.Split(raw, ",") {
+				part = strings.TrimSpace(part)
+				if part == "" {
+					continue
+				}
+				term := &TermNode{Field: field, Value: part, Phrase: valueNeedsQuoting(part)}
+				if alt == nil {
+					alt = term
+				} else {
+					alt = &OrNode{Left: alt, Right: term}
+				}
+			}
+			if alt == nil {
+				continue
+			}
+			if root == nil {
+				root = alt
+			} else {
+				root = &AndNode{Left: root, Right: alt}
+			}
+		}
+	}
+
+	if root == nil {
+		return nil, fmt.Errorf("query string has no %q parameter or filters", QueryParam)
+	}
+	return root, nil
+}
+
+// FromURL decodes the query carried by u.
+func FromURL(u *url.URL) (Node, error) {
+	return FromQueryString(u.RawQuery)
+}
+
+// paramField maps "attributes[Brand]" to "attributes.Brand".
+func paramField(key string) string {
+	key = strings.ReplaceAll(key, "][", ".")
+	key = strings.ReplaceAll(key, "[", ".")
+	return strings.TrimSuffix(key, "]")
+}
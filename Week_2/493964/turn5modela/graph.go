@@ -0,0 +1,236 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var (
+	// ErrNotFound is returned when a user or product does not exist in the graph.
+	ErrNotFound = errors.New("not found")
+	// ErrInvalid is returned when a node or edge fails validation.
+	ErrInvalid = errors.New("invalid input")
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS products (
+	id    TEXT PRIMARY KEY,
+	name  TEXT NOT NULL,
+	price REAL NOT NULL
+);
+CREATE TABLE IF NOT EXISTS users (
+	id TEXT PRIMARY KEY
+);
+CREATE TABLE IF NOT EXISTS rated (
+	user_id    TEXT NOT NULL REFERENCES users(id),
+	product_id TEXT NOT NULL REFERENCES products(id),
+	weight     REAL NOT NULL,
+	rated_at   INTEGER NOT NULL,
+	PRIMARY KEY (user_id, product_id)
+);
+CREATE INDEX IF NOT EXISTS rated_product ON rated(product_id);
+`
+
+// GraphStore is an embedded property graph of users and products connected
+// by weighted RATED edges. The whole graph is kept in memory for traversal
+// and every change is written through to SQLite so it survives restarts.
+type GraphStore struct {
+	mu       sync.RWMutex
+	db       *sql.DB
+	products map[string]*Product
+	users    map[string]struct{}
+	// adjacency in both directions: user -> product -> weight and
+	// product -> user -> weight
+	userEdges    map[string]map[string]float64
+	productEdges map[string]map[string]float64
+}
+
+// OpenGraphStore opens (or creates) the SQLite file at path and loads the
+// graph into memory.
+func OpenGraphStore(path string) (*GraphStore, error) {
+	db, err := sql.Open("sqlite3", path+"?_foreign_keys=on&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create schema: %w", err)
+	}
+
+	g := &GraphStore{
+		db:           db,
+		products:     make(map[string]*Product),
+		users:        make(map[string]struct{}),
+		userEdges:    make(map[string]map[string]float64),
+		productEdges: make(map[string]map[string]float64),
+	}
+	if err := g.load(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return g, nil
+}
+
+// Close releases the underlying database.
+func (g *GraphStore) Close() error {
+	return g.db.Close()
+}
+
+func (g *GraphStore) load() error {
+	rows, err := g.db.Query(`SELECT id, name, price FROM products`)
+	if err != nil {
+		return fmt.Errorf("load products: %w", err)
+	}
+	for rows.Next() {
+		var p Product
+		if err := rows.Scan(&p.ID, &p.Name, &p.Price); err != nil {
+			rows.Close()
+			return err
+		}
+		g.products[p.ID] = &p
+	}
+	rows.Close()
+
+	rows, err = g.db.Query(`SELECT id FROM users`)
+	if err != nil {
+		return fmt.Errorf("load users: %w", err)
+	}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		g.users[id] = struct{}{}
+	}
+	rows.Close()
+
+	rows, err = g.db.Query(`SELECT user_id, product_id, weight FROM rated`)
+	if err != nil {
+		return fmt.Errorf("load ratings: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userID, productID string
+		var weight float64
+		if err := rows.Scan(&userID, &productID, &weight); err != nil {
+			return err
+		}
+		g.link(userID, productID, weight)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for id := range g.products {
+		g.refreshRating(id)
+	}
+	return nil
+}
+
+// UpsertProduct adds a product node or updates its properties.
+func (g *GraphStore) UpsertProduct(p Product) error {
+	if p.ID == "" {
+		return fmt.Errorf("%w: product id is required", ErrInvalid)
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	_, err := g.db.Exec(`INSERT INTO products (id, name, price) VALUES (?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET name = excluded.name, price = excluded.price`,
+		p.ID, p.Name, p.Price)
+	if err != nil {
+		return fmt.Errorf("save product %s: %w", p.ID, err)
+	}
+	stored := p
+	g.products[p.ID] = &stored
+	g.refreshRating(p.ID)
+	return nil
+}
+
+// Product returns a copy of the product node with its current average rating.
+func (g *GraphStore) Product(id string) (Product, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	p, ok := g.products[id]
+	if !ok {
+		return Product{}, fmt.Errorf("product %s: %w", id, ErrNotFound)
+	}
+	return *p, nil
+}
+
+// Rate records a RATED edge from the user to the product, replacing any
+// earlier rating. Unknown users are created on the fly.
+func (g *GraphStore) Rate(f Feedback) error {
+	if f.UserID == "" || f.ProductID == "" {
+		return fmt.Errorf("%w: userId and productId are required", ErrInvalid)
+	}
+	if f.Rating < 1 || f.Rating > 5 {
+		return fmt.Errorf("%w: rating must be between 1 and 5, got %d", ErrInvalid, f.Rating)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.products[f.ProductID]; !ok {
+		return fmt.Errorf("product %s: %w", f.ProductID, ErrNotFound)
+	}
+
+	tx, err := g.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`INSERT OR IGNORE INTO users (id) VALUES (?)`, f.UserID); err != nil {
+		return fmt.Errorf("save user %s: %w", f.UserID, err)
+	}
+	weight := float64(f.Rating)
+	_, err = tx.Exec(`INSERT INTO rated (user_id, product_id, weight, rated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(user_id, product_id) DO UPDATE SET weight = excluded.weight, rated_at = excluded.rated_at`,
+		f.UserID, f.ProductID, weight, time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("save rating: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	g.users[f.UserID] = struct{}{}
+	g.link(f.UserID, f.ProductID, weight)
+	g.refreshRating(f.ProductID)
+	return nil
+}
+
+// link sets the edge weight in both adjacency maps. Callers hold the write lock.
+func (g *GraphStore) link(userID, productID string, weight float64) {
+	if g.userEdges[userID] == nil {
+		g.userEdges[userID] = make(map[string]float64)
+	}
+	if g.productEdges[productID] == nil {
+		g.productEdges[productID] = make(map[string]float64)
+	}
+	g.userEdges[userID][productID] = weight
+	g.productEdges[productID][userID] = weight
+}
+
+// refreshRating recomputes the average rating property of a product.
+func (g *GraphStore) refreshRating(productID string) {
+	p, ok := g.products[productID]
+	if !ok {
+		return
+	}
+	edges := g.productEdges[productID]
+	if len(edges) == 0 {
+		p.Rating = 0
+		return
+	}
+	var sum float64
+	for _, w := range edges {
+		sum += w
+	}
+	p.Rating = sum / float64(len(edges))
+}
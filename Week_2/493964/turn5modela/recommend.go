@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// PageRank tuning for personalized recommendations.
+const (
+	restartProbability = 0.15
+	maxIterations      = 50
+	convergence        = 1e-8
+)
+
+// AlsoRated answers "users who rated this also rated": every other product
+// rated by someone who rated productID scores the product of both edge
+// weights, normalised by the popularity of the candidate so that
+// blockbusters do not drown out closer matches.
+func (g *GraphStore) AlsoRated(productID string, limit int) ([]Recommendation, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if _, ok := g.products[productID]; !ok {
+		return nil, fmt.Errorf("product %s: %w", productID, ErrNotFound)
+	}
+
+	scores := make(map[string]float64)
+	for userID, w := range g.productEdges[productID] {
+		for other, w2 := range g.userEdges[userID] {
+			if other != productID {
+				scores[other] += w * w2
+			}
+		}
+	}
+	for id := range scores {
+		scores[id] /= math.Sqrt(float64(len(g.productEdges[id])))
+	}
+	return topN(scores, limit), nil
+}
+
+// PersonalizedPageRank ranks products by a random walk over the bipartite
+// user/product graph that restarts at userID. Transitions follow RATED edges
+// in proportion to their weight. Products the user already rated are
+// excluded; users without ratings get the most popular products instead.
+func (g *GraphStore) PersonalizedPageRank(userID string, limit int) ([]Recommendation, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if _, ok := g.users[userID]; !ok {
+		return nil, fmt.Errorf("user %s: %w", userID, ErrNotFound)
+	}
+	if len(g.userEdges[userID]) == 0 {
+		return g.popular(limit), nil
+	}
+
+	userRank := map[string]float64{userID: 1}
+	productRank := make(map[string]float64)
+	for i := 0; i < maxIterations; i++ {
+		nextUser := map[string]float64{userID: restartProbability}
+		nextProduct := make(map[string]float64)
+
+		// mass flows user -> product and product -> user along weighted edges
+		for u, rank := range userRank {
+			spread(rank, g.userEdges[u], nextProduct)
+		}
+		for p, rank := range productRank {
+			spread(rank, g.productEdges[p], nextUser)
+		}
+
+		delta := diff(userRank, nextUser) + diff(productRank, nextProduct)
+		userRank, productRank = nextUser, nextProduct
+		if delta < convergence {
+			break
+		}
+	}
+
+	for id := range g.userEdges[userID] {
+		delete(productRank, id)
+	}
+	return topN(productRank, limit), nil
+}
+
+// spread distributes (1-restart)*rank across edges proportionally to weight.
+func spread(rank float64, edges map[string]float64, into map[string]float64) {
+	var total float64
+	for _, w := range edges {
+		total += w
+	}
+	if total == 0 {
+		return
+	}
+	for id, w := range edges {
+		into[id] += (1 - restartProbability) * rank * w / total
+	}
+}
+
+func diff(a, b map[string]float64) float64 {
+	var d float64
+	for k, v := range a {
+		d += math.Abs(v - b[k])
+	}
+	for k, v := range b {
+		if _, ok := a[k]; !ok {
+			d += math.Abs(v)
+		}
+	}
+	return d
+}
+
+// popular scores products by total rating weight; used for cold starts.
+func (g *GraphStore) popular(limit int) []Recommendation {
+	scores := make(map[string]float64, len(g.products))
+	for id := range g.products {
+		var sum float64
+		for _, w := range g.productEdges[id] {
+			sum += w
+		}
+		scores[id] = sum
+	}
+	return topN(scores, limit)
+}
+
+// topN sorts by descending score, breaking ties by product ID.
+func topN(scores map[string]float64, limit int) []Recommendation {
+	recs := make([]Recommendation, 0, len(scores))
+	for id, score := range scores {
+		if score > 0 {
+			recs = append(recs, Recommendation{ProductID: id, Score: score})
+		}
+	}
+	sort.Slice(recs, func(i, j int) bool {
+		if recs[i].Score != recs[j].Score {
+			return recs[i].Score > recs[j].Score
+		}
+		return recs[i].ProductID < recs[j].ProductID
+	})
+	if limit > 0 && len(recs) > limit {
+		recs = recs[:limit]
+	}
+	return recs
+}
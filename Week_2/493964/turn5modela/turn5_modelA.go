@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type Product struct {
+	ID     string  `json:"id"`
+	Name   string  `json:"name"`
+	Price  float64 `json:"price"`
+	Rating float64 `json:"rating"`
+}
+
+type Recommendation struct {
+	ProductID string  `json:"productId"`
+	Score     float64 `json:"score"`
+}
+
+type Feedback struct {
+	UserID    string `json:"userId"`
+	ProductID string `json:"productId"`
+	Rating    int    `json:"rating"`
+}
+
+var seedProducts = []Product{
+	{ID: "p1", Name: "Laptop", Price: 999.99},
+	{ID: "p2", Name: "Wireless Mouse", Price: 25.50},
+	{ID: "p3", Name: "Mechanical Keyboard", Price: 89.00},
+	{ID: "p4", Name: "USB-C Hub", Price: 39.99},
+	{ID: "p5", Name: "Noise Cancelling Headphones", Price: 249.00},
+}
+
+func main() {
+	dbPath := flag.String("db", "graph.db", "path to the SQLite graph store")
+	addr := flag.String("addr", ":8080", "listen address")
+	flag.Parse()
+
+	store, err := OpenGraphStore(*dbPath)
+	if err != nil {
+		log.Fatalf("Failed to open graph store: %v", err)
+	}
+	defer store.Close()
+
+	for _, p := range seedProducts {
+		if _, err := store.Product(p.ID); errors.Is(err, ErrNotFound) {
+			if err := store.UpsertProduct(p); err != nil {
+				log.Fatalf("Failed to seed product %s: %v", p.ID, err)
+			}
+		}
+	}
+
+	log.Printf("Listening on %s (graph store %s)", *addr, *dbPath)
+	log.Fatal(http.ListenAndServe(*addr, newMux(store)))
+}
+
+// newMux routes the API to store.
+func newMux(store *GraphStore) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/recommendations/", func(w http.ResponseWriter, r *http.Request) {
+		userID := r.URL.Path[len("/recommendations/"):]
+		limit := queryInt(r, "limit", 10)
+
+		recs, err := store.PersonalizedPageRank(userID, limit)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, recs)
+	})
+
+	mux.HandleFunc("/products/", func(w http.ResponseWriter, r *http.Request) {
+		rest := r.URL.Path[len("/products/"):]
+		if productID, ok := strings.CutSuffix(rest, "/also-rated"); ok {
+			recs, err := store.AlsoRated(productID, queryInt(r, "limit", 10))
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, recs)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			product, err := store.Product(rest)
+			if err != nil {
+				writeJSON(w, http.StatusNotFound, map[string]string{"message": "Product not found"})
+				return
+			}
+			writeJSON(w, http.StatusOK, product)
+		case http.MethodPut:
+			var p Product
+			if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"message": "Invalid product data"})
+				return
+			}
+			p.ID = rest
+			status := http.StatusOK
+			if _, err := store.Product(p.ID); errors.Is(err, ErrNotFound) {
+				status = http.StatusCreated
+			}
+			if err := store.UpsertProduct(p); err != nil {
+				writeError(w, err)
+				return
+			}
+			// the rating is derived from feedback, so reply with what was
+			// stored rather than echoing the request
+			stored, err := store.Product(p.ID)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			writeJSON(w, status, stored)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/feedback", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var feedback Feedback
+		if err := json.NewDecoder(r.Body).Decode(&feedback); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"message": "Invalid feedback data"})
+			return
+		}
+		// The edge is live as soon as Rate returns, so the next
+		// recommendation request already reflects it.
+		if err := store.Rate(feedback); err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, map[string]string{"message": "Feedback saved"})
+	})
+	return mux
+}
+
+func queryInt(r *http.Request, name string, def int) int {
+	if v, err := strconv.Atoi(r.URL.Query().Get(name)); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": err.Error()})
+		return
+	case errors.Is(err, ErrInvalid):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": err.Error()})
+		return
+	}
+	log.Printf("Internal error: %v", err)
+	writeJSON(w, http.StatusInternalServerError, map[string]string{"message": "Internal server error"})
+}
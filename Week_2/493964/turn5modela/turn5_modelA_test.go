@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestServer(t *testing.T) (*GraphStore, *httptest.Server) {
+	t.Helper()
+	store, err := OpenGraphStore(filepath.Join(t.TempDir(), "graph.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(newMux(store))
+	t.Cleanup(func() {
+		srv.Close()
+		store.Close()
+	})
+	return store, srv
+}
+
+func do(t *testing.T, srv *httptest.Server, method, path, body string) (int, Product) {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var p Product
+	json.NewDecoder(res.Body).Decode(&p)
+	return res.StatusCode, p
+}
+
+func TestPutProduct(t *testing.T) {
+	store, srv := newTestServer(t)
+
+	// the client's rating is ignored; a new product has none yet
+	code, p := do(t, srv, http.MethodPut, "/products/p9", `{"name": "Webcam", "price": 59.5, "rating": 5}`)
+	if code != http.StatusCreated || p != (Product{ID: "p9", Name: "Webcam", Price: 59.5}) {
+		t.Fatalf("create = %d %+v", code, p)
+	}
+
+	if err := store.Rate(Feedback{UserID: "u1", ProductID: "p9", Rating: 4}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Rate(Feedback{UserID: "u2", ProductID: "p9", Rating: 2}); err != nil {
+		t.Fatal(err)
+	}
+	code, p = do(t, srv, http.MethodPut, "/products/p9", `{"name": "HD Webcam", "price": 69, "rating": 1}`)
+	if code != http.StatusOK || p.Name != "HD Webcam" || p.Price != 69 || p.Rating != 3 {
+		t.Fatalf("update = %d %+v", code, p)
+	}
+	if code, got := do(t, srv, http.MethodGet, "/products/p9", ""); code != http.StatusOK || got != p {
+		t.Errorf("GET after update = %d %+v, want %+v", code, got, p)
+	}
+
+	if code, _ := do(t, srv, http.MethodPut, "/products/p9", `{"name":`); code != http.StatusBadRequest {
+		t.Errorf("malformed body = %d", code)
+	}
+}
+
+func TestProductNotFound(t *testing.T) {
+	_, srv := newTestServer(t)
+	if code, _ := do(t, srv, http.MethodGet, "/products/missing", ""); code != http.StatusNotFound {
+		t.Errorf("GET missing = %d", code)
+	}
+	if code, _ := do(t, srv, http.MethodGet, "/products/missing/also-rated", ""); code != http.StatusNotFound {
+		t.Errorf("also-rated for missing = %d", code)
+	}
+	if code, _ := do(t, srv, http.MethodDelete, "/products/p1", ""); code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE = %d", code)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// DeadLetterSink receives items that a stage could not process.
+type DeadLetterSink[T any] interface {
+	Write(stage string, item T, err error) error
+}
+
+// DeadLetter is one NDJSON record in the dead-letter output.
+type DeadLetter[T any] struct {
+	Time  time.Time `json:"time"`
+	Stage string    `json:"stage"`
+	Error string    `json:"error"`
+	Item  T         `json:"item"`
+}
+
+// NDJSONDeadLetter writes one JSON object per failed item. It is safe for
+// use by concurrent workers.
+type NDJSONDeadLetter[T any] struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	c   io.Closer
+}
+
+// NewNDJSONDeadLetter writes records to w.
+func NewNDJSONDeadLetter[T any](w io.Writer) *NDJSONDeadLetter[T] {
+	return &NDJSONDeadLetter[T]{enc: json.NewEncoder(w)}
+}
+
+// OpenDeadLetterFile appends records to the file at path, creating it if needed.
+func OpenDeadLetterFile[T any](path string) (*NDJSONDeadLetter[T], error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	d := NewNDJSONDeadLetter[T](f)
+	d.c = f
+	return d, nil
+}
+
+func (d *NDJSONDeadLetter[T]) Write(stage string, item T, err error) error {
+	rec := DeadLetter[T]{Time: time.Now().UTC(), Stage: stage, Item: item}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.enc.Encode(rec)
+}
+
+// Close closes the underlying file when the sink was opened from a path.
+func (d *NDJSONDeadLetter[T]) Close() error {
+	if d.c == nil {
+		return nil
+	}
+	return d.c.Close()
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// Stage is one step of a pipeline. Process may return zero items to drop the
+// input, one item to transform it, or several to fan out.
+type Stage[T any] interface {
+	Name() string
+	Process(ctx context.Context, item T) ([]T, error)
+}
+
+// stageFunc adapts a function to the Stage interface.
+type stageFunc[T any] struct {
+	name string
+	fn   func(ctx context.Context, item T) ([]T, error)
+}
+
+func (s *stageFunc[T]) Name() string { return s.name }
+
+func (s *stageFunc[T]) Process(ctx context.Context, item T) ([]T, error) {
+	return s.fn(ctx, item)
+}
+
+// Map creates a stage that transforms each item into exactly one item.
+func Map[T any](name string, fn func(ctx context.Context, item T) (T, error)) Stage[T] {
+	return &stageFunc[T]{name: name, fn: func(ctx context.Context, item T) ([]T, error) {
+		out, err := fn(ctx, item)
+		if err != nil {
+			return nil, err
+		}
+		return []T{out}, nil
+	}}
+}
+
+// FanOut creates a stage that may emit any number of items per input.
+func FanOut[T any](name string, fn func(ctx context.Context, item T) ([]T, error)) Stage[T] {
+	return &stageFunc[T]{name: name, fn: fn}
+}
+
+// Filter creates a stage that drops items for which keep returns false.
+func Filter[T any](name string, keep func(item T) bool) Stage[T] {
+	return &stageFunc[T]{name: name, fn: func(_ context.Context, item T) ([]T, error) {
+		if keep(item) {
+			return []T{item}, nil
+		}
+		return nil, nil
+	}}
+}
+
+// permanentError marks an error that must not be retried.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so that the pipeline sends the item straight to the
+// dead-letter output instead of retrying.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was wrapped with Permanent.
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// RetryPolicy controls how often a failing item is retried within a stage.
+type RetryPolicy struct {
+	Attempts   int           // total attempts including the first; <=1 disables retries
+	Backoff    time.Duration // delay before the first retry
+	MaxBackoff time.Duration // cap for the doubling delay; 0 means no cap
+}
+
+func (r RetryPolicy) delay(attempt int) time.Duration {
+	d := r.Backoff << (attempt - 1)
+	if r.MaxBackoff > 0 && (d > r.MaxBackoff || d <= 0) {
+		d = r.MaxBackoff
+	}
+	return d
+}
+
+// StageOption configures how a stage is run.
+type StageOption func(*stageConfig)
+
+type stageConfig struct {
+	workers int
+	retry   RetryPolicy
+}
+
+// WithWorkers runs the stage on n concurrent workers.
+func WithWorkers(n int) StageOption {
+	return func(c *stageConfig) {
+		if n > 0 {
+			c.workers = n
+		}
+	}
+}
+
+// WithRetry retries failed items according to policy.
+func WithRetry(policy RetryPolicy) StageOption {
+	return func(c *stageConfig) { c.retry = policy }
+}
+
+type runStage[T any] struct {
+	stage Stage[T]
+	cfg   stageConfig
+	stats *stageStats
+}
+
+// Pipeline runs items through a sequence of stages. Each stage has its own
+// bounded worker pool, so a slow stage only limits its own throughput.
+// Items that still fail after their retries are written to the dead-letter
+// sink together with the error and the stage name.
+type Pipeline[T any] struct {
+	stages     []*runStage[T]
+	deadLetter DeadLetterSink[T]
+	buffer     int
+}
+
+// NewPipeline creates an empty pipeline. A nil sink discards failures.
+func NewPipeline[T any](deadLetter DeadLetterSink[T]) *Pipeline[T] {
+	return &Pipeline[T]{deadLetter: deadLetter, buffer: 16}
+}
+
+// Then appends a stage and returns the pipeline for chaining.
+func (p *Pipeline[T]) Then(stage Stage[T], opts ...StageOption) *Pipeline[T] {
+	cfg := stageConfig{workers: 1}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	p.stages = append(p.stages, &runStage[T]{stage: stage, cfg: cfg, stats: newStageStats(stage.Name())})
+	return p
+}
+
+// Run starts the pipeline over in and returns the channel of fully processed
+// items. The output is closed once in is drained and every stage is done,
+// or when ctx is cancelled.
+func (p *Pipeline[T]) Run(ctx context.Context, in <-chan T) <-chan T {
+	out := in
+	for _, rs := range p.stages {
+		if s, ok := rs.stage.(streamer[T]); ok {
+			out = s.stream(ctx, rs, out, p.buffer)
+			continue
+		}
+		out = p.runStage(ctx, rs, out)
+	}
+	return out
+}
+
+// streamer is implemented by stages that start their own goroutines when the
+// pipeline is run, such as Router, whose branches get their own worker pools.
+type streamer[T any] interface {
+	stream(ctx context.Context, rs *runStage[T], in <-chan T, buffer int) <-chan T
+}
+
+// Process runs a single item synchronously through every stage and returns
+// what comes out of the last one. Stages run inline on the caller's
+// goroutine, so WithWorkers has no effect here.
+func (p *Pipeline[T]) Process(ctx context.Context, item T) []T {
+	items := []T{item}
+	for _, rs := range p.stages {
+		var next []T
+		for _, it := range items {
+			next = append(next, p.process(ctx, rs, it)...)
+		}
+		items = next
+		if len(items) == 0 {
+			break
+		}
+	}
+	return items
+}
+
+func (p *Pipeline[T]) runStage(ctx context.Context, rs *runStage[T], in <-chan T) <-chan T {
+	out := make(chan T, p.buffer)
+	var wg sync.WaitGroup
+	for i := 0; i < rs.cfg.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case item, ok := <-in:
+					if !ok {
+						return
+					}
+					for _, res := range p.process(ctx, rs, item) {
+						select {
+						case out <- res:
+						case <-ctx.Done():
+							return
+						}
+					}
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// process applies one stage to one item, retrying per the stage policy.
+func (p *Pipeline[T]) process(ctx context.Context, rs *runStage[T], item T) []T {
+	attempts := max(rs.cfg.retry.Attempts, 1)
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		start := time.Now()
+		var res []T
+		res, err = rs.stage.Process(ctx, item)
+		rs.stats.observe(time.Since(start), len(res), err)
+		if err == nil {
+			return res
+		}
+		if IsPermanent(err) || attempt == attempts || ctx.Err() != nil {
+			break
+		}
+		rs.stats.retry()
+		select {
+		case <-time.After(rs.cfg.retry.delay(attempt)):
+		case <-ctx.Done():
+			err = ctx.Err()
+			attempt = attempts
+		}
+	}
+
+	rs.stats.deadLetter()
+	if p.deadLetter != nil {
+		if dlErr := p.deadLetter.Write(rs.stage.Name(), item, err); dlErr != nil {
+			// Nothing else can be done with the item; make the loss visible.
+			log.Printf("dead-letter write failed for stage %s: %v", rs.stage.Name(), dlErr)
+		}
+	}
+	return nil
+}
+
+// Stats returns a snapshot of per-stage counters, including stages nested in
+// routers.
+func (p *Pipeline[T]) Stats() []StageStats {
+	var out []StageStats
+	for _, rs := range p.stages {
+		out = append(out, rs.stats.snapshot())
+		if r, ok := rs.stage.(interface{ branchStats() []StageStats }); ok {
+			out = append(out, r.branchStats()...)
+		}
+	}
+	return out
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Router is a stage that sends each item down the branch selected by a key,
+// for example the event type. Branches are full pipelines with their own
+// stages, retries and dead-letter handling; their outputs are merged back
+// into the parent pipeline.
+//
+// When the parent pipeline is started with Run, every branch runs on its own
+// worker pools, so WithWorkers and WithRetry on branch stages apply as they
+// would at the top level. The router's own workers only hand items to their
+// branch. Called through Process, branches run inline instead.
+type Router[T any] struct {
+	name     string
+	key      func(T) string
+	branches map[string]*Pipeline[T]
+	fallback *Pipeline[T]
+}
+
+// NewRouter creates a router stage. Items whose key has no branch pass
+// through unchanged unless a fallback is set with Default.
+func NewRouter[T any](name string, key func(T) string) *Router[T] {
+	return &Router[T]{name: name, key: key, branches: make(map[string]*Pipeline[T])}
+}
+
+// Route registers the branch used for items with the given key.
+func (r *Router[T]) Route(key string, branch *Pipeline[T]) *Router[T] {
+	r.branches[key] = branch
+	return r
+}
+
+// Default registers the branch used for unmatched keys.
+func (r *Router[T]) Default(branch *Pipeline[T]) *Router[T] {
+	r.fallback = branch
+	return r
+}
+
+func (r *Router[T]) Name() string { return r.name }
+
+func (r *Router[T]) Process(ctx context.Context, item T) ([]T, error) {
+	branch := r.branchFor(item)
+	if branch == nil {
+		return []T{item}, nil
+	}
+	return branch.Process(ctx, item), nil
+}
+
+// branchFor returns the branch for item, or nil if it passes through.
+func (r *Router[T]) branchFor(item T) *Pipeline[T] {
+	if branch, ok := r.branches[r.key(item)]; ok {
+		return branch
+	}
+	return r.fallback
+}
+
+// stream starts each branch with Run and has the router's workers dispatch
+// items to them. Branch outputs and unrouted items are merged into the
+// returned channel, which is closed once every branch has drained.
+func (r *Router[T]) stream(ctx context.Context, rs *runStage[T], in <-chan T, buffer int) <-chan T {
+	out := make(chan T, buffer)
+	inputs := make(map[*Pipeline[T]]chan T)
+	var forward sync.WaitGroup
+	for _, branch := range append(r.sortedBranches(), r.fallback) {
+		if branch == nil || inputs[branch] != nil {
+			continue
+		}
+		ch := make(chan T, buffer)
+		inputs[branch] = ch
+		results := branch.Run(ctx, ch)
+		forward.Add(1)
+		go func() {
+			defer forward.Done()
+			for item := range results {
+				select {
+				case out <- item:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	var dispatch sync.WaitGroup
+	for i := 0; i < rs.cfg.workers; i++ {
+		dispatch.Add(1)
+		go func() {
+			defer dispatch.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case item, ok := <-in:
+					if !ok {
+						return
+					}
+					dst := out
+					if branch := r.branchFor(item); branch != nil {
+						dst = inputs[branch]
+					}
+					// latency is how long the branch took to accept the item
+					start := time.Now()
+					select {
+					case dst <- item:
+						rs.stats.observe(time.Since(start), 1, nil)
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}()
+	}
+	go func() {
+		dispatch.Wait()
+		for _, ch := range inputs {
+			close(ch)
+		}
+		forward.Wait()
+		close(out)
+	}()
+	return out
+}
+
+func (r *Router[T]) sortedKeys() []string {
+	keys := make([]string, 0, len(r.branches))
+	for k := range r.branches {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (r *Router[T]) sortedBranches() []*Pipeline[T] {
+	var out []*Pipeline[T]
+	for _, k := range r.sortedKeys() {
+		out = append(out, r.branches[k])
+	}
+	return out
+}
+
+func (r *Router[T]) branchStats() []StageStats {
+	var out []StageStats
+	for _, k := range r.sortedKeys() {
+		for _, s := range r.branches[k].Stats() {
+			s.Stage = r.name + "/" + k + "/" + s.Stage
+			out = append(out, s)
+		}
+	}
+	if r.fallback != nil {
+		for _, s := range r.fallback.Stats() {
+			s.Stage = r.name + "/default/" + s.Stage
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// StageStats is a point-in-time view of a stage's counters.
+type StageStats struct {
+	Stage        string        `json:"stage"`
+	Processed    int64         `json:"processed"` // successful attempts
+	Failed       int64         `json:"failed"`    // failed attempts, including retried ones
+	Retries      int64         `json:"retries"`
+	DeadLettered int64         `json:"deadLettered"`
+	Emitted      int64         `json:"emitted"`
+	Throughput   float64       `json:"throughputPerSec"`
+	AvgLatency   time.Duration `json:"avgLatency"`
+	MaxLatency   time.Duration `json:"maxLatency"`
+}
+
+type stageStats struct {
+	mu           sync.Mutex
+	name         string
+	started      time.Time
+	processed    int64
+	failed       int64
+	retries      int64
+	deadLettered int64
+	emitted      int64
+	totalLatency time.Duration
+	maxLatency   time.Duration
+}
+
+func newStageStats(name string) *stageStats {
+	return &stageStats{name: name, started: time.Now()}
+}
+
+func (s *stageStats) observe(latency time.Duration, emitted int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.failed++
+	} else {
+		s.processed++
+		s.emitted += int64(emitted)
+	}
+	s.totalLatency += latency
+	s.maxLatency = max(s.maxLatency, latency)
+}
+
+func (s *stageStats) retry() {
+	s.mu.Lock()
+	s.retries++
+	s.mu.Unlock()
+}
+
+func (s *stageStats) deadLetter() {
+	s.mu.Lock()
+	s.deadLettered++
+	s.mu.Unlock()
+}
+
+func (s *stageStats) snapshot() StageStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := StageStats{
+		Stage:        s.name,
+		Processed:    s.processed,
+		Failed:       s.failed,
+		Retries:      s.retries,
+		DeadLettered: s.deadLettered,
+		Emitted:      s.emitted,
+		MaxLatency:   s.maxLatency,
+	}
+	if attempts := s.processed + s.failed; attempts > 0 {
+		out.AvgLatency = s.totalLatency / time.Duration(attempts)
+	}
+	if elapsed := time.Since(s.started).Seconds(); elapsed > 0 {
+		out.Throughput = float64(s.processed) / elapsed
+	}
+	return out
+}
+
+// PrintStats writes the stats as an aligned table.
+func PrintStats(w io.Writer, stats []StageStats) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STAGE\tOK\tFAILED\tRETRIES\tDEAD\tEMITTED\tITEMS/S\tAVG\tMAX")
+	for _, s := range stats {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%.1f\t%s\t%s\n",
+			s.Stage, s.Processed, s.Failed, s.Retries, s.DeadLettered, s.Emitted,
+			s.Throughput, s.AvgLatency.Round(time.Microsecond), s.MaxLatency.Round(time.Microsecond))
+	}
+	tw.Flush()
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+)
+
+// Event is a simple event structure.
+type Event struct {
+	ID      int    `json:"id"`
+	Type    string `json:"type"`
+	Payload string `json:"payload"`
+}
+
+var errTransient = errors.New("storage temporarily unavailable")
+
+func main() {
+	deadLetter, err := OpenDeadLetterFile[Event]("dead_letter.ndjson")
+	if err != nil {
+		log.Fatalf("Error opening dead-letter file: %v", err)
+	}
+	defer deadLetter.Close()
+
+	validate := Map("validate", func(_ context.Context, e Event) (Event, error) {
+		if e.Payload == "" {
+			return e, Permanent(fmt.Errorf("invalid payload: %q", e.Payload))
+		}
+		return e, nil
+	})
+
+	// Orders are normalised; clicks carry a comma-separated batch that is
+	// split into one event per element.
+	orders := NewPipeline[Event](deadLetter).
+		Then(Map("uppercase", func(_ context.Context, e Event) (Event, error) {
+			e.Payload = strings.ToUpper(e.Payload)
+			return e, nil
+		}))
+	clicks := NewPipeline[Event](deadLetter).
+		Then(FanOut("split", func(_ context.Context, e Event) ([]Event, error) {
+			var out []Event
+			for _, part := range strings.Split(e.Payload, ",") {
+				out = append(out, Event{ID: e.ID, Type: e.Type, Payload: strings.TrimSpace(part)})
+			}
+			return out, nil
+		}), WithWorkers(2)).
+		Then(Filter("drop-empty", func(e Event) bool { return e.Payload != "" }))
+
+	router := NewRouter("route", func(e Event) string { return e.Type }).
+		Route("order", orders).
+		Route("click", clicks)
+
+	// The persister fails transiently now and then and rejects poison events.
+	persist := Map("persist", func(_ context.Context, e Event) (Event, error) {
+		time.Sleep(time.Duration(rand.Intn(5)) * time.Millisecond)
+		if strings.Contains(e.Payload, "POISON") {
+			return e, Permanent(errors.New("payload rejected by store"))
+		}
+		if rand.Intn(4) == 0 {
+			return e, errTransient
+		}
+		return e, nil
+	})
+
+	pipeline := NewPipeline[Event](deadLetter).
+		Then(validate).
+		Then(router, WithWorkers(4)).
+		Then(persist, WithWorkers(8), WithRetry(RetryPolicy{Attempts: 3, Backoff: 2 * time.Millisecond, MaxBackoff: 20 * time.Millisecond}))
+
+	in := make(chan Event)
+	go func() {
+		defer close(in)
+		types := []string{"order", "click", "signup"}
+		for i := 1; i <= 200; i++ {
+			e := Event{ID: i, Type: types[i%len(types)], Payload: fmt.Sprintf("item-%d", i)}
+			switch {
+			case i%50 == 0:
+				e.Payload = ""
+			case i%37 == 0:
+				// rejected by the persister whichever branch it takes
+				e.Payload = "POISON"
+			case e.Type == "click":
+				e.Payload = fmt.Sprintf("a%d, b%d, ,c%d", i, i, i)
+			}
+			in <- e
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	count := 0
+	for range pipeline.Run(ctx, in) {
+		count++
+	}
+	fmt.Printf("Persisted %d events\n\n", count)
+	PrintStats(os.Stdout, pipeline.Stats())
+	fmt.Println("\nFailed events were written to dead_letter.ndjson")
+}
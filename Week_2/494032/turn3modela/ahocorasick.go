@@ -0,0 +1,95 @@
+package main
+
+// acNode is a state of the Aho–Corasick automaton.
+type acNode struct {
+	next   map[rune]int
+	fail   int
+	output []int // indexes of patterns ending in this state, longest first
+}
+
+// Automaton finds every occurrence of a fixed set of patterns in a single
+// pass over the text, regardless of how many patterns there are.
+type Automaton struct {
+	nodes    []acNode
+	patterns [][]rune
+}
+
+// AutomatonMatch is one pattern occurrence as a rune range [Start, End).
+type AutomatonMatch struct {
+	Pattern    int
+	Start, End int
+}
+
+// NewAutomaton builds the trie and failure links for patterns. Empty
+// patterns are ignored.
+func NewAutomaton(patterns [][]rune) *Automaton {
+	a := &Automaton{nodes: []acNode{{next: map[rune]int{}}}, patterns: patterns}
+	for i, p := range patterns {
+		if len(p) == 0 {
+			continue
+		}
+		state := 0
+		for _, r := range p {
+			nxt, ok := a.nodes[state].next[r]
+			if !ok {
+				a.nodes = append(a.nodes, acNode{next: map[rune]int{}})
+				nxt = len(a.nodes) - 1
+				a.nodes[state].next[r] = nxt
+			}
+			state = nxt
+		}
+		a.nodes[state].output = append(a.nodes[state].output, i)
+	}
+
+	// Breadth-first construction of failure links; each state inherits the
+	// outputs of its failure state so suffix matches are reported too.
+	queue := make([]int, 0, len(a.nodes))
+	for _, child := range a.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for r, child := range a.nodes[state].next {
+			queue = append(queue, child)
+			f := a.nodes[state].fail
+			for f != 0 {
+				if _, ok := a.nodes[f].next[r]; ok {
+					break
+				}
+				f = a.nodes[f].fail
+			}
+			if target, ok := a.nodes[f].next[r]; ok && target != child {
+				a.nodes[child].fail = target
+			}
+			a.nodes[child].output = append(a.nodes[child].output, a.nodes[a.nodes[child].fail].output...)
+		}
+	}
+	return a
+}
+
+// FindAll returns every occurrence of every pattern in text, including
+// overlapping ones, ordered by end position.
+func (a *Automaton) FindAll(text []rune) []AutomatonMatch {
+	var matches []AutomatonMatch
+	state := 0
+	for i, r := range text {
+		for state != 0 {
+			if _, ok := a.nodes[state].next[r]; ok {
+				break
+			}
+			state = a.nodes[state].fail
+		}
+		if nxt, ok := a.nodes[state].next[r]; ok {
+			state = nxt
+		}
+		for _, p := range a.nodes[state].output {
+			matches = append(matches, AutomatonMatch{
+				Pattern: p,
+				Start:   i + 1 - len(a.patterns[p]),
+				End:     i + 1,
+			})
+		}
+	}
+	return matches
+}
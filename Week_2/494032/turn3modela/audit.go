@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuditEntry records one piece of blocked content.
+type AuditEntry struct {
+	Time        time.Time `json:"time"`
+	UserID      int       `json:"user_id"`
+	Role        string    `json:"role"`
+	Rules       []string  `json:"rules"`
+	Matches     []Match   `json:"matches"`
+	ContentHash string    `json:"content_sha256"`
+	Excerpt     string    `json:"excerpt"`
+}
+
+// AuditLog appends entries as JSON lines to a writer and keeps the most
+// recent ones in memory for the admin API.
+type AuditLog struct {
+	mu     sync.Mutex
+	enc    *json.Encoder
+	recent []AuditEntry
+	limit  int
+}
+
+// NewAuditLog writes to w and keeps the last limit entries in memory.
+func NewAuditLog(w io.Writer, limit int) *AuditLog {
+	return &AuditLog{enc: json.NewEncoder(w), limit: limit}
+}
+
+// Record stores an entry for blocked content. Only a hash and a short
+// excerpt with every match masked are kept; matches keep their rule and
+// offsets but not the matched text.
+func (a *AuditLog) Record(user *User, content string, res Result) error {
+	sum := sha256.Sum256([]byte(content))
+	matches := make([]Match, len(res.Matches))
+	for i, m := range res.Matches {
+		m.Text = ""
+		matches[i] = m
+	}
+	entry := AuditEntry{
+		Time:        time.Now().UTC(),
+		Rules:       res.Blocking,
+		Matches:     matches,
+		ContentHash: hex.EncodeToString(sum[:]),
+		Excerpt:     excerpt(mask(content, res.Matches), 80),
+	}
+	if user != nil {
+		entry.UserID, entry.Role = user.ID, user.Role
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.recent = append(a.recent, entry)
+	if len(a.recent) > a.limit {
+		a.recent = a.recent[len(a.recent)-a.limit:]
+	}
+	return a.enc.Encode(entry)
+}
+
+// Recent returns the in-memory entries, newest last.
+func (a *AuditLog) Recent() []AuditEntry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]AuditEntry(nil), a.recent...)
+}
+
+// mask hides every matched span, whatever its action. matches must be
+// ordered by position.
+func mask(content string, matches []Match) string {
+	var sb strings.Builder
+	last := 0
+	for i, m := range matches {
+		if m.End <= last {
+			continue
+		}
+		if i == 0 || m.Start > last {
+			sb.WriteString(content[last:m.Start])
+			sb.WriteString("***")
+		}
+		last = m.End
+	}
+	sb.WriteString(content[last:])
+	return sb.String()
+}
+
+func excerpt(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "…"
+}
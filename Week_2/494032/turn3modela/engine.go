@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Result is the structured outcome of moderating one piece of content.
+type Result struct {
+	Allowed  bool     `json:"allowed"`
+	Content  string   `json:"content"` // redacted content; empty when blocked
+	Matches  []Match  `json:"matches"`
+	Blocking []string `json:"blocking_rules,omitempty"`
+}
+
+// FilterService moderates content with rules that can be changed at
+// runtime. Compiled rule sets are cached per role and rebuilt lazily after
+// any rule change.
+type FilterService struct {
+	mu       sync.RWMutex
+	rules    map[string]*FilterRule
+	compiled map[string]*RuleSet // role -> rule set
+	audit    *AuditLog
+}
+
+// NewFilterService validates and loads the initial rules.
+func NewFilterService(rules []FilterRule, audit *AuditLog) (*FilterService, error) {
+	s := &FilterService{
+		rules:    make(map[string]*FilterRule),
+		compiled: make(map[string]*RuleSet),
+		audit:    audit,
+	}
+	for _, r := range rules {
+		if _, err := s.PutRule(r); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Rules returns the current rules ordered by ID.
+func (s *FilterService) Rules() []FilterRule {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]FilterRule, 0, len(s.rules))
+	for _, r := range s.rules {
+		out = append(out, *r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// PutRule adds a rule or replaces the rule with the same ID. It returns the
+// rule as stored, with defaults filled in.
+func (s *FilterService) PutRule(r FilterRule) (FilterRule, error) {
+	if err := r.Validate(); err != nil {
+		return FilterRule{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules[r.ID] = &r
+	s.compiled = make(map[string]*RuleSet)
+	return r, nil
+}
+
+// DeleteRule removes a rule and reports whether it existed.
+func (s *FilterService) DeleteRule(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.rules[id]; !ok {
+		return false
+	}
+	delete(s.rules, id)
+	s.compiled = make(map[string]*RuleSet)
+	return true
+}
+
+// ruleSet returns the compiled rules for role, building them if needed.
+func (s *FilterService) ruleSet(role string) (*RuleSet, error) {
+	s.mu.RLock()
+	rs, ok := s.compiled[role]
+	s.mu.RUnlock()
+	if ok {
+		return rs, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rs, ok := s.compiled[role]; ok {
+		return rs, nil
+	}
+	var active []*FilterRule
+	for _, r := range s.rules {
+		if r.appliesTo(role) {
+			active = append(active, r)
+		}
+	}
+	rs, err := CompileRuleSet(active)
+	if err != nil {
+		return nil, err
+	}
+	s.compiled[role] = rs
+	return rs, nil
+}
+
+// FilterContent moderates content for a user. Blocked content is recorded
+// in the audit log.
+func (s *FilterService) FilterContent(content string, user *User) (Result, error) {
+	role := ""
+	if user != nil {
+		role = user.Role
+	}
+	rs, err := s.ruleSet(role)
+	if err != nil {
+		return Result{}, err
+	}
+
+	res := Result{Allowed: true, Matches: rs.FindMatches(content)}
+	if res.Matches == nil {
+		res.Matches = []Match{}
+	}
+	for _, m := range res.Matches {
+		if m.Action == ActionBlock {
+			res.Allowed = false
+			res.Blocking = appendUnique(res.Blocking, m.RuleID)
+		}
+	}
+	if !res.Allowed {
+		if s.audit != nil {
+			if err := s.audit.Record(user, content, res); err != nil {
+				return res, fmt.Errorf("audit: %w", err)
+			}
+		}
+		return res, nil
+	}
+
+	res.Content = redact(content, res.Matches)
+	return res, nil
+}
+
+// redact replaces matched spans. Overlapping and adjacent replace matches
+// are merged into one span, which gets the replacement of its first match,
+// so no part of any match is left in the output. matches must be ordered by
+// position.
+func redact(content string, matches []Match) string {
+	var sb strings.Builder
+	last := 0
+	start, end := -1, -1
+	var replace string
+	flush := func() {
+		if start >= 0 {
+			sb.WriteString(content[last:start])
+			sb.WriteString(replace)
+			last = end
+		}
+	}
+	for _, m := range matches {
+		if m.Action != ActionReplace {
+			continue
+		}
+		if start >= 0 && m.Start <= end {
+			end = max(end, m.End)
+			continue
+		}
+		flush()
+		start, end, replace = m.Start, m.End, m.rule.Replace
+	}
+	flush()
+	sb.WriteString(content[last:])
+	return sb.String()
+}
+
+func appendUnique(list []string, v string) []string {
+	for _, x := range list {
+		if x == v {
+			return list
+		}
+	}
+	return append(list, v)
+}
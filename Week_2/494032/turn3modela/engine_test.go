@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRedactMergesOverlappingMatches(t *testing.T) {
+	cases := []struct {
+		rules   []FilterRule
+		content string
+		want    string
+	}{
+		// bcd overlaps the tail of ab and c sits inside bcd
+		{[]FilterRule{{ID: "ab", Keyword: "ab"}, {ID: "bcd", Keyword: "bcd"}, {ID: "c", Keyword: "c"}}, "xabcdx", "x***x"},
+		// adjacent spans become one replacement
+		{[]FilterRule{{ID: "ab", Keyword: "ab", Replace: "[1]"}, {ID: "cd", Keyword: "cd", Replace: "[2]"}}, "xabcdx", "x[1]x"},
+		// the longer match at the same start wins the replacement
+		{[]FilterRule{{ID: "a", Keyword: "ab", Replace: "[ab]"}, {ID: "b", Keyword: "abc", Replace: "[abc]"}}, "abcd", "[abc]d"},
+		{[]FilterRule{{ID: "ab", Keyword: "ab"}}, "ab x ab", "*** x ***"},
+		// flag matches do not extend a span
+		{[]FilterRule{{ID: "ab", Keyword: "ab"}, {ID: "bcd", Keyword: "bcd", Action: ActionFlag}}, "xabcdx", "x***cdx"},
+	}
+	for _, c := range cases {
+		s, err := NewFilterService(c.rules, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := s.FilterContent(c.content, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Content != c.want {
+			t.Errorf("%q with %d rules = %q, want %q", c.content, len(c.rules), res.Content, c.want)
+		}
+	}
+}
+
+func TestAuditKeepsNoMatchedText(t *testing.T) {
+	var buf bytes.Buffer
+	audit := NewAuditLog(&buf, 10)
+	s, err := NewFilterService([]FilterRule{
+		{ID: "secret", Keyword: "hunter2", Action: ActionBlock},
+		{ID: "name", Keyword: "alice", Action: ActionFlag},
+	}, audit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// both matches fall inside the excerpt
+	res, err := s.FilterContent("hunter2 is alice's password, hunter2", &User{ID: 7, Role: "user"})
+	if err != nil || res.Allowed {
+		t.Fatalf("FilterContent = %+v, %v", res, err)
+	}
+	if res.Matches[0].Text != "hunter2" {
+		t.Errorf("result lost the match text: %+v", res.Matches)
+	}
+	for _, secret := range []string{"hunter2", "alice"} {
+		if strings.Contains(buf.String(), secret) {
+			t.Errorf("audit log contains %q: %s", secret, buf.String())
+		}
+	}
+	e := audit.Recent()
+	if len(e) != 1 {
+		t.Fatalf("Recent = %+v", e)
+	}
+	recent, _ := json.Marshal(e)
+	if strings.Contains(string(recent), "hunter2") || strings.Contains(string(recent), "alice") {
+		t.Errorf("Recent contains matched text: %s", recent)
+	}
+	if e[0].Excerpt != "*** is ***'s password, ***" || e[0].Matches[0].RuleID != "secret" {
+		t.Errorf("Recent = %+v", e)
+	}
+}
+
+func TestPutRuleReturnsStoredRule(t *testing.T) {
+	s, _ := NewFilterService(nil, nil)
+	stored, err := s.PutRule(FilterRule{ID: "r", Keyword: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Action != ActionReplace || stored.Replace != "***" {
+		t.Errorf("stored = %+v, want defaults filled in", stored)
+	}
+	if _, err := s.PutRule(FilterRule{ID: "bad"}); err == nil {
+		t.Error("invalid rule accepted")
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// TextMode selects how content is transformed before literal matching.
+type TextMode int
+
+const (
+	// ModeExact matches the content as written.
+	ModeExact TextMode = iota
+	// ModeFold matches case-insensitively.
+	ModeFold
+	// ModeNormalized folds case, strips diacritics and maps homoglyphs and
+	// leetspeak to plain Latin letters, so "Ev1l", "évil" and Cyrillic "еvil"
+	// all read as "evil".
+	ModeNormalized
+)
+
+// homoglyphs maps look-alike characters from other scripts and common
+// leetspeak substitutions to the Latin letter they imitate.
+var homoglyphs = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'е': 'e', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o',
+	'р': 'p', 'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'і': 'i', 'ј': 'j',
+	'ѕ': 's', 'ԁ': 'd', 'ԛ': 'q', 'ԝ': 'w',
+	// Greek
+	'α': 'a', 'β': 'b', 'ε': 'e', 'η': 'n', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o',
+	'ρ': 'p', 'τ': 't', 'υ': 'u', 'χ': 'x', 'ω': 'w',
+	// leetspeak
+	'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't', '8': 'b', '9': 'g',
+	'@': 'a', '$': 's',
+}
+
+// Transformed is content rewritten for one TextMode. Runes[i] came from the
+// original bytes Spans[i][0]:Spans[i][1], which lets matches found in the
+// transformed text be reported and redacted in the original.
+type Transformed struct {
+	Runes []rune
+	Spans [][2]int
+}
+
+// Transform rewrites text according to mode, keeping the offset mapping.
+func Transform(text string, mode TextMode) Transformed {
+	t := Transformed{
+		Runes: make([]rune, 0, len(text)),
+		Spans: make([][2]int, 0, len(text)),
+	}
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		span := [2]int{i, i + size}
+		i += size
+
+		switch mode {
+		case ModeExact:
+			t.add(r, span)
+		case ModeFold:
+			t.add(unicode.ToLower(r), span)
+		case ModeNormalized:
+			for _, d := range norm.NFKD.String(string(r)) {
+				if unicode.Is(unicode.Mn, d) {
+					continue // combining accent
+				}
+				d = unicode.ToLower(d)
+				if m, ok := homoglyphs[d]; ok {
+					d = m
+				}
+				t.add(d, span)
+			}
+		}
+	}
+	return t
+}
+
+func (t *Transformed) add(r rune, span [2]int) {
+	t.Runes = append(t.Runes, r)
+	t.Spans = append(t.Spans, span)
+}
+
+// ByteRange converts the rune range [start, end) to original byte offsets.
+func (t Transformed) ByteRange(start, end int) (int, int) {
+	return t.Spans[start][0], t.Spans[end-1][1]
+}
+
+// NormalizePattern applies the same rewriting to a rule keyword.
+func NormalizePattern(pattern string, mode TextMode) []rune {
+	return Transform(strings.TrimSpace(pattern), mode).Runes
+}
+
+// isWordChar reports whether r is part of a word for whole-word matching.
+func isWordChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// atWordBoundary reports whether the rune range [start, end) is not glued to
+// a neighbouring word character.
+func atWordBoundary(runes []rune, start, end int) bool {
+	if start > 0 && isWordChar(runes[start-1]) {
+		return false
+	}
+	if end < len(runes) && isWordChar(runes[end]) {
+		return false
+	}
+	return true
+}
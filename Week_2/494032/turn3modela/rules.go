@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Action is what happens to content when a rule fires.
+type Action string
+
+const (
+	ActionReplace Action = "replace" // redact the match with Replace
+	ActionBlock   Action = "block"   // reject the whole content
+	ActionFlag    Action = "flag"    // report the match but leave the text alone
+)
+
+// FilterRule describes one moderation rule. Keyword rules are compiled into
+// an Aho–Corasick automaton; Regex rules are matched with the regexp
+// package. Rules without Roles apply to every role.
+type FilterRule struct {
+	ID        string   `json:"id"`
+	Keyword   string   `json:"keyword,omitempty"`
+	Regex     string   `json:"regex,omitempty"`
+	Replace   string   `json:"replace,omitempty"`
+	Action    Action   `json:"action"`
+	WholeWord bool     `json:"whole_word"`
+	MatchCase bool     `json:"match_case"`
+	Normalize bool     `json:"normalize"` // homoglyph, leetspeak and accent folding
+	UserRoles []string `json:"user_roles,omitempty"`
+}
+
+// Validate checks that the rule is well formed and fills in defaults.
+func (r *FilterRule) Validate() error {
+	if r.ID == "" {
+		return fmt.Errorf("rule id is required")
+	}
+	if (r.Keyword == "") == (r.Regex == "") {
+		return fmt.Errorf("rule %s: exactly one of keyword or regex is required", r.ID)
+	}
+	if r.Regex != "" {
+		if _, err := regexp.Compile(r.Regex); err != nil {
+			return fmt.Errorf("rule %s: invalid regex: %w", r.ID, err)
+		}
+		if r.Normalize {
+			return fmt.Errorf("rule %s: normalize is only supported for keyword rules", r.ID)
+		}
+	}
+	switch r.Action {
+	case "":
+		r.Action = ActionReplace
+	case ActionReplace, ActionBlock, ActionFlag:
+	default:
+		return fmt.Errorf("rule %s: unknown action %q", r.ID, r.Action)
+	}
+	if r.Action == ActionReplace && r.Replace == "" {
+		r.Replace = "***"
+	}
+	return nil
+}
+
+// appliesTo reports whether the rule is active for role.
+func (r *FilterRule) appliesTo(role string) bool {
+	if len(r.UserRoles) == 0 {
+		return true
+	}
+	for _, ur := range r.UserRoles {
+		if ur == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *FilterRule) textMode() TextMode {
+	switch {
+	case r.Normalize:
+		return ModeNormalized
+	case r.MatchCase:
+		return ModeExact
+	}
+	return ModeFold
+}
+
+// compiledRegex is a regex rule ready for matching.
+type compiledRegex struct {
+	rule *FilterRule
+	re   *regexp.Regexp
+}
+
+// RuleSet is the compiled form of the rules that apply to one role: one
+// automaton per text mode plus the regex rules.
+type RuleSet struct {
+	automata map[TextMode]*Automaton
+	keywords map[TextMode][]*FilterRule // pattern index -> rule
+	regexes  []compiledRegex
+}
+
+// CompileRuleSet builds the matchers for the given rules.
+func CompileRuleSet(rules []*FilterRule) (*RuleSet, error) {
+	rs := &RuleSet{
+		automata: make(map[TextMode]*Automaton),
+		keywords: make(map[TextMode][]*FilterRule),
+	}
+	patterns := make(map[TextMode][][]rune)
+	for _, rule := range rules {
+		if rule.Regex != "" {
+			expr := rule.Regex
+			if !rule.MatchCase {
+				expr = "(?i)" + expr
+			}
+			if rule.WholeWord {
+				expr = `\b(?:` + expr + `)\b`
+			}
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: %w", rule.ID, err)
+			}
+			rs.regexes = append(rs.regexes, compiledRegex{rule: rule, re: re})
+			continue
+		}
+		mode := rule.textMode()
+		patterns[mode] = append(patterns[mode], NormalizePattern(rule.Keyword, mode))
+		rs.keywords[mode] = append(rs.keywords[mode], rule)
+	}
+	for mode, p := range patterns {
+		rs.automata[mode] = NewAutomaton(p)
+	}
+	return rs, nil
+}
+
+// Match is one rule hit, with byte offsets into the original content.
+type Match struct {
+	RuleID string `json:"rule_id"`
+	Action Action `json:"action"`
+	Start  int    `json:"start"`
+	End    int    `json:"end"`
+	Text   string `json:"text,omitempty"`
+
+	rule *FilterRule
+}
+
+// FindMatches runs every rule over content and returns all hits ordered by
+// position.
+func (rs *RuleSet) FindMatches(content string) []Match {
+	var matches []Match
+	for mode, a := range rs.automata {
+		t := Transform(content, mode)
+		rules := rs.keywords[mode]
+		for _, m := range a.FindAll(t.Runes) {
+			rule := rules[m.Pattern]
+			if rule.WholeWord && !atWordBoundary(t.Runes, m.Start, m.End) {
+				continue
+			}
+			start, end := t.ByteRange(m.Start, m.End)
+			matches = append(matches, Match{RuleID: rule.ID, Action: rule.Action, Start: start, End: end, Text: content[start:end], rule: rule})
+		}
+	}
+	for _, cr := range rs.regexes {
+		for _, loc := range cr.re.FindAllStringIndex(content, -1) {
+			if loc[0] == loc[1] {
+				continue
+			}
+			matches = append(matches, Match{RuleID: cr.rule.ID, Action: cr.rule.Action, Start: loc[0], End: loc[1], Text: content[loc[0]:loc[1]], rule: cr.rule})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return lessMatch(matches[i], matches[j]) })
+	return matches
+}
+
+func lessMatch(a, b Match) bool {
+	if a.Start != b.Start {
+		return a.Start < b.Start
+	}
+	if a.End != b.End {
+		return a.End > b.End // longer match first
+	}
+	return strings.Compare(a.RuleID, b.RuleID) < 0
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// User represents a simple user entity
+type User struct {
+	ID   int    `json:"id"`
+	Role string `json:"role"`
+}
+
+// AuthenticationHandler resolves the user making the request.
+type AuthenticationHandler func(w http.ResponseWriter, r *http.Request) (*User, error)
+
+// AuthorizationHandler decides whether the user may perform the request.
+type AuthorizationHandler func(w http.ResponseWriter, r *http.Request, user *User) bool
+
+type credential struct {
+	password string
+	user     User
+}
+
+// demo accounts; a real deployment would check a user store
+var credentials = map[string]credential{
+	"alice": {password: "alice-secret", user: User{ID: 1, Role: "admin"}},
+	"bob":   {password: "bob-secret", user: User{ID: 2, Role: "user"}},
+	"kid":   {password: "kid-secret", user: User{ID: 3, Role: "child"}},
+}
+
+// BasicAuthHandler authenticates with HTTP basic auth against credentials.
+func BasicAuthHandler(w http.ResponseWriter, r *http.Request) (*User, error) {
+	name, password, ok := r.BasicAuth()
+	cred, known := credentials[name]
+	if !ok || !known || subtle.ConstantTimeCompare([]byte(password), []byte(cred.password)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+		http.Error(w, "Not authorized", http.StatusUnauthorized)
+		return nil, fmt.Errorf("authentication failed")
+	}
+	user := cred.user
+	return &user, nil
+}
+
+// AdminOnly allows only admins to manage rules and read the audit log.
+func AdminOnly(w http.ResponseWriter, r *http.Request, user *User) bool {
+	if user == nil || user.Role != "admin" {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// withAuth wraps a handler with the authentication and, optionally,
+// authorization callbacks, passing them the real writer and request.
+func withAuth(authn AuthenticationHandler, authz AuthorizationHandler, next func(http.ResponseWriter, *http.Request, *User)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := authn(w, r)
+		if err != nil {
+			return
+		}
+		if authz != nil && !authz(w, r, user) {
+			return
+		}
+		next(w, r, user)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func main() {
+	auditFile, err := os.OpenFile("moderation_audit.ndjson", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		log.Fatalf("Error opening audit log: %v", err)
+	}
+	defer auditFile.Close()
+	audit := NewAuditLog(auditFile, 100)
+
+	rules := []FilterRule{
+		{ID: "badword", Keyword: "badword", Replace: "******", WholeWord: true},
+		{ID: "evil", Keyword: "evil", Action: ActionBlock, WholeWord: true, Normalize: true},
+		{ID: "email", Regex: `[\w.+-]+@[\w-]+\.[\w.]+`, Replace: "[email]"},
+		{ID: "phone", Regex: `\+?\d[\d -]{7,}\d`, Replace: "[phone]"},
+		{ID: "sensitive", Keyword: "sensitive", Replace: "REDACTED", UserRoles: []string{"child"}},
+		{ID: "spam", Keyword: "free money", Action: ActionFlag, Normalize: true},
+	}
+	filterService, err := NewFilterService(rules, audit)
+	if err != nil {
+		log.Fatalf("Error loading rules: %v", err)
+	}
+
+	http.HandleFunc("/filter", withAuth(BasicAuthHandler, nil, func(w http.ResponseWriter, r *http.Request, user *User) {
+		var req struct {
+			Content string `json:"content"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		res, err := filterService.FilterContent(req.Content, user)
+		if err != nil {
+			log.Printf("Error filtering content: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		status := http.StatusOK
+		if !res.Allowed {
+			status = http.StatusForbidden
+		}
+		writeJSON(w, status, res)
+	}))
+
+	http.HandleFunc("/admin/rules", withAuth(BasicAuthHandler, AdminOnly, func(w http.ResponseWriter, r *http.Request, _ *User) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, filterService.Rules())
+		case http.MethodPost, http.MethodPut:
+			var rule FilterRule
+			if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			stored, err := filterService.PutRule(rule)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			writeJSON(w, http.StatusOK, stored)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+
+	http.HandleFunc("/admin/rules/", withAuth(BasicAuthHandler, AdminOnly, func(w http.ResponseWriter, r *http.Request, _ *User) {
+		if r.Method != http.MethodDelete {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		id := strings.TrimPrefix(r.URL.Path, "/admin/rules/")
+		if !filterService.DeleteRule(id) {
+			http.Error(w, "Rule not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	http.HandleFunc("/admin/audit", withAuth(BasicAuthHandler, AdminOnly, func(w http.ResponseWriter, r *http.Request, _ *User) {
+		writeJSON(w, http.StatusOK, audit.Recent())
+	}))
+
+	fmt.Println("Filter service listening on :8080")
+	if err := http.ListenAndServe(":8080", nil); err != nil {
+		fmt.Println("Error starting server:", err)
+	}
+}
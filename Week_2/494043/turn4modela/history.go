@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"sync"
+)
+
+// History keeps entered lines in memory and appends them to a file so they
+// survive restarts. Only the newest max lines are loaded back.
+type History struct {
+	mu    sync.Mutex
+	path  string
+	lines []string
+	max   int
+}
+
+// OpenHistory loads the history file at path, creating it on first write.
+func OpenHistory(path string, max int) (*History, error) {
+	h := &History{path: path, max: max}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			h.lines = append(h.lines, line)
+		}
+	}
+	if len(h.lines) > max {
+		h.lines = h.lines[len(h.lines)-max:]
+	}
+	return h, scanner.Err()
+}
+
+// Add records a line, skipping immediate repeats.
+func (h *History) Add(line string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if line == "" || len(h.lines) > 0 && h.lines[len(h.lines)-1] == line {
+		return nil
+	}
+	h.lines = append(h.lines, line)
+	if len(h.lines) > h.max {
+		h.lines = h.lines[len(h.lines)-h.max:]
+	}
+	f, err := os.OpenFile(h.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString(line + "\n")
+	return err
+}
+
+// Entry is a history line with its 1-based number.
+type Entry struct {
+	N    int
+	Line string
+}
+
+// Search returns lines containing query (case-insensitive), newest first.
+// An empty query returns the whole history.
+func (h *History) Search(query string, limit int) []Entry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	query = strings.ToLower(query)
+	var out []Entry
+	for i := len(h.lines) - 1; i >= 0; i-- {
+		if strings.Contains(strings.ToLower(h.lines[i]), query) {
+			out = append(out, Entry{N: i + 1, Line: h.lines[i]})
+			if limit > 0 && len(out) >= limit {
+				break
+			}
+		}
+	}
+	return out
+}
+
+// Get returns line n (1-based), or the last line when n is -1.
+func (h *History) Get(n int) (string, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if n == -1 {
+		n = len(h.lines)
+	}
+	if n < 1 || n > len(h.lines) {
+		return "", false
+	}
+	return h.lines[n-1], true
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// trieNode is one node of the completion trie.
+type trieNode struct {
+	children map[rune]*trieNode
+	word     string // set when a word ends here
+}
+
+// Trie answers prefix queries in time proportional to the prefix length and
+// the number of results instead of the dictionary size.
+type Trie struct {
+	root *trieNode
+}
+
+func NewTrie() *Trie {
+	return &Trie{root: &trieNode{children: map[rune]*trieNode{}}}
+}
+
+// Insert adds word under key.
+func (t *Trie) Insert(key, word string) {
+	n := t.root
+	for _, r := range key {
+		child, ok := n.children[r]
+		if !ok {
+			child = &trieNode{children: map[rune]*trieNode{}}
+			n.children[r] = child
+		}
+		n = child
+	}
+	n.word = word
+}
+
+// Delete removes key, pruning nodes that no longer lead to a word.
+func (t *Trie) Delete(key string) {
+	t.delete(t.root, []rune(key))
+}
+
+func (t *Trie) delete(n *trieNode, key []rune) bool {
+	if len(key) == 0 {
+		n.word = ""
+	} else if child, ok := n.children[key[0]]; ok && t.delete(child, key[1:]) {
+		delete(n.children, key[0])
+	}
+	return n.word == "" && len(n.children) == 0
+}
+
+// Complete returns up to limit words whose key starts with prefix, sorted.
+// A limit <= 0 returns all of them.
+func (t *Trie) Complete(prefix string, limit int) []string {
+	n := t.root
+	for _, r := range prefix {
+		child, ok := n.children[r]
+		if !ok {
+			return nil
+		}
+		n = child
+	}
+	var out []string
+	var walk func(*trieNode)
+	walk = func(n *trieNode) {
+		if n.word != "" {
+			out = append(out, n.word)
+		}
+		keys := make([]rune, 0, len(n.children))
+		for r := range n.children {
+			keys = append(keys, r)
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+		for _, r := range keys {
+			if limit > 0 && len(out) >= limit {
+				return
+			}
+			walk(n.children[r])
+		}
+	}
+	walk(n)
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+// bkNode is one node of a BK-tree; children are keyed by edit distance.
+type bkNode struct {
+	key      string
+	word     string
+	children map[int]*bkNode
+	deleted  bool
+}
+
+// BKTree finds words within an edit distance of a query while visiting only
+// a fraction of the dictionary, using the triangle inequality to prune.
+type BKTree struct {
+	root *bkNode
+}
+
+// Insert adds word under key. Re-inserting a deleted key revives it.
+func (t *BKTree) Insert(key, word string) {
+	if t.root == nil {
+		t.root = &bkNode{key: key, word: word, children: map[int]*bkNode{}}
+		return
+	}
+	n := t.root
+	for {
+		d := levenshteinDistance(key, n.key)
+		if d == 0 {
+			n.word, n.deleted = word, false
+			return
+		}
+		child, ok := n.children[d]
+		if !ok {
+			n.children[d] = &bkNode{key: key, word: word, children: map[int]*bkNode{}}
+			return
+		}
+		n = child
+	}
+}
+
+// Delete marks key as removed. Nodes stay in place to keep the tree valid.
+func (t *BKTree) Delete(key string) {
+	n := t.root
+	for n != nil {
+		d := levenshteinDistance(key, n.key)
+		if d == 0 {
+			n.deleted = true
+			return
+		}
+		n = n.children[d]
+	}
+}
+
+// Suggestion is a dictionary word and its distance from the query.
+type Suggestion struct {
+	Word     string
+	Distance int
+}
+
+// Search returns words within maxDist of key, closest first.
+func (t *BKTree) Search(key string, maxDist int) []Suggestion {
+	if t.root == nil {
+		return nil
+	}
+	var out []Suggestion
+	stack := []*bkNode{t.root}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		d := levenshteinDistance(key, n.key)
+		if d <= maxDist && !n.deleted {
+			out = append(out, Suggestion{Word: n.word, Distance: d})
+		}
+		for cd, child := range n.children {
+			if cd >= d-maxDist && cd <= d+maxDist {
+				stack = append(stack, child)
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Distance != out[j].Distance {
+			return out[i].Distance < out[j].Distance
+		}
+		return out[i].Word < out[j].Word
+	})
+	return out
+}
+
+// levenshteinDistance counts single-rune edits, using two rows of memory.
+func levenshteinDistance(s1, s2 string) int {
+	a, b := []rune(s1), []rune(s2)
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				curr[j] = prev[j-1]
+			} else {
+				curr[j] = 1 + min(prev[j], // deletion
+					curr[j-1], // insertion
+					prev[j-1]) // substitution
+			}
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// CommandIndex provides case-insensitive completion and correction of
+// command names. It is not safe for concurrent use on its own; the
+// registry guards it with its lock.
+type CommandIndex struct {
+	trie *Trie
+	bk   BKTree
+}
+
+func NewCommandIndex() *CommandIndex {
+	return &CommandIndex{trie: NewTrie()}
+}
+
+func (ix *CommandIndex) Add(name string) {
+	key := strings.ToLower(name)
+	ix.trie.Insert(key, name)
+	ix.bk.Insert(key, name)
+}
+
+func (ix *CommandIndex) Remove(name string) {
+	key := strings.ToLower(name)
+	ix.trie.Delete(key)
+	ix.bk.Delete(key)
+}
+
+func (ix *CommandIndex) Complete(prefix string, limit int) []string {
+	return ix.trie.Complete(strings.ToLower(prefix), limit)
+}
+
+func (ix *CommandIndex) Suggest(word string, maxDist int) []Suggestion {
+	return ix.bk.Search(strings.ToLower(word), maxDist)
+}
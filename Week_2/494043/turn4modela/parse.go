@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Stage is one command of a pipeline with its arguments.
+type Stage struct {
+	Name string
+	Args []string
+}
+
+// ParseLine splits an input line into pipeline stages. Arguments are
+// separated by whitespace; single quotes keep text literally, double quotes
+// allow \" and \\ escapes, and a backslash outside quotes escapes the next
+// character. An unquoted '|' starts a new stage.
+func ParseLine(line string) ([]Stage, error) {
+	var stages []Stage
+	var words []string
+	var cur strings.Builder
+	inWord := false
+
+	endWord := func() {
+		if inWord {
+			words = append(words, cur.String())
+			cur.Reset()
+			inWord = false
+		}
+	}
+	endStage := func(pos int) error {
+		endWord()
+		if len(words) == 0 {
+			return fmt.Errorf("empty command at column %d", pos+1)
+		}
+		stages = append(stages, Stage{Name: words[0], Args: words[1:]})
+		words = nil
+		return nil
+	}
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\':
+			if i+1 >= len(runes) {
+				return nil, fmt.Errorf("trailing backslash at column %d", i+1)
+			}
+			i++
+			cur.WriteRune(runes[i])
+			inWord = true
+		case r == '\'':
+			end := indexRune(runes, i+1, '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated ' starting at column %d", i+1)
+			}
+			cur.WriteString(string(runes[i+1 : end]))
+			inWord = true
+			i = end
+		case r == '"':
+			j := i + 1
+			for ; j < len(runes) && runes[j] != '"'; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) && (runes[j+1] == '"' || runes[j+1] == '\\') {
+					j++
+				}
+				cur.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("unterminated \" starting at column %d", i+1)
+			}
+			inWord = true
+			i = j
+		case r == '|':
+			if err := endStage(i); err != nil {
+				return nil, err
+			}
+		case unicode.IsSpace(r):
+			endWord()
+		default:
+			cur.WriteRune(r)
+			inWord = true
+		}
+	}
+	endWord()
+	if len(words) == 0 {
+		if len(stages) > 0 {
+			return nil, fmt.Errorf("empty command after '|'")
+		}
+		return nil, nil
+	}
+	stages = append(stages, Stage{Name: words[0], Args: words[1:]})
+	return stages, nil
+}
+
+func indexRune(runes []rune, from int, r rune) int {
+	for i := from; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// RunPipeline resolves and runs each stage in turn. The output of a stage is
+// appended as the last argument of the next one, so "Join - a b | Upper"
+// calls Upper with "a-b".
+func RunPipeline(reg *CallbackRegistry, stages []Stage, maxDist int) (string, []string, error) {
+	var out string
+	var notes []string
+	for i, st := range stages {
+		cmd, err := reg.Resolve(st.Name, maxDist)
+		if err != nil {
+			return "", notes, err
+		}
+		if !strings.EqualFold(cmd.Name, st.Name) {
+			notes = append(notes, fmt.Sprintf("'%s' corrected to '%s'", st.Name, cmd.Name))
+		}
+		args := st.Args
+		if i > 0 {
+			args = append(append([]string(nil), args...), out)
+		}
+		out, err = reg.Invoke(cmd.Name, args)
+		if err != nil {
+			return "", notes, err
+		}
+	}
+	return out, notes, nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Callback receives the parsed arguments of a command.
+type Callback func(args []string) (string, error)
+
+// ArgSpec documents one positional argument.
+type ArgSpec struct {
+	Name     string
+	Help     string
+	Optional bool
+	Variadic bool // consumes all remaining arguments; must be last
+}
+
+// Command is a callback together with its argument schema and help text.
+type Command struct {
+	Name string
+	Help string
+	Args []ArgSpec
+	Fn   Callback
+}
+
+// Usage renders a one-line synopsis such as "Split <text> <sep>".
+func (c *Command) Usage() string {
+	var sb strings.Builder
+	sb.WriteString(c.Name)
+	for _, a := range c.Args {
+		name := a.Name
+		if a.Variadic {
+			name += "..."
+		}
+		if a.Optional {
+			fmt.Fprintf(&sb, " [%s]", name)
+		} else {
+			fmt.Fprintf(&sb, " <%s>", name)
+		}
+	}
+	return sb.String()
+}
+
+// validate checks args against the schema.
+func (c *Command) validate(args []string) error {
+	required, variadic := 0, false
+	for _, a := range c.Args {
+		if !a.Optional {
+			required++
+		}
+		variadic = variadic || a.Variadic
+	}
+	if len(args) < required {
+		return fmt.Errorf("%s: expected at least %d argument(s), got %d\nusage: %s", c.Name, required, len(args), c.Usage())
+	}
+	if !variadic && len(args) > len(c.Args) {
+		return fmt.Errorf("%s: expected at most %d argument(s), got %d\nusage: %s", c.Name, len(c.Args), len(args), c.Usage())
+	}
+	return nil
+}
+
+// CallbackRegistry maps command names to callbacks. Lookups take a read
+// lock that is released before the callback runs, so callbacks may
+// register or unregister other callbacks without deadlocking.
+type CallbackRegistry struct {
+	mu       sync.RWMutex
+	commands map[string]*Command // keyed by lower-case name
+	index    *CommandIndex
+}
+
+func NewCallbackRegistry() *CallbackRegistry {
+	return &CallbackRegistry{commands: make(map[string]*Command), index: NewCommandIndex()}
+}
+
+// Register adds or replaces a command. Names are case-insensitive.
+func (r *CallbackRegistry) Register(cmd Command) error {
+	if cmd.Name == "" || cmd.Fn == nil {
+		return fmt.Errorf("command needs a name and a callback")
+	}
+	for i, a := range cmd.Args {
+		if a.Variadic && i != len(cmd.Args)-1 {
+			return fmt.Errorf("%s: variadic argument %q must be last", cmd.Name, a.Name)
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := strings.ToLower(cmd.Name)
+	if old, ok := r.commands[key]; ok {
+		r.index.Remove(old.Name)
+	}
+	r.commands[key] = &cmd
+	r.index.Add(cmd.Name)
+	return nil
+}
+
+func (r *CallbackRegistry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := strings.ToLower(name)
+	if cmd, ok := r.commands[key]; ok {
+		delete(r.commands, key)
+		r.index.Remove(cmd.Name)
+	}
+}
+
+// Lookup returns the command registered under name.
+func (r *CallbackRegistry) Lookup(name string) (*Command, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cmd, ok := r.commands[strings.ToLower(name)]
+	return cmd, ok
+}
+
+// Commands returns all commands sorted by name.
+func (r *CallbackRegistry) Commands() []*Command {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]*Command, 0, len(r.commands))
+	for _, c := range r.commands {
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Complete returns command names starting with prefix.
+func (r *CallbackRegistry) Complete(prefix string, limit int) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.index.Complete(prefix, limit)
+}
+
+// Suggest returns command names within maxDist edits of name.
+func (r *CallbackRegistry) Suggest(name string, maxDist int) []Suggestion {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.index.Suggest(name, maxDist)
+}
+
+// Invoke validates args and runs the named callback outside the lock.
+func (r *CallbackRegistry) Invoke(name string, args []string) (string, error) {
+	cmd, ok := r.Lookup(name)
+	if !ok {
+		return "", fmt.Errorf("no callback registered for '%s'", name)
+	}
+	if err := cmd.validate(args); err != nil {
+		return "", err
+	}
+	return cmd.Fn(args)
+}
+
+// Resolve maps a possibly misspelt name to a registered command. A unique
+// closest match within maxDist is accepted; otherwise the error lists the
+// candidates.
+func (r *CallbackRegistry) Resolve(name string, maxDist int) (*Command, error) {
+	if cmd, ok := r.Lookup(name); ok {
+		return cmd, nil
+	}
+	suggestions := r.Suggest(name, maxDist)
+	if len(suggestions) == 1 || len(suggestions) > 1 && suggestions[0].Distance < suggestions[1].Distance {
+		if cmd, ok := r.Lookup(suggestions[0].Word); ok {
+			return cmd, nil
+		}
+	}
+	if len(suggestions) == 0 {
+		return nil, fmt.Errorf("unknown command '%s'", name)
+	}
+	names := make([]string, len(suggestions))
+	for i, s := range suggestions {
+		names[i] = s.Word
+	}
+	return nil, fmt.Errorf("unknown command '%s', did you mean: %s?", name, strings.Join(names, ", "))
+}
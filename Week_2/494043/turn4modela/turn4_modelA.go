@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxCorrection is the largest edit distance accepted when correcting a
+// misspelt command name.
+const maxCorrection = 2
+
+var registry = NewCallbackRegistry()
+
+// aliases records the commands each alias calls, keyed by lower-case name,
+// so that definitions which would make an alias call itself are rejected.
+var (
+	aliasMu sync.Mutex
+	aliases = make(map[string][]string)
+)
+
+func init() {
+	text := ArgSpec{Name: "text", Help: "input string"}
+	commands := []Command{
+		{Name: "Upper", Help: "Convert text to upper case.", Args: []ArgSpec{text},
+			Fn: func(a []string) (string, error) { return strings.ToUpper(a[0]), nil }},
+		{Name: "Lower", Help: "Convert text to lower case.", Args: []ArgSpec{text},
+			Fn: func(a []string) (string, error) { return strings.ToLower(a[0]), nil }},
+		{Name: "TrimSpace", Help: "Remove leading and trailing white space.", Args: []ArgSpec{text},
+			Fn: func(a []string) (string, error) { return strings.TrimSpace(a[0]), nil }},
+		{Name: "Contains", Help: "Report whether substr is within text.",
+			Args: []ArgSpec{{Name: "substr"}, text},
+			Fn:   func(a []string) (string, error) { return strconv.FormatBool(strings.Contains(a[1], a[0])), nil }},
+		{Name: "Index", Help: "Index of the first substr in text, or -1.",
+			Args: []ArgSpec{{Name: "substr"}, text},
+			Fn:   func(a []string) (string, error) { return strconv.Itoa(strings.Index(a[1], a[0])), nil }},
+		{Name: "Join", Help: "Join the elements with the separator.",
+			Args: []ArgSpec{{Name: "sep"}, {Name: "elem", Variadic: true}},
+			Fn:   func(a []string) (string, error) { return strings.Join(a[1:], a[0]), nil }},
+		{Name: "Split", Help: "Split text around sep and join the parts with spaces.",
+			Args: []ArgSpec{{Name: "sep"}, text},
+			Fn:   func(a []string) (string, error) { return strings.Join(strings.Split(a[1], a[0]), " "), nil }},
+		{Name: "Replace", Help: "Replace every old with new in text.",
+			Args: []ArgSpec{{Name: "old"}, {Name: "new"}, text},
+			Fn:   func(a []string) (string, error) { return strings.ReplaceAll(a[2], a[0], a[1]), nil }},
+		{Name: "Repeat", Help: "Repeat text count times.",
+			Args: []ArgSpec{{Name: "count"}, text},
+			Fn: func(a []string) (string, error) {
+				n, err := strconv.Atoi(a[0])
+				if err != nil || n < 0 || n > 1000 {
+					return "", fmt.Errorf("count must be between 0 and 1000")
+				}
+				return strings.Repeat(a[1], n), nil
+			}},
+		// Alias registers a new callback from inside a callback, which is only
+		// possible because Invoke does not hold the registry lock.
+		{Name: "Alias", Help: "Define a new command that runs a pipeline, e.g. Alias Shout 'TrimSpace | Upper'.",
+			Args: []ArgSpec{{Name: "name"}, {Name: "pipeline"}},
+			Fn:   defineAlias},
+	}
+	for _, c := range commands {
+		if err := registry.Register(c); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+func defineAlias(a []string) (string, error) {
+	name, body := a[0], a[1]
+	stages, err := ParseLine(body)
+	if err != nil {
+		return "", err
+	}
+	if len(stages) == 0 {
+		return "", fmt.Errorf("alias body is empty")
+	}
+	calls := make([]string, len(stages))
+	for i, st := range stages {
+		calls[i] = st.Name
+	}
+
+	aliasMu.Lock()
+	defer aliasMu.Unlock()
+	if path := aliasCycle(name, calls, nil); path != nil {
+		return "", fmt.Errorf("alias %s would call itself: %s", name, strings.Join(append([]string{name}, path...), " -> "))
+	}
+	err = registry.Register(Command{
+		Name: name,
+		Help: "Alias for: " + body,
+		Args: []ArgSpec{{Name: "args", Optional: true, Variadic: true}},
+		Fn: func(args []string) (string, error) {
+			st := append([]Stage(nil), stages...)
+			st[0].Args = append(append([]string(nil), st[0].Args...), args...)
+			out, _, err := RunPipeline(registry, st, 0)
+			return out, err
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	aliases[strings.ToLower(name)] = calls
+	return fmt.Sprintf("defined %s", name), nil
+}
+
+// aliasCycle returns the chain of calls leading from calls back to name, or
+// nil if there is none. Callers hold aliasMu.
+func aliasCycle(name string, calls []string, seen map[string]bool) []string {
+	if seen == nil {
+		seen = make(map[string]bool)
+	}
+	for _, c := range calls {
+		if strings.EqualFold(c, name) {
+			return []string{c}
+		}
+		key := strings.ToLower(c)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if path := aliasCycle(name, aliases[key], seen); path != nil {
+			return append([]string{c}, path...)
+		}
+	}
+	return nil
+}
+
+func printHelp(name string) {
+	if name != "" {
+		cmd, err := registry.Resolve(name, maxCorrection)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		fmt.Printf("%s\n  %s\n", cmd.Usage(), cmd.Help)
+		for _, a := range cmd.Args {
+			if a.Help != "" {
+				fmt.Printf("  %-10s %s\n", a.Name, a.Help)
+			}
+		}
+		return
+	}
+	fmt.Println("Commands:")
+	for _, cmd := range registry.Commands() {
+		fmt.Printf("  %-28s %s\n", cmd.Usage(), cmd.Help)
+	}
+	fmt.Println("Built-ins: help [cmd], complete <prefix>, history [query], !N, !!, exit")
+	fmt.Println("Chain commands with '|'; the previous result becomes the last argument.")
+}
+
+func main() {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = "."
+	}
+	history, err := OpenHistory(filepath.Join(home, ".callback_repl_history"), 1000)
+	if err != nil {
+		log.Fatalf("Error loading history: %v", err)
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	fmt.Println("Welcome to the Go strings library REPL!")
+	fmt.Println("Type 'help' for the available commands or 'exit' to quit.")
+	for {
+		fmt.Print("→ ")
+		if !scanner.Scan() {
+			break
+		}
+		input := strings.TrimSpace(scanner.Text())
+		if input == "" {
+			continue
+		}
+
+		// history expansion
+		if strings.HasPrefix(input, "!") {
+			n := -1
+			if input != "!!" {
+				if n, err = strconv.Atoi(input[1:]); err != nil {
+					fmt.Println("Error: use !! or !N")
+					continue
+				}
+			}
+			line, ok := history.Get(n)
+			if !ok {
+				fmt.Println("Error: no such history entry")
+				continue
+			}
+			fmt.Println(line)
+			input = line
+		}
+
+		fields := strings.Fields(input)
+		switch strings.ToLower(fields[0]) {
+		case "exit":
+			fmt.Println("Goodbye!")
+			return
+		case "help":
+			printHelp(strings.Join(fields[1:], " "))
+			continue
+		case "complete":
+			prefix := ""
+			if len(fields) > 1 {
+				prefix = fields[1]
+			}
+			fmt.Println("Completions:", registry.Complete(prefix, 0))
+			continue
+		case "history":
+			for _, e := range history.Search(strings.Join(fields[1:], " "), 20) {
+				fmt.Printf("%5d  %s\n", e.N, e.Line)
+			}
+			continue
+		}
+
+		if err := history.Add(input); err != nil {
+			fmt.Println("Warning: could not save history:", err)
+		}
+		stages, err := ParseLine(input)
+		if err != nil {
+			fmt.Println("Error:", err)
+			continue
+		}
+		result, notes, err := RunPipeline(registry, stages, maxCorrection)
+		for _, n := range notes {
+			fmt.Println("Note:", n)
+		}
+		if err != nil {
+			fmt.Println("Error:", err)
+			for _, st := range stages {
+				if _, ok := registry.Lookup(st.Name); ok {
+					continue
+				}
+				if completions := registry.Complete(st.Name, 5); len(completions) > 0 {
+					fmt.Println("Possible completions:", completions)
+				}
+			}
+			continue
+		}
+		fmt.Printf("Result: %q\n", result)
+	}
+	fmt.Println("Goodbye!")
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"Week_2/494174/turn4modela/fileproc"
+)
+
+// makeFiles creates n small files and returns their paths.
+func makeFiles(tb testing.TB, n int) []string {
+	tb.Helper()
+	dir := tb.TempDir()
+	paths := make([]string, n)
+	for i := range paths {
+		paths[i] = filepath.Join(dir, fmt.Sprintf("file%d.txt", i))
+		if err := os.WriteFile(paths[i], []byte("hello\nworld\n"), 0o644); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return paths
+}
+
+// processWithErrors reads the file, does some slow work and fails the files
+// whose name ends in 7.
+func processWithErrors(ctx context.Context, path string) error {
+	if _, err := os.ReadFile(path); err != nil {
+		return err
+	}
+	if _, err := slowFunction(len(path)); err != nil {
+		return err
+	}
+	if strings.HasSuffix(path, "7.txt") {
+		return fmt.Errorf("bad file")
+	}
+	return ctx.Err()
+}
+
+func TestForEachFileCollectAll(t *testing.T) {
+	paths := makeFiles(t, 20)
+	paths = append(paths, filepath.Join(t.TempDir(), "missing.txt"))
+
+	err := fileproc.ForEachFile(context.Background(), paths, 4, processWithErrors,
+		fileproc.WithMode(fileproc.CollectAll))
+	var report *fileproc.Errors
+	if !errors.As(err, &report) {
+		t.Fatalf("expected *fileproc.Errors, got %v", err)
+	}
+	// file7 and file17 fail, plus the missing file
+	if len(report.Failures) != 3 || report.Skipped != 0 {
+		t.Fatalf("got %d failures, %d skipped:\n%v", len(report.Failures), report.Skipped, err)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Error("errors.Is should find the missing file")
+	}
+}
+
+func TestForEachFileFailFast(t *testing.T) {
+	paths := makeFiles(t, 50)
+	var calls atomic.Int32
+	err := fileproc.ForEachFile(context.Background(), paths, 2,
+		func(ctx context.Context, path string) error {
+			if calls.Add(1) == 3 {
+				return fmt.Errorf("stop")
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(5 * time.Millisecond):
+				return nil
+			}
+		})
+	var report *fileproc.Errors
+	if !errors.As(err, &report) {
+		t.Fatalf("expected *fileproc.Errors, got %v", err)
+	}
+	if len(report.Failures) != 1 || report.Skipped == 0 {
+		t.Fatalf("expected one failure and skipped files:\n%v", err)
+	}
+	if int(calls.Load()) >= len(paths) {
+		t.Errorf("fail-fast still ran %d files", calls.Load())
+	}
+}
+
+func TestForEachFileFailFastAccounting(t *testing.T) {
+	paths := makeFiles(t, 200)
+	for run := 0; run < 20; run++ {
+		var calls, deadOnEntry, completed atomic.Int32
+		err := fileproc.ForEachFile(context.Background(), paths, 8,
+			func(ctx context.Context, path string) error {
+				n := calls.Add(1)
+				if ctx.Err() != nil {
+					deadOnEntry.Add(1)
+				}
+				if n == 10 {
+					return fmt.Errorf("stop")
+				}
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(time.Millisecond):
+					completed.Add(1)
+					return nil
+				}
+			})
+		var report *fileproc.Errors
+		if !errors.As(err, &report) {
+			t.Fatalf("expected *fileproc.Errors, got %v", err)
+		}
+		if len(report.Failures) != 1 || errors.Is(err, context.Canceled) {
+			t.Fatalf("run %d: cancellation reported as a failure:\n%v", run, err)
+		}
+		if n := deadOnEntry.Load(); n != 0 {
+			t.Fatalf("run %d: %d files started after the cancellation", run, n)
+		}
+		// every file not skipped or failed really finished
+		if done := report.Total - report.Skipped - len(report.Failures); done != int(completed.Load()) {
+			t.Fatalf("run %d: report implies %d processed files, %d completed", run, done, completed.Load())
+		}
+	}
+}
+
+func TestForEachFileTimeoutAndPanic(t *testing.T) {
+	paths := makeFiles(t, 2)
+	err := fileproc.ForEachFile(context.Background(), paths, 2,
+		func(ctx context.Context, path string) error {
+			if path == paths[0] {
+				panic("boom")
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		},
+		fileproc.WithMode(fileproc.CollectAll),
+		fileproc.WithFileTimeout(10*time.Millisecond))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+	var report *fileproc.Errors
+	if !errors.As(err, &report) || len(report.Failures) != 2 {
+		t.Fatalf("expected both files to fail, got %v", err)
+	}
+}
+
+func benchmarkForEachFile(b *testing.B, mode fileproc.Mode, concurrency int) {
+	paths := makeFiles(b, 100)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := fileproc.ForEachFile(context.Background(), paths, concurrency, processWithErrors,
+			fileproc.WithMode(mode))
+		if err == nil {
+			b.Fatal("expected failures")
+		}
+	}
+}
+
+func BenchmarkForEachFileFailFast(b *testing.B)      { benchmarkForEachFile(b, fileproc.FailFast, 8) }
+func BenchmarkForEachFileCollectAll(b *testing.B)    { benchmarkForEachFile(b, fileproc.CollectAll, 8) }
+func BenchmarkForEachFileCollectAllSeq(b *testing.B) { benchmarkForEachFile(b, fileproc.CollectAll, 1) }
@@ -0,0 +1,226 @@
+// Package fileproc runs a function over many files with bounded concurrency
+// and reports every failure in a single error.
+package fileproc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mode selects what happens after the first failure.
+type Mode int
+
+const (
+	// FailFast cancels the remaining work as soon as one file fails.
+	FailFast Mode = iota
+	// CollectAll processes every file and reports all failures at the end.
+	CollectAll
+)
+
+// Progress describes one finished file.
+type Progress struct {
+	Path    string
+	Err     error
+	Elapsed time.Duration
+	Done    int // files finished so far, including this one
+	Failed  int
+	Total   int
+}
+
+// Option configures ForEachFile.
+type Option func(*config)
+
+type config struct {
+	mode     Mode
+	timeout  time.Duration
+	progress func(Progress)
+}
+
+// WithMode chooses between FailFast (the default) and CollectAll.
+func WithMode(m Mode) Option { return func(c *config) { c.mode = m } }
+
+// WithFileTimeout gives each call of fn its own deadline.
+func WithFileTimeout(d time.Duration) Option { return func(c *config) { c.timeout = d } }
+
+// WithProgress registers a callback invoked after every file. Calls are
+// serialised, so the callback needs no locking of its own.
+func WithProgress(fn func(Progress)) Option { return func(c *config) { c.progress = fn } }
+
+// FileError is the failure of a single file.
+type FileError struct {
+	Path string
+	Err  error
+}
+
+func (e *FileError) Error() string { return e.Path + ": " + e.Err.Error() }
+func (e *FileError) Unwrap() error { return e.Err }
+
+// Errors lists every failing file in input order. It unwraps to the
+// individual *FileError values, so errors.Is and errors.As see all causes.
+type Errors struct {
+	Total     int
+	Skipped   int // files not processed because of cancellation
+	Failures  []*FileError
+	Cancelled error // the caller's context error, if it stopped the run
+}
+
+func (e *Errors) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d of %d files failed", len(e.Failures), e.Total)
+	if e.Skipped > 0 {
+		fmt.Fprintf(&sb, " (%d skipped)", e.Skipped)
+	}
+	if e.Cancelled != nil {
+		fmt.Fprintf(&sb, ": %v", e.Cancelled)
+	}
+	for _, f := range e.Failures {
+		sb.WriteString("\n  ")
+		sb.WriteString(f.Error())
+	}
+	return sb.String()
+}
+
+func (e *Errors) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failures)+1)
+	for _, f := range e.Failures {
+		errs = append(errs, f)
+	}
+	if e.Cancelled != nil {
+		errs = append(errs, e.Cancelled)
+	}
+	return errs
+}
+
+// ForEachFile calls fn for every path using at most concurrency goroutines
+// (1 if concurrency < 1). It returns nil when every call succeeded, or an
+// *Errors describing each failure.
+//
+// fn must honour its context: cancellation and per-file timeouts are
+// delivered through it. A panic in fn is reported as that file's error.
+//
+// In FailFast mode the first failure cancels the context of the calls still
+// running and no new files are started; files interrupted by that
+// cancellation count as skipped, not as failures. If ctx itself is cancelled, files
+// that were interrupted report its error and the report's Cancelled is set
+// when any file was skipped.
+func ForEachFile(ctx context.Context, paths []string, concurrency int, fn func(ctx context.Context, path string) error, opts ...Option) error {
+	var cfg config
+	for _, o := range opts {
+		o(&cfg)
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if concurrency > len(paths) {
+		concurrency = len(paths)
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		results  = make([]error, len(paths))
+		started  = make([]bool, len(paths))
+		done     int
+		failed   int
+		stopping bool // set once FailFast has cancelled runCtx
+	)
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				// a job handed over just before the cancellation would only
+				// see a dead context
+				if runCtx.Err() != nil {
+					continue
+				}
+				mu.Lock()
+				started[i] = true
+				mu.Unlock()
+
+				start := time.Now()
+				err := runOne(runCtx, cfg.timeout, paths[i], fn)
+
+				mu.Lock()
+				if err != nil && stopping && ctx.Err() == nil && errors.Is(err, context.Canceled) {
+					// collateral of our own cancellation: the file was not
+					// processed, so it counts as skipped rather than done
+					started[i] = false
+					mu.Unlock()
+					continue
+				}
+				results[i] = err
+				done++
+				if err != nil {
+					failed++
+					if cfg.mode == FailFast && !stopping {
+						stopping = true
+						cancel()
+					}
+				}
+				if cfg.progress != nil {
+					cfg.progress(Progress{Path: paths[i], Err: err, Elapsed: time.Since(start),
+						Done: done, Failed: failed, Total: len(paths)})
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for i := range paths {
+		select {
+		case <-runCtx.Done():
+			break feed
+		case jobs <- i:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	report := &Errors{Total: len(paths)}
+	for i, err := range results {
+		if !started[i] {
+			report.Skipped++
+			continue
+		}
+		if err != nil {
+			report.Failures = append(report.Failures, &FileError{Path: paths[i], Err: err})
+		}
+	}
+	if report.Skipped > 0 {
+		report.Cancelled = ctx.Err()
+	}
+	if len(report.Failures) == 0 && report.Cancelled == nil {
+		return nil
+	}
+	return report
+}
+
+// runOne calls fn with an optional per-file deadline, turning a panic into
+// an error.
+func runOne(ctx context.Context, timeout time.Duration, path string, fn func(context.Context, string) error) (err error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return fn(ctx, path)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	"Week_2/494174/turn4modela/fileproc"
+)
+
+// countLines reads a file line by line, checking for cancellation between
+// lines so that timeouts and fail-fast stop it promptly.
+func countLines(ctx context.Context, path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	n := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, scanner.Err()
+}
+
+func run(mode fileproc.Mode, paths []string) {
+	var mu sync.Mutex
+	counts := map[string]int{}
+	err := fileproc.ForEachFile(context.Background(), paths, 2,
+		func(ctx context.Context, path string) error {
+			n, err := countLines(ctx, path)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			counts[path] = n
+			mu.Unlock()
+			return nil
+		},
+		fileproc.WithMode(mode),
+		fileproc.WithFileTimeout(2*time.Second),
+		fileproc.WithProgress(func(p fileproc.Progress) {
+			status := "ok"
+			if p.Err != nil {
+				status = "FAILED"
+			}
+			fmt.Printf("  [%d/%d] %-12s %s\n", p.Done, p.Total, p.Path, status)
+		}),
+	)
+	fmt.Println("  line counts:", counts)
+	if err != nil {
+		fmt.Println("  error:", err)
+		fmt.Println("  any file missing:", errors.Is(err, fs.ErrNotExist))
+	}
+}
+
+func main() {
+	paths := os.Args[1:]
+	if len(paths) == 0 {
+		paths = []string{"file1.txt", "missing.txt", "file2.txt", "file3.txt", "example.txt", "gone.txt"}
+	}
+	fmt.Println("collect all:")
+	run(fileproc.CollectAll, paths)
+	fmt.Println("fail fast:")
+	run(fileproc.FailFast, paths)
+}
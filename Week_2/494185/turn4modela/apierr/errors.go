@@ -0,0 +1,309 @@
+// Package apierr provides a single error type for network and API failures,
+// aggregates that work with errors.Is and errors.As, a retry classification,
+// a retrier that honours it and an RFC 9457 problem+json renderer.
+package apierr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Code identifies a category of failure independently of its message.
+type Code string
+
+const (
+	CodeBadRequest   Code = "bad_request"
+	CodeUnauthorized Code = "unauthorized"
+	CodeForbidden    Code = "forbidden"
+	CodeNotFound     Code = "not_found"
+	CodeConflict     Code = "conflict"
+	CodeRateLimited  Code = "rate_limited"
+	CodeTimeout      Code = "timeout"
+	CodeNetwork      Code = "network_error"
+	CodeUnavailable  Code = "unavailable"
+	CodeInternal     Code = "internal"
+	CodeCanceled     Code = "canceled"
+)
+
+// Class tells a caller whether trying again can help.
+type Class int
+
+const (
+	// Permanent failures will fail the same way if retried.
+	Permanent Class = iota
+	// Retryable failures are transient and may succeed on another attempt.
+	Retryable
+	// Throttled failures are retryable, but only after the server-provided
+	// delay has passed.
+	Throttled
+)
+
+func (c Class) String() string {
+	switch c {
+	case Retryable:
+		return "retryable"
+	case Throttled:
+		return "throttled"
+	}
+	return "permanent"
+}
+
+// Sentinels for errors.Is. An *Error matches a sentinel with the same Code.
+var (
+	ErrBadRequest   = &Error{Code: CodeBadRequest}
+	ErrUnauthorized = &Error{Code: CodeUnauthorized}
+	ErrForbidden    = &Error{Code: CodeForbidden}
+	ErrNotFound     = &Error{Code: CodeNotFound}
+	ErrConflict     = &Error{Code: CodeConflict}
+	ErrRateLimited  = &Error{Code: CodeRateLimited}
+	ErrTimeout      = &Error{Code: CodeTimeout}
+	ErrNetwork      = &Error{Code: CodeNetwork}
+	ErrUnavailable  = &Error{Code: CodeUnavailable}
+	ErrInternal     = &Error{Code: CodeInternal}
+)
+
+// defaults holds the HTTP status and retry class implied by each code.
+var defaults = map[Code]struct {
+	status int
+	class  Class
+}{
+	CodeBadRequest:   {http.StatusBadRequest, Permanent},
+	CodeUnauthorized: {http.StatusUnauthorized, Permanent},
+	CodeForbidden:    {http.StatusForbidden, Permanent},
+	CodeNotFound:     {http.StatusNotFound, Permanent},
+	CodeConflict:     {http.StatusConflict, Permanent},
+	CodeRateLimited:  {http.StatusTooManyRequests, Throttled},
+	CodeTimeout:      {http.StatusGatewayTimeout, Retryable},
+	CodeNetwork:      {http.StatusBadGateway, Retryable},
+	CodeUnavailable:  {http.StatusServiceUnavailable, Retryable},
+	CodeInternal:     {http.StatusInternalServerError, Permanent},
+	CodeCanceled:     {499, Permanent},
+}
+
+// Error is the common error type. Every field is optional; Error() never
+// panics on a zero value or a nil Cause.
+type Error struct {
+	Code       Code
+	Op         string // operation that failed, e.g. "GET /api/orders"
+	Message    string
+	Status     int // HTTP status; derived from Code when zero
+	Class      *Class
+	RetryAfter time.Duration // server-requested delay for throttled errors
+	Cause      error
+}
+
+// New creates an error with a code and message.
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Wrap annotates cause with a code, operation and message.
+func Wrap(cause error, code Code, op, message string) *Error {
+	return &Error{Code: code, Op: op, Message: message, Cause: cause}
+}
+
+func (e *Error) Error() string {
+	var parts []string
+	if e.Op != "" {
+		parts = append(parts, e.Op)
+	}
+	if e.Message != "" {
+		parts = append(parts, e.Message)
+	} else if e.Code != "" && e.Cause == nil {
+		parts = append(parts, string(e.Code))
+	}
+	if e.Cause != nil {
+		parts = append(parts, e.Cause.Error())
+	}
+	if len(parts) == 0 {
+		return "unknown error"
+	}
+	return strings.Join(parts, ": ")
+}
+
+func (e *Error) Unwrap() error { return e.Cause }
+
+// Is matches sentinels by code, so errors.Is(err, ErrNotFound) is true for
+// any *Error with CodeNotFound anywhere in the chain.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+	return t.Code != "" && t.Code == e.Code && t.Op == "" && t.Message == "" && t.Cause == nil
+}
+
+// HTTPStatus returns the explicit status or the default for the code.
+func (e *Error) HTTPStatus() int {
+	if e.Status != 0 {
+		return e.Status
+	}
+	if d, ok := defaults[e.Code]; ok {
+		return d.status
+	}
+	return http.StatusInternalServerError
+}
+
+// WithClass overrides the retry class implied by the code.
+func (e *Error) WithClass(c Class) *Error {
+	e.Class = &c
+	return e
+}
+
+// Multi aggregates several errors. It implements Unwrap() []error, so
+// errors.Is and errors.As look inside every member.
+type Multi struct {
+	Message string
+	Errs    []error
+}
+
+func (m *Multi) Error() string {
+	var sb strings.Builder
+	if m.Message != "" {
+		sb.WriteString(m.Message)
+	} else {
+		fmt.Fprintf(&sb, "%d errors occurred", len(m.Errs))
+	}
+	for _, err := range m.Errs {
+		sb.WriteString("\n\t* ")
+		sb.WriteString(strings.ReplaceAll(err.Error(), "\n", "\n\t  "))
+	}
+	return sb.String()
+}
+
+func (m *Multi) Unwrap() []error { return m.Errs }
+
+// Join aggregates errs, dropping nils. It returns nil when nothing is left
+// and the error itself when only one remains.
+func Join(message string, errs ...error) error {
+	var kept []error
+	for _, err := range errs {
+		if err != nil {
+			kept = append(kept, err)
+		}
+	}
+	switch len(kept) {
+	case 0:
+		return nil
+	case 1:
+		if message == "" {
+			return kept[0]
+		}
+	}
+	return &Multi{Message: message, Errs: kept}
+}
+
+// Classify decides whether err is worth retrying. Explicit classes and
+// codes on *Error win; otherwise well-known standard library errors are
+// recognised. An aggregate is permanent if any member is, throttled if any
+// member is throttled, and retryable only when every member is.
+func Classify(err error) Class {
+	if err == nil {
+		return Permanent
+	}
+	if m, ok := err.(*Multi); ok {
+		return classifyAll(m.Errs)
+	}
+	if u, ok := err.(interface{ Unwrap() []error }); ok {
+		return classifyAll(u.Unwrap())
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		if e.Class != nil {
+			return *e.Class
+		}
+		if d, ok := defaults[e.Code]; ok && e.Code != CodeNetwork {
+			return d.class
+		}
+		// network errors defer to what they wrap, if it says more
+		if e.Cause != nil {
+			if c, known := classifyStd(e.Cause); known {
+				return c
+			}
+		}
+		if d, ok := defaults[e.Code]; ok {
+			return d.class
+		}
+		return Permanent
+	}
+	c, _ := classifyStd(err)
+	return c
+}
+
+func classifyAll(errs []error) Class {
+	if len(errs) == 0 {
+		return Permanent
+	}
+	result := Retryable
+	for _, err := range errs {
+		switch Classify(err) {
+		case Permanent:
+			return Permanent
+		case Throttled:
+			result = Throttled
+		}
+	}
+	return result
+}
+
+// classifyStd recognises context, DNS and network errors.
+func classifyStd(err error) (Class, bool) {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return Permanent, true
+	case errors.Is(err, context.DeadlineExceeded):
+		return Retryable, true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		if dnsErr.IsNotFound {
+			return Permanent, true
+		}
+		return Retryable, true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return Retryable, true
+		}
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return Retryable, true
+	}
+	return Permanent, false
+}
+
+// RetryAfterOf returns the largest server-requested delay found in err.
+func RetryAfterOf(err error) time.Duration {
+	var d time.Duration
+	walk(err, func(err error) {
+		if e, ok := err.(*Error); ok && e.RetryAfter > d {
+			d = e.RetryAfter
+		}
+	})
+	return d
+}
+
+// walk visits err and everything it wraps, following both single and
+// multi-error unwrapping.
+func walk(err error, fn func(error)) {
+	if err == nil {
+		return
+	}
+	fn(err)
+	switch u := err.(type) {
+	case interface{ Unwrap() error }:
+		walk(u.Unwrap(), fn)
+	case interface{ Unwrap() []error }:
+		for _, e := range u.Unwrap() {
+			walk(e, fn)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package apierr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestClassify(t *testing.T) {
+	retryable := New(CodeUnavailable, "down")
+	throttled := &Error{Code: CodeRateLimited, RetryAfter: time.Second}
+	permanent := New(CodeNotFound, "no such order")
+	cases := []struct {
+		name string
+		err  error
+		want Class
+	}{
+		{"nil", nil, Permanent},
+		{"plain error", errors.New("boom"), Permanent},
+		{"unknown code", &Error{Code: "teapot"}, Permanent},
+		{"not found", permanent, Permanent},
+		{"rate limited", throttled, Throttled},
+		{"unavailable", retryable, Retryable},
+		{"wrapped by fmt", fmt.Errorf("loading: %w", retryable), Retryable},
+		{"explicit class wins", New(CodeInternal, "flaky").WithClass(Retryable), Retryable},
+		{"explicit class beats code", New(CodeUnavailable, "gone").WithClass(Permanent), Permanent},
+		{"canceled", context.Canceled, Permanent},
+		{"deadline", fmt.Errorf("call: %w", context.DeadlineExceeded), Retryable},
+		{"dial failure", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, Retryable},
+		{"unknown host", &net.DNSError{Name: "nope.invalid", IsNotFound: true}, Permanent},
+		{"dns timeout", &net.DNSError{Name: "slow.example", IsTimeout: true}, Retryable},
+		// network errors defer to the cause when it says more
+		{"network over unknown host", Wrap(&net.DNSError{IsNotFound: true}, CodeNetwork, "GET /", "resolve"), Permanent},
+		{"network over canceled", Wrap(context.Canceled, CodeNetwork, "GET /", ""), Permanent},
+		{"network over plain error", Wrap(errors.New("eof"), CodeNetwork, "GET /", ""), Retryable},
+		{"timeout ignores its cause", Wrap(context.Canceled, CodeTimeout, "GET /", ""), Retryable},
+		{"all retryable", Join("", retryable, context.DeadlineExceeded), Retryable},
+		{"one throttled", Join("", retryable, throttled), Throttled},
+		{"one permanent", Join("", retryable, throttled, permanent), Permanent},
+		{"stdlib join", errors.Join(retryable, permanent), Permanent},
+		{"empty aggregate", &Multi{}, Permanent},
+	}
+	for _, c := range cases {
+		if got := Classify(c.err); got != c.want {
+			t.Errorf("%s: Classify = %s, want %s", c.name, got, c.want)
+		}
+	}
+}
+
+func TestIsAndAs(t *testing.T) {
+	err := fmt.Errorf("sync: %w", Join("two failures",
+		Wrap(errors.New("refused"), CodeNetwork, "GET /a", ""),
+		&Error{Code: CodeRateLimited, Op: "GET /b", RetryAfter: 3 * time.Second},
+		&Error{Code: CodeRateLimited, Op: "GET /c", RetryAfter: 2 * time.Second},
+	))
+	if !errors.Is(err, ErrNetwork) || !errors.Is(err, ErrRateLimited) {
+		t.Error("sentinels not found inside the aggregate")
+	}
+	if errors.Is(err, ErrNotFound) {
+		t.Error("matched a code that is not there")
+	}
+	// a sentinel only matches bare sentinels, not errors with the same code
+	if errors.Is(ErrNotFound, New(CodeNotFound, "order 7")) {
+		t.Error("matched a non-sentinel target")
+	}
+	var e *Error
+	if !errors.As(err, &e) || e.Op != "GET /a" {
+		t.Errorf("As found %+v", e)
+	}
+	if d := RetryAfterOf(err); d != 3*time.Second {
+		t.Errorf("RetryAfterOf = %s, want the largest delay", d)
+	}
+}
+
+func TestJoin(t *testing.T) {
+	if Join("none", nil, nil) != nil {
+		t.Error("joining nils is not nil")
+	}
+	one := errors.New("one")
+	if Join("", nil, one) != one {
+		t.Error("a single unnamed error was wrapped")
+	}
+	if m, ok := Join("batch", one).(*Multi); !ok || len(m.Errs) != 1 {
+		t.Error("a named aggregate of one was unwrapped")
+	}
+	var zero Error
+	if zero.Error() != "unknown error" {
+		t.Errorf("zero Error() = %q", zero.Error())
+	}
+	if got := Wrap(one, CodeInternal, "PUT /x", "").Error(); got != "PUT /x: one" {
+		t.Errorf("Error() = %q", got)
+	}
+}
@@ -0,0 +1,100 @@
+package apierr
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CodeForStatus maps an HTTP status to the closest code.
+func CodeForStatus(status int) Code {
+	switch status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound, http.StatusGone:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return CodeTimeout
+	case http.StatusBadGateway:
+		return CodeNetwork
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	}
+	if status >= 500 {
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
+
+// FromResponse converts a non-2xx response into an *Error, or returns nil
+// for a successful one. A problem+json body from the server supplies the
+// message; Retry-After makes the error throttled. The body is drained but
+// not closed.
+func FromResponse(resp *http.Response) error {
+	if resp.StatusCode < 300 {
+		return nil
+	}
+	e := &Error{Code: CodeForStatus(resp.StatusCode), Status: resp.StatusCode}
+	if resp.Request != nil {
+		e.Op = resp.Request.Method + " " + resp.Request.URL.Path
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if mt, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mt == ProblemContentType {
+		var p Problem
+		if json.Unmarshal(body, &p) == nil {
+			e.Message = p.Detail
+			if e.Message == "" {
+				e.Message = p.Title
+			}
+			if p.Code != "" {
+				e.Code = p.Code
+			}
+		}
+	}
+	if e.Message == "" {
+		e.Message = http.StatusText(resp.StatusCode)
+	}
+	if ra, ok := ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+		e.RetryAfter = ra
+		e.WithClass(Throttled)
+	} else if resp.StatusCode == http.StatusInternalServerError {
+		// an unexplained 500 from a remote service is often transient
+		e.WithClass(Retryable)
+	}
+	return e
+}
+
+// ParseRetryAfter reads a Retry-After value in either delay-seconds or
+// HTTP-date form. Dates in the past yield zero.
+func ParseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	if d := t.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
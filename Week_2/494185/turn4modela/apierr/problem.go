@@ -0,0 +1,109 @@
+package apierr
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// ProblemContentType is the media type defined by RFC 9457.
+const ProblemContentType = "application/problem+json"
+
+// ProblemTypeBase prefixes the code to build the problem "type" URI.
+var ProblemTypeBase = "https://errors.example.com/"
+
+// Problem is an RFC 9457 problem details object. Code, Retryable and Errors
+// are extension members.
+type Problem struct {
+	Type      string    `json:"type"`
+	Title     string    `json:"title"`
+	Status    int       `json:"status"`
+	Detail    string    `json:"detail,omitempty"`
+	Instance  string    `json:"instance,omitempty"`
+	Code      Code      `json:"code,omitempty"`
+	Retryable bool      `json:"retryable"`
+	Errors    []Problem `json:"errors,omitempty"`
+}
+
+// ProblemOf builds the problem document for err. Errors that are not an
+// *Error or an aggregate are reported as an opaque internal error so that
+// their text does not leak to clients.
+func ProblemOf(err error) Problem {
+	if m, ok := err.(*Multi); ok {
+		p := Problem{
+			Type:      "about:blank",
+			Title:     "Multiple errors",
+			Detail:    m.Message,
+			Retryable: Classify(m) != Permanent,
+		}
+		for _, sub := range m.Errs {
+			sp := ProblemOf(sub)
+			p.Errors = append(p.Errors, sp)
+			if sp.Status > p.Status {
+				p.Status = sp.Status
+			}
+		}
+		p.Title = http.StatusText(p.Status)
+		return p
+	}
+
+	var e *Error
+	if !errors.As(err, &e) {
+		return Problem{
+			Type:   ProblemTypeBase + string(CodeInternal),
+			Title:  http.StatusText(http.StatusInternalServerError),
+			Status: http.StatusInternalServerError,
+			Code:   CodeInternal,
+		}
+	}
+	status := e.HTTPStatus()
+	p := Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    e.Message,
+		Code:      e.Code,
+		Retryable: Classify(err) != Permanent,
+	}
+	if p.Title == "" {
+		p.Title = string(e.Code)
+	}
+	if e.Code != "" {
+		p.Type = ProblemTypeBase + string(e.Code)
+	}
+	return p
+}
+
+// WriteProblem renders err as application/problem+json. The request path is
+// used as the instance and a Retry-After header is added for throttled
+// errors. Internal errors are logged with their full text.
+func WriteProblem(w http.ResponseWriter, r *http.Request, err error) {
+	p := ProblemOf(err)
+	if r != nil {
+		p.Instance = r.URL.Path
+	}
+	if p.Status >= 500 {
+		log.Printf("%s: %v", p.Instance, err)
+	}
+	if ra := RetryAfterOf(err); ra > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(ra.Seconds()))))
+	}
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(p.Status)
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		log.Printf("write problem: %v", err)
+	}
+}
+
+// Handler adapts a function that returns an error into an http.Handler that
+// reports failures with WriteProblem.
+type Handler func(w http.ResponseWriter, r *http.Request) error
+
+func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := h(w, r); err != nil {
+		WriteProblem(w, r, err)
+	}
+}
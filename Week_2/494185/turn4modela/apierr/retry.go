@@ -0,0 +1,112 @@
+package apierr
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Retrier runs an operation until it succeeds, fails permanently or runs out
+// of attempts. Delays grow exponentially from BaseDelay up to MaxDelay with
+// full jitter; throttled errors wait at least their RetryAfter instead.
+type Retrier struct {
+	MaxAttempts   int           // total attempts including the first; default 4
+	BaseDelay     time.Duration // default 100ms
+	MaxDelay      time.Duration // cap for the computed backoff; default 10s
+	MaxRetryAfter time.Duration // longest server delay honoured; default 1m
+
+	// OnRetry, if set, is called before each wait.
+	OnRetry func(attempt int, err error, wait time.Duration)
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// ExhaustedError is returned when the last allowed attempt still failed.
+type ExhaustedError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *ExhaustedError) Error() string {
+	return fmt.Sprintf("giving up after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *ExhaustedError) Unwrap() error { return e.Err }
+
+// Do calls fn until it returns nil or a non-retryable error. The context
+// bounds both the attempts and the waits between them.
+func (r *Retrier) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	attempts := r.MaxAttempts
+	if attempts <= 0 {
+		attempts = 4
+	}
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		class := Classify(err)
+		if class == Permanent {
+			return err
+		}
+		if attempt >= attempts {
+			return &ExhaustedError{Attempts: attempt, Err: err}
+		}
+
+		wait := r.backoff(attempt)
+		if class == Throttled {
+			if ra := RetryAfterOf(err); ra > 0 {
+				if limit := r.maxRetryAfter(); ra > limit {
+					// waiting that long is pointless for an interactive caller
+					return &ExhaustedError{Attempts: attempt, Err: err}
+				}
+				wait = ra
+			}
+		}
+		if r.OnRetry != nil {
+			r.OnRetry(attempt, err, wait)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return Join("", err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns a random delay in [0, min(MaxDelay, BaseDelay*2^(attempt-1))].
+func (r *Retrier) backoff(attempt int) time.Duration {
+	base, max := r.BaseDelay, r.MaxDelay
+	if base <= 0 {
+		base = 100 * time.Millisecond
+	}
+	if max <= 0 {
+		max = 10 * time.Second
+	}
+	d := base
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.rnd == nil {
+		r.rnd = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return time.Duration(r.rnd.Int63n(int64(d) + 1))
+}
+
+func (r *Retrier) maxRetryAfter() time.Duration {
+	if r.MaxRetryAfter > 0 {
+		return r.MaxRetryAfter
+	}
+	return time.Minute
+}
@@ -0,0 +1,113 @@
+package apierr
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBackoffBounds(t *testing.T) {
+	r := &Retrier{BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+	for attempt, limit := range map[int]time.Duration{
+		1:  10 * time.Millisecond,
+		2:  20 * time.Millisecond,
+		3:  40 * time.Millisecond,
+		4:  50 * time.Millisecond,
+		30: 50 * time.Millisecond,
+	} {
+		var longest time.Duration
+		for i := 0; i < 2000; i++ {
+			d := r.backoff(attempt)
+			if d < 0 || d > limit {
+				t.Fatalf("attempt %d: backoff %s outside [0, %s]", attempt, d, limit)
+			}
+			longest = max(longest, d)
+		}
+		// full jitter spreads over the whole range
+		if longest < limit*3/4 {
+			t.Errorf("attempt %d: longest of 2000 waits is %s, cap %s", attempt, longest, limit)
+		}
+	}
+}
+
+func TestRetrierDo(t *testing.T) {
+	retryable := New(CodeUnavailable, "down")
+	cases := []struct {
+		name     string
+		errs     []error // returned by successive attempts, then nil
+		attempts int
+		wantErr  error
+	}{
+		{"succeeds first time", nil, 1, nil},
+		{"recovers", []error{retryable, retryable}, 3, nil},
+		{"permanent stops at once", []error{ErrNotFound, retryable}, 1, ErrNotFound},
+		{"gives up", []error{retryable, retryable, retryable, retryable}, 3, ErrUnavailable},
+	}
+	for _, c := range cases {
+		r := &Retrier{MaxAttempts: 3, BaseDelay: time.Millisecond}
+		calls := 0
+		err := r.Do(context.Background(), func(context.Context) error {
+			calls++
+			if calls <= len(c.errs) {
+				return c.errs[calls-1]
+			}
+			return nil
+		})
+		if calls != c.attempts {
+			t.Errorf("%s: %d attempts, want %d", c.name, calls, c.attempts)
+		}
+		if c.wantErr == nil && err != nil || c.wantErr != nil && !errors.Is(err, c.wantErr) {
+			t.Errorf("%s: err = %v, want %v", c.name, err, c.wantErr)
+		}
+		var ex *ExhaustedError
+		if exhausted := errors.As(err, &ex); exhausted != (c.name == "gives up") {
+			t.Errorf("%s: exhausted = %v", c.name, exhausted)
+		}
+	}
+}
+
+func TestRetrierHonoursRetryAfter(t *testing.T) {
+	var waits []time.Duration
+	r := &Retrier{
+		BaseDelay:     time.Millisecond,
+		MaxRetryAfter: time.Second,
+		OnRetry:       func(_ int, _ error, wait time.Duration) { waits = append(waits, wait) },
+	}
+	calls := 0
+	err := r.Do(context.Background(), func(context.Context) error {
+		calls++
+		if calls == 1 {
+			return &Error{Code: CodeRateLimited, RetryAfter: 20 * time.Millisecond}
+		}
+		return nil
+	})
+	if err != nil || len(waits) != 1 || waits[0] != 20*time.Millisecond {
+		t.Errorf("err %v, waits %v; want one wait of the server's delay", err, waits)
+	}
+
+	// a delay past MaxRetryAfter is not waited out
+	calls = 0
+	err = r.Do(context.Background(), func(context.Context) error {
+		calls++
+		return &Error{Code: CodeRateLimited, RetryAfter: time.Hour}
+	})
+	var ex *ExhaustedError
+	if calls != 1 || !errors.As(err, &ex) || !errors.Is(err, ErrRateLimited) {
+		t.Errorf("after %d calls err = %v", calls, err)
+	}
+}
+
+func TestRetrierStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	r := &Retrier{MaxAttempts: 100, BaseDelay: time.Hour, MaxDelay: time.Hour}
+	start := time.Now()
+	err := r.Do(ctx, func(context.Context) error { return ErrUnavailable })
+	if time.Since(start) > time.Second {
+		t.Fatal("Do waited out the backoff after the context ended")
+	}
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, ErrUnavailable) {
+		t.Errorf("err = %v, want the last failure and the context error", err)
+	}
+}
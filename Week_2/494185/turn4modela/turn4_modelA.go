@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	"Week_2/494185/turn4modela/apierr"
+)
+
+// client fetches JSON from an upstream service, turning transport failures
+// and error responses into *apierr.Error values.
+type client struct {
+	base    string
+	http    *http.Client
+	retrier *apierr.Retrier
+}
+
+func (c *client) getJSON(ctx context.Context, path string, v any) error {
+	return c.retrier.Do(ctx, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.base+path, nil)
+		if err != nil {
+			return apierr.Wrap(err, apierr.CodeBadRequest, "GET "+path, "building request")
+		}
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return apierr.Wrap(err, apierr.CodeNetwork, "GET "+path, "")
+		}
+		defer resp.Body.Close()
+		if err := apierr.FromResponse(resp); err != nil {
+			return err
+		}
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			return apierr.Wrap(err, apierr.CodeInternal, "GET "+path, "decoding response")
+		}
+		return nil
+	})
+}
+
+// upstream simulates a flaky service: the first order request is throttled,
+// the second fails with a 500 and the third succeeds.
+func upstream() *httptest.Server {
+	var calls atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orders/42", func(w http.ResponseWriter, r *http.Request) {
+		switch calls.Add(1) {
+		case 1:
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+		case 2:
+			http.Error(w, "boom", http.StatusInternalServerError)
+		default:
+			json.NewEncoder(w).Encode(map[string]any{"id": 42, "total": 19.99})
+		}
+	})
+	mux.Handle("/orders/", apierr.Handler(func(w http.ResponseWriter, r *http.Request) error {
+		return apierr.New(apierr.CodeNotFound, "no order at "+r.URL.Path)
+	}))
+	return httptest.NewServer(mux)
+}
+
+// api is the service this program exposes; it validates several fields at
+// once and reports all of them in one problem document.
+func api(c *client) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/checkout", apierr.Handler(func(w http.ResponseWriter, r *http.Request) error {
+		q := r.URL.Query()
+		var errs []error
+		if q.Get("order") == "" {
+			errs = append(errs, apierr.New(apierr.CodeBadRequest, "order is required"))
+		}
+		if q.Get("card") == "" {
+			errs = append(errs, apierr.New(apierr.CodeBadRequest, "card is required"))
+		}
+		if err := apierr.Join("invalid checkout request", errs...); err != nil {
+			return err
+		}
+		var order map[string]any
+		if err := c.getJSON(r.Context(), "/orders/"+q.Get("order"), &order); err != nil {
+			return err
+		}
+		w.Header().Set("Content-Type", "application/json")
+		return json.NewEncoder(w).Encode(map[string]any{"status": "ok", "order": order})
+	}))
+	return mux
+}
+
+func show(url string) {
+	resp, err := http.Get(url)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	fmt.Printf("%d %s\n%s\n", resp.StatusCode, resp.Header.Get("Content-Type"), body)
+}
+
+func main() {
+	up := upstream()
+	defer up.Close()
+
+	c := &client{
+		base: up.URL,
+		http: &http.Client{Timeout: 5 * time.Second},
+		retrier: &apierr.Retrier{
+			MaxAttempts: 4,
+			BaseDelay:   50 * time.Millisecond,
+			OnRetry: func(attempt int, err error, wait time.Duration) {
+				fmt.Printf("  attempt %d failed (%s): %v; retrying in %v\n",
+					attempt, apierr.Classify(err), err, wait.Round(time.Millisecond))
+			},
+		},
+	}
+
+	fmt.Println("== classification and errors.Is/As over aggregates")
+	agg := apierr.Join("batch failed",
+		apierr.Wrap(context.DeadlineExceeded, apierr.CodeTimeout, "GET /a", ""),
+		&apierr.Error{Code: apierr.CodeRateLimited, RetryAfter: 2 * time.Second},
+	)
+	var e *apierr.Error
+	fmt.Println(agg)
+	fmt.Println("is timeout:", errors.Is(agg, apierr.ErrTimeout),
+		"| is deadline:", errors.Is(agg, context.DeadlineExceeded),
+		"| as *Error:", errors.As(agg, &e), e.Code,
+		"| class:", apierr.Classify(agg),
+		"| retry after:", apierr.RetryAfterOf(agg))
+	fmt.Println("zero value is safe:", &apierr.Error{})
+
+	srv := httptest.NewServer(api(c))
+	defer srv.Close()
+
+	fmt.Println("\n== missing fields")
+	show(srv.URL + "/checkout")
+	fmt.Println("== throttled, then 500, then success")
+	show(srv.URL + "/checkout?order=42&card=visa")
+	fmt.Println("== permanent upstream error is not retried")
+	show(srv.URL + "/checkout?order=7&card=visa")
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"Week_2/494174/turn4modela/fileproc"
+)
+
+var errOddSize = errors.New("file has an odd number of bytes")
+
+// processFile stands in for real work: it reads the file and rejects those
+// with an odd size.
+func processFile(ctx context.Context, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(100 * time.Millisecond): // simulated processing time
+	}
+	if len(data)%2 == 1 {
+		return fmt.Errorf("%d bytes: %w", len(data), errOddSize)
+	}
+	return nil
+}
+
+func main() {
+	paths := []string{"file1.txt", "file2.txt", "file3.txt", "example.txt"}
+
+	// The whole run gets the five seconds the original sample waited for;
+	// unlike a bare time.After, nothing is left blocked when it expires.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := fileproc.ForEachFile(ctx, paths, 3, processFile,
+		fileproc.WithMode(fileproc.CollectAll),
+		fileproc.WithProgress(func(p fileproc.Progress) {
+			log.Printf("%s done in %v (err=%v)", p.Path, p.Elapsed.Round(time.Millisecond), p.Err)
+		}))
+	if err == nil {
+		fmt.Println("all files processed")
+		return
+	}
+	fmt.Println(err)
+
+	var report *fileproc.Errors
+	if errors.As(err, &report) {
+		for _, f := range report.Failures {
+			if errors.Is(f, errOddSize) {
+				fmt.Println("needs padding:", f.Path)
+			}
+		}
+	}
+	os.Exit(1)
+}
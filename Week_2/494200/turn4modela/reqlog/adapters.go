@@ -0,0 +1,81 @@
+package reqlog
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// statusRecorder captures the status code and body size for net/http.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.size += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (s *statusRecorder) Unwrap() http.ResponseWriter { return s.ResponseWriter }
+
+// Handler wraps next for net/http. The route label comes from the pattern
+// matched by http.ServeMux (Go 1.22+) or, when used with router.Use on a
+// gorilla/mux router, from the matched route's path template. Wrapping a
+// whole gorilla router from outside leaves its routes unknown.
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := m.begin(w, r)
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, req.r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		m.finish(req, routeOf(req.r), rec.status, rec.size)
+	})
+}
+
+func routeOf(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+	return r.Pattern
+}
+
+// Gin returns the middleware as a gin handler. The route label is the
+// registered path, e.g. "/product/:id".
+func (m *Middleware) Gin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req := m.begin(c.Writer, c.Request)
+		c.Request = req.r
+		c.Next()
+		m.finish(req, c.FullPath(), c.Writer.Status(), max(c.Writer.Size(), 0))
+	}
+}
+
+// MetricsHandler serves the metrics gathered by g, or by the default
+// registry when g is nil.
+func MetricsHandler(g prometheus.Gatherer) http.Handler {
+	if g == nil {
+		g = prometheus.DefaultGatherer
+	}
+	return promhttp.HandlerFor(g, promhttp.HandlerOpts{})
+}
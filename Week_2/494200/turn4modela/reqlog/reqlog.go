@@ -0,0 +1,218 @@
+// Package reqlog is request logging and metrics middleware shared by
+// net/http (including gorilla/mux) and gin. Each request gets an ID and a
+// logrus entry in its context; completed requests are logged subject to
+// per-route sampling and level overrides, and counted in Prometheus under
+// their route template rather than the raw path.
+package reqlog
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	mrand "math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// RouteConfig overrides logging for one route template.
+type RouteConfig struct {
+	// SampleRate is the fraction of successful requests logged, up to 1;
+	// 0 keeps the default rate and a negative rate silences them. Responses
+	// with status >= 500 are always logged.
+	SampleRate float64
+	// Level is used for successful requests on this route; the zero value
+	// (PanicLevel) keeps the default level.
+	Level logrus.Level
+}
+
+// Config configures a Middleware. Zero values get sensible defaults.
+type Config struct {
+	Logger          *logrus.Logger
+	RequestIDHeader string  // default "X-Request-ID"
+	SampleRate      float64 // default for routes without an override; 0 means 1
+	Level           logrus.Level
+	Routes          map[string]RouteConfig // keyed by route template, e.g. "/product/:id"
+	LogHeaders      bool                   // include request headers in log lines
+	RedactHeaders   []string               // header values replaced before logging
+	Registerer      prometheus.Registerer  // default prometheus.DefaultRegisterer
+}
+
+// Middleware holds the shared configuration and metrics.
+type Middleware struct {
+	cfg      Config
+	redact   map[string]bool
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+
+	mu  sync.Mutex
+	rnd *mrand.Rand
+}
+
+// New validates cfg and registers the request metrics.
+func New(cfg Config) (*Middleware, error) {
+	if cfg.Logger == nil {
+		cfg.Logger = logrus.StandardLogger()
+	}
+	if cfg.RequestIDHeader == "" {
+		cfg.RequestIDHeader = "X-Request-ID"
+	}
+	if cfg.SampleRate <= 0 {
+		cfg.SampleRate = 1
+	}
+	if cfg.Level == 0 {
+		cfg.Level = logrus.InfoLevel
+	}
+	if cfg.Registerer == nil {
+		cfg.Registerer = prometheus.DefaultRegisterer
+	}
+	m := &Middleware{
+		cfg:    cfg,
+		redact: map[string]bool{},
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_request_count_total",
+			Help: "Total number of HTTP requests.",
+		}, []string{"method", "route", "status_code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Duration of HTTP requests.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		rnd: mrand.New(mrand.NewSource(time.Now().UnixNano())),
+	}
+	for _, h := range cfg.RedactHeaders {
+		m.redact[http.CanonicalHeaderKey(h)] = true
+	}
+	for _, c := range []prometheus.Collector{m.requests, m.duration} {
+		if err := cfg.Registerer.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+type ctxKey struct{}
+
+// FromContext returns the request's log entry, or an entry on the standard
+// logger when ctx did not pass through the middleware.
+func FromContext(ctx context.Context) *logrus.Entry {
+	if e, ok := ctx.Value(ctxKey{}).(*logrus.Entry); ok {
+		return e
+	}
+	return logrus.NewEntry(logrus.StandardLogger())
+}
+
+// RequestID returns the ID assigned to the request carrying ctx.
+func RequestID(ctx context.Context) string {
+	if id, ok := FromContext(ctx).Data["request_id"].(string); ok {
+		return id
+	}
+	return ""
+}
+
+// request is the state kept between begin and finish.
+type request struct {
+	start time.Time
+	entry *logrus.Entry
+	r     *http.Request
+}
+
+// begin assigns the request ID, echoes it in the response and attaches the
+// log entry to the request context.
+func (m *Middleware) begin(w http.ResponseWriter, r *http.Request) *request {
+	id := r.Header.Get(m.cfg.RequestIDHeader)
+	if id == "" || len(id) > 128 {
+		id = newID()
+	}
+	w.Header().Set(m.cfg.RequestIDHeader, id)
+	entry := m.cfg.Logger.WithField("request_id", id)
+	return &request{
+		start: time.Now(),
+		entry: entry,
+		r:     r.WithContext(context.WithValue(r.Context(), ctxKey{}, entry)),
+	}
+}
+
+// finish records metrics and writes the access log line. route is the
+// matched template, or "" when no route matched.
+func (m *Middleware) finish(req *request, route string, status, size int) {
+	elapsed := time.Since(req.start)
+	if route == "" {
+		// unmatched paths are unbounded; never use them as label values
+		route = "unmatched"
+	}
+	r := req.r
+	m.requests.WithLabelValues(r.Method, route, strconv.Itoa(status)).Inc()
+	m.duration.WithLabelValues(r.Method, route).Observe(elapsed.Seconds())
+
+	rate, level := m.cfg.SampleRate, m.cfg.Level
+	if rc, ok := m.cfg.Routes[route]; ok {
+		if rc.SampleRate != 0 {
+			rate = rc.SampleRate
+		}
+		if rc.Level != 0 {
+			level = rc.Level
+		}
+	}
+	switch {
+	case status >= 500:
+		level = logrus.ErrorLevel
+	case status >= 400 && level > logrus.WarnLevel:
+		level = logrus.WarnLevel
+	}
+	if level > logrus.ErrorLevel && !m.sample(rate) {
+		return
+	}
+	fields := logrus.Fields{
+		"method":      r.Method,
+		"path":        r.URL.Path,
+		"route":       route,
+		"status":      status,
+		"size":        size,
+		"latency_ms":  float64(elapsed.Microseconds()) / 1000,
+		"remote_addr": r.RemoteAddr,
+		"user_agent":  r.UserAgent(),
+	}
+	if m.cfg.LogHeaders {
+		fields["headers"] = m.headers(r.Header)
+	}
+	req.entry.WithFields(fields).Log(level, "request completed")
+}
+
+func (m *Middleware) sample(rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.rnd.Float64() < rate
+}
+
+// headers flattens h for logging, redacting configured names.
+func (m *Middleware) headers(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for k, v := range h {
+		if m.redact[k] {
+			out[k] = "[REDACTED]"
+			continue
+		}
+		out[k] = strings.Join(v, ", ")
+	}
+	return out
+}
+
+func newID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b[:])
+}
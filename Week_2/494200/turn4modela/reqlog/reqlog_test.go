@@ -0,0 +1,194 @@
+package reqlog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// newTest returns a middleware logging JSON lines into the returned buffer
+// and registering its metrics in a private registry.
+func newTest(t *testing.T, cfg Config) (*Middleware, *bytes.Buffer, *prometheus.Registry) {
+	t.Helper()
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.SetLevel(logrus.DebugLevel)
+	reg := prometheus.NewRegistry()
+	cfg.Logger, cfg.Registerer = logger, reg
+	m, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, &buf, reg
+}
+
+// lines decodes the log lines written so far and resets buf.
+func lines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var out []map[string]any
+	sc := bufio.NewScanner(buf)
+	for sc.Scan() {
+		var l map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &l); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, l)
+	}
+	buf.Reset()
+	return out
+}
+
+func get(h http.Handler, path string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRouteOverrides(t *testing.T) {
+	m, buf, _ := newTest(t, Config{
+		SampleRate: 1,
+		Routes: map[string]RouteConfig{
+			// no rate of its own: the global rate applies
+			"/quiet":  {Level: logrus.DebugLevel},
+			"/silent": {SampleRate: -1},
+			"/never":  {SampleRate: 0.0001},
+		},
+	})
+	mux := http.NewServeMux()
+	for _, p := range []string{"/quiet", "/silent", "/never", "/plain"} {
+		mux.HandleFunc(p, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Has("fail") {
+				http.Error(w, "boom", http.StatusInternalServerError)
+			}
+		})
+	}
+	h := m.Handler(mux)
+
+	cases := []struct {
+		path  string
+		level string // "" when nothing should be logged
+	}{
+		{"/quiet", "debug"},
+		{"/plain", "info"},
+		{"/silent", ""},
+		{"/silent?fail=1", "error"},
+		{"/nowhere", "warning"},
+	}
+	for _, c := range cases {
+		get(h, c.path, nil)
+		got := lines(t, buf)
+		if c.level == "" {
+			if len(got) != 0 {
+				t.Errorf("%s logged %v", c.path, got)
+			}
+			continue
+		}
+		if len(got) != 1 || got[0]["level"] != c.level {
+			t.Errorf("%s logged %v, want one %s line", c.path, got, c.level)
+		}
+	}
+
+	for i := 0; i < 100; i++ {
+		get(h, "/never", nil)
+	}
+	if got := lines(t, buf); len(got) > 5 {
+		t.Errorf("a route sampled at 0.0001 logged %d of 100 requests", len(got))
+	}
+}
+
+func TestRequestIDAndHeaders(t *testing.T) {
+	m, buf, _ := newTest(t, Config{LogHeaders: true, RedactHeaders: []string{"authorization"}})
+	var seen string
+	h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestID(r.Context())
+	}))
+
+	rec := get(h, "/", http.Header{"X-Request-Id": {"abc"}, "Authorization": {"Bearer secret"}})
+	if seen != "abc" || rec.Header().Get("X-Request-ID") != "abc" {
+		t.Errorf("incoming ID not kept: handler saw %q, response has %q", seen, rec.Header().Get("X-Request-ID"))
+	}
+	got := lines(t, buf)
+	if len(got) != 1 || got[0]["request_id"] != "abc" {
+		t.Fatalf("log lines = %v", got)
+	}
+	if hdrs := got[0]["headers"].(map[string]any); hdrs["Authorization"] != "[REDACTED]" {
+		t.Errorf("headers = %v", hdrs)
+	}
+
+	rec = get(h, "/", http.Header{"X-Request-Id": {strings.Repeat("x", 200)}})
+	if id := rec.Header().Get("X-Request-ID"); len(id) != 16 || seen != id {
+		t.Errorf("oversized ID replaced by %q, handler saw %q", id, seen)
+	}
+	if RequestID(httptest.NewRequest(http.MethodGet, "/", nil).Context()) != "" {
+		t.Error("a request outside the middleware has an ID")
+	}
+}
+
+func TestMetricsUseRouteTemplates(t *testing.T) {
+	m, _, reg := newTest(t, Config{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/items/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	h := m.Handler(mux)
+
+	gin.SetMode(gin.TestMode)
+	g := gin.New()
+	g.Use(m.Gin())
+	g.GET("/product/:id", func(c *gin.Context) { c.String(http.StatusCreated, "ok") })
+
+	for _, p := range []string{"/items/1", "/items/2", "/missing/3"} {
+		get(h, p, nil)
+	}
+	get(g, "/product/9", nil)
+
+	want := map[string]float64{
+		"GET /items/{id} 200":  2,
+		"GET unmatched 404":    1,
+		"GET /product/:id 201": 1,
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]float64{}
+	for _, mf := range families {
+		if mf.GetName() != "http_request_count_total" {
+			continue
+		}
+		for _, metric := range mf.GetMetric() {
+			l := map[string]string{}
+			for _, p := range metric.GetLabel() {
+				l[p.GetName()] = p.GetValue()
+			}
+			got[l["method"]+" "+l["route"]+" "+l["status_code"]] = metric.GetCounter().GetValue()
+		}
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("count for %s = %v, want %v (all: %v)", k, got[k], v, got)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("series = %v", got)
+	}
+
+	rec := get(MetricsHandler(reg), "/metrics", nil)
+	body, _ := io.ReadAll(rec.Body)
+	if rec.Code != http.StatusOK || !strings.Contains(string(body), `route="/items/{id}"`) {
+		t.Errorf("metrics endpoint = %d:\n%s", rec.Code, body)
+	}
+}
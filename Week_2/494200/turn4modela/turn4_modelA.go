@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"Week_2/494200/turn4modela/reqlog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	log := logrus.New()
+	log.SetLevel(logrus.DebugLevel)
+	log.SetFormatter(&logrus.JSONFormatter{})
+	log.SetOutput(os.Stdout)
+
+	// One middleware serves both routers so they share the same metrics.
+	mw, err := reqlog.New(reqlog.Config{
+		Logger:        log,
+		LogHeaders:    true,
+		RedactHeaders: []string{"Authorization", "Cookie", "X-Api-Key"},
+		Routes: map[string]reqlog.RouteConfig{
+			// high-traffic routes: log 1 in 100 successful requests at debug
+			"/product/:id":   {SampleRate: 0.01, Level: logrus.DebugLevel},
+			"/products/{id}": {SampleRate: 0.01, Level: logrus.DebugLevel},
+			"/metrics":       {SampleRate: -1},
+			"/healthz":       {SampleRate: -1},
+			"/orders":        {SampleRate: 1, Level: logrus.InfoLevel},
+			"/products":      {SampleRate: 0.5},
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// gin router on :8080
+	gin.SetMode(gin.ReleaseMode)
+	g := gin.New()
+	g.Use(gin.Recovery(), mw.Gin())
+	g.GET("/products", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "Product list"})
+	})
+	g.GET("/product/:id", func(c *gin.Context) {
+		reqlog.FromContext(c.Request.Context()).WithField("product_id", c.Param("id")).Debug("loading product")
+		c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Product with ID %s", c.Param("id"))})
+	})
+	g.GET("/metrics", gin.WrapH(reqlog.MetricsHandler(nil)))
+
+	// gorilla/mux router on :8081
+	r := mux.NewRouter()
+	r.Use(mw.Handler)
+	r.HandleFunc("/products", getProducts).Methods(http.MethodGet)
+	r.HandleFunc("/products/{id}", getProduct).Methods(http.MethodGet)
+	r.HandleFunc("/orders", createOrder).Methods(http.MethodPost)
+	r.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	r.Handle("/metrics", reqlog.MetricsHandler(nil))
+
+	go func() {
+		log.Info("mux server listening on :8081")
+		log.Fatal(http.ListenAndServe(":8081", r))
+	}()
+	log.Info("gin server listening on :8080")
+	log.Fatal(g.Run(":8080"))
+}
+
+func getProducts(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, []string{"Product 1", "Product 2"})
+}
+
+func getProduct(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	reqlog.FromContext(r.Context()).WithField("product_id", id).Debug("loading product")
+	respondWithJSON(w, http.StatusOK, map[string]string{"id": id})
+}
+
+func createOrder(w http.ResponseWriter, r *http.Request) {
+	var order map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
+		reqlog.FromContext(r.Context()).WithError(err).Warn("invalid order body")
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, map[string]any{
+		"order":      order,
+		"request_id": reqlog.RequestID(r.Context()),
+	})
+}
+
+func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(payload)
+}
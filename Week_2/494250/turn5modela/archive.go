@@ -0,0 +1,168 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/ulikunitz/xz"
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown archive format")
+	ErrCorrupt       = errors.New("archive does not match its manifest")
+)
+
+// ArchiveWriter adds named members to an archive being written.
+type ArchiveWriter interface {
+	Add(name string, size int64, modTime time.Time, r io.Reader) error
+	Close() error
+}
+
+// Archiver is one on-disk archive format. Members are read back in a single
+// sequential pass so that stream formats such as tar.xz work the same way
+// as zip.
+type Archiver interface {
+	Ext() string
+	Create(w io.Writer) (ArchiveWriter, error)
+	Walk(path string, fn func(name string, r io.Reader) error) error
+}
+
+// archiverFor returns the archiver registered for a format name.
+func archiverFor(format string) (Archiver, error) {
+	switch format {
+	case "zip":
+		return zipArchiver{}, nil
+	case "tar.xz", "xz":
+		return xzArchiver{}, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+}
+
+// zipArchiver writes deflate-compressed zip files.
+type zipArchiver struct{}
+
+func (zipArchiver) Ext() string { return ".zip" }
+
+func (zipArchiver) Create(w io.Writer) (ArchiveWriter, error) {
+	return &zipWriter{zw: zip.NewWriter(w)}, nil
+}
+
+func (zipArchiver) Walk(path string, fn func(name string, r io.Reader) error) error {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = fn(f.Name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type zipWriter struct{ zw *zip.Writer }
+
+func (z *zipWriter) Add(name string, size int64, modTime time.Time, r io.Reader) error {
+	w, err := z.zw.CreateHeader(&zip.FileHeader{
+		Name:               name,
+		Method:             zip.Deflate,
+		Modified:           modTime,
+		UncompressedSize64: uint64(size),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+func (z *zipWriter) Close() error { return z.zw.Close() }
+
+// xzArchiver writes tar streams compressed with xz.
+type xzArchiver struct{}
+
+func (xzArchiver) Ext() string { return ".tar.xz" }
+
+func (xzArchiver) Create(w io.Writer) (ArchiveWriter, error) {
+	xw, err := xz.NewWriter(w)
+	if err != nil {
+		return nil, err
+	}
+	return &xzWriter{xw: xw, tw: tar.NewWriter(xw)}, nil
+}
+
+func (xzArchiver) Walk(path string, fn func(name string, r io.Reader) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	xr, err := xz.NewReader(f)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(xr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			// read to the end of the xz stream so its checks and index
+			// are validated too
+			_, err = io.Copy(io.Discard, xr)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := fn(hdr.Name, tr); err != nil {
+			return err
+		}
+	}
+}
+
+type xzWriter struct {
+	xw *xz.Writer
+	tw *tar.Writer
+}
+
+func (x *xzWriter) Add(name string, size int64, modTime time.Time, r io.Reader) error {
+	err := x.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     0o644,
+		ModTime:  modTime,
+	})
+	if err != nil {
+		return err
+	}
+	// tar needs exactly size bytes; a file that changed since it was
+	// hashed fails here rather than producing a broken archive
+	n, err := io.Copy(x.tw, io.LimitReader(r, size))
+	if err == nil && n != size {
+		err = fmt.Errorf("%s: short read (%d of %d bytes)", name, n, size)
+	}
+	return err
+}
+
+func (x *xzWriter) Close() error {
+	if err := x.tw.Close(); err != nil {
+		x.xw.Close()
+		return err
+	}
+	return x.xw.Close()
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+var ErrInvalidSourceDir = errors.New("invalid source directory")
+
+// BackupOptions controls a single backup run.
+type BackupOptions struct {
+	Format string // "zip" or "tar.xz"
+	Full   bool   // ignore earlier backups and store everything
+}
+
+// Backup records sourceDir in the repository. Unless opts.Full is set or
+// the repository is empty, the backup is incremental against the latest
+// one: files whose size, mtime and mode are unchanged are skipped without
+// being read, changed files are hashed, and only content not already
+// stored somewhere in the chain goes into the new archive.
+func (r *Repository) Backup(sourceDir string, opts BackupOptions) (*Manifest, error) {
+	archiver, err := archiverFor(opts.Format)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(sourceDir); err != nil || !info.IsDir() {
+		return nil, ErrInvalidSourceDir
+	}
+	all, err := r.Manifests()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	m := &Manifest{
+		ID:      fmt.Sprintf("%04d-%s", len(all)+1, now.Format("20060102T150405Z")),
+		Created: now,
+		Source:  sourceDir,
+		Format:  opts.Format,
+		Files:   map[string]FileEntry{},
+		Blobs:   map[string]int64{},
+	}
+	m.Archive = m.ID + archiver.Ext()
+
+	prev := map[string]FileEntry{}
+	stored := map[string]string{} // hash → backup holding it
+	if len(all) > 0 && !opts.Full {
+		m.Parent = all[len(all)-1].ID
+		chain, err := r.Chain(m.Parent)
+		if err != nil {
+			return nil, err
+		}
+		prev = State(chain)
+		for _, c := range chain {
+			for h := range c.Blobs {
+				stored[h] = c.ID
+			}
+		}
+	}
+
+	// toStore maps a new hash to the first file seen with that content.
+	toStore := map[string]string{}
+	seen := map[string]bool{}
+	err = filepath.WalkDir(sourceDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		seen[rel] = true
+
+		old, ok := prev[rel]
+		if ok && old.Size == info.Size() && old.ModTime.Equal(info.ModTime()) && old.Mode == info.Mode() {
+			return nil
+		}
+		hash, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		e := FileEntry{Size: info.Size(), ModTime: info.ModTime(), Mode: info.Mode(), Hash: hash}
+		if id, ok := stored[hash]; ok {
+			e.Blob = id
+		} else {
+			e.Blob = m.ID
+			if _, ok := toStore[hash]; !ok {
+				toStore[hash] = path
+				m.Blobs[hash] = info.Size()
+			}
+		}
+		m.Files[rel] = e
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for p := range prev {
+		if !seen[p] {
+			m.Deleted = append(m.Deleted, p)
+		}
+	}
+	sort.Strings(m.Deleted)
+	if m.Parent != "" && len(m.Files) == 0 && len(m.Deleted) == 0 {
+		return nil, ErrNoChanges
+	}
+
+	if err := r.writeArchive(archiver, m, toStore); err != nil {
+		return nil, err
+	}
+	if err := r.save(m); err != nil {
+		os.Remove(r.archivePath(m))
+		return nil, err
+	}
+	return m, nil
+}
+
+// writeArchive stores each new blob, checking that the file still has the
+// content that was hashed.
+func (r *Repository) writeArchive(archiver Archiver, m *Manifest, toStore map[string]string) error {
+	path := r.archivePath(m)
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path + ".tmp")
+	defer f.Close()
+
+	aw, err := archiver.Create(f)
+	if err != nil {
+		return err
+	}
+	hashes := make([]string, 0, len(toStore))
+	for h := range toStore {
+		hashes = append(hashes, h)
+	}
+	sort.Strings(hashes)
+	for _, h := range hashes {
+		if err := addBlob(aw, h, m.Blobs[h], m.Created, toStore[h]); err != nil {
+			aw.Close()
+			return err
+		}
+	}
+	if err := aw.Close(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func addBlob(aw ArchiveWriter, hash string, size int64, modTime time.Time, path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	h := sha256.New()
+	if err := aw.Add(blobName(hash), size, modTime, io.TeeReader(src, h)); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != hash {
+		return fmt.Errorf("%s changed while being backed up", path)
+	}
+	return nil
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// writeTree creates files under dir with a fixed mtime so that later
+// changes are seen by the size and mtime check.
+func writeTree(t *testing.T, dir string, files map[string]string, mtime time.Time) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o640); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readTree(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := map[string]string{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		files[filepath.ToSlash(rel)] = string(data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestIncrementalChain(t *testing.T) {
+	for _, format := range []string{"zip", "tar.xz"} {
+		t.Run(format, func(t *testing.T) {
+			src, repoDir := t.TempDir(), t.TempDir()
+			repo, err := OpenRepository(repoDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			first := map[string]string{"a.txt": "alpha", "b.txt": "bravo", "sub/c.txt": "charlie", "copy.txt": "alpha"}
+			writeTree(t, src, first, epoch)
+
+			full, err := repo.Backup(src, BackupOptions{Format: format})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if full.Parent != "" || len(full.Files) != 4 || len(full.Blobs) != 3 {
+				t.Fatalf("full backup: parent %q, %d files, %d blobs", full.Parent, len(full.Files), len(full.Blobs))
+			}
+
+			// change b, delete c, add d with content that is already stored
+			writeTree(t, src, map[string]string{"b.txt": "bravo two", "d.txt": "charlie"}, epoch.Add(time.Hour))
+			if err := os.Remove(filepath.Join(src, "sub", "c.txt")); err != nil {
+				t.Fatal(err)
+			}
+			incr, err := repo.Backup(src, BackupOptions{Format: format})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if incr.Parent != full.ID {
+				t.Errorf("parent = %q, want %q", incr.Parent, full.ID)
+			}
+			if got := sortedKeys(incr.Files); !reflect.DeepEqual(got, []string{"b.txt", "d.txt"}) {
+				t.Errorf("incremental lists %v", got)
+			}
+			if !reflect.DeepEqual(incr.Deleted, []string{"sub/c.txt"}) {
+				t.Errorf("tombstones = %v", incr.Deleted)
+			}
+			if len(incr.Blobs) != 1 || incr.Files["d.txt"].Blob != full.ID {
+				t.Errorf("stored %d blobs, d.txt in %q; want only the new b.txt", len(incr.Blobs), incr.Files["d.txt"].Blob)
+			}
+			if _, err := repo.Backup(src, BackupOptions{Format: format}); !errors.Is(err, ErrNoChanges) {
+				t.Errorf("unchanged backup = %v", err)
+			}
+
+			second := map[string]string{"a.txt": "alpha", "b.txt": "bravo two", "copy.txt": "alpha", "d.txt": "charlie"}
+			for id, want := range map[string]map[string]string{full.ID: first, incr.ID: second} {
+				dest := filepath.Join(t.TempDir(), "out")
+				n, err := repo.Restore(id, dest)
+				if err != nil {
+					t.Fatalf("restore %s: %v", id, err)
+				}
+				if got := readTree(t, dest); n != len(want) || !reflect.DeepEqual(got, want) {
+					t.Errorf("restore %s = %d files %v, want %v", id, n, got, want)
+				}
+				info, err := os.Stat(filepath.Join(dest, "a.txt"))
+				if err != nil || info.Mode().Perm() != 0o640 || !info.ModTime().Equal(epoch) {
+					t.Errorf("restore %s: a.txt = %v, %v", id, info, err)
+				}
+			}
+
+			if _, err := repo.Verify(); err != nil {
+				t.Errorf("Verify = %v", err)
+			}
+
+			again, err := repo.Backup(src, BackupOptions{Format: format, Full: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again.Parent != "" || len(again.Files) != 4 || len(again.Blobs) != 3 {
+				t.Errorf("forced full backup: parent %q, %d files, %d blobs", again.Parent, len(again.Files), len(again.Blobs))
+			}
+		})
+	}
+}
+
+func TestRestoreAt(t *testing.T) {
+	src, repoDir := t.TempDir(), t.TempDir()
+	repo, _ := OpenRepository(repoDir)
+	writeTree(t, src, map[string]string{"notes.txt": "v1"}, epoch)
+	first, err := repo.Backup(src, BackupOptions{Format: "zip"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTree(t, src, map[string]string{"notes.txt": "v2"}, epoch.Add(time.Minute))
+	if _, err := repo.Backup(src, BackupOptions{Format: "zip"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{[]string{repoDir}, "v2"},
+		{[]string{"-at", first.ID, repoDir}, "v1"},
+	}
+	for _, c := range cases {
+		dest := t.TempDir()
+		if err := cmdRestore(append(c.args, dest)); err != nil {
+			t.Fatalf("restore %v: %v", c.args, err)
+		}
+		if got := readTree(t, dest)["notes.txt"]; got != c.want {
+			t.Errorf("restore %v gave %q, want %q", c.args, got, c.want)
+		}
+		if err := cmdRestore(append(c.args, dest)); !errors.Is(err, ErrDestNotEmpty) {
+			t.Errorf("restoring over a restored tree = %v", err)
+		}
+	}
+	if err := cmdRestore([]string{"-at", "0009-nope", repoDir, t.TempDir()}); !errors.Is(err, ErrBackupNotFound) {
+		t.Errorf("unknown backup = %v", err)
+	}
+}
+
+func TestVerifyReportsDamage(t *testing.T) {
+	src, repoDir := t.TempDir(), t.TempDir()
+	repo, _ := OpenRepository(repoDir)
+	writeTree(t, src, map[string]string{"a.txt": "alpha", "b.txt": "bravo"}, epoch)
+	full, err := repo.Backup(src, BackupOptions{Format: "tar.xz"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTree(t, src, map[string]string{"c.txt": "charlie"}, epoch)
+	if _, err := repo.Backup(src, BackupOptions{Format: "zip"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// replace the full backup's archive with one that lacks a blob
+	delete(full.Blobs, full.Files["b.txt"].Hash)
+	archiver, _ := archiverFor(full.Format)
+	if err := repo.writeArchive(archiver, full, map[string]string{full.Files["a.txt"].Hash: filepath.Join(src, "a.txt")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Verify(); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Verify of a damaged repository = %v", err)
+	}
+	if _, err := repo.Restore(full.ID, t.TempDir()); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("restoring from a damaged archive = %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNoBackups      = errors.New("repository has no backups")
+	ErrBackupNotFound = errors.New("backup not found")
+	ErrNoChanges      = errors.New("nothing changed since the last backup")
+)
+
+const manifestExt = ".json"
+
+// FileEntry records one file as it was at backup time.
+type FileEntry struct {
+	Size    int64       `json:"size"`
+	ModTime time.Time   `json:"mtime"`
+	Mode    fs.FileMode `json:"mode"`
+	Hash    string      `json:"sha256"`
+	Blob    string      `json:"blob"` // ID of the backup whose archive holds the content
+}
+
+// Manifest describes one backup. A full backup lists every file; an
+// incremental one lists only files added or changed since Parent, plus
+// tombstones for files deleted since then. Blobs are the contents actually
+// stored in this backup's archive: content already present earlier in the
+// chain is referenced rather than stored again.
+type Manifest struct {
+	ID      string               `json:"id"`
+	Parent  string               `json:"parent,omitempty"`
+	Created time.Time            `json:"created"`
+	Source  string               `json:"source"`
+	Format  string               `json:"format"`
+	Archive string               `json:"archive"`
+	Files   map[string]FileEntry `json:"files"`
+	Deleted []string             `json:"deleted,omitempty"`
+	Blobs   map[string]int64     `json:"blobs"` // sha256 → size
+}
+
+// blobName is the archive member holding content with the given hash.
+func blobName(hash string) string { return "blobs/" + hash }
+
+// Repository is a directory of archives, each with a JSON manifest beside
+// it. A backup exists once its manifest has been written.
+type Repository struct {
+	dir string
+}
+
+func OpenRepository(dir string) (*Repository, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Repository{dir: dir}, nil
+}
+
+// Manifests returns every backup, oldest first.
+func (r *Repository) Manifests() ([]*Manifest, error) {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return nil, err
+	}
+	var out []*Manifest
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), manifestExt) {
+			continue
+		}
+		m, err := r.load(strings.TrimSuffix(e.Name(), manifestExt))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func (r *Repository) load(id string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(r.dir, id+manifestExt))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrBackupNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("manifest %s: %w", id, err)
+	}
+	return &m, nil
+}
+
+// save writes m atomically so a crash never leaves a half-written manifest.
+func (r *Repository) save(m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(r.dir, m.ID+manifestExt)
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// Latest returns the newest backup.
+func (r *Repository) Latest() (*Manifest, error) {
+	all, err := r.Manifests()
+	if err != nil {
+		return nil, err
+	}
+	if len(all) == 0 {
+		return nil, ErrNoBackups
+	}
+	return all[len(all)-1], nil
+}
+
+// Chain returns the backups needed to rebuild id, from its full backup up
+// to id itself.
+func (r *Repository) Chain(id string) ([]*Manifest, error) {
+	var chain []*Manifest
+	seen := map[string]bool{}
+	for id != "" {
+		if seen[id] {
+			return nil, fmt.Errorf("backup %s: parent cycle", id)
+		}
+		seen[id] = true
+		m, err := r.load(id)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, m)
+		id = m.Parent
+	}
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain, nil
+}
+
+// State replays a chain into the set of files that existed at its last
+// backup.
+func State(chain []*Manifest) map[string]FileEntry {
+	state := map[string]FileEntry{}
+	for _, m := range chain {
+		for _, p := range m.Deleted {
+			delete(state, p)
+		}
+		for p, e := range m.Files {
+			state[p] = e
+		}
+	}
+	return state
+}
+
+func (r *Repository) archivePath(m *Manifest) string {
+	return filepath.Join(r.dir, m.Archive)
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var ErrDestNotEmpty = errors.New("restore destination is not empty")
+
+// Restore rebuilds the tree as it was at backup id into dest, which must
+// be empty or not exist yet. Each archive in the chain is read once.
+func (r *Repository) Restore(id, dest string) (int, error) {
+	chain, err := r.Chain(id)
+	if err != nil {
+		return 0, err
+	}
+	if entries, err := os.ReadDir(dest); err == nil && len(entries) > 0 {
+		return 0, ErrDestNotEmpty
+	}
+	state := State(chain)
+
+	// need[backup][hash] lists the paths restored from that blob
+	need := map[string]map[string][]string{}
+	for p, e := range state {
+		if !localPath(p) {
+			return 0, fmt.Errorf("refusing to restore unsafe path %q", p)
+		}
+		if need[e.Blob] == nil {
+			need[e.Blob] = map[string][]string{}
+		}
+		need[e.Blob][e.Hash] = append(need[e.Blob][e.Hash], p)
+	}
+
+	restored := 0
+	for _, m := range chain {
+		blobs := need[m.ID]
+		if len(blobs) == 0 {
+			continue
+		}
+		archiver, err := archiverFor(m.Format)
+		if err != nil {
+			return restored, err
+		}
+		err = archiver.Walk(r.archivePath(m), func(name string, rd io.Reader) error {
+			hash := strings.TrimPrefix(name, "blobs/")
+			paths, ok := blobs[hash]
+			if !ok {
+				return nil
+			}
+			delete(blobs, hash)
+			if err := restoreFile(dest, paths[0], state[paths[0]], rd); err != nil {
+				return err
+			}
+			restored++
+			// further files with the same content are copied from the first
+			for _, p := range paths[1:] {
+				if err := copyRestored(dest, paths[0], p, state[p]); err != nil {
+					return err
+				}
+				restored++
+			}
+			return nil
+		})
+		if err != nil {
+			return restored, fmt.Errorf("backup %s: %w", m.ID, err)
+		}
+		if len(blobs) > 0 {
+			return restored, fmt.Errorf("%w: backup %s is missing %d blobs", ErrCorrupt, m.ID, len(blobs))
+		}
+	}
+	return restored, nil
+}
+
+// restoreFile writes one file, verifying its content hash before it is
+// given its final name, mode and mtime.
+func restoreFile(dest, rel string, e FileEntry, rd io.Reader) error {
+	target := filepath.Join(dest, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(target+".partial", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, h), rd)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && hex.EncodeToString(h.Sum(nil)) != e.Hash {
+		err = fmt.Errorf("%w: %s has the wrong content", ErrCorrupt, rel)
+	}
+	if err != nil {
+		os.Remove(target + ".partial")
+		return err
+	}
+	if err := os.Rename(target+".partial", target); err != nil {
+		return err
+	}
+	if err := os.Chmod(target, e.Mode.Perm()); err != nil {
+		return err
+	}
+	return os.Chtimes(target, e.ModTime, e.ModTime)
+}
+
+func copyRestored(dest, from, to string, e FileEntry) error {
+	src, err := os.Open(filepath.Join(dest, filepath.FromSlash(from)))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	return restoreFile(dest, to, e, src)
+}
+
+// localPath reports whether a manifest path stays inside the destination.
+func localPath(p string) bool {
+	return p != "" && !path.IsAbs(p) && path.Clean(p) == p && p != ".." && !strings.HasPrefix(p, "../")
+}
+
+// Verify checks every archive against its manifest: each stored blob must
+// be present with the recorded size and hash, nothing unexpected may be in
+// the archive, and every file must point at a blob that exists in its
+// chain. All problems are reported together.
+func (r *Repository) Verify() ([]*Manifest, error) {
+	all, err := r.Manifests()
+	if err != nil {
+		return nil, err
+	}
+	byID := map[string]*Manifest{}
+	for _, m := range all {
+		byID[m.ID] = m
+	}
+
+	var errs []error
+	fail := func(m *Manifest, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%w: backup %s: %s", ErrCorrupt, m.ID, fmt.Sprintf(format, args...)))
+	}
+	for _, m := range all {
+		if m.Parent != "" && byID[m.Parent] == nil {
+			fail(m, "parent %s is missing", m.Parent)
+		}
+		for _, p := range sortedKeys(m.Files) {
+			e := m.Files[p]
+			if holder := byID[e.Blob]; holder == nil {
+				fail(m, "%s: blob backup %s is missing", p, e.Blob)
+			} else if _, ok := holder.Blobs[e.Hash]; !ok {
+				fail(m, "%s: backup %s does not store %.12s", p, e.Blob, e.Hash)
+			}
+		}
+
+		archiver, err := archiverFor(m.Format)
+		if err != nil {
+			fail(m, "%v", err)
+			continue
+		}
+		found := map[string]bool{}
+		err = archiver.Walk(r.archivePath(m), func(name string, rd io.Reader) error {
+			hash := strings.TrimPrefix(name, "blobs/")
+			size, ok := m.Blobs[hash]
+			if !ok || name != blobName(hash) {
+				fail(m, "unexpected member %s", name)
+				return nil
+			}
+			found[hash] = true
+			h := sha256.New()
+			n, err := io.Copy(h, rd)
+			if err != nil {
+				return err
+			}
+			if n != size {
+				fail(m, "blob %.12s is %d bytes, manifest says %d", hash, n, size)
+			} else if hex.EncodeToString(h.Sum(nil)) != hash {
+				fail(m, "blob %.12s has the wrong content", hash)
+			}
+			return nil
+		})
+		if err != nil {
+			fail(m, "reading %s: %v", m.Archive, err)
+			continue
+		}
+		for _, h := range sortedKeys(m.Blobs) {
+			if !found[h] {
+				fail(m, "blob %.12s missing from archive", h)
+			}
+		}
+	}
+	return all, errors.Join(errs...)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
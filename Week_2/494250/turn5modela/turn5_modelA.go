@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+const usage = `usage:
+  backup  [-format zip|tar.xz] [-full] <source-dir> <repo-dir>
+  list    <repo-dir>
+  restore [--at <backup-id>] <repo-dir> <dest-dir>
+  verify  <repo-dir>`
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		log.Fatal(usage)
+	}
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "backup":
+		err = cmdBackup(args)
+	case "list":
+		err = cmdList(args)
+	case "restore":
+		err = cmdRestore(args)
+	case "verify":
+		err = cmdVerify(args)
+	default:
+		log.Fatal(usage)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func cmdBackup(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	format := fs.String("format", "zip", "archive format: zip or tar.xz")
+	full := fs.Bool("full", false, "store every file instead of only changes")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		return errors.New(usage)
+	}
+	repo, err := OpenRepository(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	m, err := repo.Backup(fs.Arg(0), BackupOptions{Format: *format, Full: *full})
+	if errors.Is(err, ErrNoChanges) {
+		fmt.Println(err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	kind := "incremental"
+	if m.Parent == "" {
+		kind = "full"
+	}
+	fmt.Printf("created %s backup %s: %d files changed, %d deleted, %d new blobs in %s\n",
+		kind, m.ID, len(m.Files), len(m.Deleted), len(m.Blobs), m.Archive)
+	return nil
+}
+
+func cmdList(args []string) error {
+	if len(args) != 1 {
+		return errors.New(usage)
+	}
+	repo, err := OpenRepository(args[0])
+	if err != nil {
+		return err
+	}
+	all, err := repo.Manifests()
+	if err != nil {
+		return err
+	}
+	for _, m := range all {
+		parent := "(full)"
+		if m.Parent != "" {
+			parent = "<- " + m.Parent
+		}
+		var stored int64
+		for _, size := range m.Blobs {
+			stored += size
+		}
+		fmt.Printf("%s  %-24s %-7s %3d changed %3d deleted %8d bytes stored\n",
+			m.ID, parent, m.Format, len(m.Files), len(m.Deleted), stored)
+	}
+	return nil
+}
+
+func cmdRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	at := fs.String("at", "", "backup ID to restore (default: latest)")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		return errors.New(usage)
+	}
+	repo, err := OpenRepository(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	id := *at
+	if id == "" {
+		m, err := repo.Latest()
+		if err != nil {
+			return err
+		}
+		id = m.ID
+	}
+	n, err := repo.Restore(id, fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("restored %d files from %s into %s\n", n, id, fs.Arg(1))
+	return nil
+}
+
+func cmdVerify(args []string) error {
+	if len(args) != 1 {
+		return errors.New(usage)
+	}
+	repo, err := OpenRepository(args[0])
+	if err != nil {
+		return err
+	}
+	all, err := repo.Verify()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("verified %d backups: OK\n", len(all))
+	return nil
+}
@@ -0,0 +1,306 @@
+// Package etl is a streaming extract-transform-load framework. Input format
+// is sniffed without consuming the reader, rows are mapped onto a Go type
+// through a declarative mapping, and rows that cannot be read, mapped or
+// transformed are written to a reject file instead of stopping the run.
+package etl
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Format is an input format.
+type Format string
+
+const (
+	CSV    Format = "csv"
+	JSON   Format = "json"   // a single JSON array of objects
+	NDJSON Format = "ndjson" // one JSON object per line
+	XML    Format = "xml"    // a root element whose children are records
+)
+
+var ErrEmptyInput = errors.New("etl: empty input")
+
+// sniffSize is how much input Sniff may look at.
+const sniffSize = 8 << 10
+
+// Sniff detects the format of r by peeking at its start. The returned
+// reader still yields the whole input and must be used instead of r.
+func Sniff(r io.Reader) (Format, *bufio.Reader, error) {
+	br, ok := r.(*bufio.Reader)
+	if !ok || br.Size() < sniffSize {
+		br = bufio.NewReaderSize(r, sniffSize)
+	}
+	head, err := br.Peek(sniffSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return "", br, err
+	}
+	head = bytes.TrimPrefix(head, []byte("\xef\xbb\xbf"))
+	head = bytes.TrimLeft(head, " \t\r\n")
+	if len(head) == 0 {
+		return "", br, ErrEmptyInput
+	}
+	switch head[0] {
+	case '[':
+		return JSON, br, nil
+	case '{':
+		return NDJSON, br, nil
+	case '<':
+		return XML, br, nil
+	}
+	return CSV, br, nil
+}
+
+// Record is one raw row from a source. Pos is the line (CSV, NDJSON) or
+// element index (JSON, XML). A Record with Err set could not be read; the
+// pipeline rejects it with Raw as evidence.
+type Record struct {
+	Pos    int
+	Fields map[string]any
+	Raw    string
+	Err    error
+}
+
+// Extractor streams the records of one input. The channel is closed at the
+// end of input, when ctx is cancelled, or after a record carrying an error
+// that makes the rest of the input unreadable.
+type Extractor interface {
+	Extract(ctx context.Context) (<-chan Record, error)
+}
+
+// NewExtractor returns the extractor for format reading from r.
+func NewExtractor(format Format, r io.Reader) (Extractor, error) {
+	switch format {
+	case CSV:
+		return &csvExtractor{r: r}, nil
+	case JSON:
+		return &jsonExtractor{r: r}, nil
+	case NDJSON:
+		return &ndjsonExtractor{r: r}, nil
+	case XML:
+		return &xmlExtractor{r: r}, nil
+	}
+	return nil, fmt.Errorf("etl: unsupported format %q", format)
+}
+
+// emit sends rec unless ctx is done.
+func emit(ctx context.Context, out chan<- Record, rec Record) bool {
+	select {
+	case out <- rec:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// csvExtractor reads a header row and then one record per row. The
+// delimiter is whichever of , ; tab or | is most common in the header.
+type csvExtractor struct {
+	r io.Reader
+}
+
+func (e *csvExtractor) Extract(ctx context.Context) (<-chan Record, error) {
+	br := bufio.NewReader(e.r)
+	first, _ := br.Peek(sniffSize)
+	if i := bytes.IndexByte(first, '\n'); i >= 0 {
+		first = first[:i]
+	}
+	cr := csv.NewReader(br)
+	cr.Comma = guessDelimiter(string(first))
+	cr.FieldsPerRecord = -1 // wrong widths are rejected per row, not fatal
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("etl: reading CSV header: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff"))
+	}
+
+	out := make(chan Record)
+	go func() {
+		defer close(out)
+		for {
+			row, err := cr.Read()
+			if err == io.EOF {
+				return
+			}
+			rec := Record{Raw: strings.Join(row, string(cr.Comma))}
+			if err == nil {
+				rec.Pos, _ = cr.FieldPos(0)
+			}
+			var perr *csv.ParseError
+			switch {
+			case errors.As(err, &perr):
+				rec.Pos, rec.Err = perr.Line, err
+			case err != nil:
+				rec.Err = err
+				emit(ctx, out, rec)
+				return
+			case len(row) != len(header):
+				rec.Err = fmt.Errorf("row has %d fields, header has %d", len(row), len(header))
+			default:
+				rec.Fields = make(map[string]any, len(row))
+				for i, v := range row {
+					rec.Fields[header[i]] = v
+				}
+			}
+			if !emit(ctx, out, rec) {
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func guessDelimiter(line string) rune {
+	best, bestN := ',', 0
+	for _, d := range []rune{',', ';', '\t', '|'} {
+		if n := strings.Count(line, string(d)); n > bestN {
+			best, bestN = d, n
+		}
+	}
+	return best
+}
+
+// jsonExtractor streams the elements of a top-level array one at a time.
+type jsonExtractor struct {
+	r io.Reader
+}
+
+func (e *jsonExtractor) Extract(ctx context.Context) (<-chan Record, error) {
+	dec := json.NewDecoder(e.r)
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return nil, fmt.Errorf("etl: JSON input is not an array")
+	}
+	out := make(chan Record)
+	go func() {
+		defer close(out)
+		for i := 1; dec.More(); i++ {
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				// the stream position is lost; nothing after this is usable
+				emit(ctx, out, Record{Pos: i, Err: fmt.Errorf("malformed JSON, rest of input skipped: %w", err)})
+				return
+			}
+			if !emit(ctx, out, decodeObject(i, raw)) {
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// ndjsonExtractor reads one object per line; blank lines are skipped.
+type ndjsonExtractor struct {
+	r io.Reader
+}
+
+func (e *ndjsonExtractor) Extract(ctx context.Context) (<-chan Record, error) {
+	out := make(chan Record)
+	go func() {
+		defer close(out)
+		sc := bufio.NewScanner(e.r)
+		sc.Buffer(make([]byte, 64<<10), 16<<20)
+		for line := 1; sc.Scan(); line++ {
+			b := bytes.TrimSpace(sc.Bytes())
+			if len(b) == 0 {
+				continue
+			}
+			if !emit(ctx, out, decodeObject(line, append([]byte(nil), b...))) {
+				return
+			}
+		}
+		if err := sc.Err(); err != nil {
+			emit(ctx, out, Record{Err: err})
+		}
+	}()
+	return out, nil
+}
+
+func decodeObject(pos int, raw []byte) Record {
+	rec := Record{Pos: pos, Raw: string(raw)}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber() // keep integers exact until the mapping coerces them
+	if err := dec.Decode(&rec.Fields); err != nil {
+		rec.Fields, rec.Err = nil, fmt.Errorf("not a JSON object: %w", err)
+	}
+	return rec
+}
+
+// xmlExtractor treats each child of the root element as a record whose
+// attributes and child elements are its fields.
+type xmlExtractor struct {
+	r io.Reader
+}
+
+type xmlRecord struct {
+	Attrs  []xml.Attr `xml:",any,attr"`
+	Fields []struct {
+		XMLName xml.Name
+		Value   string `xml:",chardata"`
+	} `xml:",any"`
+}
+
+func (e *xmlExtractor) Extract(ctx context.Context) (<-chan Record, error) {
+	dec := xml.NewDecoder(e.r)
+	// find the root element
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("etl: XML input has no root element: %w", err)
+		}
+		if _, ok := tok.(xml.StartElement); ok {
+			break
+		}
+	}
+	out := make(chan Record)
+	go func() {
+		defer close(out)
+		for i := 1; ; {
+			tok, err := dec.Token()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				line, _ := dec.InputPos()
+				emit(ctx, out, Record{Pos: line, Err: fmt.Errorf("malformed XML, rest of input skipped: %w", err)})
+				return
+			}
+			start, ok := tok.(xml.StartElement)
+			if !ok {
+				continue
+			}
+			var x xmlRecord
+			if err := dec.DecodeElement(&x, &start); err != nil {
+				emit(ctx, out, Record{Pos: i, Err: fmt.Errorf("malformed XML, rest of input skipped: %w", err)})
+				return
+			}
+			rec := Record{Pos: i, Fields: map[string]any{}}
+			for _, a := range x.Attrs {
+				rec.Fields[a.Name.Local] = a.Value
+			}
+			var raw []string
+			for _, f := range x.Fields {
+				v := strings.TrimSpace(f.Value)
+				rec.Fields[f.XMLName.Local] = v
+				raw = append(raw, f.XMLName.Local+"="+v)
+			}
+			rec.Raw = "<" + start.Name.Local + "> " + strings.Join(raw, " ")
+			if !emit(ctx, out, rec) {
+				return
+			}
+			i++
+		}
+	}()
+	return out, nil
+}
@@ -0,0 +1,53 @@
+package etl
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSniff(t *testing.T) {
+	long := "id,name\n" + strings.Repeat("1,padding\n", sniffSize/5)
+	cases := []struct {
+		in   string
+		want Format
+		err  error
+	}{
+		{`[{"a": 1}]`, JSON, nil},
+		{"\xef\xbb\xbf \r\n\t[]", JSON, nil},
+		{`{"a": 1}` + "\n" + `{"a": 2}`, NDJSON, nil},
+		{`<?xml version="1.0"?><rows/>`, XML, nil},
+		{"  <rows></rows>", XML, nil},
+		{"sku;qty\nA1;3\n", CSV, nil},
+		{long, CSV, nil},
+		{"", "", ErrEmptyInput},
+		{"\xef\xbb\xbf \n\n", "", ErrEmptyInput},
+	}
+	for _, c := range cases {
+		got, br, err := Sniff(strings.NewReader(c.in))
+		if got != c.want || !errors.Is(err, c.err) {
+			t.Errorf("Sniff(%.20q) = %q, %v; want %q, %v", c.in, got, err, c.want, c.err)
+			continue
+		}
+		// sniffing consumes nothing
+		if rest, _ := io.ReadAll(br); string(rest) != c.in {
+			t.Errorf("Sniff(%.20q) reader yields %d of %d bytes", c.in, len(rest), len(c.in))
+		}
+	}
+}
+
+func TestGuessDelimiter(t *testing.T) {
+	for line, want := range map[string]rune{
+		"a,b,c":         ',',
+		"a;b;c":         ';',
+		"a\tb\tc":       '\t',
+		"a|b|c":         '|',
+		"name;note,x;y": ';',
+		"single":        ',',
+	} {
+		if got := guessDelimiter(line); got != want {
+			t.Errorf("guessDelimiter(%q) = %q, want %q", line, got, want)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package etl
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldMapping maps one source column onto one field of the target type.
+// Source names match case-insensitively; the first of From and Aliases
+// present in a record wins.
+type FieldMapping struct {
+	From     string   `json:"from"`
+	Aliases  []string `json:"aliases,omitempty"`
+	To       string   `json:"to"`                 // Go field name
+	Required bool     `json:"required,omitempty"` // reject rows where it is missing or empty
+	Default  string   `json:"default,omitempty"`  // used when missing or empty
+	Layout   string   `json:"layout,omitempty"`   // time.Parse layout for time.Time fields
+}
+
+// Mapping is a declarative description of how source rows become values.
+// It is usually loaded from JSON so new inputs need no code changes.
+type Mapping struct {
+	Fields []FieldMapping `json:"fields"`
+}
+
+// LoadMapping reads a Mapping from a JSON file.
+func LoadMapping(path string) (Mapping, error) {
+	var m Mapping
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("etl: mapping %s: %w", path, err)
+	}
+	return m, nil
+}
+
+// Mapper applies a Mapping to records, producing values of type T.
+type Mapper[T any] struct {
+	fields []compiledField
+}
+
+type compiledField struct {
+	FieldMapping
+	names []string // lower-cased From and Aliases
+	index []int
+	typ   reflect.Type
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// NewMapper checks m against the fields of T, which must be a struct.
+func NewMapper[T any](m Mapping) (*Mapper[T], error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("etl: mapping target %s is not a struct", t)
+	}
+	mp := &Mapper[T]{}
+	for _, f := range m.Fields {
+		sf, ok := t.FieldByName(f.To)
+		if !ok || !sf.IsExported() {
+			return nil, fmt.Errorf("etl: %s has no exported field %q", t, f.To)
+		}
+		if !coercible(sf.Type) {
+			return nil, fmt.Errorf("etl: field %s has unsupported type %s", f.To, sf.Type)
+		}
+		cf := compiledField{FieldMapping: f, index: sf.Index, typ: sf.Type}
+		for _, n := range append([]string{f.From}, f.Aliases...) {
+			cf.names = append(cf.names, strings.ToLower(n))
+		}
+		mp.fields = append(mp.fields, cf)
+	}
+	return mp, nil
+}
+
+func coercible(t reflect.Type) bool {
+	if t == timeType {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// Map builds a T from the record's fields. The error names the first field
+// that was missing or could not be coerced.
+func (mp *Mapper[T]) Map(fields map[string]any) (T, error) {
+	var out T
+	lower := make(map[string]any, len(fields))
+	for k, v := range fields {
+		lower[strings.ToLower(k)] = v
+	}
+	rv := reflect.ValueOf(&out).Elem()
+	for _, f := range mp.fields {
+		var v any
+		for _, n := range f.names {
+			if x, ok := lower[n]; ok {
+				v = x
+				break
+			}
+		}
+		if isEmpty(v) {
+			switch {
+			case f.Default != "":
+				v = f.Default
+			case f.Required:
+				return out, fmt.Errorf("%s: required value missing", f.From)
+			default:
+				continue
+			}
+		}
+		if err := coerce(rv.FieldByIndex(f.index), v, f.Layout); err != nil {
+			return out, fmt.Errorf("%s: %w", f.From, err)
+		}
+	}
+	return out, nil
+}
+
+func isEmpty(v any) bool {
+	if v == nil {
+		return true
+	}
+	s, ok := v.(string)
+	return ok && strings.TrimSpace(s) == ""
+}
+
+// coerce stores v, which comes from CSV, JSON or XML, into dst.
+func coerce(dst reflect.Value, v any, layout string) error {
+	if dst.Type() == timeType {
+		t, err := toTime(v, layout)
+		if err == nil {
+			dst.Set(reflect.ValueOf(t))
+		}
+		return err
+	}
+	switch dst.Kind() {
+	case reflect.String:
+		dst.SetString(toString(v))
+	case reflect.Bool:
+		b, err := toBool(v)
+		if err != nil {
+			return err
+		}
+		dst.SetBool(b)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(strings.TrimSpace(toString(v)), dst.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not a number", toString(v))
+		}
+		dst.SetFloat(f)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := toInt(v)
+		if err != nil {
+			return err
+		}
+		if dst.OverflowInt(n) {
+			return fmt.Errorf("%d overflows %s", n, dst.Type())
+		}
+		dst.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := toInt(v)
+		if err != nil {
+			return err
+		}
+		if n < 0 || dst.OverflowUint(uint64(n)) {
+			return fmt.Errorf("%d out of range for %s", n, dst.Type())
+		}
+		dst.SetUint(uint64(n))
+	}
+	return nil
+}
+
+func toString(v any) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case json.Number:
+		return x.String()
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// toInt accepts integers and integral decimals such as "4.0", but not "4.5".
+func toInt(v any) (int64, error) {
+	s := strings.TrimSpace(toString(v))
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f != math.Trunc(f) || math.Abs(f) > 1<<53 {
+		return 0, fmt.Errorf("%q is not an integer", s)
+	}
+	return int64(f), nil
+}
+
+func toBool(v any) (bool, error) {
+	if b, ok := v.(bool); ok {
+		return b, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(toString(v))) {
+	case "1", "t", "true", "y", "yes", "on":
+		return true, nil
+	case "0", "f", "false", "n", "no", "off":
+		return false, nil
+	}
+	return false, fmt.Errorf("%q is not a boolean", toString(v))
+}
+
+// toTime parses strings with layout (RFC 3339 by default) and treats
+// numbers as Unix seconds.
+func toTime(v any, layout string) (time.Time, error) {
+	switch v.(type) {
+	case json.Number, float64:
+		n, err := toInt(v)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(n, 0).UTC(), nil
+	}
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	s := strings.TrimSpace(toString(v))
+	t, err := time.Parse(layout, s)
+	if err != nil {
+		return t, fmt.Errorf("%q does not match layout %q", s, layout)
+	}
+	return t, nil
+}
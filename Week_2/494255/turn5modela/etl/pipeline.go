@@ -0,0 +1,179 @@
+package etl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Reject is a row that did not make it into the sinks.
+type Reject struct {
+	Source string    `json:"source"`
+	Pos    int       `json:"pos"`
+	Stage  string    `json:"stage"` // extract, map or transform
+	Reason string    `json:"reason"`
+	Raw    string    `json:"raw,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// RejectWriter appends rejects to w as NDJSON. It is safe for concurrent
+// use, so several pipelines may share one reject file.
+type RejectWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func NewRejectWriter(w io.Writer) *RejectWriter {
+	return &RejectWriter{enc: json.NewEncoder(w)}
+}
+
+func (rw *RejectWriter) Write(r Reject) error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	return rw.enc.Encode(r)
+}
+
+// Stats summarises one run.
+type Stats struct {
+	Format   Format
+	Read     int
+	Loaded   int
+	Dropped  int
+	Rejected int
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("%s: read %d, loaded %d, dropped %d, rejected %d",
+		s.Format, s.Read, s.Loaded, s.Dropped, s.Rejected)
+}
+
+// Pipeline streams records from an input through a mapper and transformer
+// into every sink. Extraction, transformation and loading run concurrently,
+// connected by channels, so memory use does not grow with the input.
+type Pipeline[T any] struct {
+	Mapper      *Mapper[T]
+	Transformer Transformer[T] // optional; see Chain
+	Sinks       []Sink[T]
+	Rejects     *RejectWriter // optional; rejects are always counted
+	BatchSize   int           // values per sink write; default 100
+}
+
+// Run processes r, naming it source in rejects. Bad rows never stop the
+// run; it fails only if the input cannot be read at all, a sink fails, or
+// ctx is cancelled.
+func (p *Pipeline[T]) Run(ctx context.Context, source string, r io.Reader) (Stats, error) {
+	var st Stats
+	format, br, err := Sniff(r)
+	if err != nil {
+		return st, err
+	}
+	st.Format = format
+	ex, err := NewExtractor(format, br)
+	if err != nil {
+		return st, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	records, err := ex.Extract(ctx)
+	if err != nil {
+		return st, err
+	}
+
+	values := make(chan T, p.batchSize())
+	var rejectErr error
+	go func() {
+		defer close(values)
+		for rec := range records {
+			st.Read++
+			v, stage, err := p.process(rec)
+			if errors.Is(err, ErrDrop) {
+				st.Dropped++
+				continue
+			}
+			if err != nil {
+				st.Rejected++
+				if p.Rejects != nil && rejectErr == nil {
+					rejectErr = p.Rejects.Write(Reject{Source: source, Pos: rec.Pos, Stage: stage,
+						Reason: err.Error(), Raw: rec.Raw, Time: time.Now().UTC()})
+				}
+				continue
+			}
+			select {
+			case values <- v:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	loaded, loadErr := p.load(ctx, values)
+	if loadErr != nil {
+		cancel()
+	}
+	for range values {
+		// let the transform goroutine finish so st is safe to read
+	}
+	st.Loaded = loaded
+	if loadErr != nil {
+		return st, loadErr
+	}
+	if rejectErr != nil {
+		return st, fmt.Errorf("etl: writing rejects: %w", rejectErr)
+	}
+	return st, ctx.Err()
+}
+
+func (p *Pipeline[T]) process(rec Record) (T, string, error) {
+	var zero T
+	if rec.Err != nil {
+		return zero, "extract", rec.Err
+	}
+	v, err := p.Mapper.Map(rec.Fields)
+	if err != nil {
+		return zero, "map", err
+	}
+	if p.Transformer != nil {
+		if v, err = p.Transformer.Transform(v); err != nil {
+			return zero, "transform", err
+		}
+	}
+	return v, "", nil
+}
+
+func (p *Pipeline[T]) batchSize() int {
+	if p.BatchSize > 0 {
+		return p.BatchSize
+	}
+	return 100
+}
+
+func (p *Pipeline[T]) load(ctx context.Context, values <-chan T) (int, error) {
+	loaded := 0
+	batch := make([]T, 0, p.batchSize())
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		for _, s := range p.Sinks {
+			if err := s.Write(ctx, batch); err != nil {
+				return err
+			}
+		}
+		loaded += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+	for v := range values {
+		batch = append(batch, v)
+		if len(batch) == cap(batch) {
+			if err := flush(); err != nil {
+				return loaded, err
+			}
+		}
+	}
+	return loaded, flush()
+}
@@ -0,0 +1,169 @@
+package etl
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type item struct {
+	SKU   string
+	Qty   int
+	Price float64
+}
+
+var itemMapping = Mapping{Fields: []FieldMapping{
+	{From: "sku", To: "SKU", Required: true},
+	{From: "qty", Aliases: []string{"quantity"}, To: "Qty"},
+	{From: "price", To: "Price", Default: "0"},
+}}
+
+func newItemPipeline(t *testing.T, rejects *bytes.Buffer) (*Pipeline[item], *KVStore[item]) {
+	t.Helper()
+	mapper, err := NewMapper[item](itemMapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kv := NewKVStore(func(v item) string { return v.SKU })
+	return &Pipeline[item]{
+		Mapper: mapper,
+		Transformer: Chain(
+			Filter(func(v item) bool { return v.Qty != 0 }),
+			Validate(func(v item) error {
+				if v.Price < 0 {
+					return errors.New("negative price")
+				}
+				return nil
+			}),
+		),
+		Sinks:     []Sink[item]{kv},
+		Rejects:   NewRejectWriter(rejects),
+		BatchSize: 2,
+	}, kv
+}
+
+func TestPipelineRejects(t *testing.T) {
+	cases := []struct {
+		name    string
+		in      string
+		format  Format
+		loaded  []string
+		dropped int
+		rejects []string // "pos stage"
+	}{
+		{
+			name:   "csv",
+			format: CSV,
+			in:     "sku;qty;price\nA1;3;9.5\nB2;x;1\nC3;2\nD4;0;1\nE5;1;-2\nF6;4\n",
+			loaded: []string{"A1"}, dropped: 1,
+			rejects: []string{"3 map", "4 extract", "6 transform", "7 extract"},
+		},
+		{
+			name:    "ndjson",
+			format:  NDJSON,
+			in:      `{"sku":"A1","qty":3,"price":9.5}` + "\n\n" + `{"sku":"B2","qty":"x"}` + "\n{bad\n" + `{"SKU":"C3","Quantity":"2"}` + "\n",
+			loaded:  []string{"A1", "C3"},
+			rejects: []string{"3 map", "4 extract"},
+		},
+		{
+			name:    "json",
+			format:  JSON,
+			in:      `[{"sku":"A1","quantity":3,"price":9.5},{"sku":"B2","qty":4.5},{"qty":1},{"sku":"C3","qty":2.0}]`,
+			loaded:  []string{"A1", "C3"},
+			rejects: []string{"2 map", "3 map"},
+		},
+		{
+			name:    "truncated json",
+			format:  JSON,
+			in:      `[{"sku":"A1","qty":3},{"sku":`,
+			loaded:  []string{"A1"},
+			rejects: []string{"2 extract"},
+		},
+		{
+			name:    "xml",
+			format:  XML,
+			in:      `<items><item sku="A1"><qty>3</qty><price>9.5</price></item><item sku="B2"><qty>x</qty></item><item><qty>1</qty></item></items>`,
+			loaded:  []string{"A1"},
+			rejects: []string{"2 map", "3 map"},
+		},
+	}
+	for _, c := range cases {
+		var rejects bytes.Buffer
+		p, kv := newItemPipeline(t, &rejects)
+		st, err := p.Run(context.Background(), c.name+".in", strings.NewReader(c.in))
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if st.Format != c.format {
+			t.Errorf("%s: sniffed %s", c.name, st.Format)
+		}
+		if got := kv.Keys(); strings.Join(got, ",") != strings.Join(c.loaded, ",") {
+			t.Errorf("%s: loaded %v, want %v", c.name, got, c.loaded)
+		}
+		want := Stats{Format: c.format, Read: len(c.loaded) + c.dropped + len(c.rejects),
+			Loaded: len(c.loaded), Dropped: c.dropped, Rejected: len(c.rejects)}
+		if st != want {
+			t.Errorf("%s: stats %s, want %s", c.name, st, want)
+		}
+
+		var got []string
+		sc := bufio.NewScanner(&rejects)
+		for sc.Scan() {
+			var r Reject
+			if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+				t.Fatal(err)
+			}
+			if r.Source != c.name+".in" || r.Reason == "" || r.Time.IsZero() {
+				t.Errorf("%s: incomplete reject %+v", c.name, r)
+			}
+			got = append(got, fmt.Sprintf("%d %s", r.Pos, r.Stage))
+		}
+		if strings.Join(got, ",") != strings.Join(c.rejects, ",") {
+			t.Errorf("%s: rejects %v, want %v", c.name, got, c.rejects)
+		}
+	}
+}
+
+func TestRejectKeepsRawRow(t *testing.T) {
+	var rejects bytes.Buffer
+	p, _ := newItemPipeline(t, &rejects)
+	if _, err := p.Run(context.Background(), "in.csv", strings.NewReader("sku|qty\nA1|many\n")); err != nil {
+		t.Fatal(err)
+	}
+	var r Reject
+	if err := json.Unmarshal(rejects.Bytes(), &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Raw != "A1|many" || !strings.Contains(r.Reason, `"many" is not an integer`) {
+		t.Errorf("reject = %+v", r)
+	}
+}
+
+type failingSink struct{ calls int }
+
+func (s *failingSink) Write(context.Context, []item) error {
+	s.calls++
+	return errors.New("disk full")
+}
+
+func (s *failingSink) Close() error { return nil }
+
+func TestPipelineStopsOnSinkError(t *testing.T) {
+	var rejects bytes.Buffer
+	p, _ := newItemPipeline(t, &rejects)
+	sink := &failingSink{}
+	p.Sinks = []Sink[item]{sink}
+	in := "sku,qty\n" + strings.Repeat("A,1\n", 1000)
+	if _, err := p.Run(context.Background(), "in.csv", strings.NewReader(in)); err == nil || sink.calls != 1 {
+		t.Errorf("Run = %v after %d writes", err, sink.calls)
+	}
+	if _, err := p.Run(context.Background(), "empty", strings.NewReader(" \n")); !errors.Is(err, ErrEmptyInput) {
+		t.Errorf("empty input = %v", err)
+	}
+}
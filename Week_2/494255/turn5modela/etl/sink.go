@@ -0,0 +1,214 @@
+package etl
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sink receives mapped values in batches.
+type Sink[T any] interface {
+	Write(ctx context.Context, batch []T) error
+	Close() error
+}
+
+// NDJSONSink writes one JSON object per line.
+type NDJSONSink[T any] struct {
+	w   *bufio.Writer
+	c   io.Closer
+	enc *json.Encoder
+}
+
+// NewNDJSONSink writes to w, closing it on Close if it is an io.Closer.
+func NewNDJSONSink[T any](w io.Writer) *NDJSONSink[T] {
+	bw := bufio.NewWriter(w)
+	s := &NDJSONSink[T]{w: bw, enc: json.NewEncoder(bw)}
+	s.c, _ = w.(io.Closer)
+	return s
+}
+
+func (s *NDJSONSink[T]) Write(_ context.Context, batch []T) error {
+	for _, v := range batch {
+		if err := s.enc.Encode(v); err != nil {
+			return err
+		}
+	}
+	return s.w.Flush()
+}
+
+func (s *NDJSONSink[T]) Close() error {
+	err := s.w.Flush()
+	if s.c != nil {
+		if cerr := s.c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// KVStore is an in-memory key-value sink, standing in for Redis where a
+// server is not available. Later values replace earlier ones with the same
+// key. It is safe for concurrent use.
+type KVStore[T any] struct {
+	mu   sync.RWMutex
+	key  func(T) string
+	data map[string]T
+}
+
+func NewKVStore[T any](key func(T) string) *KVStore[T] {
+	return &KVStore[T]{key: key, data: map[string]T{}}
+}
+
+func (s *KVStore[T]) Write(_ context.Context, batch []T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, v := range batch {
+		s.data[s.key(v)] = v
+	}
+	return nil
+}
+
+func (s *KVStore[T]) Close() error { return nil }
+
+func (s *KVStore[T]) Get(key string) (T, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.data[key]
+	return v, ok
+}
+
+func (s *KVStore[T]) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.data)
+}
+
+// Keys returns the stored keys in sorted order.
+func (s *KVStore[T]) Keys() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]string, 0, len(s.data))
+	for k := range s.data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// SQLiteSink upserts values into a table whose columns are derived from
+// the exported fields of T: the `db` tag names a column ("-" skips the
+// field), otherwise the lower-cased field name is used. Each batch is
+// written in one transaction.
+type SQLiteSink[T any] struct {
+	db     *sql.DB
+	insert string
+	index  [][]int
+}
+
+var identRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// NewSQLiteSink creates table if needed, with key as its primary key.
+func NewSQLiteSink[T any](db *sql.DB, table, key string) (*SQLiteSink[T], error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("etl: %s is not a struct", t)
+	}
+	if !identRE.MatchString(table) {
+		return nil, fmt.Errorf("etl: invalid table name %q", table)
+	}
+	s := &SQLiteSink[T]{db: db}
+	var cols, defs []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := f.Tag.Get("db")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		if !identRE.MatchString(name) {
+			return nil, fmt.Errorf("etl: invalid column name %q", name)
+		}
+		def := name + " " + sqliteType(f.Type)
+		if name == key {
+			def += " PRIMARY KEY"
+		}
+		cols = append(cols, name)
+		defs = append(defs, def)
+		s.index = append(s.index, f.Index)
+	}
+	if !contains(cols, key) {
+		return nil, fmt.Errorf("etl: key column %q not found in %s", key, t)
+	}
+	ddl := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", table, strings.Join(defs, ", "))
+	if _, err := db.Exec(ddl); err != nil {
+		return nil, err
+	}
+	s.insert = fmt.Sprintf("INSERT OR REPLACE INTO %s (%s) VALUES (?%s)",
+		table, strings.Join(cols, ", "), strings.Repeat(", ?", len(cols)-1))
+	return s, nil
+}
+
+func sqliteType(t reflect.Type) string {
+	if t == timeType {
+		return "TIMESTAMP"
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "INTEGER"
+	case reflect.Float32, reflect.Float64:
+		return "REAL"
+	}
+	return "TEXT"
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *SQLiteSink[T]) Write(ctx context.Context, batch []T) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, s.insert)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	args := make([]any, len(s.index))
+	for _, v := range batch {
+		rv := reflect.ValueOf(v)
+		for i, idx := range s.index {
+			f := rv.FieldByIndex(idx).Interface()
+			if t, ok := f.(time.Time); ok {
+				f = t.UTC().Format(time.RFC3339Nano)
+			}
+			args[i] = f
+		}
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Close does not close the database, which belongs to the caller.
+func (s *SQLiteSink[T]) Close() error { return nil }
@@ -0,0 +1,45 @@
+package etl
+
+import "errors"
+
+// ErrDrop tells the pipeline to discard a value without rejecting it.
+var ErrDrop = errors.New("etl: drop")
+
+// Transformer changes or validates one value. Returning ErrDrop filters the
+// value out; any other error rejects it with that error as the reason.
+type Transformer[T any] interface {
+	Transform(T) (T, error)
+}
+
+// TransformFunc adapts a function to Transformer.
+type TransformFunc[T any] func(T) (T, error)
+
+func (f TransformFunc[T]) Transform(v T) (T, error) { return f(v) }
+
+// Filter keeps the values for which keep returns true.
+func Filter[T any](keep func(T) bool) Transformer[T] {
+	return TransformFunc[T](func(v T) (T, error) {
+		if !keep(v) {
+			return v, ErrDrop
+		}
+		return v, nil
+	})
+}
+
+// Validate rejects values for which check returns an error.
+func Validate[T any](check func(T) error) Transformer[T] {
+	return TransformFunc[T](func(v T) (T, error) { return v, check(v) })
+}
+
+// Chain runs transformers in order, stopping at the first error.
+func Chain[T any](ts ...Transformer[T]) Transformer[T] {
+	return TransformFunc[T](func(v T) (T, error) {
+		var err error
+		for _, t := range ts {
+			if v, err = t.Transform(v); err != nil {
+				return v, err
+			}
+		}
+		return v, nil
+	})
+}
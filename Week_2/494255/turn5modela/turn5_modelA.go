@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"Week_2/494255/turn5modela/etl"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type Movie struct {
+	ID     int     `json:"id" db:"id"`
+	Title  string  `json:"title" db:"title"`
+	Genre  string  `json:"genre" db:"genre"`
+	Rating float64 `json:"rating" db:"rating"`
+}
+
+// defaultMapping accepts the column names used by the sample files as well
+// as a few common variants.
+const defaultMapping = `{
+  "fields": [
+    {"from": "id", "aliases": ["movie_id"], "to": "ID", "required": true},
+    {"from": "title", "aliases": ["name"], "to": "Title", "required": true},
+    {"from": "genre", "to": "Genre", "default": "Unknown"},
+    {"from": "rating", "aliases": ["score"], "to": "Rating"}
+  ]
+}`
+
+// Inline samples in the formats not covered by the files on disk. Each has
+// a few rows that should end up in the reject file.
+var samples = map[string]string{
+	"sample.ndjson": `{"id": 101, "title": "Heat", "genre": "Crime", "rating": 4.6}
+{"id": 102, "title": "Alien", "score": "4.4"}
+{"id": "x", "title": "Bad id"}
+{"id": 104, "title": "Broken"
+{"movie_id": 105, "name": "Vertigo", "genre": "Thriller", "rating": 7.5}
+`,
+	"sample.xml": `<?xml version="1.0"?>
+<movies>
+  <movie id="201"><title>Arrival</title><genre>Sci-Fi</genre><rating>4.3</rating></movie>
+  <movie id="202"><title></title><genre>Drama</genre><rating>3.9</rating></movie>
+  <movie id="203"><title>Up</title><rating>2.5</rating></movie>
+</movies>
+`,
+	"sample.csv": "id;title;genre;rating\n301;Ran;War;4.8\n302;Solaris;Sci-Fi\n303;Ikiru;Drama;four\n",
+}
+
+func main() {
+	dbPath := flag.String("db", ":memory:", "SQLite database for the movies table")
+	outPath := flag.String("out", "movies.ndjson", "NDJSON output file")
+	rejectPath := flag.String("rejects", "rejects.ndjson", "file receiving rejected rows")
+	mappingPath := flag.String("mapping", "", "JSON mapping file (default: built-in)")
+	flag.Parse()
+
+	var mapping etl.Mapping
+	var err error
+	if *mappingPath != "" {
+		mapping, err = etl.LoadMapping(*mappingPath)
+	} else {
+		err = json.Unmarshal([]byte(defaultMapping), &mapping)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	mapper, err := etl.NewMapper[Movie](mapping)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite3", *dbPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1) // every connection to :memory: is a separate database
+	sqlSink, err := etl.NewSQLiteSink[Movie](db, "movies", "id")
+	if err != nil {
+		log.Fatal(err)
+	}
+	out, err := os.Create(*outPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	jsonSink := etl.NewNDJSONSink[Movie](out)
+	defer jsonSink.Close()
+	kv := etl.NewKVStore(func(m Movie) string { return "movie:" + strconv.Itoa(m.ID) })
+
+	rejectFile, err := os.Create(*rejectPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rejectFile.Close()
+
+	p := &etl.Pipeline[Movie]{
+		Mapper: mapper,
+		Transformer: etl.Chain[Movie](
+			etl.Validate(func(m Movie) error {
+				if m.Rating < 0 || m.Rating > 5 {
+					return fmt.Errorf("rating %.1f outside 0-5", m.Rating)
+				}
+				return nil
+			}),
+			etl.Filter(func(m Movie) bool { return m.Rating >= 3 }),
+			etl.TransformFunc[Movie](func(m Movie) (Movie, error) {
+				m.Title = strings.TrimSpace(m.Title)
+				m.Genre = strings.ToLower(m.Genre)
+				return m, nil
+			}),
+		),
+		Sinks:     []etl.Sink[Movie]{sqlSink, jsonSink, kv},
+		Rejects:   etl.NewRejectWriter(rejectFile),
+		BatchSize: 50,
+	}
+
+	inputs := flag.Args()
+	if len(inputs) == 0 {
+		inputs = []string{"movies.csv", "movies.json", "sample.ndjson", "sample.xml", "sample.csv"}
+	}
+	ctx := context.Background()
+	for _, name := range inputs {
+		var r io.Reader
+		if s, ok := samples[name]; ok {
+			r = strings.NewReader(s)
+		} else {
+			f, err := os.Open(name)
+			if err != nil {
+				log.Printf("%s: %v", name, err)
+				continue
+			}
+			defer f.Close()
+			r = f
+		}
+		st, err := p.Run(ctx, name, r)
+		if errors.Is(err, etl.ErrEmptyInput) {
+			log.Printf("%s: empty, skipped", name)
+			continue
+		}
+		if err != nil {
+			log.Fatalf("%s: %v", name, err)
+		}
+		fmt.Printf("%-14s %v\n", name, st)
+	}
+
+	var count int
+	var avg float64
+	if err := db.QueryRow("SELECT COUNT(*), COALESCE(AVG(rating), 0) FROM movies").Scan(&count, &avg); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("\nsqlite: %d movies, average rating %.2f\n", count, avg)
+	fmt.Printf("kv store: %d keys, e.g. %v\n", kv.Len(), kv.Keys()[:3])
+	if m, ok := kv.Get("movie:101"); ok {
+		fmt.Printf("movie:101 = %+v\n", m)
+	}
+	fmt.Printf("ndjson written to %s, rejects to %s\n", *outPath, *rejectPath)
+}
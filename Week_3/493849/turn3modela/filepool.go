@@ -0,0 +1,351 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrPoolClosed = errors.New("file pool is closed")
+	ErrNotLeased  = errors.New("file is not leased from this pool")
+)
+
+// Key identifies interchangeable handles: same path, flags and permissions.
+type Key struct {
+	Path string
+	Flag int
+	Perm os.FileMode
+}
+
+func (k Key) String() string { return fmt.Sprintf("%s (flag %#x)", k.Path, k.Flag) }
+
+// Stats is a snapshot of pool activity.
+type Stats struct {
+	Open      int           // handles currently open, leased or idle
+	Idle      int           // open handles waiting to be reused
+	Leased    int           // handles currently held by callers
+	Hits      int64         // Gets served by an idle handle
+	Misses    int64         // Gets that opened a new handle
+	Evictions int64         // idle handles closed to make room for another key
+	Waits     int64         // Gets that had to wait for capacity
+	WaitTime  time.Duration // total time spent waiting
+	Timeouts  int64         // waits that ended with the context's error
+}
+
+// LeakError is returned by Close when handles are still leased.
+type LeakError struct {
+	Leases []Lease
+}
+
+// Lease describes a handle held by a caller.
+type Lease struct {
+	Key   Key
+	Since time.Time
+	Stack string // where Get was called, if stack tracking is on
+}
+
+func (e *LeakError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "file pool closed with %d leased handles", len(e.Leases))
+	for _, l := range e.Leases {
+		fmt.Fprintf(&sb, "\n  %s, leased %v ago", l.Key, time.Since(l.Since).Round(time.Millisecond))
+		if l.Stack != "" {
+			sb.WriteString("\n    " + strings.ReplaceAll(strings.TrimSpace(l.Stack), "\n", "\n    "))
+		}
+	}
+	return sb.String()
+}
+
+type idleFile struct {
+	key Key
+	f   *os.File
+}
+
+// FilePool bounds the number of open files. Handles are keyed by path and
+// open mode; a returned handle is kept open and handed to the next Get for
+// the same key. When the pool is full, Get first closes the least recently
+// used idle handle of another key, and otherwise blocks until a handle is
+// returned or its context is done.
+type FilePool struct {
+	max         int
+	trackStacks bool
+	openFile    func(name string, flag int, perm os.FileMode) (*os.File, error)
+
+	mu      sync.Mutex
+	open    int
+	lru     *list.List              // of *idleFile, most recently used at the front
+	idle    map[Key][]*list.Element // per key, most recently used last
+	leased  map[*os.File]*Lease     // handles held by callers
+	waiters []chan struct{}         // FIFO of blocked Gets
+	closed  bool
+	stats   Stats
+}
+
+// NewFilePool returns a pool allowing at most max open files. With
+// trackStacks, each lease records its caller's stack for leak reports.
+func NewFilePool(max int, trackStacks bool) *FilePool {
+	if max < 1 {
+		max = 1
+	}
+	return &FilePool{
+		max:         max,
+		trackStacks: trackStacks,
+		openFile:    os.OpenFile,
+		lru:         list.New(),
+		idle:        map[Key][]*list.Element{},
+		leased:      map[*os.File]*Lease{},
+	}
+}
+
+// Open leases a read-only handle for path.
+func (p *FilePool) Open(ctx context.Context, path string) (*os.File, error) {
+	return p.Get(ctx, path, os.O_RDONLY, 0)
+}
+
+// Get leases a handle for path opened with flag and perm, positioned at the
+// start of the file. A reused O_TRUNC handle is truncated again, so it
+// behaves like a fresh open. The handle must be given back with Put, or with
+// Discard if it should not be reused. Get blocks while the pool is at
+// capacity and every open handle is leased.
+func (p *FilePool) Get(ctx context.Context, path string, flag int, perm os.FileMode) (*os.File, error) {
+	key := Key{Path: path, Flag: flag, Perm: perm}
+	var waitStart time.Time
+	p.mu.Lock()
+	for {
+		if p.closed {
+			p.mu.Unlock()
+			return nil, ErrPoolClosed
+		}
+		if f := p.takeIdle(key); f != nil {
+			if err := rewind(f, key.Flag); err != nil {
+				// not reusable after all; drop it and try again
+				f.Close()
+				p.open--
+				continue
+			}
+			p.stats.Hits++
+			p.lease(f, key)
+			p.endWait(waitStart)
+			p.mu.Unlock()
+			return f, nil
+		}
+		if p.open >= p.max && p.lru.Len() > 0 {
+			p.evictOldest()
+		}
+		if p.open < p.max {
+			p.open++ // reserve the slot while opening without the lock
+			p.endWait(waitStart)
+			p.mu.Unlock()
+			f, err := p.openFile(path, flag, perm)
+			p.mu.Lock()
+			if err != nil {
+				p.open--
+				p.wakeOne()
+				p.mu.Unlock()
+				return nil, err
+			}
+			p.stats.Misses++
+			if p.closed {
+				f.Close()
+				p.open--
+				p.mu.Unlock()
+				return nil, ErrPoolClosed
+			}
+			p.lease(f, key)
+			p.mu.Unlock()
+			return f, nil
+		}
+
+		// at capacity with everything leased
+		if waitStart.IsZero() {
+			waitStart = time.Now()
+			p.stats.Waits++
+		}
+		ch := make(chan struct{}, 1)
+		p.waiters = append(p.waiters, ch)
+		p.mu.Unlock()
+		select {
+		case <-ch:
+			p.mu.Lock()
+		case <-ctx.Done():
+			p.mu.Lock()
+			if !p.removeWaiter(ch) {
+				// we were woken as well; pass the wakeup on
+				p.wakeOne()
+			}
+			p.stats.Timeouts++
+			p.endWait(waitStart)
+			p.mu.Unlock()
+			return nil, fmt.Errorf("waiting for %s: %w", key, ctx.Err())
+		}
+	}
+}
+
+// Put returns a leased handle for reuse.
+func (p *FilePool) Put(f *os.File) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	l, ok := p.leased[f]
+	if !ok {
+		return ErrNotLeased
+	}
+	delete(p.leased, f)
+	if p.closed {
+		p.open--
+		return f.Close()
+	}
+	e := p.lru.PushFront(&idleFile{key: l.Key, f: f})
+	p.idle[l.Key] = append(p.idle[l.Key], e)
+	p.wakeOne()
+	return nil
+}
+
+// Discard closes a leased handle instead of returning it, e.g. after an
+// I/O error.
+func (p *FilePool) Discard(f *os.File) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.leased[f]; !ok {
+		return ErrNotLeased
+	}
+	delete(p.leased, f)
+	p.open--
+	p.wakeOne()
+	return f.Close()
+}
+
+// Close closes every idle handle and fails pending and future Gets.
+// Handles still leased are closed when they are Put back; Close reports
+// them in a *LeakError.
+func (p *FilePool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	var errs []error
+	for e := p.lru.Front(); e != nil; e = e.Next() {
+		if err := e.Value.(*idleFile).f.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		p.open--
+	}
+	p.lru.Init()
+	p.idle = map[Key][]*list.Element{}
+	for _, ch := range p.waiters {
+		ch <- struct{}{}
+	}
+	p.waiters = nil
+
+	if len(p.leased) > 0 {
+		leak := &LeakError{}
+		for _, l := range p.leased {
+			leak.Leases = append(leak.Leases, *l)
+		}
+		sort.Slice(leak.Leases, func(i, j int) bool { return leak.Leases[i].Since.Before(leak.Leases[j].Since) })
+		errs = append(errs, leak)
+	}
+	return errors.Join(errs...)
+}
+
+// Stats returns a snapshot of the pool's counters.
+func (p *FilePool) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := p.stats
+	s.Open = p.open
+	s.Idle = p.lru.Len()
+	s.Leased = len(p.leased)
+	return s
+}
+
+// takeIdle removes and returns the least recently used idle handle for
+// key, so that the handles of a busy key take turns.
+func (p *FilePool) takeIdle(key Key) *os.File {
+	elems := p.idle[key]
+	if len(elems) == 0 {
+		return nil
+	}
+	e := elems[0]
+	p.setIdle(key, elems[1:])
+	return p.lru.Remove(e).(*idleFile).f
+}
+
+// rewind prepares an idle handle for its next lease.
+func rewind(f *os.File, flag int) error {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if flag&os.O_TRUNC != 0 {
+		return f.Truncate(0)
+	}
+	return nil
+}
+
+// evictOldest closes the least recently used idle handle.
+func (p *FilePool) evictOldest() {
+	e := p.lru.Back()
+	it := p.lru.Remove(e).(*idleFile)
+	elems := p.idle[it.key]
+	for i, x := range elems {
+		if x == e {
+			p.setIdle(it.key, append(elems[:i], elems[i+1:]...))
+			break
+		}
+	}
+	it.f.Close()
+	p.open--
+	p.stats.Evictions++
+}
+
+func (p *FilePool) setIdle(key Key, elems []*list.Element) {
+	if len(elems) == 0 {
+		delete(p.idle, key)
+	} else {
+		p.idle[key] = elems
+	}
+}
+
+func (p *FilePool) lease(f *os.File, key Key) {
+	l := &Lease{Key: key, Since: time.Now()}
+	if p.trackStacks {
+		buf := make([]byte, 4096)
+		l.Stack = string(buf[:runtime.Stack(buf, false)])
+	}
+	p.leased[f] = l
+}
+
+func (p *FilePool) wakeOne() {
+	if len(p.waiters) == 0 {
+		return
+	}
+	ch := p.waiters[0]
+	p.waiters = p.waiters[1:]
+	ch <- struct{}{}
+}
+
+func (p *FilePool) removeWaiter(ch chan struct{}) bool {
+	for i, w := range p.waiters {
+		if w == ch {
+			p.waiters = append(p.waiters[:i], p.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (p *FilePool) endWait(start time.Time) {
+	if !start.IsZero() {
+		p.stats.WaitTime += time.Since(start)
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func tempFiles(t *testing.T, names ...string) []string {
+	t.Helper()
+	dir := t.TempDir()
+	var paths []string
+	for _, n := range names {
+		p := filepath.Join(dir, n)
+		if err := os.WriteFile(p, []byte("contents of "+n), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+func TestReuseSameKey(t *testing.T) {
+	paths := tempFiles(t, "a.txt")
+	pool := NewFilePool(2, false)
+	defer pool.Close()
+	ctx := context.Background()
+
+	f1, err := pool.Open(ctx, paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.ReadAll(f1)
+	if err := pool.Put(f1); err != nil {
+		t.Fatal(err)
+	}
+	f2, err := pool.Open(ctx, paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f1 != f2 {
+		t.Error("expected the idle handle to be reused")
+	}
+	data, _ := io.ReadAll(f2)
+	if string(data) != "contents of a.txt" {
+		t.Errorf("reused handle was not rewound, read %q", data)
+	}
+	// a different mode is a different key
+	f3, err := pool.Get(ctx, paths[0], os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f3 == f2 {
+		t.Error("handles with different flags must not be shared")
+	}
+	pool.Put(f2)
+	pool.Put(f3)
+	if s := pool.Stats(); s.Hits != 1 || s.Misses != 2 || s.Idle != 2 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestReusedTruncatingHandle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.txt")
+	pool := NewFilePool(2, false)
+	defer pool.Close()
+	ctx := context.Background()
+	const flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+
+	var first *os.File
+	for _, content := range []string{"a much longer first version", "short"} {
+		f, err := pool.Get(ctx, path, flag, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first == nil {
+			first = f
+		} else if f != first {
+			t.Fatal("expected the idle handle to be reused")
+		}
+		if _, err := f.WriteString(content); err != nil {
+			t.Fatal(err)
+		}
+		pool.Put(f)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "short" {
+		t.Errorf("file holds %q after writing through a reused O_TRUNC handle", data)
+	}
+}
+
+func TestReusesOldestIdleHandle(t *testing.T) {
+	paths := tempFiles(t, "a.txt")
+	pool := NewFilePool(2, false)
+	defer pool.Close()
+	ctx := context.Background()
+
+	f1, _ := pool.Open(ctx, paths[0])
+	f2, _ := pool.Open(ctx, paths[0])
+	pool.Put(f1)
+	pool.Put(f2)
+	for _, want := range []*os.File{f1, f2} {
+		f, err := pool.Open(ctx, paths[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f != want {
+			t.Error("idle handles not reused oldest first")
+		}
+		defer pool.Put(f)
+	}
+}
+
+func TestEvictsLeastRecentlyUsedIdle(t *testing.T) {
+	paths := tempFiles(t, "a.txt", "b.txt", "c.txt")
+	pool := NewFilePool(2, false)
+	defer pool.Close()
+	ctx := context.Background()
+
+	a, _ := pool.Open(ctx, paths[0])
+	b, _ := pool.Open(ctx, paths[1])
+	pool.Put(a) // a is now the least recently used
+	pool.Put(b)
+
+	c, err := pool.Open(ctx, paths[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Put(c)
+	if _, err := a.Stat(); err == nil {
+		t.Error("expected a.txt's handle to be evicted and closed")
+	}
+	b2, err := pool.Open(ctx, paths[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b2 != b {
+		t.Error("expected b.txt's idle handle to survive")
+	}
+	pool.Put(b2)
+	if s := pool.Stats(); s.Evictions != 1 || s.Open != 2 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestBlocksUntilPutOrDeadline(t *testing.T) {
+	paths := tempFiles(t, "a.txt", "b.txt")
+	pool := NewFilePool(1, false)
+	defer pool.Close()
+
+	a, err := pool.Open(context.Background(), paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := pool.Open(ctx, paths[1]); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+
+	got := make(chan *os.File)
+	go func() {
+		f, err := pool.Open(context.Background(), paths[1])
+		if err != nil {
+			t.Error(err)
+		}
+		got <- f
+	}()
+	time.Sleep(10 * time.Millisecond)
+	select {
+	case <-got:
+		t.Fatal("Open should block while the only handle is leased")
+	default:
+	}
+	pool.Put(a)
+	b := <-got
+	pool.Put(b)
+
+	s := pool.Stats()
+	if s.Waits != 2 || s.Timeouts != 1 || s.WaitTime <= 0 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestPutErrorsAndLeakDetection(t *testing.T) {
+	paths := tempFiles(t, "a.txt", "b.txt")
+	pool := NewFilePool(4, true)
+	ctx := context.Background()
+
+	a, _ := pool.Open(ctx, paths[0])
+	if err := pool.Put(a); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Put(a); !errors.Is(err, ErrNotLeased) {
+		t.Errorf("double Put: got %v", err)
+	}
+	stranger, _ := os.Open(paths[0])
+	defer stranger.Close()
+	if err := pool.Put(stranger); !errors.Is(err, ErrNotLeased) {
+		t.Errorf("foreign Put: got %v", err)
+	}
+
+	leaked, _ := pool.Open(ctx, paths[1])
+	err := pool.Close()
+	var leak *LeakError
+	if !errors.As(err, &leak) || len(leak.Leases) != 1 || leak.Leases[0].Key.Path != paths[1] {
+		t.Fatalf("expected one leaked lease, got %v", err)
+	}
+	if leak.Leases[0].Stack == "" {
+		t.Error("expected the lease to record a stack")
+	}
+	if _, err := pool.Open(ctx, paths[0]); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("Open after Close: got %v", err)
+	}
+	// returning a leaked handle after Close closes it
+	if err := pool.Put(leaked); err != nil {
+		t.Fatal(err)
+	}
+	if s := pool.Stats(); s.Open != 0 {
+		t.Errorf("expected no open handles, got %+v", s)
+	}
+}
+
+func TestCloseWakesWaiters(t *testing.T) {
+	paths := tempFiles(t, "a.txt")
+	pool := NewFilePool(1, false)
+	a, _ := pool.Open(context.Background(), paths[0])
+
+	errc := make(chan error)
+	go func() {
+		_, err := pool.Get(context.Background(), paths[0], os.O_RDWR, 0)
+		errc <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	pool.Close()
+	if err := <-errc; !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("expected ErrPoolClosed, got %v", err)
+	}
+	pool.Put(a)
+}
+
+func TestConcurrentUse(t *testing.T) {
+	paths := tempFiles(t, "a.txt", "b.txt", "c.txt", "d.txt", "e.txt")
+	pool := NewFilePool(3, false)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			p := paths[i%len(paths)]
+			f, err := pool.Open(ctx, p)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			data, err := io.ReadAll(f)
+			if err != nil || string(data) != "contents of "+filepath.Base(p) {
+				t.Errorf("%s: read %q, %v", p, data, err)
+			}
+			if i%7 == 0 {
+				pool.Discard(f)
+			} else {
+				pool.Put(f)
+			}
+			if s := pool.Stats(); s.Open > 3 {
+				t.Errorf("pool exceeded its capacity: %+v", s)
+			}
+		}(i)
+	}
+	wg.Wait()
+	s := pool.Stats()
+	if s.Leased != 0 || s.Hits+s.Misses != 200 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+	if err := pool.Close(); err != nil {
+		t.Error(err)
+	}
+}
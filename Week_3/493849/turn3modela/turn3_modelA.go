@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+func main() {
+	pool := NewFilePool(10, true)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	sizes := map[int]int{}
+	for i := 0; i < 1000; i++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			path := "example.txt"
+			if idx%3 == 0 {
+				path = "test.txt"
+			}
+			f, err := pool.Open(ctx, path)
+			if err != nil {
+				log.Printf("worker %d: %v", idx, err)
+				return
+			}
+			data, err := io.ReadAll(f)
+			if err != nil {
+				pool.Discard(f)
+				log.Printf("worker %d: %v", idx, err)
+				return
+			}
+			pool.Put(f)
+
+			mu.Lock()
+			sizes[len(data)]++
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+
+	s := pool.Stats()
+	fmt.Printf("reads by size: %v\n", sizes)
+	fmt.Printf("open=%d idle=%d leased=%d hits=%d misses=%d evictions=%d waits=%d wait=%v timeouts=%d\n",
+		s.Open, s.Idle, s.Leased, s.Hits, s.Misses, s.Evictions, s.Waits, s.WaitTime.Round(time.Millisecond), s.Timeouts)
+
+	// a handle that is never returned shows up when the pool is closed
+	if _, err := pool.Open(context.Background(), "test2.txt"); err != nil {
+		log.Fatal(err)
+	}
+	if err := pool.Close(); err != nil {
+		fmt.Println(err)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"strings"
+)
+
+const base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// feistelBits is the size of the obfuscated ID space: 2^40 codes of at most
+// seven characters.
+const feistelBits = 40
+
+var ErrSequenceExhausted = errors.New("short code sequence exhausted")
+
+// Codec turns sequence numbers into short codes. Distinct numbers always
+// give distinct codes, so generated codes never collide with each other.
+// With a key, numbers are first shuffled by a keyed Feistel permutation so
+// consecutive links do not get guessable consecutive codes.
+type Codec struct {
+	key []byte
+}
+
+// NewCodec returns a codec; an empty key disables obfuscation.
+func NewCodec(key string) *Codec {
+	return &Codec{key: []byte(key)}
+}
+
+func (c *Codec) Encode(seq uint64) (string, error) {
+	if len(c.key) == 0 {
+		return base62(seq), nil
+	}
+	if seq >= 1<<feistelBits {
+		return "", ErrSequenceExhausted
+	}
+	return base62(c.permute(seq)), nil
+}
+
+// permute is a balanced four-round Feistel network over feistelBits bits.
+// Every round is invertible, so the whole is a bijection on the space.
+func (c *Codec) permute(v uint64) uint64 {
+	const half = feistelBits / 2
+	const mask = 1<<half - 1
+	l, r := v>>half, v&mask
+	for round := byte(0); round < 4; round++ {
+		l, r = r, l^(c.round(round, r)&mask)
+	}
+	return l<<half | r
+}
+
+func (c *Codec) round(n byte, v uint64) uint64 {
+	var buf [9]byte
+	buf[0] = n
+	binary.BigEndian.PutUint64(buf[1:], v)
+	h := sha256.New()
+	h.Write(c.key)
+	h.Write(buf[:])
+	return binary.BigEndian.Uint64(h.Sum(nil))
+}
+
+func base62(n uint64) string {
+	if n == 0 {
+		return "0"
+	}
+	var b [11]byte
+	i := len(b)
+	for n > 0 {
+		i--
+		b[i] = base62Alphabet[n%62]
+		n /= 62
+	}
+	return string(b[i:])
+}
+
+// reserved are paths an alias may not take because they are, or may become,
+// routes of the service itself.
+var reserved = map[string]bool{
+	"shorten": true, "stats": true, "links": true, "users": true, "me": true,
+	"api": true, "admin": true, "login": true, "logout": true, "static": true,
+	"health": true, "healthz": true, "metrics": true, "favicon.ico": true, "robots.txt": true,
+}
+
+var (
+	ErrAliasInvalid  = errors.New("alias must be 3-32 letters, digits, '-' or '_'")
+	ErrAliasReserved = errors.New("alias is a reserved word")
+)
+
+func validateAlias(alias string) error {
+	if len(alias) < 3 || len(alias) > 32 {
+		return ErrAliasInvalid
+	}
+	for _, r := range alias {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return ErrAliasInvalid
+		}
+	}
+	if reserved[strings.ToLower(alias)] {
+		return ErrAliasReserved
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCodecIsInjective(t *testing.T) {
+	for _, key := range []string{"", "secret"} {
+		c := NewCodec(key)
+		seen := map[string]uint64{}
+		for seq := uint64(0); seq < 100000; seq++ {
+			code, err := c.Encode(seq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if prev, dup := seen[code]; dup {
+				t.Fatalf("key %q: %d and %d both encode to %q", key, prev, seq, code)
+			}
+			seen[code] = seq
+			if len(code) > 7 {
+				t.Fatalf("key %q: code %q for %d is longer than seven characters", key, code, seq)
+			}
+		}
+	}
+}
+
+func TestCodecEncoding(t *testing.T) {
+	plain := NewCodec("")
+	for seq, want := range map[uint64]string{0: "0", 61: "z", 62: "10", 3843: "zz"} {
+		if got, _ := plain.Encode(seq); got != want {
+			t.Errorf("Encode(%d) = %q, want %q", seq, got, want)
+		}
+	}
+
+	a, b := NewCodec("one key"), NewCodec("another key")
+	first, _ := a.Encode(1)
+	second, _ := a.Encode(2)
+	other, _ := b.Encode(1)
+	if first == "1" || first == other || len(second) < 4 {
+		t.Errorf("keyed codes %q, %q and %q look guessable", first, second, other)
+	}
+	if again, _ := NewCodec("one key").Encode(1); again != first {
+		t.Error("encoding is not deterministic")
+	}
+	if _, err := a.Encode(1<<feistelBits - 1); err != nil {
+		t.Errorf("last code: %v", err)
+	}
+	if _, err := a.Encode(1 << feistelBits); !errors.Is(err, ErrSequenceExhausted) {
+		t.Errorf("past the space = %v", err)
+	}
+}
+
+func TestValidateAlias(t *testing.T) {
+	cases := []struct {
+		alias string
+		want  error
+	}{
+		{"abc", nil},
+		{"my-link_2024", nil},
+		{"ab", ErrAliasInvalid},
+		{"a234567890123456789012345678901234", ErrAliasInvalid},
+		{"has space", ErrAliasInvalid},
+		{"slash/es", ErrAliasInvalid},
+		{"ünï", ErrAliasInvalid},
+		{"stats", ErrAliasReserved},
+		{"Shorten", ErrAliasReserved},
+		{"favicon.ico", ErrAliasInvalid},
+	}
+	for _, c := range cases {
+		if err := validateAlias(c.alias); !errors.Is(err, c.want) || (err == nil) != (c.want == nil) {
+			t.Errorf("validateAlias(%q) = %v, want %v", c.alias, err, c.want)
+		}
+	}
+}
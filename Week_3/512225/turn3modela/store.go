@@ -0,0 +1,382 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/mattn/go-sqlite3"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// User model
+type User struct {
+	gorm.Model
+	Username string `gorm:"unique;not null" json:"username"`
+	Password string `gorm:"not null" json:"-"` // bcrypt hash
+}
+
+// URL model. ShortURL keeps its unique index after a soft delete, so a
+// deleted code is never handed to someone else.
+type URL struct {
+	gorm.Model
+	OriginalURL string     `gorm:"not null" json:"original_url"`
+	ShortURL    string     `gorm:"unique;not null" json:"short_url"`
+	UserID      uint       `gorm:"default:0;index" json:"user_id"`
+	Alias       bool       `json:"alias"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	MaxClicks   uint       `json:"max_clicks,omitempty"` // 0 means unlimited
+	Clicks      uint       `json:"clicks"`
+	Permanent   bool       `json:"permanent"` // 301 instead of 302
+}
+
+// Click records one redirect.
+type Click struct {
+	ID        uint      `gorm:"primary_key"`
+	URLID     uint      `gorm:"index"`
+	At        time.Time `gorm:"not null"`
+	Day       string    `gorm:"index"` // YYYY-MM-DD in UTC
+	Referrer  string
+	UserAgent string
+}
+
+// Sequence hands out increasing numbers for generated codes.
+type Sequence struct {
+	Name string `gorm:"primary_key"`
+	Next uint64
+}
+
+var (
+	ErrNotFound      = errors.New("short URL not found")
+	ErrGone          = errors.New("short URL has expired")
+	ErrForbidden     = errors.New("only the owner may do that")
+	ErrAliasTaken    = errors.New("alias is already taken")
+	ErrUsernameTaken = errors.New("username is already taken")
+	ErrBadURL        = errors.New("url must be an absolute http or https URL")
+	ErrBadRequest    = errors.New("invalid request")
+	// Browsers cache 301s and stop coming back, which would bypass expiry,
+	// click limits and analytics.
+	ErrPermanentLimited = errors.New("a 301 redirect cannot have an expiry or click limit")
+)
+
+// Store wraps the database with the shortener's rules.
+type Store struct {
+	db    *gorm.DB
+	codec *Codec
+}
+
+func NewStore(db *gorm.DB, codec *Codec) (*Store, error) {
+	// AutoMigrate reports failure through the returned DB's Error
+	if err := db.AutoMigrate(&User{}, &URL{}, &Click{}, &Sequence{}).Error; err != nil {
+		return nil, err
+	}
+	return &Store{db: db, codec: codec}, nil
+}
+
+func (s *Store) Register(username, password string) (*User, error) {
+	if len(username) < 3 || len(password) < 8 {
+		return nil, ErrBadRequest
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	u := &User{Username: username, Password: string(hash)}
+	var n int
+	if err := s.db.Model(&User{}).Where("username = ?", username).Count(&n).Error; err != nil {
+		return nil, err
+	}
+	if n > 0 {
+		return nil, ErrUsernameTaken
+	}
+	if err := s.db.Create(u).Error; err != nil {
+		// the count misses concurrent registrations and deleted users,
+		// whose names stay in the unique index
+		if isUniqueViolation(err) {
+			return nil, ErrUsernameTaken
+		}
+		return nil, err
+	}
+	return u, nil
+}
+
+func isUniqueViolation(err error) bool {
+	var serr sqlite3.Error
+	return errors.As(err, &serr) && serr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
+
+// Authenticate returns the user if the password matches.
+func (s *Store) Authenticate(username, password string) (*User, bool) {
+	var u User
+	if err := s.db.Where("username = ?", username).First(&u).Error; err != nil {
+		return nil, false
+	}
+	if bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password)) != nil {
+		return nil, false
+	}
+	return &u, true
+}
+
+// ShortenRequest is the body of POST /shorten.
+type ShortenRequest struct {
+	URL       string `json:"url"`
+	Alias     string `json:"alias,omitempty"`
+	ExpiresIn string `json:"expires_in,omitempty"` // Go duration, e.g. "72h"
+	MaxClicks uint   `json:"max_clicks,omitempty"`
+	Redirect  int    `json:"redirect,omitempty"` // 301 or 302 (default)
+}
+
+// Shorten creates a link owned by userID (0 for anonymous).
+func (s *Store) Shorten(req ShortenRequest, userID uint) (*URL, error) {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrBadURL
+	}
+	link := &URL{OriginalURL: u.String(), UserID: userID, MaxClicks: req.MaxClicks}
+	switch req.Redirect {
+	case 0, 302:
+	case 301:
+		link.Permanent = true
+	default:
+		return nil, ErrBadRequest
+	}
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 {
+			return nil, ErrBadRequest
+		}
+		t := time.Now().Add(d).UTC()
+		link.ExpiresAt = &t
+	}
+	if link.Permanent && (link.ExpiresAt != nil || link.MaxClicks > 0) {
+		return nil, ErrPermanentLimited
+	}
+
+	err = s.transaction(func(tx *gorm.DB) error {
+		if req.Alias != "" {
+			if err := validateAlias(req.Alias); err != nil {
+				return err
+			}
+			if s.codeUsed(tx, req.Alias) {
+				return ErrAliasTaken
+			}
+			link.ShortURL, link.Alias = req.Alias, true
+			return tx.Create(link).Error
+		}
+		for {
+			seq, err := nextSequence(tx, "urls")
+			if err != nil {
+				return err
+			}
+			code, err := s.codec.Encode(seq)
+			if err != nil {
+				return err
+			}
+			// generated codes are unique among themselves; this only
+			// skips numbers whose code someone already chose as an alias
+			if !s.codeUsed(tx, code) {
+				link.ShortURL = code
+				return tx.Create(link).Error
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+func (s *Store) codeUsed(tx *gorm.DB, code string) bool {
+	var n int
+	tx.Unscoped().Model(&URL{}).Where("short_url = ?", code).Count(&n)
+	return n > 0
+}
+
+func nextSequence(tx *gorm.DB, name string) (uint64, error) {
+	res := tx.Exec("UPDATE sequences SET next = next + 1 WHERE name = ?", name)
+	if res.Error != nil {
+		return 0, res.Error
+	}
+	if res.RowsAffected == 0 {
+		if err := tx.Create(&Sequence{Name: name, Next: 1}).Error; err != nil {
+			return 0, err
+		}
+	}
+	var seq Sequence
+	if err := tx.Where("name = ?", name).First(&seq).Error; err != nil {
+		return 0, err
+	}
+	return seq.Next, nil
+}
+
+func (s *Store) transaction(fn func(tx *gorm.DB) error) error {
+	tx := s.db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func (s *Store) find(code string) (*URL, error) {
+	var link URL
+	err := s.db.Where("short_url = ?", code).First(&link).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// Follow resolves code for a redirect and records the click. The click
+// counter is checked and incremented in one statement, so concurrent
+// requests cannot exceed MaxClicks.
+func (s *Store) Follow(code, referrer, userAgent string) (*URL, error) {
+	link, err := s.find(code)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if link.ExpiresAt != nil && !now.Before(*link.ExpiresAt) {
+		return nil, ErrGone
+	}
+	err = s.transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&URL{}).
+			Where("id = ? AND (max_clicks = 0 OR clicks < max_clicks)", link.ID).
+			UpdateColumn("clicks", gorm.Expr("clicks + 1"))
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrGone
+		}
+		return tx.Create(&Click{
+			URLID:     link.ID,
+			At:        now,
+			Day:       now.Format("2006-01-02"),
+			Referrer:  referrerHost(referrer),
+			UserAgent: truncate(userAgent, 256),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+// referrerHost keeps only the referring site; full referrer URLs can carry
+// tokens and personal data.
+func referrerHost(ref string) string {
+	if ref == "" {
+		return ""
+	}
+	u, err := url.Parse(ref)
+	if err != nil || u.Host == "" {
+		return "invalid"
+	}
+	return strings.ToLower(u.Host)
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// Count is a value and how often it occurred.
+type Count struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Stats is the body of GET /stats/{code}.
+type Stats struct {
+	ShortURL      string     `json:"short_url"`
+	OriginalURL   string     `json:"original_url"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	Clicks        uint       `json:"clicks"`
+	ClicksLeft    *uint      `json:"clicks_left,omitempty"`
+	ByDay         []Count    `json:"by_day"`
+	TopReferrers  []Count    `json:"top_referrers"`
+	TopUserAgents []Count    `json:"top_user_agents"`
+}
+
+// Stats reports the clicks on a link owned by userID.
+func (s *Store) Stats(code string, userID uint) (*Stats, error) {
+	link, err := s.find(code)
+	if err != nil {
+		return nil, err
+	}
+	if link.UserID == 0 || link.UserID != userID {
+		return nil, ErrForbidden
+	}
+	st := &Stats{
+		ShortURL:    link.ShortURL,
+		OriginalURL: link.OriginalURL,
+		CreatedAt:   link.CreatedAt,
+		ExpiresAt:   link.ExpiresAt,
+		Clicks:      link.Clicks,
+	}
+	if link.MaxClicks > 0 {
+		left := link.MaxClicks - min(link.Clicks, link.MaxClicks)
+		st.ClicksLeft = &left
+	}
+	if st.ByDay, err = s.groupClicks(link.ID, "day", 0); err != nil {
+		return nil, err
+	}
+	sort.Slice(st.ByDay, func(i, j int) bool { return st.ByDay[i].Value < st.ByDay[j].Value })
+	if st.TopReferrers, err = s.groupClicks(link.ID, "referrer", 10); err != nil {
+		return nil, err
+	}
+	if st.TopUserAgents, err = s.groupClicks(link.ID, "user_agent", 10); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// groupClicks counts clicks by column, most frequent first. column is
+// always one of the fixed names above, never user input.
+func (s *Store) groupClicks(urlID uint, column string, limit int) ([]Count, error) {
+	q := s.db.Model(&Click{}).
+		Select(column+" AS value, COUNT(*) AS count").
+		Where("url_id = ?", urlID).
+		Group(column).
+		Order("count DESC, value")
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	counts := []Count{}
+	if err := q.Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// Delete soft-deletes a link owned by userID.
+func (s *Store) Delete(code string, userID uint) error {
+	link, err := s.find(code)
+	if err != nil {
+		return err
+	}
+	if link.UserID == 0 || link.UserID != userID {
+		return ErrForbidden
+	}
+	return s.db.Delete(link).Error
+}
+
+// LinksOf lists the links owned by userID, newest first.
+func (s *Store) LinksOf(userID uint) ([]URL, error) {
+	links := []URL{}
+	err := s.db.Where("user_id = ?", userID).Order("id DESC").Find(&links).Error
+	return links, err
+}
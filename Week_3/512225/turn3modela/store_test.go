@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+)
+
+func openTest(t *testing.T) *Store {
+	t.Helper()
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every connection would get its own in-memory database
+	db.DB().SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	s, err := NewStore(db, NewCodec("test key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestRegisterTakenNames(t *testing.T) {
+	s := openTest(t)
+	u, err := s.Register("alice", "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Register("alice", "another password"); !errors.Is(err, ErrUsernameTaken) {
+		t.Errorf("second registration = %v", err)
+	}
+	// a deleted user is invisible to the count but keeps the unique index
+	if err := s.db.Delete(u).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Register("alice", "correct horse"); !errors.Is(err, ErrUsernameTaken) {
+		t.Errorf("registering a deleted user's name = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = s.Register("bob", "correct horse")
+		}()
+	}
+	wg.Wait()
+	created := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case !errors.Is(err, ErrUsernameTaken):
+			t.Errorf("concurrent registration = %v", err)
+		}
+	}
+	if created != 1 {
+		t.Errorf("%d concurrent registrations of one name succeeded", created)
+	}
+}
+
+func TestClickLimit(t *testing.T) {
+	s := openTest(t)
+	link, err := s.Shorten(ShortenRequest{URL: "https://example.com/a", MaxClicks: 3}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	followed := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.Follow(link.ShortURL, "", "")
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				followed++
+			case !errors.Is(err, ErrGone):
+				t.Errorf("Follow = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if followed != 3 {
+		t.Errorf("%d redirects through a link limited to 3", followed)
+	}
+	var clicks int
+	s.db.Model(&Click{}).Where("url_id = ?", link.ID).Count(&clicks)
+	if clicks != 3 {
+		t.Errorf("%d clicks recorded", clicks)
+	}
+
+	if _, err := s.Shorten(ShortenRequest{URL: "https://example.com", MaxClicks: 1, Redirect: 301}, 0); !errors.Is(err, ErrPermanentLimited) {
+		t.Errorf("limited 301 = %v", err)
+	}
+}
+
+func TestAliases(t *testing.T) {
+	s := openTest(t)
+	if _, err := s.Shorten(ShortenRequest{URL: "https://example.com", Alias: "docs"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	for alias, want := range map[string]error{
+		"docs":  ErrAliasTaken,
+		"stats": ErrAliasReserved,
+		"x":     ErrAliasInvalid,
+	} {
+		if _, err := s.Shorten(ShortenRequest{URL: "https://example.com", Alias: alias}, 0); !errors.Is(err, want) {
+			t.Errorf("alias %q = %v, want %v", alias, err, want)
+		}
+	}
+	if _, err := s.Shorten(ShortenRequest{URL: "ftp://example.com"}, 0); !errors.Is(err, ErrBadURL) {
+		t.Errorf("ftp URL = %v", err)
+	}
+}
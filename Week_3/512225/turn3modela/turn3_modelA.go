@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type server struct {
+	store   *Store
+	baseURL string
+}
+
+func main() {
+	dbPath := flag.String("db", "urlshortener.db", "SQLite database file")
+	addr := flag.String("addr", ":8080", "listen address")
+	baseURL := flag.String("base", "http://localhost:8080", "public base URL for short links")
+	key := flag.String("key", "", "secret that obfuscates generated codes (empty: sequential codes)")
+	flag.Parse()
+
+	db, err := gorm.Open("sqlite3", *dbPath)
+	if err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+	defer db.Close()
+	db.DB().SetMaxOpenConns(1) // SQLite allows one writer at a time
+
+	store, err := NewStore(db, NewCodec(*key))
+	if err != nil {
+		log.Fatalf("failed to migrate database: %v", err)
+	}
+	s := &server{store: store, baseURL: strings.TrimRight(*baseURL, "/")}
+
+	log.Printf("listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, s.routes()))
+}
+
+func (s *server) routes() http.Handler {
+	router := mux.NewRouter()
+	router.HandleFunc("/users", s.register).Methods("POST")
+	router.HandleFunc("/users/me/links", s.requireUser(s.myLinks)).Methods("GET")
+	router.HandleFunc("/shorten", s.shorten).Methods("POST")
+	router.HandleFunc("/stats/{code}", s.requireUser(s.stats)).Methods("GET")
+	router.HandleFunc("/links/{code}", s.requireUser(s.deleteLink)).Methods("DELETE")
+	router.HandleFunc("/{code}", s.redirect).Methods("GET", "HEAD")
+	return router
+}
+
+func (s *server) register(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, ErrBadRequest)
+		return
+	}
+	u, err := s.store.Register(req.Username, req.Password)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, u)
+}
+
+// currentUser authenticates HTTP basic credentials. ok reports a successful
+// login; valid is false only when credentials were sent and rejected.
+func (s *server) currentUser(r *http.Request) (u *User, ok, valid bool) {
+	name, pass, ok := r.BasicAuth()
+	if !ok {
+		return nil, false, true
+	}
+	u, ok = s.store.Authenticate(name, pass)
+	return u, ok, ok
+}
+
+func (s *server) requireUser(next func(http.ResponseWriter, *http.Request, *User)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, ok, _ := s.currentUser(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="shortener"`)
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "authentication required"})
+			return
+		}
+		next(w, r, u)
+	}
+}
+
+// linkResponse adds the full short link to a URL record.
+type linkResponse struct {
+	*URL
+	Link string `json:"link"`
+}
+
+func (s *server) shorten(w http.ResponseWriter, r *http.Request) {
+	// anonymous links are allowed, but wrong credentials are an error
+	u, _, valid := s.currentUser(r)
+	if !valid {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid credentials"})
+		return
+	}
+	var req ShortenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, ErrBadRequest)
+		return
+	}
+	var owner uint
+	if u != nil {
+		owner = u.ID
+	}
+	link, err := s.store.Shorten(req, owner)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, linkResponse{link, s.baseURL + "/" + link.ShortURL})
+}
+
+func (s *server) redirect(w http.ResponseWriter, r *http.Request) {
+	link, err := s.store.Follow(mux.Vars(r)["code"], r.Referer(), r.UserAgent())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	status := http.StatusFound
+	if link.Permanent {
+		status = http.StatusMovedPermanently
+	}
+	http.Redirect(w, r, link.OriginalURL, status)
+}
+
+func (s *server) stats(w http.ResponseWriter, r *http.Request, u *User) {
+	st, err := s.store.Stats(mux.Vars(r)["code"], u.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, st)
+}
+
+func (s *server) deleteLink(w http.ResponseWriter, r *http.Request, u *User) {
+	if err := s.store.Delete(mux.Vars(r)["code"], u.ID); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) myLinks(w http.ResponseWriter, r *http.Request, u *User) {
+	links, err := s.store.LinksOf(u.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	out := make([]linkResponse, len(links))
+	for i := range links {
+		out[i] = linkResponse{&links[i], s.baseURL + "/" + links[i].ShortURL}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrGone):
+		status = http.StatusGone
+	case errors.Is(err, ErrForbidden):
+		status = http.StatusForbidden
+	case errors.Is(err, ErrAliasTaken), errors.Is(err, ErrUsernameTaken):
+		status = http.StatusConflict
+	case errors.Is(err, ErrBadURL), errors.Is(err, ErrBadRequest), errors.Is(err, ErrPermanentLimited),
+		errors.Is(err, ErrAliasInvalid), errors.Is(err, ErrAliasReserved):
+		status = http.StatusBadRequest
+	}
+	msg := err.Error()
+	if status == http.StatusInternalServerError {
+		log.Printf("internal error: %v", err)
+		msg = "internal error"
+	}
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
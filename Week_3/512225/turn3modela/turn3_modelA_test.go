@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStatsAreOwnerOnly(t *testing.T) {
+	s := openTest(t)
+	srv := httptest.NewServer((&server{store: s, baseURL: "http://sho.rt"}).routes())
+	defer srv.Close()
+
+	alice, err := s.Register("alice", "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Register("mallory", "battery staple"); err != nil {
+		t.Fatal(err)
+	}
+	owned, err := s.Shorten(ShortenRequest{URL: "https://example.com/a"}, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	anon, err := s.Shorten(ShortenRequest{URL: "https://example.com/b"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		code, user, pass string
+		want             int
+	}{
+		{owned.ShortURL, "alice", "correct horse", http.StatusOK},
+		{owned.ShortURL, "mallory", "battery staple", http.StatusForbidden},
+		{owned.ShortURL, "", "", http.StatusUnauthorized},
+		{owned.ShortURL, "alice", "wrong password", http.StatusUnauthorized},
+		{anon.ShortURL, "alice", "correct horse", http.StatusForbidden},
+		{"missing", "alice", "correct horse", http.StatusNotFound},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/stats/"+c.code, nil)
+		if c.user != "" {
+			req.SetBasicAuth(c.user, c.pass)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != c.want {
+			t.Errorf("stats of %s as %q = %d, want %d", c.code, c.user, res.StatusCode, c.want)
+		}
+	}
+
+	res, err := http.Post(srv.URL+"/users", "application/json", bytes.NewBufferString(`{"username":"alice","password":"correct horse"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusConflict {
+		t.Errorf("duplicate registration = %d", res.StatusCode)
+	}
+}
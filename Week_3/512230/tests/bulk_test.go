@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+
+	"Week_3/512230/turn3modela/bulk"
+)
+
+func openUsersDB(tb testing.TB) *sql.DB {
+	tb.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	tb.Cleanup(func() { db.Close() })
+	_, err = db.Exec(`CREATE TABLE users (
+		id INTEGER PRIMARY KEY,
+		first_name TEXT NOT NULL,
+		last_name TEXT NOT NULL,
+		age INTEGER
+	)`)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return db
+}
+
+func makeUsers(n int) []User {
+	users := make([]User, n)
+	for i := range users {
+		users[i] = User{ID: i + 1, First: fmt.Sprintf("First%d", i), Last: fmt.Sprintf("Last%d", i), Age: i%100 + 1}
+	}
+	return users
+}
+
+func userValues(u User) []any { return []any{u.ID, u.First, u.Last, u.Age} }
+
+var userOpts = bulk.Options{
+	Table:        "users",
+	Columns:      []string{"id", "first_name", "last_name", "age"},
+	ConflictKeys: []string{"id"},
+}
+
+func TestBulkInsertConflictModes(t *testing.T) {
+	ctx := context.Background()
+	db := openUsersDB(t)
+	users := makeUsers(1234)
+	opts := userOpts
+	opts.RowsPerStatement, opts.TxRows = 100, 500
+	st, err := bulk.Insert(ctx, db, opts, users, userValues)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 12 full statements plus one short one, committed in 3 transactions
+	if st.Rows != 1234 || st.Statements != 13 || st.Transactions != 3 {
+		t.Fatalf("stats = %+v", st)
+	}
+
+	changed := []User{{ID: 1, First: "Ada", Last: "Lovelace", Age: 36}}
+	if _, err := bulk.Insert(ctx, db, opts, changed, userValues); err == nil {
+		t.Fatal("duplicate key with ConflictError succeeded")
+	}
+
+	opts.Conflict = bulk.ConflictIgnore
+	if _, err := bulk.Insert(ctx, db, opts, changed, userValues); err != nil {
+		t.Fatal(err)
+	}
+	var first string
+	db.QueryRow("SELECT first_name FROM users WHERE id = 1").Scan(&first)
+	if first != "First0" {
+		t.Fatalf("ConflictIgnore changed the row: %q", first)
+	}
+
+	opts.Conflict = bulk.ConflictUpdate
+	if _, err := bulk.Insert(ctx, db, opts, changed, userValues); err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	db.QueryRow("SELECT first_name, (SELECT COUNT(*) FROM users) FROM users WHERE id = 1").Scan(&first, &count)
+	if first != "Ada" || count != 1234 {
+		t.Fatalf("after upsert: first=%q count=%d", first, count)
+	}
+}
+
+func TestImportCSV(t *testing.T) {
+	ctx := context.Background()
+	db := openUsersDB(t)
+	input := "\ufeffid,first,last,age,ignored\n" +
+		"1,Ada,Lovelace,36,x\n" +
+		"2,\"Grace, RDML\",Hopper,,y\n"
+	st, err := bulk.ImportCSV(ctx, db, strings.NewReader(input), bulk.CSVOptions{
+		Options:     userOpts,
+		Mapping:     map[string]string{"id": "id", "first": "first_name", "last": "last_name", "age": "age"},
+		NullIfEmpty: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Rows != 2 {
+		t.Fatalf("imported %d rows, want 2", st.Rows)
+	}
+	var first string
+	var age sql.NullInt64
+	db.QueryRow("SELECT first_name, age FROM users WHERE id = 2").Scan(&first, &age)
+	if first != "Grace, RDML" || age.Valid {
+		t.Fatalf("row 2 = %q, %v", first, age)
+	}
+
+	_, err = bulk.ImportCSV(ctx, db, strings.NewReader("id,first_name,last_name,age\n3,A,B\n"), bulk.CSVOptions{Options: userOpts})
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("short record: err = %v", err)
+	}
+}
+
+// BenchmarkBulkInsertRowsPerStatement loads the same 10,000 users as the
+// benchmarks above with different batch sizes; 1 is the row-at-a-time case.
+func BenchmarkBulkInsertRowsPerStatement(b *testing.B) {
+	users := makeUsers(10000)
+	for _, rows := range []int{1, 10, 100, 500} {
+		b.Run(fmt.Sprintf("rows=%d", rows), func(b *testing.B) {
+			opts := userOpts
+			opts.RowsPerStatement = rows
+			opts.Conflict = bulk.ConflictUpdate
+			db := openUsersDB(b)
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				if _, err := bulk.Insert(context.Background(), db, opts, users, userValues); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(len(users)*b.N)/b.Elapsed().Seconds(), "rows/s")
+		})
+	}
+}
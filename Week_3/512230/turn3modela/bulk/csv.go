@@ -0,0 +1,86 @@
+package bulk
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CSVOptions configures ImportCSV. The target columns are derived from the
+// CSV header and Mapping, so Options.Columns is ignored.
+type CSVOptions struct {
+	Options
+	// Mapping renames CSV header names to table columns. When it is set,
+	// CSV columns not in it are skipped; when nil, header names are used
+	// as column names unchanged.
+	Mapping map[string]string
+	// NullIfEmpty stores empty fields as NULL instead of ''.
+	NullIfEmpty bool
+	Comma       rune // default ','
+}
+
+// ImportCSV streams a CSV file with a header row into a table, like
+// PostgreSQL's COPY FROM. Values are passed as text and SQLite's column
+// affinity converts them. A malformed row stops the import with its line
+// number; transactions committed before it are kept.
+func ImportCSV(ctx context.Context, db *sql.DB, r io.Reader, opts CSVOptions) (Stats, error) {
+	cr := csv.NewReader(r)
+	if opts.Comma != 0 {
+		cr.Comma = opts.Comma
+	}
+	cr.ReuseRecord = true
+	header, err := cr.Read()
+	if err != nil {
+		return Stats{}, fmt.Errorf("bulk: reading CSV header: %w", err)
+	}
+
+	var fields []int // CSV field index for each table column
+	opts.Columns = nil
+	for i, h := range header {
+		h = strings.TrimSpace(strings.TrimPrefix(h, "\ufeff"))
+		col := h
+		if opts.Mapping != nil {
+			var ok bool
+			if col, ok = opts.Mapping[h]; !ok {
+				continue
+			}
+		}
+		opts.Columns = append(opts.Columns, col)
+		fields = append(fields, i)
+	}
+	if len(opts.Columns) == 0 {
+		return Stats{}, errors.New("bulk: no CSV column maps to the table")
+	}
+
+	l, err := NewLoader(db, opts.Options)
+	if err != nil {
+		return Stats{}, err
+	}
+	row := make([]any, len(fields))
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// ParseError already carries the line number
+			return l.Stats(), l.abort(err)
+		}
+		for j, i := range fields {
+			if opts.NullIfEmpty && rec[i] == "" {
+				row[j] = nil
+			} else {
+				row[j] = rec[i]
+			}
+		}
+		if err := l.Add(ctx, row...); err != nil {
+			line, _ := cr.FieldPos(0)
+			return l.Stats(), fmt.Errorf("batch ending at line %d: %w", line, err)
+		}
+	}
+	return l.Close(ctx)
+}
@@ -0,0 +1,274 @@
+// Package bulk loads large numbers of rows into SQLite using prepared
+// multi-row INSERT statements sized to the variable limit, grouped into
+// transactions, with optional ON CONFLICT upserts.
+package bulk
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Conflict selects what happens when a row violates a unique constraint.
+type Conflict int
+
+const (
+	// ConflictError fails the load, as a plain INSERT does.
+	ConflictError Conflict = iota
+	// ConflictIgnore keeps the existing row (ON CONFLICT DO NOTHING).
+	ConflictIgnore
+	// ConflictUpdate overwrites the existing row's other columns
+	// (ON CONFLICT (keys) DO UPDATE SET col = excluded.col).
+	ConflictUpdate
+)
+
+// DefaultMaxVariables is SQLITE_MAX_VARIABLE_NUMBER for SQLite 3.32 and
+// later; use 999 for older builds.
+const DefaultMaxVariables = 32766
+
+// Options describes the target table and how rows are batched.
+type Options struct {
+	Table        string
+	Columns      []string
+	Conflict     Conflict
+	ConflictKeys []string // required for ConflictUpdate
+	// MaxVariables bounds the placeholders in one statement.
+	MaxVariables int
+	// RowsPerStatement defaults to as many rows as MaxVariables allows,
+	// capped at 500; bigger statements stop paying off.
+	RowsPerStatement int
+	// TxRows is the number of rows per transaction, default 100000.
+	TxRows int
+	// Progress, if set, is called after every committed transaction.
+	Progress func(Stats)
+}
+
+// Stats reports the work done so far.
+type Stats struct {
+	Rows         int64
+	Statements   int64
+	Transactions int64
+	Elapsed      time.Duration
+}
+
+func (s Stats) RowsPerSec() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Rows) / s.Elapsed.Seconds()
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("%d rows in %v (%.0f rows/s, %d statements, %d transactions)",
+		s.Rows, s.Elapsed.Round(time.Millisecond), s.RowsPerSec(), s.Statements, s.Transactions)
+}
+
+// Loader buffers rows and writes them in batches. It is not safe for
+// concurrent use. Call Close to write the remaining rows.
+type Loader struct {
+	db    *sql.DB
+	opts  Options
+	start time.Time
+	stats Stats
+
+	tx      *sql.Tx
+	stmt    *sql.Stmt // full-batch statement, prepared once per transaction
+	buf     []any
+	pending int // rows in buf
+	txRows  int
+}
+
+// NewLoader validates opts.
+func NewLoader(db *sql.DB, opts Options) (*Loader, error) {
+	if opts.Table == "" || len(opts.Columns) == 0 {
+		return nil, errors.New("bulk: table and columns are required")
+	}
+	if opts.Conflict == ConflictUpdate && len(opts.ConflictKeys) == 0 {
+		return nil, errors.New("bulk: ConflictUpdate needs ConflictKeys")
+	}
+	if opts.MaxVariables <= 0 {
+		opts.MaxVariables = DefaultMaxVariables
+	}
+	maxRows := opts.MaxVariables / len(opts.Columns)
+	if maxRows == 0 {
+		return nil, fmt.Errorf("bulk: %d columns exceed the variable limit", len(opts.Columns))
+	}
+	if opts.RowsPerStatement <= 0 {
+		opts.RowsPerStatement = min(maxRows, 500)
+	}
+	opts.RowsPerStatement = min(opts.RowsPerStatement, maxRows)
+	if opts.TxRows <= 0 {
+		opts.TxRows = 100000
+	}
+	return &Loader{
+		db:    db,
+		opts:  opts,
+		start: time.Now(),
+		buf:   make([]any, 0, opts.RowsPerStatement*len(opts.Columns)),
+	}, nil
+}
+
+// Add queues one row; values must match Columns in number and order. Any
+// error, including a value count mismatch, rolls back the open transaction.
+func (l *Loader) Add(ctx context.Context, values ...any) error {
+	if len(values) != len(l.opts.Columns) {
+		return l.abort(fmt.Errorf("got %d values for %d columns", len(values), len(l.opts.Columns)))
+	}
+	l.buf = append(l.buf, values...)
+	l.pending++
+	if l.pending == l.opts.RowsPerStatement {
+		return l.flush(ctx)
+	}
+	return nil
+}
+
+// flush writes the buffered rows.
+func (l *Loader) flush(ctx context.Context) error {
+	if l.pending == 0 {
+		return nil
+	}
+	if l.tx == nil {
+		tx, err := l.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		l.tx, l.stmt = tx, nil
+	}
+	var err error
+	if l.pending == l.opts.RowsPerStatement {
+		if l.stmt == nil {
+			if l.stmt, err = l.tx.PrepareContext(ctx, l.query(l.pending)); err != nil {
+				return l.abort(err)
+			}
+		}
+		_, err = l.stmt.ExecContext(ctx, l.buf...)
+	} else {
+		// the final short batch gets its own statement
+		_, err = l.tx.ExecContext(ctx, l.query(l.pending), l.buf...)
+	}
+	if err != nil {
+		return l.abort(err)
+	}
+	l.stats.Statements++
+	l.stats.Rows += int64(l.pending)
+	l.txRows += l.pending
+	l.buf, l.pending = l.buf[:0], 0
+	if l.txRows >= l.opts.TxRows {
+		return l.commit()
+	}
+	return nil
+}
+
+func (l *Loader) commit() error {
+	if l.tx == nil {
+		return nil
+	}
+	if l.stmt != nil {
+		l.stmt.Close()
+	}
+	err := l.tx.Commit()
+	l.tx, l.stmt, l.txRows = nil, nil, 0
+	if err != nil {
+		return err
+	}
+	l.stats.Transactions++
+	if l.opts.Progress != nil {
+		l.opts.Progress(l.Stats())
+	}
+	return nil
+}
+
+// abort rolls back the open transaction and drops the buffered rows, so a
+// later Close does not write half a batch. Rows committed by earlier
+// transactions stay in the table.
+func (l *Loader) abort(err error) error {
+	if l.stmt != nil {
+		l.stmt.Close()
+	}
+	if l.tx != nil {
+		l.tx.Rollback()
+	}
+	l.stats.Rows -= int64(l.txRows)
+	l.tx, l.stmt, l.txRows = nil, nil, 0
+	l.buf, l.pending = l.buf[:0], 0
+	return fmt.Errorf("bulk: insert into %s: %w", l.opts.Table, err)
+}
+
+// Close writes any buffered rows and commits.
+func (l *Loader) Close(ctx context.Context) (Stats, error) {
+	if err := l.flush(ctx); err != nil {
+		return l.Stats(), err
+	}
+	err := l.commit()
+	return l.Stats(), err
+}
+
+// Stats returns the rows written so far, including uncommitted ones.
+func (l *Loader) Stats() Stats {
+	s := l.stats
+	s.Elapsed = time.Since(l.start)
+	return s
+}
+
+// query builds the INSERT for n rows.
+func (l *Loader) query(n int) string {
+	cols := make([]string, len(l.opts.Columns))
+	for i, c := range l.opts.Columns {
+		cols[i] = quoteIdent(c)
+	}
+	row := "(" + strings.TrimSuffix(strings.Repeat("?,", len(cols)), ",") + ")"
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "INSERT INTO %s (%s) VALUES ", quoteIdent(l.opts.Table), strings.Join(cols, ","))
+	sb.Grow(n * (len(row) + 1))
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(row)
+	}
+	switch l.opts.Conflict {
+	case ConflictIgnore:
+		sb.WriteString(" ON CONFLICT DO NOTHING")
+	case ConflictUpdate:
+		keys := make([]string, len(l.opts.ConflictKeys))
+		isKey := map[string]bool{}
+		for i, k := range l.opts.ConflictKeys {
+			keys[i] = quoteIdent(k)
+			isKey[k] = true
+		}
+		var sets []string
+		for _, c := range l.opts.Columns {
+			if !isKey[c] {
+				sets = append(sets, fmt.Sprintf("%s=excluded.%s", quoteIdent(c), quoteIdent(c)))
+			}
+		}
+		if len(sets) == 0 {
+			fmt.Fprintf(&sb, " ON CONFLICT (%s) DO NOTHING", strings.Join(keys, ","))
+		} else {
+			fmt.Fprintf(&sb, " ON CONFLICT (%s) DO UPDATE SET %s", strings.Join(keys, ","), strings.Join(sets, ","))
+		}
+	}
+	return sb.String()
+}
+
+func quoteIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// Insert loads items using values to turn each one into a row.
+func Insert[T any](ctx context.Context, db *sql.DB, opts Options, items []T, values func(T) []any) (Stats, error) {
+	l, err := NewLoader(db, opts)
+	if err != nil {
+		return Stats{}, err
+	}
+	for _, it := range items {
+		if err := l.Add(ctx, values(it)...); err != nil {
+			return l.Stats(), err
+		}
+	}
+	return l.Close(ctx)
+}
@@ -0,0 +1,91 @@
+package bulk
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+)
+
+func openTest(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every connection would get its own in-memory database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec("CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func count(t *testing.T, db *sql.DB) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM t").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestCloseAfterErrorWritesNothingMore(t *testing.T) {
+	db := openTest(t)
+	ctx := context.Background()
+	l, err := NewLoader(db, Options{Table: "t", Columns: []string{"id", "name"}, RowsPerStatement: 2, TxRows: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// rows 1-4 commit, 5-6 are written in the open transaction and 7 is
+	// still buffered when the bad row arrives
+	for id := 1; id <= 7; id++ {
+		if err := l.Add(ctx, id, "row"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Add(ctx, 8); err == nil {
+		t.Fatal("short row accepted")
+	}
+	st, err := l.Close(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, db); n != 4 || st.Rows != 4 {
+		t.Errorf("table has %d rows, stats say %d; want the 4 committed", n, st.Rows)
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	ctx := context.Background()
+	for _, c := range []struct {
+		conflict Conflict
+		want     string
+		fails    bool
+	}{
+		{ConflictError, "old", true},
+		{ConflictIgnore, "old", false},
+		{ConflictUpdate, "new", false},
+	} {
+		db := openTest(t)
+		db.Exec("INSERT INTO t VALUES (1, 'old')")
+		l, err := NewLoader(db, Options{Table: "t", Columns: []string{"id", "name"}, Conflict: c.conflict, ConflictKeys: []string{"id"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		l.Add(ctx, 1, "new")
+		l.Add(ctx, 2, "other")
+		_, err = l.Close(ctx)
+		if (err != nil) != c.fails {
+			t.Errorf("conflict mode %d: Close = %v", c.conflict, err)
+		}
+		var name string
+		db.QueryRow("SELECT name FROM t WHERE id = 1").Scan(&name)
+		if name != c.want {
+			t.Errorf("conflict mode %d: row 1 is %q, want %q", c.conflict, name, c.want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package dbpool
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// histogram is a fixed-bucket Prometheus histogram.
+type histogram struct {
+	mu      sync.Mutex
+	bounds  []float64
+	buckets []uint64 // per bucket, not cumulative
+	count   uint64
+	sum     float64
+}
+
+func newHistogram(bounds ...float64) *histogram {
+	return &histogram{bounds: bounds, buckets: make([]uint64, len(bounds))}
+}
+
+func (h *histogram) observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, b := range h.bounds {
+		if v <= b {
+			h.buckets[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+func (h *histogram) write(w io.Writer, name, help string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	var cum uint64
+	for i, b := range h.bounds {
+		cum += h.buckets[i]
+		fmt.Fprintf(w, "%s_bucket{le=\"%g\"} %d\n", name, b, cum)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n%s_sum %g\n%s_count %d\n", name, h.count, name, h.sum, name, h.count)
+}
+
+type metrics struct {
+	wait, hold    *histogram
+	acquired      atomic.Int64
+	acquireErrors atomic.Int64
+	leaks         atomic.Int64
+	timeouts      atomic.Int64
+	busyRetries   atomic.Int64
+	busyFailures  atomic.Int64
+	healthOK      atomic.Int64
+	healthFailed  atomic.Int64
+	healthy       atomic.Bool
+	pingMicros    atomic.Int64
+}
+
+func newMetrics() metrics {
+	return metrics{
+		wait: newHistogram(.0001, .0005, .001, .005, .01, .05, .1, .5, 1, 5),
+		hold: newHistogram(.001, .005, .01, .05, .1, .5, 1, 5, 10, 30, 60),
+	}
+}
+
+func (m *metrics) health(h Health) {
+	m.healthy.Store(h.OK)
+	m.pingMicros.Store(h.Latency.Microseconds())
+	if h.OK {
+		m.healthOK.Add(1)
+	} else {
+		m.healthFailed.Add(1)
+	}
+}
+
+// WriteMetrics writes the pool's metrics in the Prometheus text format,
+// including database/sql's own counters.
+func (p *Pool) WriteMetrics(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	m := &p.m
+	st := p.db.Stats()
+	p.mu.Lock()
+	inUse := len(p.leases)
+	p.mu.Unlock()
+	leaked := len(p.Leaks())
+
+	gauge := func(name, help string, v any) {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s gauge\n%s %v\n", name, help, name, name, v)
+	}
+	counter := func(name, help string, v any) {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s counter\n%s %v\n", name, help, name, name, v)
+	}
+
+	m.wait.write(bw, "dbpool_acquire_wait_seconds", "Time spent waiting for a connection.")
+	m.hold.write(bw, "dbpool_hold_seconds", "Time connections were held before release.")
+	counter("dbpool_acquired_total", "Connections acquired.", m.acquired.Load())
+	counter("dbpool_acquire_errors_total", "Acquire calls that failed.", m.acquireErrors.Load())
+	gauge("dbpool_in_use", "Connections currently checked out through the pool.", inUse)
+	gauge("dbpool_held_too_long", "Connections currently held beyond the leak threshold.", leaked)
+	counter("dbpool_leaks_total", "Connections reported as held beyond the leak threshold.", m.leaks.Load())
+	counter("dbpool_query_timeouts_total", "Statements cancelled by the per-query timeout.", m.timeouts.Load())
+	counter("dbpool_busy_retries_total", "Statements retried after SQLITE_BUSY.", m.busyRetries.Load())
+	counter("dbpool_busy_failures_total", "Statements still busy after the last retry.", m.busyFailures.Load())
+	fmt.Fprintf(bw, "# HELP dbpool_health_checks_total Health check pings by result.\n# TYPE dbpool_health_checks_total counter\n")
+	fmt.Fprintf(bw, "dbpool_health_checks_total{result=\"ok\"} %d\ndbpool_health_checks_total{result=\"error\"} %d\n", m.healthOK.Load(), m.healthFailed.Load())
+	healthy := 0
+	if m.healthy.Load() {
+		healthy = 1
+	}
+	gauge("dbpool_healthy", "1 if the last health check succeeded.", healthy)
+	gauge("dbpool_ping_seconds", "Latency of the last health check.", float64(m.pingMicros.Load())/1e6)
+
+	gauge("dbpool_max_open_connections", "Configured maximum of open connections.", st.MaxOpenConnections)
+	gauge("dbpool_open_connections", "Open connections, in use or idle.", st.OpenConnections)
+	gauge("dbpool_idle_connections", "Idle connections.", st.Idle)
+	counter("dbpool_wait_count_total", "Connections waited for by database/sql.", st.WaitCount)
+	counter("dbpool_wait_duration_seconds_total", "Total time database/sql blocked waiting for a connection.", st.WaitDuration.Seconds())
+	counter("dbpool_max_idle_closed_total", "Connections closed due to SetMaxIdleConns.", st.MaxIdleClosed)
+	counter("dbpool_max_lifetime_closed_total", "Connections closed due to SetConnMaxLifetime.", st.MaxLifetimeClosed)
+	return bw.Flush()
+}
+
+// MetricsHandler serves WriteMetrics for a Prometheus scrape.
+func (p *Pool) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		p.WriteMetrics(w)
+	})
+}
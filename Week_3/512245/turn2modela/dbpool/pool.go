@@ -0,0 +1,425 @@
+// Package dbpool wraps database/sql with the instrumentation its built-in
+// pool lacks: acquire wait and hold time histograms, detection of
+// connections held too long (with the stack that took them), periodic
+// health checks, per-query timeouts and retry of SQLITE_BUSY. The numbers
+// are exposed in the Prometheus text format.
+package dbpool
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config tunes a Pool. Zero values select the defaults noted on each field.
+type Config struct {
+	MaxOpen int // default 10
+	MaxIdle int // default MaxOpen
+
+	// LeakThreshold is how long a connection may be held before it is
+	// reported. Default 30s; negative disables leak detection.
+	LeakThreshold time.Duration
+	// OnLeak is called once per leaked lease. Default logs it with its stack.
+	OnLeak func(Leak)
+
+	// HealthInterval is the period of the PingContext health check.
+	// Default 15s; negative disables it.
+	HealthInterval time.Duration
+	HealthTimeout  time.Duration // default 2s
+
+	// QueryTimeout bounds every Exec and Query made through the pool
+	// unless the caller's context has an earlier deadline. Zero means none.
+	QueryTimeout time.Duration
+
+	// BusyRetries is how many times a statement failing with SQLITE_BUSY
+	// is retried, with BusyBackoff doubling between attempts. Default 5
+	// retries from 10ms; negative disables retrying.
+	BusyRetries int
+	BusyBackoff time.Duration
+
+	Logger *log.Logger // default log.Default()
+}
+
+func (c *Config) setDefaults() {
+	if c.MaxOpen <= 0 {
+		c.MaxOpen = 10
+	}
+	if c.MaxIdle <= 0 {
+		c.MaxIdle = c.MaxOpen
+	}
+	if c.LeakThreshold == 0 {
+		c.LeakThreshold = 30 * time.Second
+	}
+	if c.HealthInterval == 0 {
+		c.HealthInterval = 15 * time.Second
+	}
+	if c.HealthTimeout <= 0 {
+		c.HealthTimeout = 2 * time.Second
+	}
+	if c.BusyRetries == 0 {
+		c.BusyRetries = 5
+	}
+	if c.BusyBackoff <= 0 {
+		c.BusyBackoff = 10 * time.Millisecond
+	}
+	if c.Logger == nil {
+		c.Logger = log.Default()
+	}
+	if c.OnLeak == nil {
+		logger := c.Logger
+		c.OnLeak = func(l Leak) { logger.Printf("dbpool: %v\n%s", l, l.Stack) }
+	}
+}
+
+// Leak describes a connection held longer than Config.LeakThreshold.
+type Leak struct {
+	ID       uint64
+	Acquired time.Time
+	Held     time.Duration
+	Stack    string // where Acquire was called
+}
+
+func (l Leak) String() string {
+	return fmt.Sprintf("connection %d held for %s (acquired %s)", l.ID, l.Held.Round(time.Millisecond), l.Acquired.Format(time.RFC3339))
+}
+
+// Pool is an instrumented *sql.DB.
+type Pool struct {
+	db  *sql.DB
+	cfg Config
+	m   metrics
+
+	mu     sync.Mutex
+	nextID uint64
+	leases map[uint64]*lease
+	health Health
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+type lease struct {
+	acquired time.Time
+	pcs      []uintptr
+	reported bool
+}
+
+// Health is the result of the latest health check.
+type Health struct {
+	OK      bool
+	Checked time.Time
+	Latency time.Duration
+	Err     error
+}
+
+// Open opens the database and starts the leak and health monitors. The
+// first health check runs before Open returns, so a bad DSN fails here
+// rather than on first use.
+func Open(driverName, dsn string, cfg Config) (*Pool, error) {
+	cfg.setDefaults()
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(cfg.MaxOpen)
+	db.SetMaxIdleConns(cfg.MaxIdle)
+
+	p := &Pool{db: db, cfg: cfg, m: newMetrics(), leases: map[uint64]*lease{}, stop: make(chan struct{})}
+	if h := p.Check(context.Background()); !h.OK {
+		db.Close()
+		return nil, fmt.Errorf("dbpool: health check: %w", h.Err)
+	}
+	if cfg.LeakThreshold > 0 {
+		p.every(max(cfg.LeakThreshold/4, 10*time.Millisecond), p.findLeaks)
+	}
+	if cfg.HealthInterval > 0 {
+		p.every(cfg.HealthInterval, func() { p.Check(context.Background()) })
+	}
+	return p, nil
+}
+
+// DB returns the underlying handle for code that needs it directly. Work
+// done through it is not instrumented.
+func (p *Pool) DB() *sql.DB { return p.db }
+
+func (p *Pool) every(d time.Duration, fn func()) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		t := time.NewTicker(d)
+		defer t.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-t.C:
+				fn()
+			}
+		}
+	}()
+}
+
+// Close stops the monitors, reports connections that are still held and
+// closes the database.
+func (p *Pool) Close() error {
+	close(p.stop)
+	p.wg.Wait()
+	p.mu.Lock()
+	for id, l := range p.leases {
+		p.cfg.Logger.Printf("dbpool: connection %d still held at close, acquired at:\n%s", id, formatStack(l.pcs))
+	}
+	p.mu.Unlock()
+	return p.db.Close()
+}
+
+// Check pings the database and records the result.
+func (p *Pool) Check(ctx context.Context) Health {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.HealthTimeout)
+	defer cancel()
+	start := time.Now()
+	err := p.db.PingContext(ctx)
+	h := Health{OK: err == nil, Checked: start, Latency: time.Since(start), Err: err}
+	p.m.health(h)
+	p.mu.Lock()
+	p.health = h
+	p.mu.Unlock()
+	if err != nil {
+		p.cfg.Logger.Printf("dbpool: health check failed: %v", err)
+	}
+	return h
+}
+
+// Health returns the result of the latest check.
+func (p *Pool) Health() Health {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.health
+}
+
+func (p *Pool) findLeaks() {
+	now := time.Now()
+	var leaks []Leak
+	p.mu.Lock()
+	for id, l := range p.leases {
+		if !l.reported && now.Sub(l.acquired) > p.cfg.LeakThreshold {
+			l.reported = true
+			leaks = append(leaks, Leak{ID: id, Acquired: l.acquired, Held: now.Sub(l.acquired), Stack: formatStack(l.pcs)})
+		}
+	}
+	p.mu.Unlock()
+	for _, l := range leaks {
+		p.m.leaks.Add(1)
+		p.cfg.OnLeak(l)
+	}
+}
+
+// Leaks returns the connections currently held beyond the threshold.
+func (p *Pool) Leaks() []Leak {
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var out []Leak
+	for id, l := range p.leases {
+		if held := now.Sub(l.acquired); p.cfg.LeakThreshold > 0 && held > p.cfg.LeakThreshold {
+			out = append(out, Leak{ID: id, Acquired: l.acquired, Held: held, Stack: formatStack(l.pcs)})
+		}
+	}
+	return out
+}
+
+// Conn is a connection checked out of the pool. It must be released.
+type Conn struct {
+	*sql.Conn
+	p        *Pool
+	id       uint64
+	acquired time.Time
+	once     sync.Once
+}
+
+// Acquire takes a connection from the pool, waiting if all are in use.
+func (p *Pool) Acquire(ctx context.Context) (*Conn, error) {
+	start := time.Now()
+	sc, err := p.db.Conn(ctx)
+	now := time.Now()
+	p.m.wait.observe(now.Sub(start).Seconds())
+	if err != nil {
+		p.m.acquireErrors.Add(1)
+		return nil, err
+	}
+	pcs := make([]uintptr, 32)
+	pcs = pcs[:runtime.Callers(2, pcs)]
+
+	p.mu.Lock()
+	p.nextID++
+	id := p.nextID
+	p.leases[id] = &lease{acquired: now, pcs: pcs}
+	p.mu.Unlock()
+	p.m.acquired.Add(1)
+	return &Conn{Conn: sc, p: p, id: id, acquired: now}, nil
+}
+
+// Release returns the connection to the pool. It is safe to call twice.
+func (c *Conn) Release() error {
+	var err error
+	c.once.Do(func() {
+		c.p.m.hold.observe(time.Since(c.acquired).Seconds())
+		c.p.mu.Lock()
+		delete(c.p.leases, c.id)
+		c.p.mu.Unlock()
+		err = c.Conn.Close()
+	})
+	return err
+}
+
+// Close is Release, so the embedded *sql.Conn is never closed behind the
+// pool's back.
+func (c *Conn) Close() error { return c.Release() }
+
+// ExecContext runs a statement with the pool's query timeout, retrying
+// while the database is busy.
+func (c *Conn) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	qctx, cancel := c.p.withTimeout(ctx)
+	defer cancel()
+	var res sql.Result
+	err := c.p.retry(ctx, qctx, func(ctx context.Context) (err error) {
+		res, err = c.Conn.ExecContext(ctx, query, args...)
+		return err
+	})
+	return res, err
+}
+
+// QueryContext is ExecContext for statements that return rows. The timeout
+// covers reading the rows too; it ends when they are closed.
+func (c *Conn) QueryContext(ctx context.Context, query string, args ...any) (*Rows, error) {
+	qctx, cancel := c.p.withTimeout(ctx)
+	var rows *sql.Rows
+	err := c.p.retry(ctx, qctx, func(ctx context.Context) (err error) {
+		rows, err = c.Conn.QueryContext(ctx, query, args...)
+		return err
+	})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &Rows{Rows: rows, done: cancel}, nil
+}
+
+// Rows releases whatever it holds beyond the result set when closed.
+type Rows struct {
+	*sql.Rows
+	done func()
+	once sync.Once
+}
+
+func (r *Rows) Close() error {
+	err := r.Rows.Close()
+	r.once.Do(r.done)
+	return err
+}
+
+// Exec acquires a connection, runs one statement and releases it.
+func (p *Pool) Exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	c, err := p.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Release()
+	return c.ExecContext(ctx, query, args...)
+}
+
+// Query acquires a connection that stays checked out until the rows are
+// closed.
+func (p *Pool) Query(ctx context.Context, query string, args ...any) (*Rows, error) {
+	c, err := p.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := c.QueryContext(ctx, query, args...)
+	if err != nil {
+		c.Release()
+		return nil, err
+	}
+	cancel := rows.done
+	rows.done = func() { cancel(); c.Release() }
+	return rows, nil
+}
+
+// WithConn runs fn with a connection and releases it afterwards, even if
+// fn panics.
+func (p *Pool) WithConn(ctx context.Context, fn func(*Conn) error) error {
+	c, err := p.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Release()
+	return fn(c)
+}
+
+func (p *Pool) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.cfg.QueryTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, p.cfg.QueryTimeout)
+}
+
+// retry runs fn with qctx, the caller's ctx bounded by the query timeout,
+// retrying busy errors with exponential backoff.
+func (p *Pool) retry(ctx, qctx context.Context, fn func(context.Context) error) error {
+	backoff := p.cfg.BusyBackoff
+	for attempt := 0; ; attempt++ {
+		err := fn(qctx)
+		switch {
+		case err == nil:
+			return nil
+		case qctx.Err() != nil && ctx.Err() == nil:
+			p.m.timeouts.Add(1)
+			return fmt.Errorf("dbpool: query timed out after %s: %w", p.cfg.QueryTimeout, err)
+		case !IsBusy(err) || attempt >= p.cfg.BusyRetries:
+			if IsBusy(err) {
+				p.m.busyFailures.Add(1)
+			}
+			return err
+		}
+		p.m.busyRetries.Add(1)
+		select {
+		case <-qctx.Done():
+			if ctx.Err() == nil {
+				p.m.timeouts.Add(1)
+			}
+			return fmt.Errorf("dbpool: gave up waiting for a busy database: %w", err)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// IsBusy reports whether err means the database was locked by another
+// connection. It recognises drivers whose errors have a Temporary method,
+// such as github.com/ncruces/go-sqlite3, and the "database is locked"
+// message used by the others.
+func IsBusy(err error) bool {
+	var t interface{ Temporary() bool }
+	if errors.As(err, &t) && t.Temporary() {
+		return true
+	}
+	return err != nil && strings.Contains(err.Error(), "database is locked")
+}
+
+func formatStack(pcs []uintptr) string {
+	var sb strings.Builder
+	frames := runtime.CallersFrames(pcs)
+	for {
+		f, more := frames.Next()
+		fmt.Fprintf(&sb, "\t%s\n\t\t%s:%d\n", f.Function, f.File, f.Line)
+		if !more {
+			break
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,175 @@
+package dbpool
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+)
+
+// openTest opens a pool over a fresh database file. SQLite's own busy
+// handler is off so that lock conflicts reach the pool.
+func openTest(t *testing.T, cfg Config) (*Pool, string) {
+	t.Helper()
+	dsn := "file:" + filepath.Join(t.TempDir(), "test.db") + "?_pragma=busy_timeout(0)"
+	if cfg.Logger == nil {
+		cfg.Logger = log.New(io.Discard, "", 0)
+	}
+	// the first open compiles SQLite, which is slow under the race detector
+	cfg.HealthTimeout = time.Minute
+	p, err := Open("sqlite3", dsn, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+	if _, err := p.Exec(context.Background(), "CREATE TABLE t (v INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+	return p, dsn
+}
+
+// metric returns the value of an unlabelled metric from WriteMetrics.
+func metric(t *testing.T, p *Pool, name string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := p.WriteMetrics(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if v, ok := strings.CutPrefix(line, name+" "); ok {
+			return v
+		}
+	}
+	t.Fatalf("metric %s not found", name)
+	return ""
+}
+
+// lockFor holds the write lock from a second connection for d.
+func lockFor(t *testing.T, dsn string, d time.Duration) {
+	t.Helper()
+	other, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { other.Close() })
+	tx, err := other.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec("INSERT INTO t VALUES (0)"); err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(d, func() { tx.Rollback() })
+}
+
+func TestBusyRetry(t *testing.T) {
+	ctx := context.Background()
+	p, dsn := openTest(t, Config{BusyRetries: 8, BusyBackoff: 5 * time.Millisecond})
+	lockFor(t, dsn, 50*time.Millisecond)
+	if _, err := p.Exec(ctx, "INSERT INTO t VALUES (1)"); err != nil {
+		t.Fatalf("insert while locked = %v", err)
+	}
+	if n := metric(t, p, "dbpool_busy_retries_total"); n == "0" {
+		t.Error("the insert succeeded without retrying")
+	}
+
+	q, dsn := openTest(t, Config{BusyRetries: -1})
+	lockFor(t, dsn, time.Second)
+	if _, err := q.Exec(ctx, "INSERT INTO t VALUES (1)"); !IsBusy(err) {
+		t.Errorf("insert without retries = %v, want a busy error", err)
+	}
+	if n := metric(t, q, "dbpool_busy_failures_total"); n != "1" {
+		t.Errorf("busy failures = %s", n)
+	}
+}
+
+func TestLeakReport(t *testing.T) {
+	leaks := make(chan Leak, 4)
+	p, _ := openTest(t, Config{LeakThreshold: 20 * time.Millisecond, OnLeak: func(l Leak) { leaks <- l }})
+	c, err := p.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var l Leak
+	select {
+	case l = <-leaks:
+	case <-time.After(2 * time.Second):
+		t.Fatal("held connection not reported")
+	}
+	if l.Held < 20*time.Millisecond || !strings.Contains(l.Stack, "TestLeakReport") {
+		t.Errorf("leak held %s, stack:\n%s", l.Held, l.Stack)
+	}
+	if got := p.Leaks(); len(got) != 1 || got[0].ID != l.ID {
+		t.Errorf("Leaks = %v", got)
+	}
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case again := <-leaks:
+		t.Errorf("leak reported twice: %v", again)
+	default:
+	}
+
+	c.Release()
+	c.Release() // a second release is harmless
+	if got := p.Leaks(); len(got) != 0 {
+		t.Errorf("Leaks after release = %v", got)
+	}
+	if n := metric(t, p, "dbpool_leaks_total"); n != "1" {
+		t.Errorf("leaks_total = %s", n)
+	}
+}
+
+// slowQuery runs for far longer than any test timeout.
+const slowQuery = `WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 1e9)
+	SELECT COUNT(*) FROM n`
+
+func TestQueryTimeout(t *testing.T) {
+	p, _ := openTest(t, Config{QueryTimeout: 50 * time.Millisecond})
+	ctx := context.Background()
+
+	start := time.Now()
+	rows, err := p.Query(ctx, slowQuery)
+	if err == nil {
+		for rows.Next() {
+		}
+		err = rows.Err()
+		rows.Close()
+	}
+	if err == nil || time.Since(start) > 5*time.Second {
+		t.Fatalf("slow query = %v after %s", err, time.Since(start))
+	}
+	if _, err := p.Exec(ctx, "INSERT INTO t "+strings.Replace(slowQuery, "COUNT(*)", "MAX(i)", 1)); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("slow exec = %v", err)
+	}
+	if n := metric(t, p, "dbpool_query_timeouts_total"); n == "0" {
+		t.Error("timeout not counted")
+	}
+
+	// the caller's own deadline is not the pool's timeout
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	p2, _ := openTest(t, Config{QueryTimeout: time.Minute})
+	if _, err := p2.Exec(cctx, "INSERT INTO t "+strings.Replace(slowQuery, "COUNT(*)", "MAX(i)", 1)); err == nil || strings.Contains(err.Error(), "timed out") {
+		t.Errorf("exec past the caller's deadline = %v", err)
+	}
+	if n := metric(t, p2, "dbpool_query_timeouts_total"); n != "0" {
+		t.Errorf("caller's deadline counted as %s pool timeouts", n)
+	}
+
+	// quick statements are unaffected
+	if _, err := p.Exec(ctx, "INSERT INTO t VALUES (1)"); err != nil {
+		t.Errorf("quick insert = %v", err)
+	}
+	if n := metric(t, p, "dbpool_in_use"); n != "0" {
+		t.Errorf("%s connections still checked out", n)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"Week_3/512245/turn2modela/dbpool"
+
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+)
+
+func main() {
+	addr := flag.String("addr", "", "serve /metrics on this address and keep running, e.g. :2112")
+	workers := flag.Int("workers", 8, "concurrent writers")
+	flag.Parse()
+
+	dir, err := os.MkdirTemp("", "dbpool")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// busy_timeout(0) makes SQLite fail immediately when another connection
+	// holds the write lock, so the pool's own retry does the waiting.
+	dsn := "file:" + filepath.Join(dir, "demo.db") + "?_pragma=busy_timeout(0)"
+	pool, err := dbpool.Open("sqlite3", dsn, dbpool.Config{
+		MaxOpen:        4,
+		LeakThreshold:  500 * time.Millisecond,
+		HealthInterval: time.Second,
+		QueryTimeout:   2 * time.Second,
+		BusyRetries:    8,
+		BusyBackoff:    2 * time.Millisecond,
+		OnLeak: func(l dbpool.Leak) {
+			fmt.Printf("LEAK: %v, acquired at:\n%s", l, l.Stack)
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer pool.Close()
+
+	ctx := context.Background()
+	if _, err := pool.Exec(ctx, "CREATE TABLE events (id INTEGER PRIMARY KEY, worker INTEGER, at TEXT)"); err != nil {
+		log.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < *workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				_, err := pool.Exec(ctx, "INSERT INTO events (worker, at) VALUES (?, ?)", w, time.Now().Format(time.RFC3339Nano))
+				if err != nil {
+					fmt.Printf("worker %d: %v\n", w, err)
+				}
+			}
+		}(w)
+	}
+
+	// A forgetful caller: the connection is held well past the threshold.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		holdConnection(ctx, pool, 1200*time.Millisecond)
+	}()
+	wg.Wait()
+
+	rows, err := pool.Query(ctx, "SELECT worker, COUNT(*) FROM events GROUP BY worker ORDER BY worker")
+	if err != nil {
+		log.Fatal(err)
+	}
+	for rows.Next() {
+		var w, n int
+		rows.Scan(&w, &n)
+		fmt.Printf("worker %d wrote %d rows\n", w, n)
+	}
+	rows.Close()
+
+	if h := pool.Health(); h.OK {
+		fmt.Printf("healthy, last ping %s\n", h.Latency)
+	}
+	fmt.Println()
+	pool.WriteMetrics(os.Stdout)
+
+	if *addr != "" {
+		http.Handle("/metrics", pool.MetricsHandler())
+		log.Printf("serving metrics on %s/metrics", *addr)
+		log.Fatal(http.ListenAndServe(*addr, nil))
+	}
+}
+
+func holdConnection(ctx context.Context, pool *dbpool.Pool, d time.Duration) {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	time.Sleep(d)
+	conn.Release()
+}
@@ -0,0 +1,229 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Aside reads through a Cache: values are looked up first and loaded and
+// stored on a miss. A failing cache is treated as a miss, so an outage
+// slows requests down instead of failing them.
+type Aside struct {
+	Cache Cache
+	TTL   time.Duration // default 5m
+	// Jitter spreads expiries by up to this fraction of TTL in either
+	// direction, default 0.1.
+	Jitter float64
+	Logger *log.Logger // default log.Default()
+
+	mu       sync.Mutex
+	inflight map[string]*call
+	gen      map[string]uint64 // bumped by Invalidate
+
+	hits, misses, loads, shared   atomic.Int64
+	loadErrors, cacheErrors       atomic.Int64
+	invalidations, staleDiscarded atomic.Int64
+}
+
+// call is one in-flight load shared by every caller asking for its key.
+type call struct {
+	done chan struct{}
+	val  []byte
+	err  error
+}
+
+// NewAside wraps c with the default TTL and jitter.
+func NewAside(c Cache) *Aside {
+	return &Aside{Cache: c}
+}
+
+func (a *Aside) ttl() time.Duration {
+	ttl := a.TTL
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	j := a.Jitter
+	if j == 0 {
+		j = 0.1
+	}
+	if j < 0 {
+		return ttl
+	}
+	return ttl + time.Duration((rand.Float64()*2-1)*j*float64(ttl))
+}
+
+func (a *Aside) logf(format string, args ...any) {
+	if a.Logger != nil {
+		a.Logger.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
+
+// Fetch returns the cached value for key, or calls load and caches its
+// result. Concurrent misses on the same key share a single call to load,
+// which runs detached from any one caller's cancellation.
+func Fetch[T any](ctx context.Context, a *Aside, key string, load func(context.Context) (T, error)) (T, error) {
+	var v T
+	b, err := a.get(ctx, key, func(ctx context.Context) ([]byte, error) {
+		v, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(v)
+	})
+	if err != nil {
+		return v, err
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return v, fmt.Errorf("cache: decoding %q: %w", key, err)
+	}
+	return v, nil
+}
+
+func (a *Aside) get(ctx context.Context, key string, load func(context.Context) ([]byte, error)) ([]byte, error) {
+	b, ok, err := a.Cache.Get(ctx, key)
+	if err != nil {
+		a.cacheErrors.Add(1)
+		a.logf("cache: get %q: %v", key, err)
+	}
+	if ok {
+		a.hits.Add(1)
+		return b, nil
+	}
+	a.misses.Add(1)
+
+	a.mu.Lock()
+	if a.inflight == nil {
+		a.inflight, a.gen = map[string]*call{}, map[string]uint64{}
+	}
+	if c, ok := a.inflight[key]; ok {
+		a.mu.Unlock()
+		a.shared.Add(1)
+		select {
+		case <-c.done:
+			return c.val, c.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	c := &call{done: make(chan struct{})}
+	a.inflight[key] = c
+	gen := a.gen[key]
+	a.mu.Unlock()
+
+	go a.load(context.WithoutCancel(ctx), key, gen, c, load)
+	select {
+	case <-c.done:
+		return c.val, c.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (a *Aside) load(ctx context.Context, key string, gen uint64, c *call, load func(context.Context) ([]byte, error)) {
+	defer close(c.done)
+	a.loads.Add(1)
+	func() {
+		// a panicking loader must not leave the waiters blocked
+		defer func() {
+			if r := recover(); r != nil {
+				c.err = fmt.Errorf("cache: loading %q panicked: %v", key, r)
+			}
+		}()
+		c.val, c.err = load(ctx)
+	}()
+
+	a.mu.Lock()
+	if a.inflight[key] == c {
+		delete(a.inflight, key)
+	}
+	current := a.gen[key] == gen
+	a.mu.Unlock()
+
+	if c.err != nil {
+		a.loadErrors.Add(1)
+		return
+	}
+	// An Invalidate during the load means the value may predate the write
+	// that triggered it; hand it to the waiting callers but do not cache it.
+	if !current {
+		a.staleDiscarded.Add(1)
+		return
+	}
+	if err := a.Cache.Set(ctx, key, c.val, a.ttl()); err != nil {
+		a.cacheErrors.Add(1)
+		a.logf("cache: set %q: %v", key, err)
+	}
+}
+
+// Invalidate deletes keys after a write. Loads of those keys already in
+// flight finish for their callers but are neither cached nor joined by new
+// callers. This guards against stale reads within the process; other
+// processes sharing the cache rely on the TTL for loads that race a write.
+func (a *Aside) Invalidate(ctx context.Context, keys ...string) error {
+	a.mu.Lock()
+	if a.inflight == nil {
+		a.inflight, a.gen = map[string]*call{}, map[string]uint64{}
+	}
+	for _, k := range keys {
+		a.gen[k]++
+		delete(a.inflight, k)
+	}
+	a.mu.Unlock()
+	a.invalidations.Add(int64(len(keys)))
+	err := a.Cache.Delete(ctx, keys...)
+	if err != nil {
+		a.cacheErrors.Add(1)
+	}
+	return err
+}
+
+// Stats is a snapshot of the counters.
+type Stats struct {
+	Hits, Misses, Loads, Shared   int64
+	LoadErrors, CacheErrors       int64
+	Invalidations, StaleDiscarded int64
+}
+
+// HitRatio is hits over lookups, or 0 before the first lookup.
+func (s Stats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+func (a *Aside) Stats() Stats {
+	return Stats{
+		Hits: a.hits.Load(), Misses: a.misses.Load(), Loads: a.loads.Load(), Shared: a.shared.Load(),
+		LoadErrors: a.loadErrors.Load(), CacheErrors: a.cacheErrors.Load(),
+		Invalidations: a.invalidations.Load(), StaleDiscarded: a.staleDiscarded.Load(),
+	}
+}
+
+// WriteMetrics writes the counters in the Prometheus text format.
+func (a *Aside) WriteMetrics(w io.Writer) error {
+	s := a.Stats()
+	bw := bufio.NewWriter(w)
+	counter := func(name, help string, v int64) {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, v)
+	}
+	fmt.Fprintf(bw, "# HELP cache_requests_total Cache lookups by result.\n# TYPE cache_requests_total counter\n")
+	fmt.Fprintf(bw, "cache_requests_total{result=\"hit\"} %d\ncache_requests_total{result=\"miss\"} %d\n", s.Hits, s.Misses)
+	counter("cache_loads_total", "Calls to the loader after a miss.", s.Loads)
+	counter("cache_shared_loads_total", "Misses that waited for a load already in flight.", s.Shared)
+	counter("cache_load_errors_total", "Loader calls that failed.", s.LoadErrors)
+	counter("cache_errors_total", "Cache operations that failed.", s.CacheErrors)
+	counter("cache_invalidations_total", "Keys invalidated after writes.", s.Invalidations)
+	counter("cache_stale_discarded_total", "Loaded values not cached because their key was invalidated meanwhile.", s.StaleDiscarded)
+	return bw.Flush()
+}
@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitFor polls cond until it holds or the test times out.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestConcurrentMissesShareOneLoad(t *testing.T) {
+	a := NewAside(NewMemory())
+	release := make(chan struct{})
+	var calls atomic.Int32
+	load := func(context.Context) (string, error) {
+		calls.Add(1)
+		<-release
+		return "loaded", nil
+	}
+
+	const n = 20
+	var wg sync.WaitGroup
+	results := make([]string, n)
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = Fetch(context.Background(), a, "k", load)
+		}()
+	}
+	// hold the load until every other caller has joined it
+	waitFor(t, "callers to join the load", func() bool { return a.Stats().Shared == n-1 })
+	close(release)
+	wg.Wait()
+
+	for i := range results {
+		if errs[i] != nil || results[i] != "loaded" {
+			t.Errorf("caller %d got %q, %v", i, results[i], errs[i])
+		}
+	}
+	if c := calls.Load(); c != 1 {
+		t.Errorf("loader called %d times, want 1", c)
+	}
+	if s := a.Stats(); s.Loads != 1 || s.Misses != n {
+		t.Errorf("stats = %+v", s)
+	}
+	if v, err := Fetch(context.Background(), a, "k", load); err != nil || v != "loaded" || a.Stats().Hits != 1 {
+		t.Errorf("after the load: %q, %v, %+v", v, err, a.Stats())
+	}
+}
+
+func TestLoadRacingInvalidateIsNotCached(t *testing.T) {
+	ctx := context.Background()
+	mem := NewMemory()
+	a := NewAside(mem)
+	started, release := make(chan struct{}), make(chan struct{})
+	value := "old"
+	slow := func(context.Context) (string, error) {
+		v := value
+		close(started)
+		<-release
+		return v, nil
+	}
+
+	done := make(chan string)
+	go func() {
+		v, err := Fetch(ctx, a, "k", slow)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- v
+	}()
+	<-started
+	// a write lands while the old value is being loaded
+	value = "new"
+	if err := a.Invalidate(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	joined := make(chan string)
+	go func() {
+		v, _ := Fetch(ctx, a, "k", func(context.Context) (string, error) { return "fresh", nil })
+		joined <- v
+	}()
+	if v := <-joined; v != "fresh" {
+		t.Errorf("caller after Invalidate joined the stale load and got %q", v)
+	}
+	close(release)
+	if v := <-done; v != "old" {
+		t.Errorf("caller of the racing load got %q", v)
+	}
+
+	if s := a.Stats(); s.StaleDiscarded != 1 || s.Loads != 2 {
+		t.Errorf("stats = %+v", s)
+	}
+	v, err := Fetch(ctx, a, "k", func(context.Context) (string, error) { return "reloaded", nil })
+	if err != nil || v != "fresh" {
+		t.Errorf("cached value = %q, %v; the stale load must not overwrite it", v, err)
+	}
+}
@@ -0,0 +1,95 @@
+// Package cache implements the cache-aside pattern over a pluggable store:
+// an in-process map or any server speaking the Redis protocol. Loads are
+// coalesced per key so an expired hot key costs one database query, TTLs
+// are jittered so keys written together do not expire together, and
+// invalidation wins over loads that were already in flight.
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Cache stores opaque values with a time to live.
+type Cache interface {
+	// Get returns the value and true, or false if the key is absent or
+	// expired.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores val for ttl; a ttl <= 0 means no expiry.
+	Set(ctx context.Context, key string, val []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+type memEntry struct {
+	val     []byte
+	expires time.Time // zero for no expiry
+}
+
+// Memory is a Cache held in the process. Expired entries are dropped when
+// read and swept periodically as new ones are written.
+type Memory struct {
+	mu      sync.Mutex
+	entries map[string]memEntry
+	writes  int
+	now     func() time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{entries: map[string]memEntry{}, now: time.Now}
+}
+
+func (m *Memory) Get(_ context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if !e.expires.IsZero() && !m.now().Before(e.expires) {
+		delete(m.entries, key)
+		return nil, false, nil
+	}
+	// a copy, so callers cannot change the stored value
+	return append([]byte(nil), e.val...), true, nil
+}
+
+func (m *Memory) Set(_ context.Context, key string, val []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := memEntry{val: append([]byte(nil), val...)}
+	if ttl > 0 {
+		e.expires = m.now().Add(ttl)
+	}
+	m.entries[key] = e
+	if m.writes++; m.writes%1024 == 0 {
+		m.sweep()
+	}
+	return nil
+}
+
+func (m *Memory) Delete(_ context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, k := range keys {
+		delete(m.entries, k)
+	}
+	return nil
+}
+
+// Len returns the number of stored entries, including expired ones not yet
+// swept.
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.entries)
+}
+
+func (m *Memory) sweep() {
+	now := m.now()
+	for k, e := range m.entries {
+		if !e.expires.IsZero() && !now.Before(e.expires) {
+			delete(m.entries, k)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryGetReturnsCopy(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	m.Set(ctx, "k", []byte("value"), 0)
+	b, _, _ := m.Get(ctx, "k")
+	b[0] = 'X'
+	if again, _, _ := m.Get(ctx, "k"); string(again) != "value" {
+		t.Errorf("stored value changed to %q through a returned slice", again)
+	}
+}
+
+func TestMemoryExpiry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(0, 0)
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+	m.Set(ctx, "k", []byte("v"), time.Minute)
+	if _, ok, _ := m.Get(ctx, "k"); !ok {
+		t.Fatal("fresh entry missing")
+	}
+	now = now.Add(time.Minute)
+	if _, ok, _ := m.Get(ctx, "k"); ok {
+		t.Error("expired entry returned")
+	}
+}
@@ -0,0 +1,243 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// RedisOptions configures a Redis client. Zero values select the defaults.
+type RedisOptions struct {
+	Password    string
+	DB          int
+	PoolSize    int           // idle connections kept, default 10
+	DialTimeout time.Duration // default 3s
+	IOTimeout   time.Duration // per command when ctx has no deadline, default 3s
+}
+
+// Redis is a Cache backed by a server speaking RESP2, such as Redis,
+// Valkey or KeyDB. It keeps a small pool of connections and discards any
+// connection that saw an I/O error.
+type Redis struct {
+	addr string
+	opts RedisOptions
+	idle chan *redisConn
+}
+
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// RedisError is an error reply from the server.
+type RedisError string
+
+func (e RedisError) Error() string { return "redis: " + string(e) }
+
+// NewRedis returns a client for addr. No connection is made until the
+// first command; call Ping to check the server up front.
+func NewRedis(addr string, opts RedisOptions) *Redis {
+	if opts.PoolSize <= 0 {
+		opts.PoolSize = 10
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = 3 * time.Second
+	}
+	if opts.IOTimeout <= 0 {
+		opts.IOTimeout = 3 * time.Second
+	}
+	return &Redis{addr: addr, opts: opts, idle: make(chan *redisConn, opts.PoolSize)}
+}
+
+func (c *Redis) Ping(ctx context.Context) error {
+	_, err := c.do(ctx, "PING")
+	return err
+}
+
+func (c *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	v, err := c.do(ctx, "GET", key)
+	if err != nil || v == nil {
+		return nil, false, err
+	}
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("redis: GET returned %T", v)
+	}
+	return b, true, nil
+}
+
+func (c *Redis) Set(ctx context.Context, key string, val []byte, ttl time.Duration) error {
+	args := []any{"SET", key, val}
+	if ttl > 0 {
+		args = append(args, "PX", max(ttl.Milliseconds(), 1))
+	}
+	_, err := c.do(ctx, args...)
+	return err
+}
+
+func (c *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	args := []any{"DEL"}
+	for _, k := range keys {
+		args = append(args, k)
+	}
+	_, err := c.do(ctx, args...)
+	return err
+}
+
+// Close closes the idle connections.
+func (c *Redis) Close() error {
+	for {
+		select {
+		case rc := <-c.idle:
+			rc.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+func (c *Redis) do(ctx context.Context, args ...any) (any, error) {
+	rc, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	v, err := rc.roundTrip(ctx, c.opts.IOTimeout, args...)
+	var rerr RedisError
+	if err != nil && !errors.As(err, &rerr) {
+		rc.Close()
+		return nil, err
+	}
+	c.put(rc)
+	return v, err
+}
+
+func (c *Redis) get(ctx context.Context) (*redisConn, error) {
+	select {
+	case rc := <-c.idle:
+		return rc, nil
+	default:
+	}
+	d := net.Dialer{Timeout: c.opts.DialTimeout}
+	conn, err := d.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, err
+	}
+	rc := &redisConn{Conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}
+	if c.opts.Password != "" {
+		if _, err := rc.roundTrip(ctx, c.opts.IOTimeout, "AUTH", c.opts.Password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if c.opts.DB != 0 {
+		if _, err := rc.roundTrip(ctx, c.opts.IOTimeout, "SELECT", c.opts.DB); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return rc, nil
+}
+
+func (c *Redis) put(rc *redisConn) {
+	select {
+	case c.idle <- rc:
+	default:
+		rc.Close()
+	}
+}
+
+func (rc *redisConn) roundTrip(ctx context.Context, timeout time.Duration, args ...any) (any, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(timeout)
+	}
+	rc.SetDeadline(deadline)
+	// a cancelled context interrupts blocked I/O by moving the deadline
+	stop := context.AfterFunc(ctx, func() { rc.SetDeadline(time.Now()) })
+	defer stop()
+
+	fmt.Fprintf(rc.w, "*%d\r\n", len(args))
+	for _, a := range args {
+		var b []byte
+		switch a := a.(type) {
+		case string:
+			b = []byte(a)
+		case []byte:
+			b = a
+		case int:
+			b = strconv.AppendInt(nil, int64(a), 10)
+		case int64:
+			b = strconv.AppendInt(nil, a, 10)
+		default:
+			return nil, fmt.Errorf("redis: unsupported argument type %T", a)
+		}
+		fmt.Fprintf(rc.w, "$%d\r\n", len(b))
+		rc.w.Write(b)
+		rc.w.WriteString("\r\n")
+	}
+	if err := rc.w.Flush(); err != nil {
+		return nil, ctxErr(ctx, err)
+	}
+	v, err := readReply(rc.r)
+	return v, ctxErr(ctx, err)
+}
+
+func ctxErr(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// readReply parses one RESP2 reply. Bulk strings become []byte, nil bulk
+// strings and arrays become nil, and error replies become RedisError.
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadSlice('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	body := string(line[1 : len(line)-2])
+	switch line[0] {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, RedisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		out := make([]any, n)
+		for i := range out {
+			if out[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply type %q", line[0])
+}
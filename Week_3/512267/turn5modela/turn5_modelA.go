@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"Week_3/512267/turn5modela/cache"
+
+	"github.com/gorilla/mux"
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+)
+
+type User struct {
+	ID              int     `json:"id"`
+	Name            string  `json:"name"`
+	Email           string  `json:"email"`
+	ConversionRate  float32 `json:"conversion_rate"`
+	SessionDuration float32 `json:"session_duration"`
+}
+
+// Cache keys. The list and each user are cached separately so a lookup by
+// ID does not pull the whole table into the cache.
+const usersKey = "users:all"
+
+func userKey(id int) string { return "user:" + strconv.Itoa(id) }
+
+type server struct {
+	db    *sql.DB
+	cache *cache.Aside
+	delay time.Duration // simulated query latency, to make stampedes visible
+}
+
+func (s *server) queryUsers(ctx context.Context, where string, args ...any) ([]User, error) {
+	time.Sleep(s.delay)
+	rows, err := s.db.QueryContext(ctx, "SELECT id, name, email, conversion_rate, session_duration FROM users "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	users := make([]User, 0)
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.ConversionRate, &u.SessionDuration); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (s *server) ListUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := cache.Fetch(r.Context(), s.cache, usersKey, func(ctx context.Context) ([]User, error) {
+		log.Printf("Cache miss for key: %s", usersKey)
+		return s.queryUsers(ctx, "ORDER BY id")
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, users)
+}
+
+var errNoUser = errors.New("user not found")
+
+func (s *server) GetUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["userId"])
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+	key := userKey(id)
+	user, err := cache.Fetch(r.Context(), s.cache, key, func(ctx context.Context) (User, error) {
+		log.Printf("Cache miss for key: %s", key)
+		users, err := s.queryUsers(ctx, "WHERE id = ?", id)
+		if err != nil {
+			return User{}, err
+		}
+		if len(users) == 0 {
+			return User{}, errNoUser
+		}
+		return users[0], nil
+	})
+	switch {
+	case errors.Is(err, errNoUser):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		writeJSON(w, http.StatusOK, user)
+	}
+}
+
+func (s *server) AddUser(w http.ResponseWriter, r *http.Request) {
+	var user User
+	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if user.Name == "" || user.Email == "" {
+		http.Error(w, "name and email are required", http.StatusBadRequest)
+		return
+	}
+
+	// Generate random engagement metrics
+	user.ConversionRate = float32(rand.Float64()) * 100
+	user.SessionDuration = float32(rand.Float64()) * 60
+
+	res, err := s.db.ExecContext(r.Context(),
+		"INSERT INTO users (name, email, conversion_rate, session_duration) VALUES (?, ?, ?, ?)",
+		user.Name, user.Email, user.ConversionRate, user.SessionDuration)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	id, _ := res.LastInsertId()
+	user.ID = int(id)
+
+	// The write is committed; drop every key it makes stale.
+	if err := s.cache.Invalidate(r.Context(), usersKey, userKey(user.ID)); err != nil {
+		log.Printf("Error invalidating cache: %v", err)
+	}
+	writeJSON(w, http.StatusCreated, user)
+}
+
+func (s *server) Metrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	s.cache.WriteMetrics(w)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func openDB(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS users (
+		id INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		email TEXT NOT NULL UNIQUE,
+		conversion_rate REAL,
+		session_duration REAL
+	)`)
+	if err != nil {
+		return nil, err
+	}
+	for i := 1; i <= 10; i++ {
+		_, err := db.Exec("INSERT OR IGNORE INTO users (name, email, conversion_rate, session_duration) VALUES (?, ?, ?, ?)",
+			fmt.Sprintf("User%d", i), fmt.Sprintf("user%d@example.com", i), rand.Float64()*100, rand.Float64()*60)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+func main() {
+	addr := flag.String("addr", ":8080", "listen address")
+	dbPath := flag.String("db", "users.db", "SQLite database")
+	redisAddr := flag.String("redis", "", "Redis address, e.g. localhost:6379; empty uses an in-memory cache")
+	ttl := flag.Duration("ttl", time.Minute, "cache TTL, jittered by ±10%")
+	delay := flag.Duration("delay", 0, "simulated database latency")
+	flag.Parse()
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		log.Fatalf("Error opening DB: %v", err)
+	}
+	defer db.Close()
+
+	var store cache.Cache = cache.NewMemory()
+	if *redisAddr != "" {
+		rc := cache.NewRedis(*redisAddr, cache.RedisOptions{})
+		if err := rc.Ping(context.Background()); err != nil {
+			log.Fatalf("Failed to connect to Redis: %v", err)
+		}
+		defer rc.Close()
+		store = rc
+	}
+	s := &server{db: db, cache: &cache.Aside{Cache: store, TTL: *ttl}, delay: *delay}
+
+	r := mux.NewRouter()
+	r.HandleFunc("/users", s.ListUsers).Methods(http.MethodGet)
+	r.HandleFunc("/users", s.AddUser).Methods(http.MethodPost)
+	r.HandleFunc("/users/{userId}", s.GetUser).Methods(http.MethodGet)
+	r.HandleFunc("/metrics", s.Metrics).Methods(http.MethodGet)
+
+	log.Printf("Listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, r))
+}
@@ -0,0 +1,158 @@
+package tsdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+)
+
+// DefaultCompression bounds a digest to roughly this many centroids. 100
+// keeps p99 within a fraction of a percent of the true value.
+const DefaultCompression = 100
+
+type centroid struct {
+	Mean, Weight float64
+}
+
+// Digest is a merging t-digest (Dunning & Ertl): a compact sketch of a
+// distribution that answers quantile queries with high accuracy at the
+// tails, and that can be merged, so per-minute sketches roll up into hourly
+// and daily ones without keeping the samples.
+type Digest struct {
+	compression float64
+	centroids   []centroid // sorted by mean once compressed
+	buffer      []centroid
+	total       float64
+	min, max    float64
+}
+
+func NewDigest(compression float64) *Digest {
+	if compression <= 0 {
+		compression = DefaultCompression
+	}
+	return &Digest{compression: compression, min: math.Inf(1), max: math.Inf(-1)}
+}
+
+// Add records one value.
+func (d *Digest) Add(v float64) { d.add(centroid{v, 1}) }
+
+func (d *Digest) add(c centroid) {
+	d.buffer = append(d.buffer, c)
+	d.total += c.Weight
+	d.min, d.max = math.Min(d.min, c.Mean), math.Max(d.max, c.Mean)
+	if len(d.buffer) >= int(d.compression)*5 {
+		d.compress()
+	}
+}
+
+// Merge folds other into d.
+func (d *Digest) Merge(other *Digest) {
+	if other == nil || other.total == 0 {
+		return
+	}
+	other.compress()
+	for _, c := range other.centroids {
+		d.add(c)
+	}
+	d.min, d.max = math.Min(d.min, other.min), math.Max(d.max, other.max)
+}
+
+// Count is the number of values added, including merged ones.
+func (d *Digest) Count() float64 { return d.total }
+
+// k1 is the arcsine scale function; it makes centroids small near q=0 and
+// q=1, which is where the accuracy of p99 comes from.
+func (d *Digest) k(q float64) float64 {
+	return d.compression / (2 * math.Pi) * math.Asin(2*q-1)
+}
+
+func (d *Digest) compress() {
+	if len(d.buffer) == 0 {
+		return
+	}
+	all := append(d.centroids, d.buffer...)
+	d.buffer = d.buffer[:0]
+	sort.Slice(all, func(i, j int) bool { return all[i].Mean < all[j].Mean })
+
+	merged := all[:1]
+	cur := &merged[0]
+	var before float64 // weight of the centroids left of cur
+	kLeft := d.k(0)
+	for _, c := range all[1:] {
+		if d.k((before+cur.Weight+c.Weight)/d.total)-kLeft <= 1 {
+			w := cur.Weight + c.Weight
+			cur.Mean += (c.Mean - cur.Mean) * c.Weight / w
+			cur.Weight = w
+			continue
+		}
+		before += cur.Weight
+		kLeft = d.k(before / d.total)
+		merged = append(merged, c)
+		cur = &merged[len(merged)-1]
+	}
+	d.centroids = append([]centroid(nil), merged...)
+}
+
+// Quantile estimates the value at q in [0, 1]. It returns NaN for an empty
+// digest.
+func (d *Digest) Quantile(q float64) float64 {
+	d.compress()
+	cs := d.centroids
+	if len(cs) == 0 {
+		return math.NaN()
+	}
+	if q <= 0 {
+		return d.min
+	}
+	if q >= 1 {
+		return d.max
+	}
+	if len(cs) == 1 {
+		return cs[0].Mean
+	}
+	index := q * d.total
+	first, last := cs[0], cs[len(cs)-1]
+	if index < first.Weight/2 {
+		return d.min + (first.Mean-d.min)*index/(first.Weight/2)
+	}
+	if index > d.total-last.Weight/2 {
+		return last.Mean + (d.max-last.Mean)*(index-(d.total-last.Weight/2))/(last.Weight/2)
+	}
+	// interpolate between the centres of the two centroids around index
+	pos := first.Weight / 2
+	for i := 0; i < len(cs)-1; i++ {
+		gap := (cs[i].Weight + cs[i+1].Weight) / 2
+		if index <= pos+gap {
+			return cs[i].Mean + (cs[i+1].Mean-cs[i].Mean)*(index-pos)/gap
+		}
+		pos += gap
+	}
+	return last.Mean
+}
+
+// MarshalBinary encodes the compressed digest for storage.
+func (d *Digest) MarshalBinary() ([]byte, error) {
+	d.compress()
+	b := make([]byte, 0, 8*(4+2*len(d.centroids)))
+	for _, f := range []float64{d.compression, d.total, d.min, d.max} {
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(f))
+	}
+	for _, c := range d.centroids {
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(c.Mean))
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(c.Weight))
+	}
+	return b, nil
+}
+
+func (d *Digest) UnmarshalBinary(b []byte) error {
+	if len(b) < 32 || len(b)%16 != 0 {
+		return errors.New("tsdb: malformed digest")
+	}
+	f := func(i int) float64 { return math.Float64frombits(binary.LittleEndian.Uint64(b[8*i:])) }
+	*d = Digest{compression: f(0), total: f(1), min: f(2), max: f(3)}
+	for i := 4; i < len(b)/8; i += 2 {
+		d.centroids = append(d.centroids, centroid{f(i), f(i + 1)})
+	}
+	return nil
+}
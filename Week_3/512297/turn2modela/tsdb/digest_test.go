@@ -0,0 +1,95 @@
+package tsdb
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// rank is the fraction of values at or below v.
+func rank(values []float64, v float64) float64 {
+	n := 0
+	for _, x := range values {
+		if x <= v {
+			n++
+		}
+	}
+	return float64(n) / float64(len(values))
+}
+
+func TestDigestMergeAccuracy(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	// latency-like: mostly fast with a long tail
+	values := make([]float64, 100000)
+	for i := range values {
+		values[i] = math.Exp(rng.NormFloat64()) * 50
+	}
+
+	whole := NewDigest(0)
+	for _, v := range values {
+		whole.Add(v)
+	}
+	// per-minute digests merged into an hourly one, passing through the
+	// stored encoding as the rollups do
+	merged := NewDigest(0)
+	for start := 0; start < len(values); start += len(values) / 60 {
+		part := NewDigest(0)
+		for _, v := range values[start:min(start+len(values)/60, len(values))] {
+			part.Add(v)
+		}
+		b, err := part.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var stored Digest
+		if err := stored.UnmarshalBinary(b); err != nil {
+			t.Fatal(err)
+		}
+		merged.Merge(&stored)
+	}
+
+	if merged.Count() != float64(len(values)) {
+		t.Fatalf("merged count = %v", merged.Count())
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	for _, q := range []float64{.01, .5, .9, .95, .99, .999} {
+		for name, d := range map[string]*Digest{"whole": whole, "merged": merged} {
+			got := d.Quantile(q)
+			// rank error shrinks towards the tails
+			tol := 0.005
+			if q <= .01 || q >= .99 {
+				tol = 0.001
+			}
+			if r := rank(values, got); math.Abs(r-q) > tol {
+				t.Errorf("%s p%v = %v (exact %v) sits at rank %.4f", name, q*100, got, sorted[int(q*float64(len(sorted)))], r)
+			}
+		}
+	}
+	if merged.Quantile(0) != sorted[0] || merged.Quantile(1) != sorted[len(sorted)-1] {
+		t.Error("min or max lost in the merge")
+	}
+	if n := len(merged.centroids); n > 2*DefaultCompression {
+		t.Errorf("merged digest has %d centroids", n)
+	}
+}
+
+func TestDigestEdges(t *testing.T) {
+	d := NewDigest(0)
+	if !math.IsNaN(d.Quantile(.5)) {
+		t.Error("empty digest has a median")
+	}
+	d.Add(42)
+	if d.Quantile(.01) != 42 || d.Quantile(.99) != 42 {
+		t.Error("single value digest")
+	}
+	d.Merge(NewDigest(0))
+	if d.Count() != 1 {
+		t.Error("merging an empty digest changed the count")
+	}
+	var bad Digest
+	if err := bad.UnmarshalBinary(make([]byte, 20)); err == nil {
+		t.Error("truncated digest decoded")
+	}
+}
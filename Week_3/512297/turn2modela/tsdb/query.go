@@ -0,0 +1,152 @@
+package tsdb
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// MaxPoints bounds the length of a queried series.
+const MaxPoints = 10000
+
+// Point is one step of a series. Statistics are nil for empty steps so
+// that gaps stay visible to the caller.
+type Point struct {
+	Time  time.Time `json:"time"`
+	Count int64     `json:"count"`
+	Sum   *float64  `json:"sum"`
+	Min   *float64  `json:"min"`
+	Max   *float64  `json:"max"`
+	Avg   *float64  `json:"avg"`
+	P50   *float64  `json:"p50"`
+	P95   *float64  `json:"p95"`
+	P99   *float64  `json:"p99"`
+}
+
+// Series is the answer to a query.
+type Series struct {
+	Type   string        `json:"type"`
+	From   time.Time     `json:"from"`
+	To     time.Time     `json:"to"`
+	Step   time.Duration `json:"-"`
+	Source string        `json:"source"` // "raw" or the rollup resolution used
+	Points []Point       `json:"points"`
+}
+
+// Query returns the series for typ with one point per step, aligned so
+// that every point starts at a multiple of step. It reads from the
+// coarsest rollup that step is a multiple of, and from raw samples for
+// steps finer than every rollup.
+func (s *Store) Query(ctx context.Context, typ string, from, to time.Time, step time.Duration) (Series, error) {
+	if step < time.Second || step%time.Second != 0 {
+		return Series{}, fmt.Errorf("tsdb: step must be a whole number of seconds")
+	}
+	if !to.After(from) {
+		return Series{}, fmt.Errorf("tsdb: empty range")
+	}
+	start := time.Unix(bucketOf(from, step), 0).UTC()
+	n := int((to.Sub(start) + step - 1) / step)
+	if n > MaxPoints {
+		return Series{}, fmt.Errorf("tsdb: %d points requested, at most %d allowed; use a larger step", n, MaxPoints)
+	}
+
+	res := -1
+	for i, r := range s.opts.Resolutions {
+		if step%r.Step == 0 {
+			res = i
+		}
+	}
+	if res < 0 && time.Since(from) > s.opts.RawRetention {
+		return Series{}, fmt.Errorf("tsdb: raw samples are kept for %s; use a step that is a multiple of %s for older data",
+			s.opts.RawRetention, s.opts.Resolutions[0].Step)
+	}
+
+	if res >= 0 && res+1 < len(s.opts.Resolutions) && time.Since(from) > s.opts.Resolutions[res].Retention {
+		r := s.opts.Resolutions[res]
+		return Series{}, fmt.Errorf("tsdb: %s rollups are kept for %s; use a step that is a multiple of %s for older data",
+			r.Name, r.Retention, s.opts.Resolutions[res+1].Step)
+	}
+
+	buckets := make([]*rollup, n)
+	at := func(unix int64) *rollup {
+		i := int((unix - start.Unix()) / int64(step/time.Second))
+		if i < 0 || i >= n {
+			return nil
+		}
+		if buckets[i] == nil {
+			buckets[i] = s.newRollup()
+		}
+		return buckets[i]
+	}
+
+	series := Series{Type: typ, From: start, To: to, Step: step}
+	if res < 0 {
+		series.Source = "raw"
+		rows, err := s.db.QueryContext(ctx, "SELECT ts, value FROM samples WHERE type = ? AND ts >= ? AND ts < ?",
+			typ, start.UnixMilli(), to.UnixMilli())
+		if err != nil {
+			return Series{}, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var ts int64
+			var v float64
+			if err := rows.Scan(&ts, &v); err != nil {
+				return Series{}, err
+			}
+			if b := at(bucketOf(time.UnixMilli(ts), step)); b != nil {
+				b.add(v)
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return Series{}, err
+		}
+	} else {
+		r := s.opts.Resolutions[res]
+		series.Source = r.Name
+		rows, err := s.db.QueryContext(ctx, "SELECT bucket, count, sum, min, max, digest FROM rollup_"+r.Name+" WHERE type = ? AND bucket >= ? AND bucket < ?",
+			typ, start.Unix(), to.Unix())
+		if err != nil {
+			return Series{}, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var bucket int64
+			var blob []byte
+			part := s.newRollup()
+			if err := rows.Scan(&bucket, &part.count, &part.sum, &part.min, &part.max, &blob); err != nil {
+				return Series{}, err
+			}
+			if err := part.digest.UnmarshalBinary(blob); err != nil {
+				return Series{}, err
+			}
+			if b := at(bucketOf(time.Unix(bucket, 0), step)); b != nil {
+				b.merge(part)
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return Series{}, err
+		}
+	}
+
+	series.Points = make([]Point, n)
+	for i := range series.Points {
+		p := Point{Time: start.Add(time.Duration(i) * step)}
+		if b := buckets[i]; b != nil && b.count > 0 {
+			p.Count = b.count
+			p.Sum, p.Min, p.Max = ptr(b.sum), ptr(b.min), ptr(b.max)
+			p.Avg = ptr(b.sum / float64(b.count))
+			p.P50, p.P95, p.P99 = ptr(b.digest.Quantile(.5)), ptr(b.digest.Quantile(.95)), ptr(b.digest.Quantile(.99))
+		}
+		series.Points[i] = p
+	}
+	return series, nil
+}
+
+func ptr(f float64) *float64 {
+	if math.IsNaN(f) {
+		return nil
+	}
+	return &f
+}
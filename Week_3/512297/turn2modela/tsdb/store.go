@@ -0,0 +1,323 @@
+// Package tsdb stores request metrics as raw samples plus rollups at
+// fixed resolutions in SQLite. Samples are written in batches; every batch
+// also updates the 1-minute, 1-hour and 1-day rollups (count, sum, min, max
+// and a t-digest for percentiles), so queries over long ranges never touch
+// raw data. Each resolution has its own retention.
+package tsdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+// Sample is one observation of a metric.
+type Sample struct {
+	Type   string
+	Value  float64
+	Time   time.Time
+	Params string
+}
+
+// Resolution is a rollup granularity and how long it is kept.
+type Resolution struct {
+	Name      string // table suffix, e.g. "1m"
+	Step      time.Duration
+	Retention time.Duration
+}
+
+// DefaultResolutions keep minutes for a week, hours for 90 days and days
+// for two years.
+var DefaultResolutions = []Resolution{
+	{"1m", time.Minute, 7 * 24 * time.Hour},
+	{"1h", time.Hour, 90 * 24 * time.Hour},
+	{"1d", 24 * time.Hour, 730 * 24 * time.Hour},
+}
+
+// Options configures a Store. Zero values select the defaults.
+type Options struct {
+	BatchSize     int           // samples per write, default 1000
+	FlushInterval time.Duration // longest a sample waits to be written, default 1s
+	RawRetention  time.Duration // default 24h
+	Resolutions   []Resolution  // finest first, default DefaultResolutions
+	Compression   float64       // t-digest compression, default 100
+	PruneInterval time.Duration // default 10m
+	Logger        *log.Logger   // default log.Default()
+}
+
+// ErrClosed is returned by Add after Close.
+var ErrClosed = errors.New("tsdb: store closed")
+
+// Store is safe for concurrent use.
+type Store struct {
+	db   *sql.DB
+	opts Options
+
+	mu      sync.Mutex
+	pending []Sample
+	closed  bool
+	kick    chan struct{}
+	writeMu sync.Mutex // serialises batch writes
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Open creates the tables if needed and starts the background flusher and
+// pruner.
+func Open(db *sql.DB, opts Options) (*Store, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1000
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	if opts.RawRetention <= 0 {
+		opts.RawRetention = 24 * time.Hour
+	}
+	if len(opts.Resolutions) == 0 {
+		opts.Resolutions = DefaultResolutions
+	}
+	if opts.Compression <= 0 {
+		opts.Compression = DefaultCompression
+	}
+	if opts.PruneInterval <= 0 {
+		opts.PruneInterval = 10 * time.Minute
+	}
+	if opts.Logger == nil {
+		opts.Logger = log.Default()
+	}
+
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS samples (
+			type TEXT NOT NULL,
+			ts INTEGER NOT NULL, -- unix milliseconds
+			value REAL NOT NULL,
+			params TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE INDEX IF NOT EXISTS samples_type_ts ON samples (type, ts)`,
+	}
+	for _, r := range opts.Resolutions {
+		stmts = append(stmts, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS rollup_%s (
+			type TEXT NOT NULL,
+			bucket INTEGER NOT NULL, -- unix seconds, aligned to the step
+			count INTEGER NOT NULL,
+			sum REAL NOT NULL,
+			min REAL NOT NULL,
+			max REAL NOT NULL,
+			digest BLOB NOT NULL,
+			PRIMARY KEY (type, bucket)
+		) WITHOUT ROWID`, r.Name))
+	}
+	for _, s := range stmts {
+		if _, err := db.Exec(s); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &Store{db: db, opts: opts, kick: make(chan struct{}, 1), stop: make(chan struct{}), done: make(chan struct{})}
+	go s.run()
+	return s, nil
+}
+
+// Add queues a sample for the next batch. It never blocks on the database.
+func (s *Store) Add(sample Sample) error {
+	if sample.Type == "" || math.IsNaN(sample.Value) || math.IsInf(sample.Value, 0) {
+		return fmt.Errorf("tsdb: invalid sample %+v", sample)
+	}
+	if sample.Time.IsZero() {
+		sample.Time = time.Now()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	s.pending = append(s.pending, sample)
+	if len(s.pending) >= s.opts.BatchSize {
+		select {
+		case s.kick <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (s *Store) run() {
+	defer close(s.done)
+	flush := time.NewTicker(s.opts.FlushInterval)
+	defer flush.Stop()
+	prune := time.NewTicker(s.opts.PruneInterval)
+	defer prune.Stop()
+	ctx := context.Background()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-s.kick:
+		case <-flush.C:
+		case <-prune.C:
+			if err := s.Prune(ctx, time.Now()); err != nil {
+				s.opts.Logger.Printf("tsdb: prune: %v", err)
+			}
+			continue
+		}
+		if err := s.Flush(ctx); err != nil {
+			s.opts.Logger.Printf("tsdb: flush: %v", err)
+		}
+	}
+}
+
+// Flush writes every queued sample. On failure the batch is put back so
+// the next flush retries it.
+func (s *Store) Flush(ctx context.Context) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	for {
+		s.mu.Lock()
+		n := min(len(s.pending), s.opts.BatchSize)
+		batch := s.pending[:n:n]
+		s.pending = s.pending[n:]
+		s.mu.Unlock()
+		if n == 0 {
+			return nil
+		}
+		if err := s.write(ctx, batch); err != nil {
+			s.mu.Lock()
+			s.pending = append(batch, s.pending...)
+			s.mu.Unlock()
+			return err
+		}
+	}
+}
+
+// Close writes what is queued and stops the background work. The *sql.DB
+// is left open.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+	close(s.stop)
+	<-s.done
+	return s.Flush(context.Background())
+}
+
+type rollupKey struct {
+	res    int
+	typ    string
+	bucket int64
+}
+
+type rollup struct {
+	count    int64
+	sum      float64
+	min, max float64
+	digest   *Digest
+}
+
+func (r *rollup) add(v float64) {
+	r.count++
+	r.sum += v
+	r.min, r.max = math.Min(r.min, v), math.Max(r.max, v)
+	r.digest.Add(v)
+}
+
+func (r *rollup) merge(o *rollup) {
+	r.count += o.count
+	r.sum += o.sum
+	r.min, r.max = math.Min(r.min, o.min), math.Max(r.max, o.max)
+	r.digest.Merge(o.digest)
+}
+
+func (s *Store) newRollup() *rollup {
+	return &rollup{min: math.Inf(1), max: math.Inf(-1), digest: NewDigest(s.opts.Compression)}
+}
+
+// write stores a batch and folds it into every rollup in one transaction,
+// so the raw data and the rollups never disagree.
+func (s *Store) write(ctx context.Context, batch []Sample) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	ins, err := tx.PrepareContext(ctx, "INSERT INTO samples (type, ts, value, params) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer ins.Close()
+	agg := map[rollupKey]*rollup{}
+	for _, smp := range batch {
+		if _, err := ins.ExecContext(ctx, smp.Type, smp.Time.UnixMilli(), smp.Value, smp.Params); err != nil {
+			return err
+		}
+		for i, res := range s.opts.Resolutions {
+			k := rollupKey{i, smp.Type, bucketOf(smp.Time, res.Step)}
+			r, ok := agg[k]
+			if !ok {
+				r = s.newRollup()
+				agg[k] = r
+			}
+			r.add(smp.Value)
+		}
+	}
+
+	for k, r := range agg {
+		table := "rollup_" + s.opts.Resolutions[k.res].Name
+		old := s.newRollup()
+		var blob []byte
+		err := tx.QueryRowContext(ctx, "SELECT count, sum, min, max, digest FROM "+table+" WHERE type = ? AND bucket = ?", k.typ, k.bucket).
+			Scan(&old.count, &old.sum, &old.min, &old.max, &blob)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+		case err != nil:
+			return err
+		default:
+			if err := old.digest.UnmarshalBinary(blob); err != nil {
+				return err
+			}
+			r.merge(old)
+		}
+		if blob, err = r.digest.MarshalBinary(); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "INSERT OR REPLACE INTO "+table+" (type, bucket, count, sum, min, max, digest) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			k.typ, k.bucket, r.count, r.sum, r.min, r.max, blob)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// bucketOf aligns t down to step, in unix seconds. Steps of a day or more
+// align to UTC midnight.
+func bucketOf(t time.Time, step time.Duration) int64 {
+	sec := int64(step / time.Second)
+	u := t.Unix()
+	return u - ((u%sec)+sec)%sec
+}
+
+// Prune deletes raw samples and rollup buckets past their retention.
+func (s *Store) Prune(ctx context.Context, now time.Time) error {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM samples WHERE ts < ?", now.Add(-s.opts.RawRetention).UnixMilli()); err != nil {
+		return err
+	}
+	for _, r := range s.opts.Resolutions {
+		cutoff := bucketOf(now.Add(-r.Retention), r.Step)
+		if _, err := s.db.ExecContext(ctx, "DELETE FROM rollup_"+r.Name+" WHERE bucket < ?", cutoff); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package tsdb
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"testing"
+	"time"
+
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+)
+
+// openTest returns a store over an in-memory database. The background
+// flusher is slowed down so that tests decide when batches are written.
+func openTest(t *testing.T) *Store {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every connection would get its own in-memory database
+	db.SetMaxOpenConns(1)
+	s, err := Open(db, Options{FlushInterval: time.Hour, PruneInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		s.Close()
+		db.Close()
+	})
+	return s
+}
+
+func add(t *testing.T, s *Store, typ string, at time.Time, values ...float64) {
+	t.Helper()
+	for _, v := range values {
+		if err := s.Add(Sample{Type: typ, Value: v, Time: at}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestBucketOf(t *testing.T) {
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		t    time.Time
+		step time.Duration
+		want int64
+	}{
+		{time.Unix(125, 0), time.Minute, 120},
+		{time.Unix(120, 0), time.Minute, 120},
+		{time.Unix(119, 999e6), time.Minute, 60},
+		{time.Unix(-1, 0), time.Minute, -60},
+		{time.Unix(3599, 0), time.Hour, 0},
+		{day.Add(13*time.Hour + 5*time.Second), 24 * time.Hour, day.Unix()},
+		// the zone does not move day buckets away from UTC midnight
+		{day.Add(23 * time.Hour).In(time.FixedZone("UTC+5", 5*3600)), 24 * time.Hour, day.Unix()},
+		{time.Unix(95, 0), 30 * time.Second, 90},
+	}
+	for _, c := range cases {
+		if got := bucketOf(c.t, c.step); got != c.want {
+			t.Errorf("bucketOf(%v, %s) = %d, want %d", c.t.UTC(), c.step, got, c.want)
+		}
+	}
+}
+
+func TestQuerySource(t *testing.T) {
+	s := openTest(t)
+	ctx := context.Background()
+	to := time.Now()
+	from := to.Add(-time.Hour)
+	cases := []struct {
+		step time.Duration
+		want string
+	}{
+		{time.Second, "raw"},
+		{30 * time.Second, "raw"},
+		{90 * time.Second, "raw"},
+		{time.Minute, "1m"},
+		{5 * time.Minute, "1m"},
+		{time.Hour, "1h"},
+		{3 * time.Hour, "1h"},
+		{24 * time.Hour, "1d"},
+		{48 * time.Hour, "1d"},
+	}
+	for _, c := range cases {
+		series, err := s.Query(ctx, "api", from, to, c.step)
+		if err != nil {
+			t.Errorf("step %s: %v", c.step, err)
+			continue
+		}
+		if series.Source != c.want {
+			t.Errorf("step %s read from %s, want %s", c.step, series.Source, c.want)
+		}
+	}
+
+	// raw samples are gone after a day; rollups cover older ranges
+	old := to.Add(-48 * time.Hour)
+	if _, err := s.Query(ctx, "api", old, old.Add(time.Hour), 30*time.Second); err == nil {
+		t.Error("raw query past the raw retention accepted")
+	}
+	if series, err := s.Query(ctx, "api", old, old.Add(time.Hour), time.Minute); err != nil || series.Source != "1m" {
+		t.Errorf("1m query within its retention = %+v, %v", series.Source, err)
+	}
+	// minutes are kept for a week; older minute-aligned queries point at hours
+	old = to.Add(-10 * 24 * time.Hour)
+	if _, err := s.Query(ctx, "api", old, old.Add(time.Hour), time.Minute); err == nil {
+		t.Error("1m query past the 1m retention accepted")
+	}
+	if _, err := s.Query(ctx, "api", from, to, 1500*time.Millisecond); err == nil {
+		t.Error("fractional step accepted")
+	}
+	if _, err := s.Query(ctx, "api", to, from, time.Minute); err == nil {
+		t.Error("reversed range accepted")
+	}
+}
+
+func TestQueryAlignment(t *testing.T) {
+	s := openTest(t)
+	ctx := context.Background()
+	base := time.Now().Truncate(time.Hour).Add(-2 * time.Hour)
+	add(t, s, "api", base.Add(10*time.Second), 1)
+	add(t, s, "api", base.Add(70*time.Second), 2, 4)
+	add(t, s, "api", base.Add(130*time.Second), 8)
+	add(t, s, "other", base.Add(70*time.Second), 100)
+	if err := s.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// the range starts mid-minute; the first point still starts on the minute
+	series, err := s.Query(ctx, "api", base.Add(30*time.Second), base.Add(3*time.Minute), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !series.From.Equal(base) || len(series.Points) != 3 {
+		t.Fatalf("from %v with %d points, want %v with 3", series.From, len(series.Points), base)
+	}
+	want := []struct {
+		count         int64
+		sum, min, max float64
+	}{{1, 1, 1, 1}, {2, 6, 2, 4}, {1, 8, 8, 8}}
+	for i, p := range series.Points {
+		w := want[i]
+		if !p.Time.Equal(base.Add(time.Duration(i) * time.Minute)) {
+			t.Errorf("point %d at %v", i, p.Time)
+		}
+		if p.Count != w.count || *p.Sum != w.sum || *p.Min != w.min || *p.Max != w.max || *p.Avg != w.sum/float64(w.count) {
+			t.Errorf("point %d = count %d sum %v min %v max %v", i, p.Count, *p.Sum, *p.Min, *p.Max)
+		}
+	}
+
+	// raw steps align the same way, and empty steps have no statistics
+	series, err = s.Query(ctx, "api", base.Add(40*time.Second), base.Add(3*time.Minute), 30*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if series.Source != "raw" || !series.From.Equal(base.Add(30*time.Second)) || len(series.Points) != 5 {
+		t.Fatalf("raw series from %v with %d points", series.From, len(series.Points))
+	}
+	counts := []int64{0, 2, 0, 1, 0}
+	for i, p := range series.Points {
+		if p.Count != counts[i] {
+			t.Errorf("raw point %d count = %d, want %d", i, p.Count, counts[i])
+		}
+		if p.Count == 0 && (p.Sum != nil || p.P99 != nil) {
+			t.Errorf("empty raw point %d has statistics", i)
+		}
+	}
+
+	// a coarser step merges the minute rollups it covers
+	series, err = s.Query(ctx, "api", base, base.Add(time.Hour), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := series.Points[0]; series.Source != "1h" || p.Count != 4 || *p.Sum != 15 || *p.Min != 1 || *p.Max != 8 {
+		t.Errorf("hour point = %s %+v", series.Source, p)
+	}
+}
+
+func TestRollupsMergeAcrossBatches(t *testing.T) {
+	s := openTest(t)
+	ctx := context.Background()
+	base := time.Now().Truncate(time.Hour).Add(-time.Hour)
+	var all []float64
+	// ten batches into the same minute bucket exercise the read-merge-write
+	// of stored digests
+	for b := 0; b < 10; b++ {
+		var batch []float64
+		for i := 0; i < 1000; i++ {
+			v := float64((b*1000+i)*7919%10000) / 10
+			batch = append(batch, v)
+		}
+		add(t, s, "api", base.Add(time.Duration(b)*time.Second), batch...)
+		if err := s.Flush(ctx); err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, batch...)
+	}
+
+	for _, step := range []time.Duration{time.Minute, time.Hour, 24 * time.Hour} {
+		series, err := s.Query(ctx, "api", base, base.Add(time.Minute), step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var p Point
+		for _, pt := range series.Points {
+			if pt.Count > 0 {
+				p = pt
+			}
+		}
+		if p.Count != int64(len(all)) {
+			t.Fatalf("%s: count = %d, want %d", step, p.Count, len(all))
+		}
+		for _, q := range []struct {
+			q   float64
+			got *float64
+		}{{.5, p.P50}, {.95, p.P95}, {.99, p.P99}} {
+			if r := rank(all, *q.got); math.Abs(r-q.q) > 0.005 {
+				t.Errorf("%s: p%v = %v sits at rank %.4f", step, q.q*100, *q.got, r)
+			}
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"Week_3/512297/turn2modela/tsdb"
+
+	"github.com/gin-gonic/gin"
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+)
+
+// Metric types
+const (
+	DistinctParamCount = "distinct_param_count"
+	ParamFrequency     = "param_frequency"
+	ErrorRate          = "error_rate"
+	ParamLength        = "param_length"
+	ResponseTime       = "response_time"
+	ServerLoad         = "server_load"
+)
+
+func validType(t string) bool {
+	switch t {
+	case DistinctParamCount, ParamFrequency, ErrorRate, ParamLength, ResponseTime, ServerLoad:
+		return true
+	}
+	return false
+}
+
+// Metric struct
+type Metric struct {
+	Type   string  `json:"type"`
+	Value  float64 `json:"value"`
+	Time   string  `json:"time"` // ISO 8601 format; empty means now
+	Params string  `json:"params"`
+}
+
+func (m Metric) sample() (tsdb.Sample, error) {
+	if !validType(m.Type) {
+		return tsdb.Sample{}, fmt.Errorf("invalid metric type %q", m.Type)
+	}
+	s := tsdb.Sample{Type: m.Type, Value: m.Value, Params: m.Params}
+	if m.Time != "" {
+		t, err := time.Parse(time.RFC3339Nano, m.Time)
+		if err != nil {
+			return tsdb.Sample{}, fmt.Errorf("invalid time %q: %v", m.Time, err)
+		}
+		s.Time = t
+	}
+	return s, nil
+}
+
+var store *tsdb.Store
+
+// collectMetric accepts a single metric or an array of them.
+func collectMetric(c *gin.Context) {
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var metrics []Metric
+	if trimmed := strings.TrimSpace(string(body)); strings.HasPrefix(trimmed, "[") {
+		err = json.Unmarshal(body, &metrics)
+	} else {
+		metrics = make([]Metric, 1)
+		err = json.Unmarshal(body, &metrics[0])
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	samples := make([]tsdb.Sample, len(metrics))
+	for i, m := range metrics {
+		if samples[i], err = m.sample(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "index": i})
+			return
+		}
+	}
+	for _, s := range samples {
+		if err := store.Add(s); err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusAccepted, gin.H{"accepted": len(samples)})
+}
+
+// queryMetrics serves GET /metrics/query?type=&from=&to=&step=. Times are
+// RFC 3339, unix seconds, "now" or an offset from now such as "-6h"; step
+// is a Go duration or a number of days such as "1d".
+func queryMetrics(c *gin.Context) {
+	now := time.Now()
+	typ := c.Query("type")
+	if !validType(typ) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid metric type"})
+		return
+	}
+	to, err := parseTime(c.DefaultQuery("to", "now"), now)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to: " + err.Error()})
+		return
+	}
+	from, err := parseTime(c.DefaultQuery("from", "-1h"), now)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from: " + err.Error()})
+		return
+	}
+	step, err := parseStep(c.DefaultQuery("step", "1m"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "step: " + err.Error()})
+		return
+	}
+	series, err := store.Query(c.Request.Context(), typ, from, to, step)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"type": series.Type, "from": series.From, "to": series.To,
+		"step": step.String(), "source": series.Source, "points": series.Points,
+	})
+}
+
+func parseTime(s string, now time.Time) (time.Time, error) {
+	switch {
+	case s == "now":
+		return now, nil
+	case strings.HasPrefix(s, "-"):
+		d, err := parseStep(s[1:])
+		return now.Add(-d), err
+	}
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func parseStep(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		return time.Duration(n) * 24 * time.Hour, err
+	}
+	return time.ParseDuration(s)
+}
+
+// recordResponseTime feeds the store with the latency of every request it
+// serves, in milliseconds.
+func recordResponseTime(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+	store.Add(tsdb.Sample{
+		Type:   ResponseTime,
+		Value:  float64(time.Since(start).Microseconds()) / 1000,
+		Time:   start,
+		Params: c.Request.URL.RawQuery,
+	})
+}
+
+// backfill generates synthetic history so the rollups have something to
+// show: log-normal response times and a daily server-load cycle.
+func backfill(d time.Duration) {
+	now := time.Now()
+	n := 0
+	for t := now.Add(-d); t.Before(now); t = t.Add(5 * time.Second) {
+		store.Add(tsdb.Sample{Type: ResponseTime, Value: math.Exp(rand.NormFloat64()*0.6 + 3), Time: t})
+		load := 0.5 + 0.4*math.Sin(2*math.Pi*float64(t.Hour())/24) + rand.Float64()*0.1
+		store.Add(tsdb.Sample{Type: ServerLoad, Value: load, Time: t})
+		n += 2
+	}
+	if err := store.Flush(context.Background()); err != nil {
+		log.Fatalf("Error writing backfill: %v", err)
+	}
+	log.Printf("Backfilled %d samples over %s", n, d)
+}
+
+func main() {
+	addr := flag.String("addr", ":8080", "listen address")
+	dbPath := flag.String("db", "./metrics_ts.db", "SQLite database")
+	history := flag.Duration("backfill", 0, "generate this much synthetic history at startup, e.g. 48h")
+	flag.Parse()
+
+	db, err := sql.Open("sqlite3", *dbPath)
+	if err != nil {
+		log.Fatalf("Error opening database: %v", err)
+	}
+	defer db.Close()
+	store, err = tsdb.Open(db, tsdb.Options{})
+	if err != nil {
+		log.Fatalf("Error creating tables: %v", err)
+	}
+	if *history > 0 {
+		backfill(*history)
+	}
+
+	r := gin.Default()
+	r.Use(recordResponseTime)
+	r.POST("/metrics", collectMetric)
+	r.GET("/metrics/query", queryMetrics)
+
+	srv := &http.Server{Addr: *addr, Handler: r}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	<-signals
+	log.Println("Shutting down gracefully...")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	srv.Shutdown(ctx)
+	// flush the samples still queued before the database closes
+	if err := store.Close(); err != nil {
+		log.Printf("Error flushing metrics: %v", err)
+	}
+}
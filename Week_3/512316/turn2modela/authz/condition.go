@@ -0,0 +1,131 @@
+package authz
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Attributes known to conditions. Subject attributes describe the caller,
+// resource attributes the object being accessed.
+var knownAttrs = map[string]bool{
+	"subject.id": true, "subject.username": true, "subject.role": true,
+	"resource.id": true, "resource.owner_id": true, "resource.status": true, "resource.type": true,
+}
+
+// operand is either a literal or a reference to an attribute.
+type operand struct {
+	attr    string
+	literal string
+}
+
+func (o operand) value(attrs map[string]string) string {
+	if o.attr != "" {
+		return attrs[o.attr]
+	}
+	return o.literal
+}
+
+func (o operand) String() string {
+	if o.attr != "" {
+		return o.attr
+	}
+	return strconv.Quote(o.literal)
+}
+
+type clause struct {
+	left, right operand
+	negate      bool // != instead of ==
+}
+
+// Condition is a conjunction of equality tests, for example
+//
+//	resource.owner_id == subject.id && resource.status != "archived"
+//
+// Quoted strings and bare numbers are literals; anything else must name a
+// known attribute. The empty condition is always true.
+type Condition struct {
+	src     string
+	clauses []clause
+}
+
+var clauseRE = regexp.MustCompile(`^\s*(\S+)\s*(==|!=)\s*(\S.*?)\s*$`)
+
+// ParseCondition validates and compiles src.
+func ParseCondition(src string) (Condition, error) {
+	c := Condition{src: strings.TrimSpace(src)}
+	if c.src == "" {
+		return c, nil
+	}
+	for _, part := range splitClauses(c.src) {
+		m := clauseRE.FindStringSubmatch(part)
+		if m == nil {
+			return Condition{}, fmt.Errorf("authz: cannot parse %q; expected <attr> == <value> or <attr> != <value>", strings.TrimSpace(part))
+		}
+		left, err := parseOperand(m[1])
+		if err != nil {
+			return Condition{}, err
+		}
+		right, err := parseOperand(m[3])
+		if err != nil {
+			return Condition{}, err
+		}
+		c.clauses = append(c.clauses, clause{left: left, right: right, negate: m[2] == "!="})
+	}
+	return c, nil
+}
+
+// splitClauses splits src at each && that is not inside a string literal.
+func splitClauses(src string) []string {
+	var parts []string
+	start, quoted := 0, false
+	for i := 0; i < len(src); i++ {
+		switch {
+		case quoted && src[i] == '\\':
+			i++ // skip the escaped character
+		case src[i] == '"':
+			quoted = !quoted
+		case !quoted && strings.HasPrefix(src[i:], "&&"):
+			parts = append(parts, src[start:i])
+			start = i + 2
+			i++
+		}
+	}
+	return append(parts, src[start:])
+}
+
+func parseOperand(s string) (operand, error) {
+	if strings.HasPrefix(s, `"`) {
+		lit, err := strconv.Unquote(s)
+		if err != nil {
+			return operand{}, fmt.Errorf("authz: bad string literal %s", s)
+		}
+		return operand{literal: lit}, nil
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return operand{literal: s}, nil
+	}
+	if !knownAttrs[s] {
+		return operand{}, fmt.Errorf("authz: unknown attribute %q", s)
+	}
+	return operand{attr: s}, nil
+}
+
+func (c Condition) String() string { return c.src }
+
+// eval reports whether every clause holds, and otherwise the first that
+// failed, for explanations.
+func (c Condition) eval(attrs map[string]string) (bool, string) {
+	for _, cl := range c.clauses {
+		l, r := cl.left.value(attrs), cl.right.value(attrs)
+		if (l == r) == cl.negate {
+			op := "=="
+			if cl.negate {
+				op = "!="
+			}
+			return false, fmt.Sprintf("%s %s %s is false (%q vs %q)", cl.left, op, cl.right, l, r)
+		}
+	}
+	return true, ""
+}
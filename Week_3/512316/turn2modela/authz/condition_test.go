@@ -0,0 +1,45 @@
+package authz
+
+import "testing"
+
+func TestParseCondition(t *testing.T) {
+	attrs := map[string]string{
+		"subject.id": "7", "resource.owner_id": "7", "resource.status": "a && b", "resource.type": "project",
+	}
+	cases := []struct {
+		src     string
+		clauses int
+		holds   bool
+		wantErr bool
+	}{
+		{"", 0, true, false},
+		{"resource.owner_id == subject.id", 1, true, false},
+		{`resource.owner_id == subject.id && resource.type != "project"`, 2, false, false},
+		{"resource.owner_id == 7", 1, true, false},
+		// && inside a literal belongs to the literal
+		{`resource.status == "a && b"`, 1, true, false},
+		{`resource.status == "a && b" && resource.type == "project"`, 2, true, false},
+		{`resource.status != "x \" && y"`, 1, true, false},
+		{`resource.status == "a"&&resource.type == "project"`, 2, false, false},
+		{"resource.owner_id = subject.id", 0, false, true},
+		{"resource.owner == subject.id", 0, false, true},
+		{`resource.status == "unterminated`, 0, false, true},
+		{"resource.type == project && ", 0, false, true},
+	}
+	for _, c := range cases {
+		cond, err := ParseCondition(c.src)
+		if (err != nil) != c.wantErr {
+			t.Errorf("ParseCondition(%q) error = %v, want error %v", c.src, err, c.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if len(cond.clauses) != c.clauses {
+			t.Errorf("%q has %d clauses, want %d", c.src, len(cond.clauses), c.clauses)
+		}
+		if ok, why := cond.eval(attrs); ok != c.holds {
+			t.Errorf("%q = %v (%s), want %v", c.src, ok, why, c.holds)
+		}
+	}
+}
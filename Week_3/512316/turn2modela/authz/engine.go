@@ -0,0 +1,371 @@
+// Package authz is a small embedded policy engine combining role-based
+// and attribute-based rules. Rules live in SQLite and can be changed at
+// runtime; every decision can be explained rule by rule.
+package authz
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Effect is what a matching rule does.
+type Effect string
+
+const (
+	Allow Effect = "allow"
+	Deny  Effect = "deny"
+)
+
+// Rule grants or denies actions on a resource type to a subject. Subject
+// is "*", "role:<name>" or "user:<username>"; Resource is a resource type
+// or "*"; Actions is a comma-separated list or "*". A deny that matches
+// always wins over any allow.
+type Rule struct {
+	ID          int64  `json:"id"`
+	Effect      Effect `json:"effect"`
+	Subject     string `json:"subject"`
+	Resource    string `json:"resource"`
+	Actions     string `json:"actions"`
+	Condition   string `json:"condition,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	cond Condition
+}
+
+// Validate checks the rule and compiles its condition.
+func (r *Rule) Validate() error {
+	if r.Effect != Allow && r.Effect != Deny {
+		return fmt.Errorf("authz: effect must be %q or %q", Allow, Deny)
+	}
+	if r.Subject != "*" && !strings.HasPrefix(r.Subject, "role:") && !strings.HasPrefix(r.Subject, "user:") {
+		return errors.New(`authz: subject must be "*", "role:<name>" or "user:<username>"`)
+	}
+	if r.Resource == "" || r.Actions == "" {
+		return errors.New("authz: resource and actions are required")
+	}
+	var err error
+	r.cond, err = ParseCondition(r.Condition)
+	return err
+}
+
+func (r *Rule) String() string {
+	s := fmt.Sprintf("#%d %s %s %s on %s", r.ID, r.Effect, r.Subject, r.Actions, r.Resource)
+	if r.Condition != "" {
+		s += " if " + r.Condition
+	}
+	return s
+}
+
+func (r *Rule) hasAction(action string) bool {
+	for _, a := range strings.Split(r.Actions, ",") {
+		if a = strings.TrimSpace(a); a == "*" || a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// Subject is the caller.
+type Subject struct {
+	ID       int64
+	Username string
+	Role     string
+}
+
+// Resource is what the caller wants to act on.
+type Resource struct {
+	Type    string
+	ID      int64
+	OwnerID int64
+	Status  string
+}
+
+// Step is one rule's part in a decision.
+type Step struct {
+	Rule    Rule   `json:"rule"`
+	Matched bool   `json:"matched"`
+	Why     string `json:"why"`
+}
+
+// Decision is the outcome of Check with the rules that led to it.
+type Decision struct {
+	Allowed bool   `json:"allowed"`
+	Action  string `json:"action"`
+	Reason  string `json:"reason"`
+	Rule    *Rule  `json:"rule,omitempty"` // the deciding rule, nil for the default deny
+	Steps   []Step `json:"steps"`
+}
+
+// Engine evaluates requests against the rules in its database.
+type Engine struct {
+	db *sql.DB
+
+	mu      sync.RWMutex
+	rules   []Rule
+	parents map[string]string // role -> role it inherits from
+}
+
+// Open creates the policy tables, seeds them with defaults when empty and
+// loads them.
+func Open(ctx context.Context, db *sql.DB, defaults []Rule, roles map[string]string) (*Engine, error) {
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS policy_rules (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			effect TEXT NOT NULL,
+			subject TEXT NOT NULL,
+			resource TEXT NOT NULL,
+			actions TEXT NOT NULL,
+			condition TEXT NOT NULL DEFAULT '',
+			description TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE TABLE IF NOT EXISTS policy_roles (
+			name TEXT PRIMARY KEY,
+			inherits TEXT NOT NULL DEFAULT ''
+		)`,
+	}
+	for _, s := range stmts {
+		if _, err := db.ExecContext(ctx, s); err != nil {
+			return nil, err
+		}
+	}
+	e := &Engine{db: db}
+	var n int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM policy_rules").Scan(&n); err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		for _, r := range defaults {
+			if _, err := e.insert(ctx, r); err != nil {
+				return nil, err
+			}
+		}
+		for role, parent := range roles {
+			if err := e.setRole(ctx, role, parent); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return e, e.Reload(ctx)
+}
+
+// Reload reads the rules and roles from the database.
+func (e *Engine) Reload(ctx context.Context) error {
+	rows, err := e.db.QueryContext(ctx, "SELECT id, effect, subject, resource, actions, condition, description FROM policy_rules ORDER BY id")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var rules []Rule
+	for rows.Next() {
+		var r Rule
+		if err := rows.Scan(&r.ID, &r.Effect, &r.Subject, &r.Resource, &r.Actions, &r.Condition, &r.Description); err != nil {
+			return err
+		}
+		if err := r.Validate(); err != nil {
+			return fmt.Errorf("rule %d: %w", r.ID, err)
+		}
+		rules = append(rules, r)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	parents := map[string]string{}
+	rrows, err := e.db.QueryContext(ctx, "SELECT name, inherits FROM policy_roles")
+	if err != nil {
+		return err
+	}
+	defer rrows.Close()
+	for rrows.Next() {
+		var name, inherits string
+		if err := rrows.Scan(&name, &inherits); err != nil {
+			return err
+		}
+		parents[name] = inherits
+	}
+	if err := rrows.Err(); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	e.rules, e.parents = rules, parents
+	e.mu.Unlock()
+	return nil
+}
+
+// Rules returns the current rules.
+func (e *Engine) Rules() []Rule {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return append([]Rule(nil), e.rules...)
+}
+
+// Roles returns each role with the role it inherits from.
+func (e *Engine) Roles() map[string]string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	out := make(map[string]string, len(e.parents))
+	for k, v := range e.parents {
+		out[k] = v
+	}
+	return out
+}
+
+// AddRule validates and stores a rule and returns it with its ID.
+func (e *Engine) AddRule(ctx context.Context, r Rule) (Rule, error) {
+	id, err := e.insert(ctx, r)
+	if err != nil {
+		return Rule{}, err
+	}
+	r.ID = id
+	return r, e.Reload(ctx)
+}
+
+func (e *Engine) insert(ctx context.Context, r Rule) (int64, error) {
+	if err := r.Validate(); err != nil {
+		return 0, err
+	}
+	res, err := e.db.ExecContext(ctx, "INSERT INTO policy_rules (effect, subject, resource, actions, condition, description) VALUES (?, ?, ?, ?, ?, ?)",
+		r.Effect, r.Subject, r.Resource, r.Actions, r.Condition, r.Description)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// ErrNoRule is returned when deleting a rule that does not exist.
+var ErrNoRule = errors.New("authz: no such rule")
+
+func (e *Engine) DeleteRule(ctx context.Context, id int64) error {
+	res, err := e.db.ExecContext(ctx, "DELETE FROM policy_rules WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNoRule
+	}
+	return e.Reload(ctx)
+}
+
+// SetRole defines role, optionally inheriting every rule of parent.
+func (e *Engine) SetRole(ctx context.Context, role, parent string) error {
+	if err := e.setRole(ctx, role, parent); err != nil {
+		return err
+	}
+	return e.Reload(ctx)
+}
+
+func (e *Engine) setRole(ctx context.Context, role, parent string) error {
+	if role == "" {
+		return errors.New("authz: role name is required")
+	}
+	// refuse cycles, which would make role expansion loop
+	e.mu.RLock()
+	for p := parent; p != ""; p = e.parents[p] {
+		if p == role {
+			e.mu.RUnlock()
+			return fmt.Errorf("authz: %s inheriting from %s would create a cycle", role, parent)
+		}
+	}
+	e.mu.RUnlock()
+	_, err := e.db.ExecContext(ctx, "INSERT INTO policy_roles (name, inherits) VALUES (?, ?) ON CONFLICT (name) DO UPDATE SET inherits = excluded.inherits", role, parent)
+	return err
+}
+
+// roles returns role and every role it inherits from. The caller holds mu.
+func (e *Engine) roles(role string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for r := role; r != "" && !seen[r]; r = e.parents[r] {
+		seen[r] = true
+		out = append(out, r)
+	}
+	return out
+}
+
+// Check decides whether sub may perform action on res. Every rule is
+// evaluated so the decision can be explained: a matching deny wins,
+// otherwise the first matching allow grants access, otherwise access is
+// denied by default.
+func (e *Engine) Check(sub Subject, action string, res Resource) Decision {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	roles := e.roles(sub.Role)
+	attrs := map[string]string{
+		"subject.id": strconv.FormatInt(sub.ID, 10), "subject.username": sub.Username, "subject.role": sub.Role,
+		"resource.id": strconv.FormatInt(res.ID, 10), "resource.owner_id": strconv.FormatInt(res.OwnerID, 10),
+		"resource.status": res.Status, "resource.type": res.Type,
+	}
+
+	d := Decision{Action: action}
+	var allow, deny *Rule
+	for i := range e.rules {
+		r := &e.rules[i]
+		step := Step{Rule: *r}
+		switch {
+		case r.Resource != "*" && r.Resource != res.Type:
+			step.Why = "resource type " + res.Type + " does not match"
+		case !r.hasAction(action):
+			step.Why = "action " + action + " does not match"
+		case !subjectMatches(r.Subject, sub.Username, roles):
+			step.Why = fmt.Sprintf("subject %s does not match user %s with roles %v", r.Subject, sub.Username, roles)
+		default:
+			if ok, why := r.cond.eval(attrs); !ok {
+				step.Why = "condition failed: " + why
+				break
+			}
+			step.Matched = true
+			step.Why = "matched"
+			if r.Effect == Deny && deny == nil {
+				deny = r
+			}
+			if r.Effect == Allow && allow == nil {
+				allow = r
+			}
+		}
+		d.Steps = append(d.Steps, step)
+	}
+	sort.SliceStable(d.Steps, func(i, j int) bool { return d.Steps[i].Matched && !d.Steps[j].Matched })
+
+	switch {
+	case deny != nil:
+		d.Rule = deny
+		d.Reason = "denied by " + deny.String()
+	case allow != nil:
+		d.Allowed, d.Rule = true, allow
+		d.Reason = "allowed by " + allow.String()
+	default:
+		d.Reason = "denied by default: no rule allows " + action + " on " + res.Type
+	}
+	if d.Rule != nil {
+		rule := *d.Rule
+		d.Rule = &rule
+		if rule.Description != "" {
+			d.Reason += " (" + rule.Description + ")"
+		}
+	}
+	return d
+}
+
+func subjectMatches(pattern, username string, roles []string) bool {
+	if pattern == "*" {
+		return true
+	}
+	if u, ok := strings.CutPrefix(pattern, "user:"); ok {
+		return u == username
+	}
+	role := strings.TrimPrefix(pattern, "role:")
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
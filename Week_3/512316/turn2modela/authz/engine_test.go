@@ -0,0 +1,153 @@
+package authz
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+)
+
+var testRules = []Rule{
+	{Effect: Deny, Subject: "*", Resource: "project", Actions: "update,delete,archive",
+		Condition: `resource.status == "archived"`, Description: "archived projects are immutable"},
+	{Effect: Allow, Subject: "role:admin", Resource: "*", Actions: "*"},
+	{Effect: Allow, Subject: "role:user", Resource: "project", Actions: "create,read"},
+	{Effect: Allow, Subject: "role:user", Resource: "project", Actions: "update,delete,archive,unarchive",
+		Condition: "resource.owner_id == subject.id"},
+	{Effect: Deny, Subject: "user:mallory", Resource: "*", Actions: "*"},
+}
+
+func openTest(t *testing.T) *Engine {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	e, err := Open(context.Background(), db, testRules, map[string]string{"user": "", "admin": "user", "auditor": ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestCheck(t *testing.T) {
+	e := openTest(t)
+	alice := Subject{ID: 1, Username: "alice", Role: "user"}
+	bob := Subject{ID: 2, Username: "bob", Role: "user"}
+	root := Subject{ID: 3, Username: "root", Role: "admin"}
+	mallory := Subject{ID: 4, Username: "mallory", Role: "admin"}
+	auditor := Subject{ID: 5, Username: "eve", Role: "auditor"}
+	active := Resource{Type: "project", ID: 10, OwnerID: 1, Status: "active"}
+	archived := Resource{Type: "project", ID: 11, OwnerID: 1, Status: "archived"}
+
+	cases := []struct {
+		name   string
+		sub    Subject
+		action string
+		res    Resource
+		want   bool
+		ruleID int64 // deciding rule, 0 for the default deny
+	}{
+		{"owner updates own project", alice, "update", active, true, 4},
+		{"non-owner cannot update", bob, "update", active, false, 0},
+		{"anyone with a role reads", bob, "read", active, true, 3},
+		{"owner cannot update archived", alice, "update", archived, false, 1},
+		{"owner cannot delete archived", alice, "delete", archived, false, 1},
+		{"owner can unarchive", alice, "unarchive", archived, true, 4},
+		{"admin cannot update archived", root, "update", archived, false, 1},
+		{"admin can unarchive", root, "unarchive", archived, true, 2},
+		{"admin edits others' projects", root, "delete", active, true, 2},
+		// admin inherits user, so role:user rules apply too
+		{"admin inherits create", root, "create", active, true, 2},
+		{"user deny beats role allow", mallory, "read", active, false, 5},
+		{"role without rules", auditor, "read", active, false, 0},
+		{"unknown resource type", alice, "read", Resource{Type: "invoice"}, false, 0},
+	}
+	for _, c := range cases {
+		d := e.Check(c.sub, c.action, c.res)
+		if d.Allowed != c.want {
+			t.Errorf("%s: allowed = %v (%s)", c.name, d.Allowed, d.Reason)
+			continue
+		}
+		var got int64
+		if d.Rule != nil {
+			got = d.Rule.ID
+		}
+		if got != c.ruleID {
+			t.Errorf("%s: decided by rule %d, want %d (%s)", c.name, got, c.ruleID, d.Reason)
+		}
+		if len(d.Steps) != len(testRules) {
+			t.Errorf("%s: %d steps, want one per rule", c.name, len(d.Steps))
+		}
+	}
+}
+
+func TestRuleChangesTakeEffect(t *testing.T) {
+	e := openTest(t)
+	ctx := context.Background()
+	bob := Subject{ID: 2, Username: "bob", Role: "user"}
+	res := Resource{Type: "project", OwnerID: 1, Status: "active"}
+	if e.Check(bob, "update", res).Allowed {
+		t.Fatal("bob can update before the rule is added")
+	}
+	r, err := e.AddRule(ctx, Rule{Effect: Allow, Subject: "user:bob", Resource: "project", Actions: "update"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !e.Check(bob, "update", res).Allowed {
+		t.Error("added rule not applied")
+	}
+	if err := e.DeleteRule(ctx, r.ID); err != nil {
+		t.Fatal(err)
+	}
+	if e.Check(bob, "update", res).Allowed {
+		t.Error("deleted rule still applied")
+	}
+	if err := e.DeleteRule(ctx, r.ID); !errors.Is(err, ErrNoRule) {
+		t.Errorf("deleting twice = %v", err)
+	}
+	if _, err := e.AddRule(ctx, Rule{Effect: Allow, Subject: "bob", Resource: "project", Actions: "read"}); err == nil {
+		t.Error("subject without a prefix accepted")
+	}
+	if _, err := e.AddRule(ctx, Rule{Effect: Allow, Subject: "*", Resource: "project", Actions: "read", Condition: "resource.colour == 1"}); err == nil {
+		t.Error("unknown attribute accepted")
+	}
+}
+
+func TestRoleInheritance(t *testing.T) {
+	e := openTest(t)
+	ctx := context.Background()
+	lead := Subject{ID: 9, Username: "lee", Role: "lead"}
+	res := Resource{Type: "project", OwnerID: 1, Status: "active"}
+	if e.Check(lead, "delete", res).Allowed {
+		t.Fatal("undefined role allowed")
+	}
+	// lead -> admin -> user
+	if err := e.SetRole(ctx, "lead", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if d := e.Check(lead, "delete", res); !d.Allowed || d.Rule.Subject != "role:admin" {
+		t.Errorf("lead did not inherit admin: %s", d.Reason)
+	}
+
+	for _, c := range []struct{ role, parent string }{
+		{"user", "user"},
+		{"user", "admin"},
+		{"user", "lead"},
+	} {
+		if err := e.SetRole(ctx, c.role, c.parent); err == nil {
+			t.Errorf("%s inheriting from %s accepted", c.role, c.parent)
+		}
+	}
+	if got := e.Roles()["user"]; got != "" {
+		t.Errorf("a rejected cycle changed user's parent to %q", got)
+	}
+	if err := e.SetRole(ctx, "", "user"); err == nil {
+		t.Error("empty role name accepted")
+	}
+}
@@ -0,0 +1,546 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"Week_3/512316/turn2modela/authz"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	adminRole      = "admin"
+	userRole       = "user"
+	sqliteFilePath = "authz.db"
+	tokenTTL       = time.Hour
+)
+
+type User struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	Password string `json:"password,omitempty"`
+	Role     string `json:"role"`
+}
+
+// Project statuses. Only these are accepted, so the archived deny rule
+// cannot be sidestepped with a variant spelling.
+const (
+	statusActive   = "active"
+	statusArchived = "archived"
+)
+
+type Project struct {
+	ID     int64  `json:"id"`
+	Name   string `json:"name"`
+	UserID int64  `json:"user_id"`
+	Status string `json:"status"`
+}
+
+func (p Project) resource() authz.Resource {
+	return authz.Resource{Type: "project", ID: p.ID, OwnerID: p.UserID, Status: p.Status}
+}
+
+// defaultRules are installed into an empty policy table. Deny rules win
+// over allow rules, so archived projects stay immutable even for admins,
+// who can only unarchive them.
+var defaultRules = []authz.Rule{
+	{Effect: authz.Deny, Subject: "*", Resource: "project", Actions: "update,delete,archive",
+		Condition: `resource.status == "archived"`, Description: "archived projects are immutable"},
+	{Effect: authz.Allow, Subject: "role:" + adminRole, Resource: "*", Actions: "*",
+		Description: "administrators can do anything not denied"},
+	{Effect: authz.Allow, Subject: "role:" + userRole, Resource: "project", Actions: "create,read",
+		Description: "users can create projects and read all of them"},
+	{Effect: authz.Allow, Subject: "role:" + userRole, Resource: "project", Actions: "update,delete,archive,unarchive",
+		Condition: "resource.owner_id == subject.id", Description: "owners can edit their own projects"},
+}
+
+var (
+	db        *sql.DB
+	engine    *authz.Engine
+	jwtSecret []byte
+)
+
+func initDB(ctx context.Context) error {
+	var err error
+	db, err = sql.Open("sqlite3", sqliteFilePath)
+	if err != nil {
+		return err
+	}
+	for _, s := range []string{
+		"CREATE TABLE IF NOT EXISTS users (id INTEGER PRIMARY KEY AUTOINCREMENT, username TEXT UNIQUE NOT NULL, password_hash TEXT NOT NULL, role TEXT NOT NULL)",
+		"CREATE TABLE IF NOT EXISTS projects (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL, user_id INTEGER NOT NULL, status TEXT NOT NULL)",
+	} {
+		if _, err := db.ExecContext(ctx, s); err != nil {
+			return err
+		}
+	}
+	engine, err = authz.Open(ctx, db, defaultRules, map[string]string{userRole: "", adminRole: userRole})
+	if err != nil {
+		return err
+	}
+
+	var admins int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE role = ?", adminRole).Scan(&admins); err != nil {
+		return err
+	}
+	if admins == 0 {
+		password := os.Getenv("ADMIN_PASSWORD")
+		if password == "" {
+			password = randomString(12)
+			log.Printf("Created user %q with password %q", "admin", password)
+		}
+		if _, err := insertUser(ctx, "admin", password, adminRole); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func randomString(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func insertUser(ctx context.Context, username, password, role string) (int64, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return 0, err
+	}
+	res, err := db.ExecContext(ctx, "INSERT INTO users (username, password_hash, role) VALUES (?, ?, ?)", username, hash, role)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func createUser(w http.ResponseWriter, r *http.Request) {
+	var user User
+	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if user.Username == "" || len(user.Password) < 8 {
+		http.Error(w, "username and a password of at least 8 characters are required", http.StatusBadRequest)
+		return
+	}
+	id, err := insertUser(r.Context(), user.Username, user.Password, userRole)
+	if err != nil {
+		http.Error(w, "username is taken", http.StatusConflict)
+		return
+	}
+	writeJSON(w, http.StatusCreated, User{ID: id, Username: user.Username, Role: userRole})
+}
+
+// dummyHash is compared against when the user does not exist, so a
+// login takes the same time either way.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+
+func loginUser(w http.ResponseWriter, r *http.Request) {
+	var creds User
+	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	var id int64
+	hash := dummyHash
+	err := db.QueryRowContext(r.Context(), "SELECT id, password_hash FROM users WHERE username = ?", creds.Username).Scan(&id, &hash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(creds.Password)) != nil || err != nil {
+		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		return
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   strconv.FormatInt(id, 10),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(tokenTTL)),
+	})
+	signed, err := token.SignedString(jwtSecret)
+	if err != nil {
+		http.Error(w, "Error signing token", http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: "token", Value: signed, HttpOnly: true, SameSite: http.SameSiteStrictMode, Expires: now.Add(tokenTTL)})
+	writeJSON(w, http.StatusOK, map[string]string{"token": signed})
+}
+
+type ctxKey struct{}
+
+func currentUser(r *http.Request) User { return r.Context().Value(ctxKey{}).(User) }
+
+// authenticate accepts the token as a bearer token or cookie. The role is
+// read from the database on every request, so role changes apply at once.
+func authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			c, err := r.Cookie("token")
+			if err != nil {
+				http.Error(w, "No token found", http.StatusUnauthorized)
+				return
+			}
+			raw = c.Value
+		}
+		var claims jwt.RegisteredClaims
+		_, err := jwt.ParseWithClaims(raw, &claims, func(*jwt.Token) (any, error) { return jwtSecret, nil },
+			jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+		if err != nil {
+			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			return
+		}
+		var u User
+		err = db.QueryRowContext(r.Context(), "SELECT id, username, role FROM users WHERE id = ?", claims.Subject).Scan(&u.ID, &u.Username, &u.Role)
+		if err != nil {
+			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKey{}, u)))
+	})
+}
+
+func subjectOf(u User) authz.Subject {
+	return authz.Subject{ID: u.ID, Username: u.Username, Role: u.Role}
+}
+
+// allowed checks the policy and writes a 403 naming the deciding rule
+// when access is denied.
+func allowed(w http.ResponseWriter, r *http.Request, action string, res authz.Resource) bool {
+	d := engine.Check(subjectOf(currentUser(r)), action, res)
+	if !d.Allowed {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": d.Reason})
+	}
+	return d.Allowed
+}
+
+func loadProject(ctx context.Context, id string) (Project, error) {
+	var p Project
+	err := db.QueryRowContext(ctx, "SELECT id, name, user_id, status FROM projects WHERE id = ?", id).Scan(&p.ID, &p.Name, &p.UserID, &p.Status)
+	return p, err
+}
+
+// projectFromPath loads the project named in the URL, writing the error
+// response itself when it cannot.
+func projectFromPath(w http.ResponseWriter, r *http.Request) (Project, bool) {
+	p, err := loadProject(r.Context(), mux.Vars(r)["id"])
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return p, false
+	case err != nil:
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return p, false
+	}
+	return p, true
+}
+
+func createProject(w http.ResponseWriter, r *http.Request) {
+	var project Project
+	if err := json.NewDecoder(r.Body).Decode(&project); err != nil || project.Name == "" {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	// the caller always owns what they create
+	project.UserID = currentUser(r).ID
+	project.Status = statusActive
+	if !allowed(w, r, "create", project.resource()) {
+		return
+	}
+	res, err := db.ExecContext(r.Context(), "INSERT INTO projects (name, user_id, status) VALUES (?, ?, ?)", project.Name, project.UserID, project.Status)
+	if err != nil {
+		http.Error(w, "Error creating project", http.StatusInternalServerError)
+		return
+	}
+	project.ID, _ = res.LastInsertId()
+	writeJSON(w, http.StatusCreated, project)
+}
+
+func listProjects(w http.ResponseWriter, r *http.Request) {
+	rows, err := db.QueryContext(r.Context(), "SELECT id, name, user_id, status FROM projects ORDER BY id")
+	if err != nil {
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	sub := subjectOf(currentUser(r))
+	projects := make([]Project, 0)
+	for rows.Next() {
+		var p Project
+		if err := rows.Scan(&p.ID, &p.Name, &p.UserID, &p.Status); err != nil {
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+		if engine.Check(sub, "read", p.resource()).Allowed {
+			projects = append(projects, p)
+		}
+	}
+	writeJSON(w, http.StatusOK, projects)
+}
+
+func getProject(w http.ResponseWriter, r *http.Request) {
+	p, ok := projectFromPath(w, r)
+	if ok && allowed(w, r, "read", p.resource()) {
+		writeJSON(w, http.StatusOK, p)
+	}
+}
+
+// updateProject renames a project or changes its status. Moving to or
+// from "archived" is checked as the archive or unarchive action.
+func updateProject(w http.ResponseWriter, r *http.Request) {
+	p, ok := projectFromPath(w, r)
+	if !ok {
+		return
+	}
+	var patch struct {
+		Name   *string `json:"name"`
+		Status *string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if patch.Status != nil && *patch.Status != statusActive && *patch.Status != statusArchived {
+		http.Error(w, fmt.Sprintf("status must be %q or %q", statusActive, statusArchived), http.StatusBadRequest)
+		return
+	}
+	action := "update"
+	if patch.Status != nil && *patch.Status != p.Status {
+		switch {
+		case *patch.Status == statusArchived:
+			action = "archive"
+		case p.Status == statusArchived:
+			action = "unarchive"
+		}
+	}
+	if !allowed(w, r, action, p.resource()) {
+		return
+	}
+	// unarchiving only changes the status; further edits need a second
+	// request that is checked against the active project
+	if action == "unarchive" && patch.Name != nil {
+		http.Error(w, "unarchive the project before renaming it", http.StatusConflict)
+		return
+	}
+	if patch.Name != nil {
+		p.Name = *patch.Name
+	}
+	if patch.Status != nil {
+		p.Status = *patch.Status
+	}
+	if _, err := db.ExecContext(r.Context(), "UPDATE projects SET name = ?, status = ? WHERE id = ?", p.Name, p.Status, p.ID); err != nil {
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+func deleteProject(w http.ResponseWriter, r *http.Request) {
+	p, ok := projectFromPath(w, r)
+	if !ok || !allowed(w, r, "delete", p.resource()) {
+		return
+	}
+	if _, err := db.ExecContext(r.Context(), "DELETE FROM projects WHERE id = ?", p.ID); err != nil {
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// explain shows how the policy decides a request without performing it:
+// GET /authz/explain?action=update&resource=project&id=3[&user=alice].
+// Explaining another user's access requires the explain permission on the
+// policy.
+func explain(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	action := q.Get("action")
+	if action == "" {
+		http.Error(w, "action is required", http.StatusBadRequest)
+		return
+	}
+	sub := subjectOf(currentUser(r))
+	if name := q.Get("user"); name != "" && name != sub.Username {
+		if !allowed(w, r, "explain", authz.Resource{Type: "policy"}) {
+			return
+		}
+		err := db.QueryRowContext(r.Context(), "SELECT id, username, role FROM users WHERE username = ?", name).Scan(&sub.ID, &sub.Username, &sub.Role)
+		if err != nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+	}
+	res := authz.Resource{Type: q.Get("resource")}
+	if res.Type == "" {
+		res.Type = "project"
+	}
+	if id := q.Get("id"); id != "" {
+		if res.Type != "project" {
+			http.Error(w, "only projects can be looked up by id", http.StatusBadRequest)
+			return
+		}
+		p, err := loadProject(r.Context(), id)
+		if err != nil {
+			http.Error(w, "Project not found", http.StatusNotFound)
+			return
+		}
+		res = p.resource()
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"subject":  sub,
+		"resource": res,
+		"decision": engine.Check(sub, action, res),
+	})
+}
+
+// requirePolicyAdmin guards the admin API with the manage permission on
+// the policy itself, so who may edit rules is also a rule.
+func requirePolicyAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if allowed(w, r, "manage", authz.Resource{Type: "policy"}) {
+			next(w, r)
+		}
+	}
+}
+
+func listRules(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"rules": engine.Rules(), "roles": engine.Roles()})
+}
+
+func addRule(w http.ResponseWriter, r *http.Request) {
+	var rule authz.Rule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	rule, err := engine.AddRule(r.Context(), rule)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusCreated, rule)
+}
+
+func deleteRule(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	err := engine.DeleteRule(r.Context(), id)
+	switch {
+	case errors.Is(err, authz.ErrNoRule):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func setRole(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Inherits string `json:"inherits"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if err := engine.SetRole(r.Context(), mux.Vars(r)["name"], body.Inherits); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, engine.Roles())
+}
+
+func setUserRole(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Role == "" {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if _, ok := engine.Roles()[body.Role]; !ok {
+		http.Error(w, "unknown role", http.StatusBadRequest)
+		return
+	}
+	res, err := db.ExecContext(r.Context(), "UPDATE users SET role = ? WHERE id = ?", body.Role, mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func main() {
+	ctx := context.Background()
+	if s := os.Getenv("JWT_SECRET"); len(s) >= 32 {
+		jwtSecret = []byte(s)
+	} else {
+		log.Println("JWT_SECRET is unset or shorter than 32 bytes; using a random key, so tokens end with the process")
+		jwtSecret = make([]byte, 32)
+		rand.Read(jwtSecret)
+	}
+	if err := initDB(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	r := mux.NewRouter()
+	r.HandleFunc("/users", createUser).Methods("POST")
+	r.HandleFunc("/login", loginUser).Methods("POST")
+
+	api := r.NewRoute().Subrouter()
+	api.Use(authenticate)
+	api.HandleFunc("/projects", createProject).Methods("POST")
+	api.HandleFunc("/projects", listProjects).Methods("GET")
+	api.HandleFunc("/projects/{id:[0-9]+}", getProject).Methods("GET")
+	api.HandleFunc("/projects/{id:[0-9]+}", updateProject).Methods("PUT", "PATCH")
+	api.HandleFunc("/projects/{id:[0-9]+}", deleteProject).Methods("DELETE")
+	api.HandleFunc("/authz/explain", explain).Methods("GET")
+	api.HandleFunc("/admin/rules", requirePolicyAdmin(listRules)).Methods("GET")
+	api.HandleFunc("/admin/rules", requirePolicyAdmin(addRule)).Methods("POST")
+	api.HandleFunc("/admin/rules/{id:[0-9]+}", requirePolicyAdmin(deleteRule)).Methods("DELETE")
+	api.HandleFunc("/admin/roles/{name}", requirePolicyAdmin(setRole)).Methods("PUT")
+	api.HandleFunc("/admin/users/{id:[0-9]+}/role", requirePolicyAdmin(setUserRole)).Methods("PUT")
+
+	srv := &http.Server{Addr: ":8080", Handler: r}
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+		<-signals
+		log.Println("Shutting down gracefully...")
+		srv.Shutdown(ctx)
+	}()
+	fmt.Println("Starting localhost on 8080...")
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	db.Close()
+}
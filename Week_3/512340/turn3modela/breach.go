@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// BreachList looks passwords up in a local copy of a breached-password
+// corpus in the Have I Been Pwned format: one upper-case SHA-1 per line,
+// optionally followed by ":count", sorted by hash. Lookups work like the
+// HIBP range API: the 5-character hash prefix selects the lines to read
+// and the suffix is matched among them, so a remote range source can
+// replace the file without ever being sent a full hash.
+type BreachList struct {
+	mu     sync.Mutex
+	f      *os.File
+	ranges map[string][2]int64 // prefix -> [start, end) byte offsets
+}
+
+// OpenBreachList indexes the file by hash prefix. It reads the file once;
+// afterwards each lookup reads only the lines for one prefix.
+func OpenBreachList(path string) (*BreachList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	bl := &BreachList{f: f, ranges: map[string][2]int64{}}
+	r := bufio.NewReader(f)
+	var off int64
+	var prev string
+	for lineNo := 1; ; lineNo++ {
+		line, err := r.ReadString('\n')
+		if len(line) > 0 {
+			hash := strings.ToUpper(strings.TrimSpace(strings.SplitN(line, ":", 2)[0]))
+			if len(hash) != 40 {
+				f.Close()
+				return nil, fmt.Errorf("%s:%d: not a SHA-1 hash", path, lineNo)
+			}
+			if hash < prev {
+				f.Close()
+				return nil, fmt.Errorf("%s:%d: hashes are not sorted", path, lineNo)
+			}
+			prev = hash
+			rg, ok := bl.ranges[hash[:5]]
+			if !ok {
+				rg[0] = off
+			}
+			off += int64(len(line))
+			rg[1] = off
+			bl.ranges[hash[:5]] = rg
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return bl, nil
+}
+
+func (bl *BreachList) Close() error { return bl.f.Close() }
+
+// Range returns the hash suffixes under prefix with their counts.
+func (bl *BreachList) Range(prefix string) (map[string]int, error) {
+	rg, ok := bl.ranges[strings.ToUpper(prefix)]
+	if !ok {
+		return nil, nil
+	}
+	buf := make([]byte, rg[1]-rg[0])
+	bl.mu.Lock()
+	_, err := bl.f.ReadAt(buf, rg[0])
+	bl.mu.Unlock()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	out := map[string]int{}
+	for _, line := range bytes.Split(buf, []byte("\n")) {
+		hash, count, _ := strings.Cut(strings.TrimSpace(string(line)), ":")
+		if len(hash) != 40 {
+			continue
+		}
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			n = 1
+		}
+		out[strings.ToUpper(hash[5:])] = n
+	}
+	return out, nil
+}
+
+// Count returns how often password appears in the corpus, 0 if never.
+func (bl *BreachList) Count(password string) (int, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	suffixes, err := bl.Range(hash[:5])
+	if err != nil {
+		return 0, err
+	}
+	return suffixes[hash[5:]], nil
+}
+
+// WriteBreachList writes passwords in the format OpenBreachList reads,
+// for building a list from a plain-text wordlist.
+func WriteBreachList(w io.Writer, passwords []string) error {
+	counts := map[string]int{}
+	for _, p := range passwords {
+		sum := sha1.Sum([]byte(p))
+		counts[strings.ToUpper(hex.EncodeToString(sum[:]))]++
+	}
+	hashes := make([]string, 0, len(counts))
+	for h := range counts {
+		hashes = append(hashes, h)
+	}
+	sort.Strings(hashes)
+	bw := bufio.NewWriter(w)
+	for _, h := range hashes {
+		fmt.Fprintf(bw, "%s:%d\n", h, counts[h])
+	}
+	return bw.Flush()
+}
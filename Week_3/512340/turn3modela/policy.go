@@ -0,0 +1,321 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Candidate is a password being set, with what the rules need to judge it.
+type Candidate struct {
+	Username string
+	Password string
+	// History holds the hashes of the user's previous passwords, newest
+	// first, including the current one.
+	History []string
+}
+
+// Rule is one requirement of a password policy. It returns a short,
+// user-facing description of what is wrong, or nil.
+type Rule interface {
+	Check(c Candidate) error
+}
+
+// RuleFunc adapts a function to Rule.
+type RuleFunc func(c Candidate) error
+
+func (f RuleFunc) Check(c Candidate) error { return f(c) }
+
+// PolicyError lists every rule a password broke, so a user can fix them
+// all at once.
+type PolicyError struct {
+	Violations []string
+}
+
+func (e *PolicyError) Error() string {
+	return "password rejected: " + strings.Join(e.Violations, "; ")
+}
+
+// Policy is a list of rules that must all pass.
+type Policy []Rule
+
+// Check runs every rule. Rules that fail for a reason other than the
+// password itself, such as an unreadable breach list, abort the check.
+func (p Policy) Check(c Candidate) error {
+	var violations []string
+	for _, r := range p {
+		err := r.Check(c)
+		var v violation
+		switch {
+		case err == nil:
+		case errors.As(err, &v):
+			violations = append(violations, string(v))
+		default:
+			return err
+		}
+	}
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// violation marks an error as the password's fault.
+type violation string
+
+func (v violation) Error() string { return string(v) }
+
+func violationf(format string, args ...any) error { return violation(fmt.Sprintf(format, args...)) }
+
+// MinLength requires at least n characters.
+func MinLength(n int) Rule {
+	return RuleFunc(func(c Candidate) error {
+		if utf8.RuneCountInString(c.Password) < n {
+			return violationf("must be at least %d characters long", n)
+		}
+		return nil
+	})
+}
+
+// MaxLength caps the length; argon2 makes very long inputs expensive.
+func MaxLength(n int) Rule {
+	return RuleFunc(func(c Candidate) error {
+		if utf8.RuneCountInString(c.Password) > n {
+			return violationf("must be at most %d characters long", n)
+		}
+		return nil
+	})
+}
+
+// CharClasses requires characters from at least n of: lower case, upper
+// case, digits and symbols.
+func CharClasses(n int) Rule {
+	return RuleFunc(func(c Candidate) error {
+		var lower, upper, digit, symbol int
+		for _, r := range c.Password {
+			switch {
+			case unicode.IsLower(r):
+				lower = 1
+			case unicode.IsUpper(r):
+				upper = 1
+			case unicode.IsDigit(r):
+				digit = 1
+			default:
+				symbol = 1
+			}
+		}
+		if lower+upper+digit+symbol < n {
+			return violationf("must mix at least %d of lower case, upper case, digits and symbols", n)
+		}
+		return nil
+	})
+}
+
+// MinEntropy requires an estimated strength of at least bits, see
+// EstimateEntropy.
+func MinEntropy(bits float64) Rule {
+	return RuleFunc(func(c Candidate) error {
+		if e := EstimateEntropy(c.Password, c.Username); e < bits {
+			return violationf("is too easy to guess (about %.0f bits, need %.0f)", e, bits)
+		}
+		return nil
+	})
+}
+
+// NotUsername rejects passwords containing the username, or the local part
+// of an e-mail username, forwards or backwards.
+func NotUsername() Rule {
+	return RuleFunc(func(c Candidate) error {
+		pw := strings.ToLower(c.Password)
+		for _, u := range usernameTokens(c.Username) {
+			if len(u) >= 3 && (strings.Contains(pw, u) || strings.Contains(pw, reverse(u))) {
+				return violation("must not contain the username")
+			}
+		}
+		return nil
+	})
+}
+
+func usernameTokens(username string) []string {
+	u := strings.ToLower(username)
+	tokens := []string{u}
+	if local, _, ok := strings.Cut(u, "@"); ok {
+		tokens = append(tokens, local)
+	}
+	return tokens
+}
+
+// NotReused rejects any of the user's last n passwords, the current one
+// included.
+func NotReused(n int) Rule {
+	return RuleFunc(func(c Candidate) error {
+		for i, h := range c.History {
+			if i >= n {
+				break
+			}
+			ok, _, err := verifyPassword(c.Password, h)
+			if err != nil {
+				return err
+			}
+			if ok {
+				return violationf("must differ from your last %d passwords", n)
+			}
+		}
+		return nil
+	})
+}
+
+// NotBreached rejects passwords found in the breach list.
+func NotBreached(list *BreachList) Rule {
+	return RuleFunc(func(c Candidate) error {
+		n, err := list.Count(c.Password)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return violation("appears in a list of breached passwords")
+		}
+		return nil
+	})
+}
+
+// DefaultPolicy is a reasonable policy for interactive logins. The breach
+// list is optional.
+func DefaultPolicy(breaches *BreachList) Policy {
+	p := Policy{MinLength(10), MaxLength(128), CharClasses(3), NotUsername(), MinEntropy(40), NotReused(5)}
+	if breaches != nil {
+		p = append(p, NotBreached(breaches))
+	}
+	return p
+}
+
+// commonWords seeds the dictionary matcher. A real deployment would load a
+// frequency-ranked list; the rank is the estimated number of guesses.
+var commonWords = func() map[string]int {
+	words := strings.Fields(`password qwerty letmein welcome admin iloveyou monkey dragon
+		football baseball sunshine princess master shadow login secret passw0rd trustno1
+		superman batman hello freedom whatever starwars summer winter spring autumn
+		love god money michael jordan charlie thomas jessica ashley daniel soccer hockey
+		computer internet cookie cheese orange banana apple flower tiger killer pepper
+		ninja mustang access master company change test user guest root default`)
+	m := make(map[string]int, len(words))
+	for i, w := range words {
+		if _, ok := m[w]; !ok {
+			m[w] = i + 1
+		}
+	}
+	return m
+}()
+
+var leet = strings.NewReplacer("4", "a", "@", "a", "3", "e", "1", "i", "!", "i", "0", "o", "5", "s", "$", "s", "7", "t")
+
+var keyboardRows = []string{"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./"}
+
+var yearRE = regexp.MustCompile(`^(19|20)\d\d$`)
+
+// EstimateEntropy approximates log2 of the guesses an attacker needs, in
+// the style of zxcvbn: the password is covered by the cheapest sequence of
+// patterns (dictionary words, including l33t spellings and the username;
+// repeats; sequences like "abc" or "987"; keyboard runs like "qwer";
+// years), and characters no pattern explains cost log2(10) bits each.
+func EstimateEntropy(password, username string) float64 {
+	const bruteBits = 3.321928 // log2(10) per character, as zxcvbn does
+	pw := []rune(strings.ToLower(password))
+	var extra float64
+	if len(pw) > 128 {
+		// keep the quadratic search bounded; the tail only adds strength
+		extra = float64(len(pw)-128) * bruteBits
+		pw = pw[:128]
+	}
+	n := len(pw)
+	if n == 0 {
+		return 0
+	}
+	users := usernameTokens(username)
+
+	// best[i] is the cheapest cost in bits of pw[:i], plus a small charge
+	// per pattern so that many short patterns do not undercut one long one
+	best := make([]float64, n+1)
+	for i := 1; i <= n; i++ {
+		best[i] = best[i-1] + bruteBits
+		for j := 0; j <= i-1; j++ {
+			if bits, ok := patternBits(string(pw[j:i]), users); ok {
+				best[i] = math.Min(best[i], best[j]+bits+1)
+			}
+		}
+	}
+	// upper case beyond the first letter adds a little uncertainty
+	upper := 0
+	for i, r := range password {
+		if unicode.IsUpper(r) && i > 0 {
+			upper++
+		}
+	}
+	return best[n] + extra + float64(min(upper, 4))
+}
+
+// patternBits returns the cost of s if it matches a low-entropy pattern.
+func patternBits(s string, usernames []string) (float64, bool) {
+	r := []rune(s)
+	if len(r) < 3 {
+		return 0, false
+	}
+	for _, u := range usernames {
+		if s == u || s == reverse(u) {
+			return 1, true
+		}
+	}
+	if rank, ok := commonWords[s]; ok {
+		return math.Log2(float64(rank)) + 1, true
+	}
+	if un := leet.Replace(s); un != s {
+		if rank, ok := commonWords[un]; ok {
+			return math.Log2(float64(rank)) + 3, true // a bit for the substitutions
+		}
+	}
+	if rank, ok := commonWords[reverse(s)]; ok {
+		return math.Log2(float64(rank)) + 2, true
+	}
+	if yearRE.MatchString(s) {
+		return math.Log2(200), true
+	}
+	if strings.Count(s, string(r[0])) == len(r) {
+		return math.Log2(float64(95 * len(r))), true
+	}
+	if isSequence(r) {
+		return math.Log2(float64(26 * 2 * len(r))), true
+	}
+	for _, row := range keyboardRows {
+		if len(r) >= 4 && (strings.Contains(row, s) || strings.Contains(row, reverse(s))) {
+			return math.Log2(float64(47 * 2 * len(r))), true
+		}
+	}
+	return 0, false
+}
+
+// isSequence reports whether each rune is one more, or one less, than the
+// previous one, as in "abcd" or "9876".
+func isSequence(r []rune) bool {
+	d := r[1] - r[0]
+	if d != 1 && d != -1 {
+		return false
+	}
+	for i := 2; i < len(r); i++ {
+		if r[i]-r[i-1] != d {
+			return false
+		}
+	}
+	return true
+}
+
+func reverse(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
@@ -0,0 +1,338 @@
+// auth.go
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// User is a registered account as stored; the password itself is never
+// kept.
+type User struct {
+	Username       string
+	PasswordHash   string
+	CreatedAt      time.Time
+	FailedAttempts int
+	LockedUntil    time.Time
+}
+
+var (
+	ErrUserExists         = errors.New("username already exists")
+	ErrInvalidCredentials = errors.New("invalid username or password")
+)
+
+// LockedError is returned while an account is locked out.
+type LockedError struct {
+	Until time.Time
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("account locked until %s", e.Until.Format(time.RFC3339))
+}
+
+// Lockout locks an account after MaxFailures failed logins within Window,
+// for Duration. A successful login resets the count.
+type Lockout struct {
+	MaxFailures int
+	Window      time.Duration
+	Duration    time.Duration
+}
+
+// DefaultLockout allows five attempts per 15 minutes.
+var DefaultLockout = Lockout{MaxFailures: 5, Window: 15 * time.Minute, Duration: 15 * time.Minute}
+
+// Options configures a UserStore. Zero values select the defaults.
+type Options struct {
+	Policy           Policy       // default DefaultPolicy(nil)
+	Params           Argon2Params // default DefaultArgon2Params
+	Lockout          Lockout      // zero fields default to DefaultLockout's; MaxFailures < 0 disables it
+	History          int          // previous hashes kept for NotReused, default 5
+	ValidateUsername func(username string) error
+	Now              func() time.Time
+}
+
+// UserStore stores registered users in SQLite.
+type UserStore struct {
+	db        *sql.DB
+	opts      Options
+	dummyHash string // verified for unknown users so timing does not reveal them
+}
+
+// NewUserStore creates the tables if needed.
+func NewUserStore(db *sql.DB, opts Options) (*UserStore, error) {
+	if opts.Policy == nil {
+		opts.Policy = DefaultPolicy(nil)
+	}
+	if opts.Params == (Argon2Params{}) {
+		opts.Params = DefaultArgon2Params
+	}
+	// each lockout field defaults on its own, so setting only MaxFailures
+	// does not leave a zero window that never counts two failures together
+	if opts.Lockout.MaxFailures == 0 {
+		opts.Lockout.MaxFailures = DefaultLockout.MaxFailures
+	}
+	if opts.Lockout.Window <= 0 {
+		opts.Lockout.Window = DefaultLockout.Window
+	}
+	if opts.Lockout.Duration <= 0 {
+		opts.Lockout.Duration = DefaultLockout.Duration
+	}
+	if opts.History <= 0 {
+		opts.History = 5
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	for _, s := range []string{
+		`CREATE TABLE IF NOT EXISTS users (
+			username TEXT PRIMARY KEY,
+			password_hash TEXT NOT NULL,
+			created_at INTEGER NOT NULL,
+			failed_attempts INTEGER NOT NULL DEFAULT 0,
+			first_failure_at INTEGER NOT NULL DEFAULT 0,
+			locked_until INTEGER NOT NULL DEFAULT 0
+		)`,
+		`CREATE TABLE IF NOT EXISTS password_history (
+			username TEXT NOT NULL REFERENCES users (username) ON DELETE CASCADE,
+			password_hash TEXT NOT NULL,
+			changed_at INTEGER NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS password_history_user ON password_history (username, changed_at)`,
+	} {
+		if _, err := db.Exec(s); err != nil {
+			return nil, err
+		}
+	}
+	dummy, err := hashPassword("dummy password", opts.Params)
+	if err != nil {
+		return nil, err
+	}
+	return &UserStore{db: db, opts: opts, dummyHash: dummy}, nil
+}
+
+// Register creates a user after checking the username and the password
+// policy.
+func (s *UserStore) Register(ctx context.Context, username, password string) error {
+	if s.opts.ValidateUsername != nil {
+		if err := s.opts.ValidateUsername(username); err != nil {
+			return err
+		}
+	}
+	var exists int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE username = ?", username).Scan(&exists); err != nil {
+		return err
+	}
+	if exists > 0 {
+		return ErrUserExists
+	}
+	if err := s.opts.Policy.Check(Candidate{Username: username, Password: password}); err != nil {
+		return err
+	}
+	hash, err := hashPassword(password, s.opts.Params)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	now := s.opts.Now().Unix()
+	if _, err := tx.ExecContext(ctx, "INSERT INTO users (username, password_hash, created_at) VALUES (?, ?, ?)", username, hash, now); err != nil {
+		// lost a race with another registration of the same name
+		return ErrUserExists
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO password_history (username, password_hash, changed_at) VALUES (?, ?, ?)", username, hash, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Get returns the stored user.
+func (s *UserStore) Get(ctx context.Context, username string) (User, error) {
+	var u User
+	var created, locked int64
+	err := s.db.QueryRowContext(ctx, "SELECT username, password_hash, created_at, failed_attempts, locked_until FROM users WHERE username = ?", username).
+		Scan(&u.Username, &u.PasswordHash, &created, &u.FailedAttempts, &locked)
+	if err != nil {
+		return User{}, err
+	}
+	u.CreatedAt = time.Unix(created, 0)
+	if locked > 0 {
+		u.LockedUntil = time.Unix(locked, 0)
+	}
+	return u, nil
+}
+
+// Authenticate checks a login. It returns nil on success,
+// ErrInvalidCredentials for a wrong username or password, and a
+// *LockedError while the account is locked; a locked account is refused
+// without looking at the password. A hash made with parameters other than
+// the current ones is replaced after a successful login.
+func (s *UserStore) Authenticate(ctx context.Context, username, password string) error {
+	var hash string
+	var failures int
+	var lockedUntil int64
+	err := s.db.QueryRowContext(ctx, "SELECT password_hash, failed_attempts, locked_until FROM users WHERE username = ?", username).
+		Scan(&hash, &failures, &lockedUntil)
+	if errors.Is(err, sql.ErrNoRows) {
+		verifyPassword(password, s.dummyHash)
+		return ErrInvalidCredentials
+	}
+	if err != nil {
+		return err
+	}
+	now := s.opts.Now()
+	if lockedUntil > now.Unix() {
+		return &LockedError{Until: time.Unix(lockedUntil, 0)}
+	}
+
+	ok, params, err := verifyPassword(password, hash)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return s.recordFailure(ctx, username, now)
+	}
+	if failures > 0 || lockedUntil > 0 {
+		if _, err := s.db.ExecContext(ctx, "UPDATE users SET failed_attempts = 0, first_failure_at = 0, locked_until = 0 WHERE username = ?", username); err != nil {
+			return err
+		}
+	}
+	if params != s.opts.Params {
+		return s.rehash(ctx, username, password, hash)
+	}
+	return nil
+}
+
+// recordFailure counts a failed login and locks the account once the count
+// reaches the limit. The count is incremented in SQL rather than written
+// back from the value read by Authenticate, so concurrent wrong guesses
+// each count.
+func (s *UserStore) recordFailure(ctx context.Context, username string, now time.Time) error {
+	l := s.opts.Lockout
+	if l.MaxFailures < 0 {
+		return ErrInvalidCredentials
+	}
+	// a failure outside the window starts a new one; SET sees the old row
+	var failures int
+	err := s.db.QueryRowContext(ctx, `UPDATE users SET
+			failed_attempts = CASE WHEN first_failure_at = 0 OR ?1 - first_failure_at > ?2 THEN 1 ELSE failed_attempts + 1 END,
+			first_failure_at = CASE WHEN first_failure_at = 0 OR ?1 - first_failure_at > ?2 THEN ?1 ELSE first_failure_at END
+		WHERE username = ?3 RETURNING failed_attempts`,
+		now.Unix(), int64(l.Window/time.Second), username).Scan(&failures)
+	if err != nil {
+		return err
+	}
+	if failures < l.MaxFailures {
+		return ErrInvalidCredentials
+	}
+	lockedUntil := now.Add(l.Duration).Unix()
+	_, err = s.db.ExecContext(ctx, "UPDATE users SET failed_attempts = 0, first_failure_at = 0, locked_until = MAX(locked_until, ?) WHERE username = ?",
+		lockedUntil, username)
+	if err != nil {
+		return err
+	}
+	return &LockedError{Until: time.Unix(lockedUntil, 0)}
+}
+
+// rehash upgrades a hash to the current parameters. The history entry for
+// the same password is updated too, so NotReused keeps working.
+func (s *UserStore) rehash(ctx context.Context, username, password, old string) error {
+	hash, err := hashPassword(password, s.opts.Params)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	// compare-and-swap, in case a password change won the race
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET password_hash = ? WHERE username = ? AND password_hash = ?", hash, username, old); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE password_history SET password_hash = ? WHERE username = ? AND password_hash = ?", hash, username, old); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ChangePassword replaces the password after checking the old one and the
+// policy, including the password history.
+func (s *UserStore) ChangePassword(ctx context.Context, username, oldPassword, newPassword string) error {
+	if err := s.Authenticate(ctx, username, oldPassword); err != nil {
+		return err
+	}
+	history, err := s.history(ctx, username)
+	if err != nil {
+		return err
+	}
+	if err := s.opts.Policy.Check(Candidate{Username: username, Password: newPassword, History: history}); err != nil {
+		return err
+	}
+	hash, err := hashPassword(newPassword, s.opts.Params)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	now := s.opts.Now().Unix()
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET password_hash = ? WHERE username = ?", hash, username); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO password_history (username, password_hash, changed_at) VALUES (?, ?, ?)", username, hash, now); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM password_history WHERE username = ? AND rowid NOT IN (
+		SELECT rowid FROM password_history WHERE username = ? ORDER BY changed_at DESC, rowid DESC LIMIT ?)`,
+		username, username, s.opts.History)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// history returns the user's previous hashes, newest first.
+func (s *UserStore) history(ctx context.Context, username string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT password_hash FROM password_history WHERE username = ? ORDER BY changed_at DESC, rowid DESC LIMIT ?", username, s.opts.History)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var h string
+		if err := rows.Scan(&h); err != nil {
+			return nil, err
+		}
+		out = append(out, h)
+	}
+	return out, rows.Err()
+}
+
+// Unlock clears a lockout, e.g. after the user proved their identity
+// another way.
+func (s *UserStore) Unlock(ctx context.Context, username string) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE users SET failed_attempts = 0, first_failure_at = 0, locked_until = 0 WHERE username = ?", username)
+	return err
+}
+
+var emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
+
+// ValidateEmail is a ValidateUsername for stores keyed by e-mail address.
+func ValidateEmail(username string) error {
+	if !emailRegex.MatchString(username) {
+		return errors.New("username must be a valid email address")
+	}
+	return nil
+}
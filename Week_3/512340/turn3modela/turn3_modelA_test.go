@@ -0,0 +1,277 @@
+// auth_test.go
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+)
+
+// cheap parameters keep the tests fast; production uses DefaultArgon2Params
+var testParams = Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLen: 16, KeyLen: 32}
+
+func newTestStore(t *testing.T, opts Options) (*UserStore, *sql.DB) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "users.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if opts.Params == (Argon2Params{}) {
+		opts.Params = testParams
+	}
+	store, err := NewUserStore(db, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, db
+}
+
+func TestRegisterAndAuthenticate(t *testing.T) {
+	ctx := context.Background()
+	store, db := newTestStore(t, Options{ValidateUsername: ValidateEmail})
+
+	if err := store.Register(ctx, "testuser@example.com", "Tr0ub4dor&3-horse"); err != nil {
+		t.Fatalf("register failed: %s", err)
+	}
+	if err := store.Authenticate(ctx, "testuser@example.com", "Tr0ub4dor&3-horse"); err != nil {
+		t.Errorf("authentication failed: %v", err)
+	}
+	if err := store.Authenticate(ctx, "testuser@example.com", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("wrong password: err = %v", err)
+	}
+	if err := store.Authenticate(ctx, "nobody@example.com", "Tr0ub4dor&3-horse"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("unknown user: err = %v", err)
+	}
+	if err := store.Register(ctx, "testuser@example.com", "An0ther#Passphrase"); !errors.Is(err, ErrUserExists) {
+		t.Errorf("duplicate: err = %v", err)
+	}
+	if err := store.Register(ctx, "invalidemail", "Val1d#Passphrase"); err == nil {
+		t.Error("expected registration failure; invalid email format")
+	}
+
+	var stored string
+	db.QueryRow("SELECT password_hash FROM users").Scan(&stored)
+	if !strings.HasPrefix(stored, "$argon2id$v=19$m=64,t=1,p=1$") || strings.Contains(stored, "Tr0ub4dor") {
+		t.Errorf("stored hash = %q", stored)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	policy := DefaultPolicy(nil)
+	tests := []struct {
+		username, password string
+		violation          string // substring of the expected violation, "" if valid
+	}{
+		{"alice", "Sh0rt!", "at least 10"},
+		{"alice", "alllowercaseletters", "at least 3"},
+		{"alice", "Password123!", "too easy"},
+		{"alice", "P@ssw0rd2024!", "too easy"},
+		{"alice", "Qwertyuiop1!", "too easy"},
+		{"alice", "Abcdefgh1234!", "too easy"},
+		{"alice", "Alice#Rocks2024", "username"},
+		{"alice@example.com", "ecila-Vq7#mLp2x", "username"},
+		{"alice", "correct-Horse-battery-7", ""},
+		{"alice", "vT9#kq2Lm$wZ", ""},
+	}
+	for _, tt := range tests {
+		err := policy.Check(Candidate{Username: tt.username, Password: tt.password})
+		if tt.violation == "" {
+			if err != nil {
+				t.Errorf("%q: unexpected error %v (entropy %.1f)", tt.password, err, EstimateEntropy(tt.password, tt.username))
+			}
+			continue
+		}
+		var pe *PolicyError
+		if !errors.As(err, &pe) || !strings.Contains(err.Error(), tt.violation) {
+			t.Errorf("%q: err = %v, want a violation mentioning %q", tt.password, err, tt.violation)
+		}
+	}
+}
+
+func TestRehashOnLogin(t *testing.T) {
+	ctx := context.Background()
+	store, db := newTestStore(t, Options{})
+	if err := store.Register(ctx, "bob", "vT9#kq2Lm$wZ"); err != nil {
+		t.Fatal(err)
+	}
+
+	stronger := testParams
+	stronger.Iterations = 2
+	store.opts.Params = stronger
+	if err := store.Authenticate(ctx, "bob", "vT9#kq2Lm$wZ"); err != nil {
+		t.Fatal(err)
+	}
+	var hash, history string
+	db.QueryRow("SELECT password_hash FROM users").Scan(&hash)
+	db.QueryRow("SELECT password_hash FROM password_history").Scan(&history)
+	if !strings.Contains(hash, "t=2") || hash != history {
+		t.Fatalf("after rehash: users=%q history=%q", hash, history)
+	}
+	if err := store.Authenticate(ctx, "bob", "vT9#kq2Lm$wZ"); err != nil {
+		t.Fatalf("login after rehash: %v", err)
+	}
+}
+
+func TestLockout(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1_700_000_000, 0)
+	store, _ := newTestStore(t, Options{
+		Lockout: Lockout{MaxFailures: 3, Window: time.Minute, Duration: 10 * time.Minute},
+		Now:     func() time.Time { return now },
+	})
+	if err := store.Register(ctx, "carol", "vT9#kq2Lm$wZ"); err != nil {
+		t.Fatal(err)
+	}
+
+	// failures spread wider than the window do not add up
+	for i := 0; i < 4; i++ {
+		if err := store.Authenticate(ctx, "carol", "guess"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("attempt %d: err = %v", i, err)
+		}
+		now = now.Add(40 * time.Second)
+	}
+
+	store.Authenticate(ctx, "carol", "guess")
+	store.Authenticate(ctx, "carol", "guess")
+	var locked *LockedError
+	if err := store.Authenticate(ctx, "carol", "guess"); !errors.As(err, &locked) {
+		t.Fatalf("third failure in window: err = %v", err)
+	}
+	if err := store.Authenticate(ctx, "carol", "vT9#kq2Lm$wZ"); !errors.As(err, &locked) {
+		t.Fatalf("correct password while locked: err = %v", err)
+	}
+	now = now.Add(11 * time.Minute)
+	if err := store.Authenticate(ctx, "carol", "vT9#kq2Lm$wZ"); err != nil {
+		t.Fatalf("after lockout expired: %v", err)
+	}
+}
+
+func TestPartialLockoutConfig(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1_700_000_000, 0)
+	// only MaxFailures is set; the window and duration take the defaults
+	store, _ := newTestStore(t, Options{
+		Lockout: Lockout{MaxFailures: 2},
+		Now:     func() time.Time { return now },
+	})
+	if store.opts.Lockout != (Lockout{MaxFailures: 2, Window: DefaultLockout.Window, Duration: DefaultLockout.Duration}) {
+		t.Errorf("lockout = %+v", store.opts.Lockout)
+	}
+	if err := store.Register(ctx, "dave", "vT9#kq2Lm$wZ"); err != nil {
+		t.Fatal(err)
+	}
+	store.Authenticate(ctx, "dave", "guess")
+	now = now.Add(time.Minute)
+	var locked *LockedError
+	if err := store.Authenticate(ctx, "dave", "guess"); !errors.As(err, &locked) {
+		t.Fatalf("second failure a minute later: err = %v", err)
+	}
+	if want := now.Add(DefaultLockout.Duration); !locked.Until.Equal(want) {
+		t.Errorf("locked until %v, want %v", locked.Until, want)
+	}
+
+	// a zero lockout is the default one
+	store, _ = newTestStore(t, Options{})
+	if store.opts.Lockout != DefaultLockout {
+		t.Errorf("zero lockout = %+v", store.opts.Lockout)
+	}
+}
+
+func TestConcurrentFailuresAllCount(t *testing.T) {
+	ctx := context.Background()
+	const guesses = 8
+	// a slower hash keeps every guess between its read and its write at once
+	store, _ := newTestStore(t, Options{
+		Params:  Argon2Params{Memory: 8 * 1024, Iterations: 2, Parallelism: 1, SaltLen: 16, KeyLen: 32},
+		Lockout: Lockout{MaxFailures: guesses, Window: time.Hour, Duration: time.Hour},
+	})
+	if err := store.Register(ctx, "erin", "vT9#kq2Lm$wZ"); err != nil {
+		t.Fatal(err)
+	}
+	errs := make(chan error, guesses)
+	for i := 0; i < guesses; i++ {
+		go func() { errs <- store.Authenticate(ctx, "erin", "guess") }()
+	}
+	lockouts := 0
+	for i := 0; i < guesses; i++ {
+		var locked *LockedError
+		switch err := <-errs; {
+		case errors.As(err, &locked):
+			lockouts++
+		case !errors.Is(err, ErrInvalidCredentials):
+			t.Fatalf("guess: %v", err)
+		}
+	}
+	if lockouts != 1 {
+		t.Errorf("%d of %d concurrent guesses reported the lockout, want 1", lockouts, guesses)
+	}
+	var locked *LockedError
+	if err := store.Authenticate(ctx, "erin", "vT9#kq2Lm$wZ"); !errors.As(err, &locked) {
+		t.Errorf("account not locked after %d concurrent failures: %v", guesses, err)
+	}
+}
+
+func TestPasswordHistory(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t, Options{History: 2, Policy: Policy{MinLength(8), NotReused(2)}})
+	if err := store.Register(ctx, "dave", "first-pass"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ChangePassword(ctx, "dave", "first-pass", "second-pass"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ChangePassword(ctx, "dave", "second-pass", "first-pass"); err == nil {
+		t.Fatal("reusing the previous password succeeded")
+	}
+	if err := store.ChangePassword(ctx, "dave", "second-pass", "third-pass"); err != nil {
+		t.Fatal(err)
+	}
+	// only the last two are remembered, so the first may come back
+	if err := store.ChangePassword(ctx, "dave", "third-pass", "first-pass"); err != nil {
+		t.Fatalf("password older than the history: %v", err)
+	}
+}
+
+func TestBreachList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaked := []string{"Summer2024!", "Summer2024!", "vT9#kq2Lm$wZ-leaked", "hunter2"}
+	if err := WriteBreachList(f, leaked); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	list, err := OpenBreachList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer list.Close()
+	if n, err := list.Count("Summer2024!"); err != nil || n != 2 {
+		t.Errorf("Count(Summer2024!) = %d, %v", n, err)
+	}
+	if n, _ := list.Count("never-leaked-9#Q"); n != 0 {
+		t.Errorf("Count of an unlisted password = %d", n)
+	}
+
+	err = Policy{NotBreached(list)}.Check(Candidate{Password: "vT9#kq2Lm$wZ-leaked"})
+	if err == nil || !strings.Contains(err.Error(), "breached") {
+		t.Errorf("breached password: err = %v", err)
+	}
+
+	os.WriteFile(path, []byte("FFFFF00000000000000000000000000000000000:1\n00000000000000000000000000000000000000AA:1\n"), 0o644)
+	if _, err := OpenBreachList(path); err == nil {
+		t.Error("unsorted list was accepted")
+	}
+}
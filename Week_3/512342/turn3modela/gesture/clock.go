@@ -0,0 +1,120 @@
+package gesture
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the recognizer's only source of time. Production code uses
+// RealClock; tests use a FakeClock and advance it by hand so timer-driven
+// gestures (long-press, hover-intent) fire at exact, repeatable instants.
+type Clock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is the part of *time.Timer the recognizer needs.
+type Timer interface {
+	Stop() bool
+}
+
+// RealClock is backed by the time package.
+type RealClock struct{}
+
+func (RealClock) Now() time.Time { return time.Now() }
+
+func (RealClock) AfterFunc(d time.Duration, f func()) Timer { return time.AfterFunc(d, f) }
+
+// FakeClock only moves when Advance or Set is called. Due timers run
+// synchronously on the calling goroutine, in deadline order.
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+	seq    int
+}
+
+type fakeTimer struct {
+	clock *FakeClock
+	when  time.Time
+	seq   int // breaks ties so timers due together fire in creation order
+	f     func()
+}
+
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	t := &fakeTimer{clock: c, when: c.now.Add(d), seq: c.seq, f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, other := range c.timers {
+		if other == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Advance moves the clock forward by d, firing every timer that falls due.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+// Set moves the clock to t, firing every timer due at or before t. Each
+// timer sees Now() equal to its own deadline, and timers it schedules are
+// fired too if they fall due before t. Moving backwards is a no-op.
+func (c *FakeClock) Set(t time.Time) {
+	for {
+		c.mu.Lock()
+		if len(c.timers) == 0 {
+			break
+		}
+		sort.Slice(c.timers, func(i, j int) bool {
+			a, b := c.timers[i], c.timers[j]
+			if !a.when.Equal(b.when) {
+				return a.when.Before(b.when)
+			}
+			return a.seq < b.seq
+		})
+		next := c.timers[0]
+		if next.when.After(t) {
+			break
+		}
+		c.timers = c.timers[1:]
+		if next.when.After(c.now) {
+			c.now = next.when
+		}
+		c.mu.Unlock()
+		next.f()
+	}
+	if t.After(c.now) {
+		c.now = t
+	}
+	c.mu.Unlock()
+}
+
+// Pending reports how many timers are scheduled and not yet fired.
+func (c *FakeClock) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
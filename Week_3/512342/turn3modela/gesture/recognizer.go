@@ -0,0 +1,414 @@
+// Package gesture turns a stream of raw, timestamped mouse events into
+// higher-level gestures: click, double-click, drag start/move/end,
+// long-press, hover-intent and swipe. All timing goes through an injectable
+// Clock, so recorded traces replay to the same gestures every time.
+package gesture
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// EventType is a raw input event.
+type EventType string
+
+const (
+	Down  EventType = "down"  // button pressed
+	Up    EventType = "up"    // button released
+	Move  EventType = "move"  // pointer moved, with or without a button held
+	Leave EventType = "leave" // pointer left the target
+)
+
+// Event is a raw mouse event. A zero Time means "now" on the recognizer's
+// clock; recorded traces carry their own timestamps.
+type Event struct {
+	Type EventType
+	X, Y int
+	Time time.Time
+}
+
+// Type identifies a recognised gesture.
+type Type string
+
+const (
+	Click       Type = "click"
+	DoubleClick Type = "double_click"
+	DragStart   Type = "drag_start"
+	DragMove    Type = "drag_move"
+	DragEnd     Type = "drag_end"
+	LongPress   Type = "long_press"
+	HoverIntent Type = "hover_intent"
+	Swipe       Type = "swipe"
+)
+
+// Direction is the dominant axis and sense of a swipe.
+type Direction string
+
+const (
+	SwipeLeft  Direction = "left"
+	SwipeRight Direction = "right"
+	SwipeUp    Direction = "up"
+	SwipeDown  Direction = "down"
+)
+
+// Gesture is what the recognizer emits. X, Y is where it happened; for drag
+// and swipe gestures StartX, StartY is where the button went down and DX, DY
+// the total displacement from there. Duration is the time since the button
+// went down (or, for hover-intent, since the pointer settled).
+type Gesture struct {
+	Type      Type
+	X, Y      int
+	StartX    int
+	StartY    int
+	DX, DY    int
+	Direction Direction // swipes only
+	Time      time.Time
+	Duration  time.Duration
+}
+
+func (g Gesture) String() string {
+	switch g.Type {
+	case DragStart, DragMove, DragEnd:
+		return fmt.Sprintf("%s at (%d,%d) delta (%d,%d)", g.Type, g.X, g.Y, g.DX, g.DY)
+	case Swipe:
+		return fmt.Sprintf("%s %s from (%d,%d) to (%d,%d) in %v", g.Type, g.Direction, g.StartX, g.StartY, g.X, g.Y, g.Duration)
+	case LongPress, HoverIntent:
+		return fmt.Sprintf("%s at (%d,%d) after %v", g.Type, g.X, g.Y, g.Duration)
+	}
+	return fmt.Sprintf("%s at (%d,%d)", g.Type, g.X, g.Y)
+}
+
+// Config holds the recognizer thresholds. Zero fields take the defaults.
+type Config struct {
+	// DoubleClickInterval is the longest gap between two clicks that still
+	// counts as a double-click, and DoubleClickRadius how far apart they may be.
+	DoubleClickInterval time.Duration
+	DoubleClickRadius   int
+	// DragThreshold is how far the pointer must move with the button held
+	// before a press becomes a drag. It also cancels a pending long-press.
+	DragThreshold int
+	// LongPressDuration is how long the button must stay down, without
+	// dragging, to fire a long-press. A long-press suppresses the click.
+	LongPressDuration time.Duration
+	// HoverIntentDelay is how long the pointer must stay within
+	// HoverIntentRadius of one spot, with no button held, to signal intent.
+	HoverIntentDelay  time.Duration
+	HoverIntentRadius int
+	// A drag that covers at least SwipeMinDistance within SwipeMaxDuration
+	// is also reported as a swipe when it ends.
+	SwipeMinDistance int
+	SwipeMaxDuration time.Duration
+}
+
+// DefaultConfig uses common desktop values.
+var DefaultConfig = Config{
+	DoubleClickInterval: 500 * time.Millisecond,
+	DoubleClickRadius:   4,
+	DragThreshold:       5,
+	LongPressDuration:   600 * time.Millisecond,
+	HoverIntentDelay:    300 * time.Millisecond,
+	HoverIntentRadius:   7,
+	SwipeMinDistance:    50,
+	SwipeMaxDuration:    300 * time.Millisecond,
+}
+
+func (c Config) withDefaults() Config {
+	d := DefaultConfig
+	if c.DoubleClickInterval <= 0 {
+		c.DoubleClickInterval = d.DoubleClickInterval
+	}
+	if c.DoubleClickRadius <= 0 {
+		c.DoubleClickRadius = d.DoubleClickRadius
+	}
+	if c.DragThreshold <= 0 {
+		c.DragThreshold = d.DragThreshold
+	}
+	if c.LongPressDuration <= 0 {
+		c.LongPressDuration = d.LongPressDuration
+	}
+	if c.HoverIntentDelay <= 0 {
+		c.HoverIntentDelay = d.HoverIntentDelay
+	}
+	if c.HoverIntentRadius <= 0 {
+		c.HoverIntentRadius = d.HoverIntentRadius
+	}
+	if c.SwipeMinDistance <= 0 {
+		c.SwipeMinDistance = d.SwipeMinDistance
+	}
+	if c.SwipeMaxDuration <= 0 {
+		c.SwipeMaxDuration = d.SwipeMaxDuration
+	}
+	return c
+}
+
+type state int
+
+const (
+	idle state = iota
+	pressed
+	dragging
+	longPressed
+)
+
+type point struct{ x, y int }
+
+func (p point) dist(q point) float64 {
+	return math.Hypot(float64(p.x-q.x), float64(p.y-q.y))
+}
+
+// Callback receives recognised gestures.
+type Callback func(Gesture)
+
+// Recognizer is a state machine fed one raw event at a time. It is safe for
+// concurrent use; callbacks run without the lock held, so they may call
+// Feed themselves.
+type Recognizer struct {
+	cfg   Config
+	clock Clock
+
+	mu        sync.Mutex
+	callbacks []Callback
+	state     state
+	down      point
+	downAt    time.Time
+	last      point
+	press     int // incremented on every Down so stale timers can tell
+	longTimer Timer
+
+	lastClick   point
+	lastClickAt time.Time // zero once a click has been paired
+
+	hoverAnchor point
+	hoverSince  time.Time
+	hoverTimer  Timer
+	hovered     bool
+	hoverGen    int
+}
+
+// New creates a recognizer. A nil clock means RealClock.
+func New(cfg Config, clock Clock) *Recognizer {
+	if clock == nil {
+		clock = RealClock{}
+	}
+	return &Recognizer{cfg: cfg.withDefaults(), clock: clock}
+}
+
+// AddCallback registers a gesture callback, in the style of
+// MouseEventChain.AddCallback.
+func (r *Recognizer) AddCallback(cb Callback) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.callbacks = append(r.callbacks, cb)
+}
+
+// Feed consumes one raw event and returns the gestures it completed, which
+// are also passed to every callback. Gestures fired later by timers only go
+// to the callbacks.
+func (r *Recognizer) Feed(e Event) []Gesture {
+	r.mu.Lock()
+	if e.Time.IsZero() {
+		e.Time = r.clock.Now()
+	}
+	p := point{e.X, e.Y}
+	var out []Gesture
+	switch e.Type {
+	case Down:
+		out = r.onDown(p, e.Time)
+	case Up:
+		out = r.onUp(p, e.Time)
+	case Move:
+		out = r.onMove(p, e.Time)
+	case Leave:
+		out = r.onLeave(p, e.Time)
+	}
+	r.last = p
+	cbs := r.callbacks
+	r.mu.Unlock()
+	dispatch(cbs, out)
+	return out
+}
+
+func dispatch(cbs []Callback, gs []Gesture) {
+	for _, g := range gs {
+		for _, cb := range cbs {
+			cb(g)
+		}
+	}
+}
+
+func (r *Recognizer) onDown(p point, t time.Time) []Gesture {
+	var out []Gesture
+	if r.state == dragging {
+		// a lost Up; close the old drag before starting over
+		out = append(out, r.gesture(DragEnd, r.last, t))
+	}
+	r.cancelHover()
+	r.stopLong()
+	r.state = pressed
+	r.down, r.downAt = p, t
+	r.press++
+	press := r.press
+	r.longTimer = r.clock.AfterFunc(r.cfg.LongPressDuration, func() { r.fireLongPress(press) })
+	return out
+}
+
+func (r *Recognizer) onUp(p point, t time.Time) []Gesture {
+	var out []Gesture
+	switch r.state {
+	case pressed:
+		out = append(out, r.gesture(Click, p, t))
+		if !r.lastClickAt.IsZero() &&
+			t.Sub(r.lastClickAt) <= r.cfg.DoubleClickInterval &&
+			p.dist(r.lastClick) <= float64(r.cfg.DoubleClickRadius) {
+			out = append(out, r.gesture(DoubleClick, p, t))
+			// a third click starts a new pair rather than a second double
+			r.lastClickAt = time.Time{}
+		} else {
+			r.lastClick, r.lastClickAt = p, t
+		}
+	case dragging:
+		out = append(out, r.gesture(DragEnd, p, t))
+		if sw, ok := r.swipe(p, t); ok {
+			out = append(out, sw)
+		}
+		r.lastClickAt = time.Time{}
+	case longPressed:
+		r.lastClickAt = time.Time{}
+	}
+	r.stopLong()
+	r.state = idle
+	return out
+}
+
+func (r *Recognizer) onMove(p point, t time.Time) []Gesture {
+	switch r.state {
+	case pressed:
+		if p.dist(r.down) <= float64(r.cfg.DragThreshold) {
+			return nil
+		}
+		r.stopLong()
+		r.state = dragging
+		return []Gesture{r.gesture(DragStart, r.down, t), r.gesture(DragMove, p, t)}
+	case dragging:
+		return []Gesture{r.gesture(DragMove, p, t)}
+	case longPressed:
+		return nil
+	}
+	r.trackHover(p, t)
+	return nil
+}
+
+func (r *Recognizer) onLeave(p point, t time.Time) []Gesture {
+	r.cancelHover()
+	r.hovered = false
+	if r.state == dragging {
+		// the button may be released outside; end the drag here
+		r.state = idle
+		r.stopLong()
+		return []Gesture{r.gesture(DragEnd, p, t)}
+	}
+	if r.state != idle {
+		r.stopLong()
+		r.state = idle
+	}
+	return nil
+}
+
+// trackHover restarts the hover-intent timer whenever the pointer strays
+// outside the radius around where it last settled.
+func (r *Recognizer) trackHover(p point, t time.Time) {
+	if r.hoverTimer != nil && p.dist(r.hoverAnchor) <= float64(r.cfg.HoverIntentRadius) {
+		return
+	}
+	if r.hovered && p.dist(r.hoverAnchor) <= float64(r.cfg.HoverIntentRadius) {
+		return
+	}
+	r.cancelHover()
+	r.hovered = false
+	r.hoverAnchor, r.hoverSince = p, t
+	r.hoverGen++
+	gen := r.hoverGen
+	r.hoverTimer = r.clock.AfterFunc(r.cfg.HoverIntentDelay, func() { r.fireHover(gen) })
+}
+
+func (r *Recognizer) cancelHover() {
+	if r.hoverTimer != nil {
+		r.hoverTimer.Stop()
+		r.hoverTimer = nil
+	}
+	r.hoverGen++
+}
+
+func (r *Recognizer) stopLong() {
+	if r.longTimer != nil {
+		r.longTimer.Stop()
+		r.longTimer = nil
+	}
+}
+
+func (r *Recognizer) fireLongPress(press int) {
+	r.mu.Lock()
+	if r.press != press || r.state != pressed {
+		r.mu.Unlock()
+		return
+	}
+	r.state = longPressed
+	r.longTimer = nil
+	g := r.gesture(LongPress, r.last, r.clock.Now())
+	cbs := r.callbacks
+	r.mu.Unlock()
+	dispatch(cbs, []Gesture{g})
+}
+
+func (r *Recognizer) fireHover(gen int) {
+	r.mu.Lock()
+	if r.hoverGen != gen || r.state != idle {
+		r.mu.Unlock()
+		return
+	}
+	r.hoverTimer = nil
+	r.hovered = true
+	now := r.clock.Now()
+	g := Gesture{Type: HoverIntent, X: r.last.x, Y: r.last.y, StartX: r.hoverAnchor.x, StartY: r.hoverAnchor.y,
+		Time: now, Duration: now.Sub(r.hoverSince)}
+	cbs := r.callbacks
+	r.mu.Unlock()
+	dispatch(cbs, []Gesture{g})
+}
+
+func (r *Recognizer) swipe(p point, t time.Time) (Gesture, bool) {
+	if t.Sub(r.downAt) > r.cfg.SwipeMaxDuration || p.dist(r.down) < float64(r.cfg.SwipeMinDistance) {
+		return Gesture{}, false
+	}
+	g := r.gesture(Swipe, p, t)
+	dx, dy := g.DX, g.DY
+	switch {
+	case abs(dx) >= abs(dy) && dx > 0:
+		g.Direction = SwipeRight
+	case abs(dx) >= abs(dy):
+		g.Direction = SwipeLeft
+	case dy > 0:
+		g.Direction = SwipeDown // screen coordinates grow downwards
+	default:
+		g.Direction = SwipeUp
+	}
+	return g, true
+}
+
+func (r *Recognizer) gesture(typ Type, p point, t time.Time) Gesture {
+	return Gesture{
+		Type: typ, X: p.x, Y: p.y,
+		StartX: r.down.x, StartY: r.down.y,
+		DX: p.x - r.down.x, DY: p.y - r.down.y,
+		Time: t, Duration: t.Sub(r.downAt),
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,187 @@
+package gesture
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// replay runs a textual trace through a fresh recognizer and returns the
+// gesture types in emission order.
+func replay(t *testing.T, cfg Config, trace string) []Gesture {
+	t.Helper()
+	events, err := ParseTrace(strings.NewReader(trace), epoch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := NewFakeClock(epoch)
+	r := New(cfg, clock)
+	var got []Gesture
+	r.AddCallback(func(g Gesture) { got = append(got, g) })
+	Replay(r, clock, events, 5*time.Second)
+	return got
+}
+
+func types(gs []Gesture) string {
+	var s []string
+	for _, g := range gs {
+		s = append(s, string(g.Type))
+	}
+	return strings.Join(s, " ")
+}
+
+func TestRecognizerTraces(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   Config
+		trace string
+		want  string
+	}{
+		{"click", Config{}, `
+			0ms   down 10 10
+			80ms  up   10 10`,
+			"click"},
+		{"double click", Config{}, `
+			0ms   down 10 10
+			80ms  up   10 10
+			300ms down 11 10
+			380ms up   11 10`,
+			"click click double_click"},
+		{"clicks too far apart in time", Config{}, `
+			0ms   down 10 10
+			80ms  up   10 10
+			700ms down 10 10
+			780ms up   10 10`,
+			"click click"},
+		{"custom double click interval", Config{DoubleClickInterval: time.Second}, `
+			0ms   down 10 10
+			80ms  up   10 10
+			700ms down 10 10
+			780ms up   10 10`,
+			"click click double_click"},
+		{"clicks too far apart in space", Config{}, `
+			0ms   down 10 10
+			80ms  up   10 10
+			200ms down 40 10
+			280ms up   40 10`,
+			"click click"},
+		{"triple click is one double", Config{}, `
+			0ms   down 10 10
+			50ms  up   10 10
+			100ms down 10 10
+			150ms up   10 10
+			200ms down 10 10
+			250ms up   10 10`,
+			"click click double_click click"},
+		{"jitter below drag threshold is a click", Config{}, `
+			0ms   down 10 10
+			20ms  move 13 12
+			80ms  up   13 12`,
+			"click"},
+		{"drag", Config{}, `
+			0ms    down 10 10
+			100ms  move 14 10
+			200ms  move 30 10
+			300ms  move 60 20
+			900ms  up   60 20`,
+			"drag_start drag_move drag_move drag_end"},
+		{"swipe", Config{}, `
+			0ms    down 200 100
+			50ms   move 180 102
+			100ms  move 130 105
+			150ms  up   120 106`,
+			"drag_start drag_move drag_move drag_end swipe"},
+		{"long press", Config{}, `
+			0ms    down 10 10
+			300ms  move 12 11
+			1000ms up   12 11`,
+			"long_press"},
+		{"drag cancels long press", Config{}, `
+			0ms    down 10 10
+			300ms  move 40 10
+			1000ms up   40 10`,
+			"drag_start drag_move drag_end"},
+		{"hover intent", Config{}, `
+			0ms    move 10 10
+			100ms  move 12 11
+			200ms  move 13 13`,
+			"hover_intent"},
+		{"passing through is not hover intent", Config{}, `
+			0ms    move 10 10
+			100ms  move 40 10
+			200ms  move 80 10
+			250ms  leave 90 10`,
+			""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := types(replay(t, tc.cfg, tc.trace)); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGestureDetails(t *testing.T) {
+	got := replay(t, Config{}, `
+		0ms    down 10 10
+		700ms  up   10 10
+		800ms  move 10 10
+		900ms  move 15 12
+		2000ms down 100 100
+		2040ms move 100 60
+		2080ms move 100 20
+		2100ms up   100 10`)
+	want := "long_press hover_intent drag_start drag_move drag_move drag_end swipe"
+	if types(got) != want {
+		t.Fatalf("got %q, want %q", types(got), want)
+	}
+	lp, hover, sw := got[0], got[1], got[len(got)-1]
+	if lp.Duration != 600*time.Millisecond || !lp.Time.Equal(epoch.Add(600*time.Millisecond)) {
+		t.Errorf("long press at %v after %v", lp.Time.Sub(epoch), lp.Duration)
+	}
+	if hover.Duration != 300*time.Millisecond || hover.X != 15 || hover.Y != 12 {
+		t.Errorf("hover intent %+v", hover)
+	}
+	if sw.Direction != SwipeUp || sw.DY != -90 || sw.Duration != 100*time.Millisecond {
+		t.Errorf("swipe %+v", sw)
+	}
+}
+
+func TestLiveTimersUseInjectedClock(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	r := New(Config{LongPressDuration: time.Second}, clock)
+	var got []Gesture
+	r.AddCallback(func(g Gesture) { got = append(got, g) })
+
+	r.Feed(Event{Type: Down, X: 5, Y: 5})
+	clock.Advance(999 * time.Millisecond)
+	if len(got) != 0 {
+		t.Fatalf("long press fired early: %v", got)
+	}
+	clock.Advance(time.Millisecond)
+	if types(got) != "long_press" {
+		t.Fatalf("got %q", types(got))
+	}
+	if out := r.Feed(Event{Type: Up, X: 5, Y: 5}); len(out) != 0 {
+		t.Errorf("release after long press emitted %v", out)
+	}
+	if n := clock.Pending(); n != 0 {
+		t.Errorf("%d timers left pending", n)
+	}
+}
+
+func TestParseTraceErrors(t *testing.T) {
+	for _, trace := range []string{
+		"0ms down 1",
+		"x down 1 1",
+		"0ms press 1 1",
+		"10ms down 1 1\n5ms up 1 1",
+	} {
+		if _, err := ParseTrace(strings.NewReader(trace), epoch); err == nil {
+			t.Errorf("ParseTrace(%q) succeeded", trace)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package gesture
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseTrace reads a recorded event trace, one event per line:
+//
+//	<offset> <down|up|move|leave> <x> <y>
+//
+// where offset is a Go duration from start, e.g. "0ms" or "1.25s". Blank
+// lines and lines starting with '#' are skipped. Offsets must not decrease.
+func ParseTrace(r io.Reader, start time.Time) ([]Event, error) {
+	var events []Event
+	var prev time.Duration
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		f := strings.Fields(line)
+		if len(f) != 4 {
+			return nil, fmt.Errorf("trace line %d: want 4 fields, got %d", n, len(f))
+		}
+		off, err := time.ParseDuration(f[0])
+		if err != nil {
+			return nil, fmt.Errorf("trace line %d: %w", n, err)
+		}
+		if off < prev {
+			return nil, fmt.Errorf("trace line %d: offset %v goes backwards", n, off)
+		}
+		prev = off
+		typ := EventType(f[1])
+		switch typ {
+		case Down, Up, Move, Leave:
+		default:
+			return nil, fmt.Errorf("trace line %d: unknown event %q", n, f[1])
+		}
+		x, errX := strconv.Atoi(f[2])
+		y, errY := strconv.Atoi(f[3])
+		if errX != nil || errY != nil {
+			return nil, fmt.Errorf("trace line %d: bad coordinates", n)
+		}
+		events = append(events, Event{Type: typ, X: x, Y: y, Time: start.Add(off)})
+	}
+	return events, sc.Err()
+}
+
+// Replay feeds events to r, first moving clock to each event's timestamp so
+// that timers due in between fire exactly where they would have live. The
+// clock is then advanced by settle to flush trailing long-press or
+// hover-intent timers.
+func Replay(r *Recognizer, clock *FakeClock, events []Event, settle time.Duration) {
+	for _, e := range events {
+		clock.Set(e.Time)
+		r.Feed(e)
+	}
+	clock.Advance(settle)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"Week_3/512342/turn3modela/gesture"
+)
+
+type MouseEvent struct {
+	EventType string    // "down", "up", "move", "leave"
+	X, Y      int       // Position of the mouse event
+	Time      time.Time // When the event happened; zero means now
+}
+
+type MouseCallback func(MouseEvent)
+
+type MouseEventChain struct {
+	Callbacks []MouseCallback
+}
+
+func (chain *MouseEventChain) AddCallback(callback MouseCallback) {
+	chain.Callbacks = append(chain.Callbacks, callback)
+}
+
+func (chain *MouseEventChain) HandleEvent(event MouseEvent) {
+	for _, callback := range chain.Callbacks {
+		callback(event)
+	}
+}
+
+// GestureCallback adapts a recognizer to the chain: every raw event is fed
+// to it, and recognised gestures go to the recognizer's own callbacks.
+func GestureCallback(r *gesture.Recognizer) MouseCallback {
+	return func(event MouseEvent) {
+		r.Feed(gesture.Event{
+			Type: gesture.EventType(event.EventType),
+			X:    event.X,
+			Y:    event.Y,
+			Time: event.Time,
+		})
+	}
+}
+
+// demoTrace is a recorded session: a double-click, a long-press, a slow
+// drag, a fast swipe to the right and a pause over one spot.
+const demoTrace = `
+# offset  event  x    y
+0ms       down   100  100
+70ms      up     100  100
+240ms     down   101  100
+300ms     up     101  100
+1000ms    down   200  200
+1800ms    up     200  200
+2500ms    down   50   50
+2600ms    move   58   50
+2800ms    move   90   60
+3100ms    move   120  80
+3300ms    up     120  80
+4000ms    down   10   300
+4060ms    move   60   305
+4120ms    move   140  310
+4150ms    up     170  312
+4500ms    move   400  200
+4600ms    move   402  203
+4700ms    move   403  204
+5200ms    leave  500  200
+`
+
+func main() {
+	tracePath := flag.String("trace", "", "replay events from this trace file instead of the built-in one")
+	dblClick := flag.Duration("double-click", gesture.DefaultConfig.DoubleClickInterval, "double-click interval")
+	threshold := flag.Int("drag-threshold", gesture.DefaultConfig.DragThreshold, "drag threshold in pixels")
+	longPress := flag.Duration("long-press", gesture.DefaultConfig.LongPressDuration, "long-press duration")
+	flag.Parse()
+
+	var src io.Reader = strings.NewReader(demoTrace)
+	if *tracePath != "" {
+		f, err := os.Open(*tracePath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		src = f
+	}
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	events, err := gesture.ParseTrace(src, start)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// The fake clock makes the replay instantaneous and repeatable; a live
+	// application would pass gesture.RealClock{} and zero event times.
+	clock := gesture.NewFakeClock(start)
+	recognizer := gesture.New(gesture.Config{
+		DoubleClickInterval: *dblClick,
+		DragThreshold:       *threshold,
+		LongPressDuration:   *longPress,
+	}, clock)
+	recognizer.AddCallback(func(g gesture.Gesture) {
+		fmt.Printf("%8v  %s\n", g.Time.Sub(start), g)
+	})
+
+	chain := &MouseEventChain{}
+	chain.AddCallback(GestureCallback(recognizer))
+
+	for _, e := range events {
+		clock.Set(e.Time)
+		chain.HandleEvent(MouseEvent{EventType: string(e.Type), X: e.X, Y: e.Y, Time: e.Time})
+	}
+	clock.Advance(5 * time.Second)
+}
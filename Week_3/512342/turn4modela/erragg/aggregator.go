@@ -0,0 +1,237 @@
+// Package erragg deduplicates a stream of errors into groups keyed by a
+// fingerprint, notifies subscribers per group with throttling, raises
+// threshold alerts over sliding windows and exposes the group table as
+// JSON.
+package erragg
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Report is one error occurrence.
+type Report struct {
+	Type    string         // e.g. "client_error", "system_error"
+	Message string         // the raw error text
+	Payload map[string]any // optional context kept as a sample
+	Time    time.Time      // zero means now
+}
+
+// Sample is a stored occurrence of a group.
+type Sample struct {
+	Message string         `json:"message"`
+	Payload map[string]any `json:"payload,omitempty"`
+	Time    time.Time      `json:"time"`
+}
+
+// Group is the aggregate of every report sharing a fingerprint.
+type Group struct {
+	Fingerprint string    `json:"fingerprint"`
+	Type        string    `json:"type"`
+	Template    string    `json:"template"`
+	Count       int       `json:"count"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+	Samples     []Sample  `json:"samples"` // oldest first, at most Options.MaxSamples
+
+	notifiedAt time.Time
+	suppressed int
+}
+
+// Notification is delivered to a group callback. Suppressed counts the
+// reports swallowed by throttling since the previous notification.
+type Notification struct {
+	Group      Group
+	Report     Report
+	New        bool
+	Suppressed int
+}
+
+// GroupCallback receives throttled per-group notifications.
+type GroupCallback func(Notification)
+
+// Options configures an Aggregator. Zero values select the defaults.
+type Options struct {
+	Throttle   time.Duration // min gap between notifications per group, default 30s; < 0 disables
+	MaxSamples int           // samples kept per group, default 5
+	MaxGroups  int           // least recently seen groups are evicted past this, default 1000
+	Now        func() time.Time
+}
+
+type subscriber struct {
+	match func(Report) bool
+	cb    GroupCallback
+}
+
+// Aggregator is safe for concurrent use. Callbacks run after the lock is
+// released, in registration order.
+type Aggregator struct {
+	opts Options
+
+	mu      sync.Mutex
+	groups  map[string]*Group
+	subs    []subscriber
+	rules   []*ruleState
+	alertCb []AlertCallback
+	total   int
+}
+
+func New(opts Options) *Aggregator {
+	if opts.Throttle == 0 {
+		opts.Throttle = 30 * time.Second
+	}
+	if opts.MaxSamples <= 0 {
+		opts.MaxSamples = 5
+	}
+	if opts.MaxGroups <= 0 {
+		opts.MaxGroups = 1000
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &Aggregator{opts: opts, groups: map[string]*Group{}}
+}
+
+// Subscribe registers cb for groups whose reports satisfy match; a nil
+// match receives every group. Throttling is per group: a new group notifies
+// at once, then at most once per Options.Throttle, reaching every matching
+// subscriber together.
+func (a *Aggregator) Subscribe(match func(Report) bool, cb GroupCallback) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.subs = append(a.subs, subscriber{match: match, cb: cb})
+}
+
+// Add records a report and returns the fingerprint of its group.
+func (a *Aggregator) Add(r Report) string {
+	a.mu.Lock()
+	now := a.opts.Now()
+	if r.Time.IsZero() {
+		r.Time = now
+	}
+	fp := Fingerprint(r.Type, r.Message)
+	g, ok := a.groups[fp]
+	isNew := !ok
+	if isNew {
+		a.evict()
+		g = &Group{Fingerprint: fp, Type: r.Type, Template: Normalize(r.Message), FirstSeen: r.Time}
+		a.groups[fp] = g
+	}
+	a.total++
+	g.Count++
+	if r.Time.After(g.LastSeen) {
+		g.LastSeen = r.Time
+	}
+	g.Samples = append(g.Samples, Sample{Message: r.Message, Payload: r.Payload, Time: r.Time})
+	if len(g.Samples) > a.opts.MaxSamples {
+		g.Samples = g.Samples[len(g.Samples)-a.opts.MaxSamples:]
+	}
+
+	var calls []func()
+	if isNew || a.opts.Throttle < 0 || now.Sub(g.notifiedAt) >= a.opts.Throttle {
+		n := Notification{Group: g.snapshot(), Report: r, New: isNew, Suppressed: g.suppressed}
+		g.notifiedAt, g.suppressed = now, 0
+		for _, s := range a.subs {
+			if s.match == nil || s.match(r) {
+				cb := s.cb
+				calls = append(calls, func() { cb(n) })
+			}
+		}
+	} else {
+		g.suppressed++
+	}
+	calls = append(calls, a.evaluate(r, now)...)
+	a.mu.Unlock()
+
+	for _, call := range calls {
+		call()
+	}
+	return fp
+}
+
+// evict drops the least recently seen group when the table is full.
+func (a *Aggregator) evict() {
+	if len(a.groups) < a.opts.MaxGroups {
+		return
+	}
+	var oldest *Group
+	for _, g := range a.groups {
+		if oldest == nil || g.LastSeen.Before(oldest.LastSeen) {
+			oldest = g
+		}
+	}
+	delete(a.groups, oldest.Fingerprint)
+}
+
+func (g *Group) snapshot() Group {
+	c := *g
+	c.Samples = append([]Sample(nil), g.Samples...)
+	return c
+}
+
+// Snapshot returns a copy of every group, most frequent first.
+func (a *Aggregator) Snapshot() []Group {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := make([]Group, 0, len(a.groups))
+	for _, g := range a.groups {
+		out = append(out, g.snapshot())
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].LastSeen.After(out[j].LastSeen)
+	})
+	return out
+}
+
+// Group returns one group by fingerprint.
+func (a *Aggregator) Group(fp string) (Group, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	g, ok := a.groups[fp]
+	if !ok {
+		return Group{}, false
+	}
+	return g.snapshot(), true
+}
+
+// Reset forgets every group; alert windows are kept.
+func (a *Aggregator) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.groups = map[string]*Group{}
+	a.total = 0
+}
+
+type snapshotJSON struct {
+	Total  int     `json:"total"`
+	Groups []Group `json:"groups"`
+	At     string  `json:"generated_at"`
+}
+
+// MarshalJSON renders the current group table.
+func (a *Aggregator) MarshalJSON() ([]byte, error) {
+	groups := a.Snapshot()
+	a.mu.Lock()
+	total := a.total
+	a.mu.Unlock()
+	return json.Marshal(snapshotJSON{Total: total, Groups: groups, At: a.opts.Now().UTC().Format(time.RFC3339)})
+}
+
+// Handler serves the group table as JSON.
+func (a *Aggregator) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := a.MarshalJSON()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	})
+}
@@ -0,0 +1,119 @@
+package erragg
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) Now() time.Time          { return c.t }
+func (c *fakeClock) Advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newClock() *fakeClock {
+	return &fakeClock{t: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func TestNormalize(t *testing.T) {
+	a := Fingerprint("system_error", `timeout after 31ms at (12,40) for "left"`)
+	b := Fingerprint("system_error", `timeout after 250ms  at (3,7) for "right"`)
+	if a != b {
+		t.Errorf("fingerprints differ: %s vs %s (%q)", a, b, Normalize(`timeout after 31ms at (12,40) for "left"`))
+	}
+	if Fingerprint("client_error", "timeout after 31ms at (12,40)") == a {
+		t.Error("type is not part of the fingerprint")
+	}
+	if got := Normalize("device 0x1f id 6f1c1a2e-3b4d-4e5f-8a9b-0c1d2e3f4a5b"); got != "device <hex> id <uuid>" {
+		t.Errorf("Normalize = %q", got)
+	}
+}
+
+func TestGroupingAndThrottle(t *testing.T) {
+	clock := newClock()
+	agg := New(Options{Throttle: 10 * time.Second, MaxSamples: 2, Now: clock.Now})
+	var notes []Notification
+	agg.Subscribe(TypeIs("system_error"), func(n Notification) { notes = append(notes, n) })
+
+	var fp string
+	for i := 0; i < 5; i++ {
+		fp = agg.Add(Report{Type: "system_error", Message: "pointer lost at 1" + string(rune('0'+i)), Payload: map[string]any{"i": i}})
+		clock.Advance(3 * time.Second)
+	}
+	agg.Add(Report{Type: "client_error", Message: "bad click"})
+
+	// t=0 new, t=3/6/9 throttled, t=12 notifies with three suppressed
+	if len(notes) != 2 || !notes[0].New || notes[1].New || notes[1].Suppressed != 3 {
+		t.Fatalf("notifications %+v", notes)
+	}
+	g, ok := agg.Group(fp)
+	if !ok || g.Count != 5 || len(g.Samples) != 2 || g.Samples[1].Payload["i"] != 4 {
+		t.Fatalf("group %+v", g)
+	}
+	if g.LastSeen.Sub(g.FirstSeen) != 12*time.Second {
+		t.Errorf("first/last seen %v .. %v", g.FirstSeen, g.LastSeen)
+	}
+	if snap := agg.Snapshot(); len(snap) != 2 || snap[0].Fingerprint != fp {
+		t.Errorf("snapshot %+v", snap)
+	}
+}
+
+func TestAlertRule(t *testing.T) {
+	clock := newClock()
+	agg := New(Options{Now: clock.Now})
+	if err := agg.AddRule(Rule{Name: "system errors", Match: TypeIs("system_error"), Threshold: 10, Window: time.Minute}); err != nil {
+		t.Fatal(err)
+	}
+	var alerts []Alert
+	agg.OnAlert(func(a Alert) { alerts = append(alerts, a) })
+
+	// 10 in a minute is not "more than 10"
+	for i := 0; i < 10; i++ {
+		agg.Add(Report{Type: "system_error", Message: "oom"})
+		agg.Add(Report{Type: "client_error", Message: "oom"})
+		clock.Advance(5 * time.Second)
+	}
+	if len(alerts) != 0 {
+		t.Fatalf("alerted early: %v", alerts)
+	}
+	agg.Add(Report{Type: "system_error", Message: "oom"})
+	if len(alerts) != 1 || alerts[0].Count != 11 {
+		t.Fatalf("alerts %v", alerts)
+	}
+	// still above the threshold, but within the cooldown
+	clock.Advance(5 * time.Second)
+	agg.Add(Report{Type: "system_error", Message: "oom"})
+	if len(alerts) != 1 {
+		t.Fatalf("alert repeated during cooldown: %v", alerts)
+	}
+	// after a quiet spell the window has emptied
+	clock.Advance(2 * time.Minute)
+	agg.Add(Report{Type: "system_error", Message: "oom"})
+	if len(alerts) != 1 {
+		t.Fatalf("alert after window emptied: %v", alerts)
+	}
+}
+
+func TestHandlerJSON(t *testing.T) {
+	agg := New(Options{Now: newClock().Now})
+	agg.Add(Report{Type: "client_error", Message: "invalid input 3"})
+	agg.Add(Report{Type: "client_error", Message: "invalid input 4"})
+
+	rec := httptest.NewRecorder()
+	agg.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/errors", nil))
+	var body struct {
+		Total  int
+		Groups []struct {
+			Template string
+			Count    int
+			Samples  []Sample
+		}
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Total != 2 || len(body.Groups) != 1 || body.Groups[0].Template != "invalid input <n>" || body.Groups[0].Count != 2 {
+		t.Errorf("body %s", rec.Body)
+	}
+}
@@ -0,0 +1,94 @@
+package erragg
+
+import (
+	"fmt"
+	"time"
+)
+
+// Rule raises an alert when more than Threshold matching reports arrive
+// within Window, e.g. "more than 10 system errors in 1 minute". After
+// firing it stays quiet for Cooldown (default Window).
+type Rule struct {
+	Name      string
+	Match     func(Report) bool // nil matches everything
+	Threshold int
+	Window    time.Duration
+	Cooldown  time.Duration
+}
+
+// TypeIs matches reports of one type.
+func TypeIs(typ string) func(Report) bool {
+	return func(r Report) bool { return r.Type == typ }
+}
+
+// Alert is delivered to alert callbacks.
+type Alert struct {
+	Rule  string
+	Count int // matching reports within the window when it fired
+	Since time.Time
+	At    time.Time
+}
+
+func (a Alert) String() string {
+	return fmt.Sprintf("%s: %d errors since %s", a.Rule, a.Count, a.Since.Format(time.TimeOnly))
+}
+
+type AlertCallback func(Alert)
+
+type ruleState struct {
+	Rule
+	times   []time.Time // matching report times within the window, oldest first
+	firedAt time.Time
+}
+
+// AddRule registers an alert rule.
+func (a *Aggregator) AddRule(r Rule) error {
+	if r.Threshold < 0 || r.Window <= 0 {
+		return fmt.Errorf("rule %q: threshold must be >= 0 and window > 0", r.Name)
+	}
+	if r.Cooldown <= 0 {
+		r.Cooldown = r.Window
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.rules = append(a.rules, &ruleState{Rule: r})
+	return nil
+}
+
+// OnAlert registers an alert callback.
+func (a *Aggregator) OnAlert(cb AlertCallback) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.alertCb = append(a.alertCb, cb)
+}
+
+// evaluate slides every matching rule's window forward and returns the
+// alert deliveries to run once the lock is released. Windows are measured
+// on the aggregator's clock, not on report times, so a late or backdated
+// report still counts as arriving now.
+func (a *Aggregator) evaluate(r Report, now time.Time) []func() {
+	var calls []func()
+	for _, rs := range a.rules {
+		if rs.Match != nil && !rs.Match(r) {
+			continue
+		}
+		cutoff := now.Add(-rs.Window)
+		i := 0
+		for i < len(rs.times) && !rs.times[i].After(cutoff) {
+			i++
+		}
+		rs.times = append(rs.times[i:], now)
+		if len(rs.times) <= rs.Threshold {
+			continue
+		}
+		if !rs.firedAt.IsZero() && now.Sub(rs.firedAt) < rs.Cooldown {
+			continue
+		}
+		rs.firedAt = now
+		alert := Alert{Rule: rs.Name, Count: len(rs.times), Since: rs.times[0], At: now}
+		for _, cb := range a.alertCb {
+			calls = append(calls, func() { cb(alert) })
+		}
+	}
+	return calls
+}
@@ -0,0 +1,38 @@
+package erragg
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"regexp"
+	"strings"
+)
+
+// Volatile parts of a message are replaced by placeholders so that
+// "timeout after 31ms at (12,40)" and "timeout after 250ms at (3,7)" land in
+// the same group. Order matters: the more specific patterns run first.
+var normalizers = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`), "<uuid>"},
+	{regexp.MustCompile(`\b0x[0-9a-fA-F]+\b`), "<hex>"},
+	{regexp.MustCompile(`"[^"]*"|'[^']*'`), "<str>"},
+	{regexp.MustCompile(`\b\d+(\.\d+)?(ns|us|µs|ms|s|m|h|px|%)?\b`), "<n>"},
+	{regexp.MustCompile(`\s+`), " "},
+}
+
+// Normalize reduces a message to its template.
+func Normalize(msg string) string {
+	msg = strings.TrimSpace(msg)
+	for _, n := range normalizers {
+		msg = n.re.ReplaceAllString(msg, n.repl)
+	}
+	return msg
+}
+
+// Fingerprint identifies a group: the error type plus the normalized
+// message, hashed to a short stable key.
+func Fingerprint(typ, msg string) string {
+	sum := sha1.Sum([]byte(typ + "\x00" + Normalize(msg)))
+	return hex.EncodeToString(sum[:8])
+}
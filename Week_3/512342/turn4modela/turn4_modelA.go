@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"Week_3/512342/turn4modela/erragg"
+)
+
+type MouseError struct {
+	EventType string
+	Error     error
+	X, Y      int // pointer position when the error happened
+}
+
+type MouseErrorCallback func(MouseError)
+
+// MouseErrorHandler no longer fans every error out to every callback.
+// Errors are fingerprinted and grouped by the aggregator, and callbacks are
+// told about a group at most once per throttle interval.
+type MouseErrorHandler struct {
+	agg *erragg.Aggregator
+}
+
+func NewMouseErrorHandler(opts erragg.Options) *MouseErrorHandler {
+	return &MouseErrorHandler{agg: erragg.New(opts)}
+}
+
+// AddErrorGroup subscribes callback to errors whose event type is one of
+// types; no types means every error.
+func (h *MouseErrorHandler) AddErrorGroup(name string, callback func(string, erragg.Notification), types ...string) {
+	match := func(r erragg.Report) bool {
+		for _, t := range types {
+			if r.Type == t {
+				return true
+			}
+		}
+		return len(types) == 0
+	}
+	h.agg.Subscribe(match, func(n erragg.Notification) { callback(name, n) })
+}
+
+func (h *MouseErrorHandler) AddAlertRule(rule erragg.Rule) error {
+	return h.agg.AddRule(rule)
+}
+
+func (h *MouseErrorHandler) OnAlert(cb erragg.AlertCallback) {
+	h.agg.OnAlert(cb)
+}
+
+func (h *MouseErrorHandler) HandleError(err MouseError) {
+	msg := "<nil>"
+	if err.Error != nil {
+		msg = err.Error.Error()
+	}
+	h.agg.Add(erragg.Report{
+		Type:    err.EventType,
+		Message: msg,
+		Payload: map[string]any{"x": err.X, "y": err.Y},
+	})
+}
+
+// Snapshot returns the current group table.
+func (h *MouseErrorHandler) Snapshot() []erragg.Group {
+	return h.agg.Snapshot()
+}
+
+func (h *MouseErrorHandler) SnapshotHandler() http.Handler {
+	return h.agg.Handler()
+}
+
+func printNotification(group string, n erragg.Notification) {
+	state := "new"
+	if !n.New {
+		state = fmt.Sprintf("%d so far, %d suppressed", n.Group.Count, n.Suppressed)
+	}
+	fmt.Printf("[%s] %s: %s (%s)\n", group, n.Group.Type, n.Group.Template, state)
+}
+
+func main() {
+	addr := flag.String("addr", "", "serve the group table at http://<addr>/errors after the simulation")
+	throttle := flag.Duration("throttle", 2*time.Second, "minimum gap between notifications per group")
+	n := flag.Int("n", 200, "errors to simulate")
+	flag.Parse()
+
+	handler := NewMouseErrorHandler(erragg.Options{Throttle: *throttle})
+	handler.AddErrorGroup("Client Errors", printNotification, "client_error")
+	handler.AddErrorGroup("System Errors", printNotification, "system_error")
+	if err := handler.AddAlertRule(erragg.Rule{
+		Name:      "more than 10 system errors in 1 minute",
+		Match:     erragg.TypeIs("system_error"),
+		Threshold: 10,
+		Window:    time.Minute,
+	}); err != nil {
+		log.Fatal(err)
+	}
+	handler.OnAlert(func(a erragg.Alert) { fmt.Println("ALERT", a) })
+
+	for i := 0; i < *n; i++ {
+		x, y := rand.Intn(1920), rand.Intn(1080)
+		var err MouseError
+		switch rand.Intn(4) {
+		case 0:
+			err = MouseError{EventType: "client_error", Error: fmt.Errorf("invalid position (%d,%d)", x, y)}
+		case 1:
+			err = MouseError{EventType: "client_error", Error: fmt.Errorf("unsupported button %d", rand.Intn(8))}
+		case 2:
+			err = MouseError{EventType: "system_error", Error: fmt.Errorf("event queue full after %dms", rand.Intn(500))}
+		default:
+			err = MouseError{EventType: "unexpected_error", Error: errors.New("something went wrong")}
+		}
+		err.X, err.Y = x, y
+		handler.HandleError(err)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for _, g := range handler.Snapshot() {
+		fmt.Printf("%6d  %-16s %s\n", g.Count, g.Type, g.Template)
+	}
+
+	if *addr != "" {
+		http.Handle("/errors", handler.SnapshotHandler())
+		log.Printf("serving the group table on http://%s/errors", *addr)
+		log.Fatal(http.ListenAndServe(*addr, nil))
+	}
+}
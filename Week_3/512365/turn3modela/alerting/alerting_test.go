@@ -0,0 +1,185 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var t0 = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func change(path string, at time.Duration) FileChange {
+	return FileChange{Event: "WRITE", FilePath: path, Time: t0.Add(at)}
+}
+
+func kinds(ns []Notification) string {
+	var s []string
+	for _, n := range ns {
+		s = append(s, string(n.Kind)+" "+n.Key)
+	}
+	return strings.Join(s, ", ")
+}
+
+func TestMatchGlob(t *testing.T) {
+	cases := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.conf", "/etc/app/main.conf", true},
+		{"watch/*.txt", "watch/file.txt", true},
+		{"watch/*.txt", "watch/sub/file.txt", false},
+		{"watch/**/*.txt", "watch/sub/deeper/file.txt", true},
+		{"watch/**/*.txt", "watch/file.txt", true},
+		{"watch/**", "other/file.txt", false},
+	}
+	for _, c := range cases {
+		if got := MatchGlob(c.pattern, c.name); got != c.want {
+			t.Errorf("MatchGlob(%q, %q) = %v", c.pattern, c.name, got)
+		}
+	}
+}
+
+func TestHistoryRing(t *testing.T) {
+	h := NewHistory(3)
+	for i := 0; i < 5; i++ {
+		h.Add(change("f", time.Duration(i)*time.Second))
+	}
+	got := h.Recent(0)
+	if h.Len() != 3 || h.Total() != 5 || len(got) != 3 || !got[0].Time.Equal(t0.Add(2*time.Second)) {
+		t.Fatalf("ring kept %+v", got)
+	}
+	if last := h.Recent(1); !last[0].Time.Equal(t0.Add(4 * time.Second)) {
+		t.Errorf("Recent(1) = %+v", last)
+	}
+}
+
+func TestEngineAlertSuppressRecover(t *testing.T) {
+	e := NewEngine([]Rule{
+		{Name: "per file", Glob: "watch/*.txt", Per: "path", Threshold: 3, Window: time.Minute},
+		{Name: "whole dir", Glob: "watch/*", Threshold: 5, Window: time.Minute, Repeat: 30 * time.Second},
+	})
+	var got []Notification
+	for i := 0; i < 4; i++ {
+		got = append(got, e.Observe(change("watch/a.txt", time.Duration(i)*time.Second))...)
+	}
+	// the fourth change to a.txt crosses "per file"; the directory is at 4
+	if kinds(got) != "alert watch/a.txt" {
+		t.Fatalf("got %q", kinds(got))
+	}
+	got = nil
+	for i := 4; i < 8; i++ {
+		got = append(got, e.Observe(change("watch/b.txt", time.Duration(i)*time.Second))...)
+	}
+	// b.txt stays at 4 per file too, so it alerts once; the directory
+	// alerts at its sixth change and is suppressed after that
+	if kinds(got) != "alert watch/*, alert watch/b.txt" {
+		t.Fatalf("got %q", kinds(got))
+	}
+
+	if got := e.Tick(t0.Add(40 * time.Second)); kinds(got) != "reminder watch/*" || got[0].Suppressed != 2 {
+		t.Fatalf("reminder: %+v", got)
+	}
+	got = e.Tick(t0.Add(2 * time.Minute))
+	if len(got) != 3 {
+		t.Fatalf("expected three recoveries, got %q", kinds(got))
+	}
+	for _, n := range got {
+		if n.Kind != KindResolved || n.Count != 0 {
+			t.Errorf("recovery %+v", n)
+		}
+	}
+	if f := e.Firing(); len(f) != 0 {
+		t.Errorf("still firing: %v", f)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	received := make(chan Notification, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "secret" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		var n Notification
+		if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		received <- n
+	}))
+	defer srv.Close()
+
+	n := Notification{Kind: KindAlert, Rule: "r", Key: "watch/a.txt", Count: 4, Threshold: 3, At: t0,
+		Recent: []FileChange{change("watch/a.txt", 0)}}
+	ok := WebhookNotifier{URL: srv.URL, Headers: map[string]string{"X-Token": "secret"}}
+	if err := ok.Notify(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-received; got.Key != n.Key || got.Count != 4 || len(got.Recent) != 1 {
+		t.Errorf("webhook received %+v", got)
+	}
+	bad := WebhookNotifier{URL: srv.URL}
+	if err := bad.Notify(context.Background(), n); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("expected 403 error, got %v", err)
+	}
+}
+
+func TestEmailNotifierAndDispatcher(t *testing.T) {
+	sink, err := NewSMTPSink("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	d := &Dispatcher{Notifiers: map[string]Notifier{
+		"mail": EmailNotifier{Addr: sink.Addr(), From: "monitor@localhost", To: []string{"ops@localhost"}},
+		"log":  LogNotifier{},
+	}}
+	n := Notification{Kind: KindResolved, Rule: "r", Key: "watch/a.txt", At: t0, Notify: []string{"mail"}}
+	if err := d.Send(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+	mails := sink.Received()
+	if len(mails) != 1 || mails[0].To[0] != "ops@localhost" || !strings.Contains(mails[0].Data, "Subject: [resolved] r: watch/a.txt") {
+		t.Fatalf("mails %+v", mails)
+	}
+
+	n.Notify = []string{"missing"}
+	if err := d.Send(context.Background(), n); err == nil {
+		t.Error("expected an error for an unknown notifier")
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	os.WriteFile(path, []byte(`
+history: 50
+notifiers:
+  console: {type: log}
+rules:
+  - name: hot file
+    glob: "watch/*.txt"
+    per: path
+    threshold: 3
+    window: 1m
+    notify: [console]
+`), 0o644)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.History != 50 || len(cfg.Rules) != 1 || cfg.Rules[0].Window != time.Minute {
+		t.Errorf("config %+v", cfg)
+	}
+
+	os.WriteFile(path, []byte("rules:\n  - {name: x, glob: '*', threshold: 1, window: 1m, notify: [nope]}\n"), 0o644)
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "unknown notifier") {
+		t.Errorf("expected unknown notifier error, got %v", err)
+	}
+}
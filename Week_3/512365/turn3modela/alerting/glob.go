@@ -0,0 +1,43 @@
+package alerting
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// MatchGlob reports whether name matches pattern. Patterns use
+// path.Match syntax per segment, plus "**" for any number of segments, so
+// "logs/**/*.log" matches "logs/a/b/c.log". A pattern without a slash is
+// matched against the base name only, so "*.conf" matches in any directory.
+func MatchGlob(pattern, name string) bool {
+	name = filepath.ToSlash(filepath.Clean(name))
+	pattern = filepath.ToSlash(pattern)
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(name))
+		return ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pat, name []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			rest := pat[1:]
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(rest, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], name[0]); !ok {
+			return false
+		}
+		pat, name = pat[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,76 @@
+package alerting
+
+import (
+	"sync"
+	"time"
+)
+
+type FileChange struct {
+	Event    string    `json:"event"`
+	FilePath string    `json:"file_path"`
+	Time     time.Time `json:"time"`
+}
+
+// History keeps the most recent changes in a fixed-size ring, so a busy
+// directory cannot grow it without bound.
+type History struct {
+	mu    sync.Mutex
+	buf   []FileChange
+	next  int
+	full  bool
+	total int
+}
+
+func NewHistory(capacity int) *History {
+	if capacity <= 0 {
+		capacity = 1000
+	}
+	return &History{buf: make([]FileChange, capacity)}
+}
+
+// Add records a change, overwriting the oldest once the ring is full.
+func (h *History) Add(c FileChange) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.buf[h.next] = c
+	h.next = (h.next + 1) % len(h.buf)
+	if h.next == 0 {
+		h.full = true
+	}
+	h.total++
+}
+
+// Recent returns up to n changes, oldest first; n <= 0 returns all kept.
+func (h *History) Recent(n int) []FileChange {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	size := h.next
+	if h.full {
+		size = len(h.buf)
+	}
+	if n <= 0 || n > size {
+		n = size
+	}
+	out := make([]FileChange, n)
+	start := (h.next - n + len(h.buf)) % len(h.buf)
+	for i := range out {
+		out[i] = h.buf[(start+i)%len(h.buf)]
+	}
+	return out
+}
+
+// Len is the number of changes kept; Total counts every change ever added.
+func (h *History) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.full {
+		return len(h.buf)
+	}
+	return h.next
+}
+
+func (h *History) Total() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.total
+}
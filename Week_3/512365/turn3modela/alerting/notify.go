@@ -0,0 +1,242 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/smtp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Notifier delivers a notification somewhere.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// LogNotifier writes one line per notification.
+type LogNotifier struct {
+	Logger *log.Logger // nil uses the standard logger
+}
+
+func (l LogNotifier) Notify(_ context.Context, n Notification) error {
+	msg := n.Subject()
+	if n.Suppressed > 0 {
+		msg += fmt.Sprintf(" (%d further changes)", n.Suppressed)
+	}
+	if l.Logger != nil {
+		l.Logger.Print(msg)
+	} else {
+		log.Print(msg)
+	}
+	return nil
+}
+
+// WebhookNotifier POSTs the notification as JSON. Any status outside 2xx
+// is an error.
+type WebhookNotifier struct {
+	URL     string
+	Headers map[string]string
+	Client  *http.Client // nil uses a client with a 10s timeout
+}
+
+func (wh WebhookNotifier) Notify(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range wh.Headers {
+		req.Header.Set(k, v)
+	}
+	client := wh.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook %s: %s: %s", wh.URL, resp.Status, bytes.TrimSpace(snippet))
+	}
+	return nil
+}
+
+// EmailNotifier sends a plain-text mail through an SMTP server. Without
+// Auth it speaks plain SMTP, which is what a local relay or the SMTPSink
+// stand-in expect.
+type EmailNotifier struct {
+	Addr string // host:port
+	From string
+	To   []string
+	Auth smtp.Auth
+}
+
+func (m EmailNotifier) Notify(ctx context.Context, n Notification) error {
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\n", m.From)
+	fmt.Fprintf(&body, "To: %s\r\n", strings.Join(m.To, ", "))
+	fmt.Fprintf(&body, "Subject: %s\r\n", n.Subject())
+	fmt.Fprintf(&body, "Date: %s\r\n", n.At.Format(time.RFC1123Z))
+	body.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&body, "Rule:      %s\r\nKey:       %s\r\nCount:     %d in %v (threshold %d)\r\nSince:     %s\r\n",
+		n.Rule, n.Key, n.Count, n.Window, n.Threshold, n.Since.Format(time.RFC3339))
+	if n.Suppressed > 0 {
+		fmt.Fprintf(&body, "Suppressed: %d changes since the previous notification\r\n", n.Suppressed)
+	}
+	if len(n.Recent) > 0 {
+		body.WriteString("\r\nRecent changes:\r\n")
+		for _, c := range n.Recent {
+			fmt.Fprintf(&body, "  %s  %-12s %s\r\n", c.Time.Format(time.TimeOnly), c.Event, c.FilePath)
+		}
+	}
+	return m.send(ctx, []byte(body.String()))
+}
+
+// send is smtp.SendMail with the connection bounded by ctx.
+func (m EmailNotifier) send(ctx context.Context, msg []byte) error {
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", m.Addr)
+	if err != nil {
+		return err
+	}
+	if dl, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(dl)
+	}
+	host, _, _ := net.SplitHostPort(m.Addr)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if m.Auth != nil {
+		if err := c.Auth(m.Auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(m.From); err != nil {
+		return err
+	}
+	for _, to := range m.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// NotifierConfig describes one notifier in the rules file.
+type NotifierConfig struct {
+	Type    string            `yaml:"type"` // log, webhook or email
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+	Timeout time.Duration     `yaml:"timeout"`
+	Addr    string            `yaml:"addr"`
+	From    string            `yaml:"from"`
+	To      []string          `yaml:"to"`
+}
+
+// BuildNotifiers creates the notifiers named in the config. A config
+// without any gets a single "log" notifier.
+func BuildNotifiers(cfgs map[string]NotifierConfig) (map[string]Notifier, error) {
+	out := map[string]Notifier{}
+	for name, c := range cfgs {
+		switch c.Type {
+		case "", "log":
+			out[name] = LogNotifier{}
+		case "webhook":
+			if c.URL == "" {
+				return nil, fmt.Errorf("notifier %q: url is required", name)
+			}
+			timeout := c.Timeout
+			if timeout <= 0 {
+				timeout = 10 * time.Second
+			}
+			out[name] = WebhookNotifier{URL: c.URL, Headers: c.Headers, Client: &http.Client{Timeout: timeout}}
+		case "email":
+			if c.Addr == "" || c.From == "" || len(c.To) == 0 {
+				return nil, fmt.Errorf("notifier %q: addr, from and to are required", name)
+			}
+			out[name] = EmailNotifier{Addr: c.Addr, From: c.From, To: c.To}
+		default:
+			return nil, fmt.Errorf("notifier %q: unknown type %q", name, c.Type)
+		}
+	}
+	if len(out) == 0 {
+		out["log"] = LogNotifier{}
+	}
+	return out, nil
+}
+
+// Dispatcher routes notifications to the notifiers their rule names, or to
+// all of them when the rule names none.
+type Dispatcher struct {
+	Notifiers map[string]Notifier
+	Timeout   time.Duration // per delivery, default 15s
+}
+
+// Send delivers n to every target concurrently and joins the failures.
+func (d *Dispatcher) Send(ctx context.Context, n Notification) error {
+	names := n.Notify
+	if len(names) == 0 {
+		for name := range d.Notifiers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	timeout := d.Timeout
+	if timeout <= 0 {
+		timeout = 15 * time.Second
+	}
+	errs := make([]error, len(names))
+	done := make(chan struct{})
+	for i, name := range names {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			nt, ok := d.Notifiers[name]
+			if !ok {
+				errs[i] = fmt.Errorf("notifier %q not configured", name)
+				return
+			}
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			if err := nt.Notify(ctx, n); err != nil {
+				errs[i] = fmt.Errorf("notifier %q: %w", name, err)
+			}
+		}()
+	}
+	for range names {
+		<-done
+	}
+	return errors.Join(errs...)
+}
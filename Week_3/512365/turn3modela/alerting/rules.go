@@ -0,0 +1,259 @@
+// Package alerting evaluates file changes against sliding-window threshold
+// rules and delivers alert, reminder and recovery notifications through
+// pluggable notifiers.
+package alerting
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rule alerts when more than Threshold matching changes happen within
+// Window. With Per "path" every file is counted separately; with "rule"
+// (the default) all files matching Glob share one counter.
+type Rule struct {
+	Name      string        `yaml:"name"`
+	Glob      string        `yaml:"glob"`
+	Ops       []string      `yaml:"ops"` // e.g. [write, create]; empty matches any
+	Per       string        `yaml:"per"`
+	Threshold int           `yaml:"threshold"`
+	Window    time.Duration `yaml:"window"`
+	// Repeat re-sends a reminder while the rule keeps firing; zero sends
+	// nothing between the alert and its recovery.
+	Repeat time.Duration `yaml:"repeat"`
+	Notify []string      `yaml:"notify"` // notifier names; empty means all
+}
+
+func (r Rule) matches(c FileChange) bool {
+	if !MatchGlob(r.Glob, c.FilePath) {
+		return false
+	}
+	if len(r.Ops) == 0 {
+		return true
+	}
+	// fsnotify reports combined ops as "CREATE|WRITE"
+	for _, op := range strings.Split(c.Event, "|") {
+		for _, want := range r.Ops {
+			if strings.EqualFold(op, want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r Rule) key(c FileChange) string {
+	if r.Per == "path" {
+		return c.FilePath
+	}
+	return r.Glob
+}
+
+// Config is the rules file.
+type Config struct {
+	History   int                       `yaml:"history"` // ring buffer size for FileChange history
+	Notifiers map[string]NotifierConfig `yaml:"notifiers"`
+	Rules     []Rule                    `yaml:"rules"`
+}
+
+// LoadConfig reads and validates a YAML rules file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+func (c *Config) Validate() error {
+	names := map[string]bool{}
+	for i, r := range c.Rules {
+		switch {
+		case r.Name == "":
+			return fmt.Errorf("rule %d: name is required", i)
+		case names[r.Name]:
+			return fmt.Errorf("rule %q: duplicate name", r.Name)
+		case r.Glob == "":
+			return fmt.Errorf("rule %q: glob is required", r.Name)
+		case r.Threshold < 0 || r.Window <= 0:
+			return fmt.Errorf("rule %q: threshold must be >= 0 and window > 0", r.Name)
+		case r.Per != "" && r.Per != "path" && r.Per != "rule":
+			return fmt.Errorf("rule %q: per must be \"path\" or \"rule\"", r.Name)
+		}
+		for _, n := range r.Notify {
+			if _, ok := c.Notifiers[n]; !ok {
+				return fmt.Errorf("rule %q: unknown notifier %q", r.Name, n)
+			}
+		}
+		names[r.Name] = true
+	}
+	return nil
+}
+
+// Kind says why a notification was sent.
+type Kind string
+
+const (
+	KindAlert    Kind = "alert"
+	KindReminder Kind = "reminder"
+	KindResolved Kind = "resolved"
+)
+
+// Notification is what notifiers deliver.
+type Notification struct {
+	Kind       Kind          `json:"kind"`
+	Rule       string        `json:"rule"`
+	Key        string        `json:"key"` // the path, or the glob for per-rule counting
+	Count      int           `json:"count"`
+	Threshold  int           `json:"threshold"`
+	Window     time.Duration `json:"window_ns"`
+	Since      time.Time     `json:"since"` // when the rule started firing
+	At         time.Time     `json:"at"`
+	Suppressed int           `json:"suppressed"` // changes since the previous notification
+	Recent     []FileChange  `json:"recent,omitempty"`
+	Notify     []string      `json:"-"`
+}
+
+func (n Notification) Subject() string {
+	switch n.Kind {
+	case KindResolved:
+		return fmt.Sprintf("[resolved] %s: %s back to %d changes in %v", n.Rule, n.Key, n.Count, n.Window)
+	case KindReminder:
+		return fmt.Sprintf("[still firing] %s: %s has %d changes in %v (threshold %d)", n.Rule, n.Key, n.Count, n.Window, n.Threshold)
+	}
+	return fmt.Sprintf("[alert] %s: %s has %d changes in %v (threshold %d)", n.Rule, n.Key, n.Count, n.Window, n.Threshold)
+}
+
+// recentPerAlert bounds the changes attached to a notification.
+const recentPerAlert = 10
+
+type window struct {
+	changes    []FileChange // within the window, oldest first
+	firing     bool
+	since      time.Time
+	notifiedAt time.Time
+	suppressed int
+}
+
+// Engine tracks one sliding window per rule and key. It is safe for
+// concurrent use; time comes from the change timestamps and from Tick, so
+// tests can drive it without sleeping.
+type Engine struct {
+	mu      sync.Mutex
+	rules   []Rule
+	windows map[string]*window // rule name + "\x00" + key
+}
+
+func NewEngine(rules []Rule) *Engine {
+	return &Engine{rules: rules, windows: map[string]*window{}}
+}
+
+// Observe records a change and returns the notifications it triggers.
+func (e *Engine) Observe(c FileChange) []Notification {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var out []Notification
+	for _, r := range e.rules {
+		if !r.matches(c) {
+			continue
+		}
+		id := r.Name + "\x00" + r.key(c)
+		w := e.windows[id]
+		if w == nil {
+			w = &window{}
+			e.windows[id] = w
+		}
+		w.changes = append(w.changes, c)
+		if n, ok := e.evaluate(r, r.key(c), w, c.Time); ok {
+			out = append(out, n)
+		} else if w.firing {
+			w.suppressed++
+		}
+	}
+	return out
+}
+
+// Tick expires old changes and returns recoveries and reminders that are
+// due at now. Call it periodically; without it a rule that stops receiving
+// changes never recovers.
+func (e *Engine) Tick(now time.Time) []Notification {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var out []Notification
+	for _, r := range e.rules {
+		prefix := r.Name + "\x00"
+		for id, w := range e.windows {
+			if !strings.HasPrefix(id, prefix) {
+				continue
+			}
+			if n, ok := e.evaluate(r, strings.TrimPrefix(id, prefix), w, now); ok {
+				out = append(out, n)
+			}
+			if !w.firing && len(w.changes) == 0 {
+				delete(e.windows, id)
+			}
+		}
+	}
+	return out
+}
+
+// evaluate slides w to now and decides whether a notification is due.
+func (e *Engine) evaluate(r Rule, key string, w *window, now time.Time) (Notification, bool) {
+	cutoff := now.Add(-r.Window)
+	i := 0
+	for i < len(w.changes) && !w.changes[i].Time.After(cutoff) {
+		i++
+	}
+	w.changes = w.changes[i:]
+	count := len(w.changes)
+
+	n := Notification{Rule: r.Name, Key: key, Count: count, Threshold: r.Threshold,
+		Window: r.Window, At: now, Notify: r.Notify}
+	switch {
+	case !w.firing && count > r.Threshold:
+		w.firing, w.since, w.notifiedAt, w.suppressed = true, now, now, 0
+		n.Kind = KindAlert
+	case w.firing && count <= r.Threshold:
+		n.Kind = KindResolved
+		n.Suppressed = w.suppressed
+		w.firing, w.suppressed = false, 0
+	case w.firing && r.Repeat > 0 && now.Sub(w.notifiedAt) >= r.Repeat:
+		n.Kind = KindReminder
+		n.Suppressed = w.suppressed
+		w.notifiedAt, w.suppressed = now, 0
+	default:
+		return Notification{}, false
+	}
+	n.Since = w.since
+	recent := w.changes
+	if len(recent) > recentPerAlert {
+		recent = recent[len(recent)-recentPerAlert:]
+	}
+	n.Recent = append([]FileChange(nil), recent...)
+	return n, true
+}
+
+// Firing lists the rule/key pairs currently in alert.
+func (e *Engine) Firing() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var out []string
+	for id, w := range e.windows {
+		if w.firing {
+			out = append(out, strings.Replace(id, "\x00", ": ", 1))
+		}
+	}
+	return out
+}
@@ -0,0 +1,138 @@
+package alerting
+
+import (
+	"bufio"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+// Mail is a message accepted by SMTPSink.
+type Mail struct {
+	From string
+	To   []string
+	Data string
+}
+
+// SMTPSink is a minimal local SMTP server that accepts every message and
+// keeps it in memory. It stands in for a real relay in tests and demos;
+// it supports neither TLS nor authentication.
+type SMTPSink struct {
+	ln     net.Listener
+	mu     sync.Mutex
+	mails  []Mail
+	notify chan Mail
+	wg     sync.WaitGroup
+}
+
+// NewSMTPSink listens on addr, e.g. "127.0.0.1:0". Accepted mails are
+// also sent to the returned sink's Mails channel if it is being read.
+func NewSMTPSink(addr string) (*SMTPSink, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &SMTPSink{ln: ln, notify: make(chan Mail, 16)}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+func (s *SMTPSink) Addr() string { return s.ln.Addr().String() }
+
+// Mails delivers each accepted mail; it drops mails nobody reads.
+func (s *SMTPSink) Mails() <-chan Mail { return s.notify }
+
+// Received returns every mail accepted so far.
+func (s *SMTPSink) Received() []Mail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Mail(nil), s.mails...)
+}
+
+func (s *SMTPSink) Close() error {
+	err := s.ln.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *SMTPSink) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *SMTPSink) handle(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost SMTP sink ready")
+	var m Mail
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250 localhost")
+		case "MAIL":
+			m = Mail{From: addrArg(arg)}
+			tp.PrintfLine("250 OK")
+		case "RCPT":
+			m.To = append(m.To, addrArg(arg))
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 end data with <CR><LF>.<CR><LF>")
+			data, err := readDot(tp.R)
+			if err != nil {
+				return
+			}
+			m.Data = data
+			s.mu.Lock()
+			s.mails = append(s.mails, m)
+			s.mu.Unlock()
+			select {
+			case s.notify <- m:
+			default:
+			}
+			tp.PrintfLine("250 OK queued")
+		case "RSET":
+			m = Mail{}
+			tp.PrintfLine("250 OK")
+		case "NOOP":
+			tp.PrintfLine("250 OK")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 command not implemented")
+		}
+	}
+}
+
+// addrArg extracts the address from "FROM:<a@b>" or "TO:<a@b>".
+func addrArg(arg string) string {
+	if i := strings.IndexByte(arg, '<'); i >= 0 {
+		if j := strings.IndexByte(arg[i:], '>'); j > 0 {
+			return arg[i+1 : i+j]
+		}
+	}
+	_, addr, _ := strings.Cut(arg, ":")
+	return strings.TrimSpace(addr)
+}
+
+func readDot(r *bufio.Reader) (string, error) {
+	data, err := textproto.NewReader(r).ReadDotBytes()
+	return string(data), err
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"Week_3/512365/turn3modela/alerting"
+)
+
+// Monitor replaces the global changes slice: changes go into a bounded
+// history and through the rule engine, and a ticker lets quiet rules
+// recover.
+type Monitor struct {
+	History    *alerting.History
+	Engine     *alerting.Engine
+	Dispatcher *alerting.Dispatcher
+}
+
+func (m *Monitor) handle(ctx context.Context, notes []alerting.Notification) {
+	for _, n := range notes {
+		go func() {
+			if err := m.Dispatcher.Send(ctx, n); err != nil {
+				log.Println("Notify:", err)
+			}
+		}()
+	}
+}
+
+func (m *Monitor) watchFileSystem(ctx context.Context, path string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(path); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			m.handle(ctx, m.Engine.Tick(now))
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			change := alerting.FileChange{
+				Event:    event.Op.String(),
+				FilePath: event.Name,
+				Time:     time.Now(),
+			}
+			m.History.Add(change)
+			log.Println("Event:", event)
+			m.handle(ctx, m.Engine.Observe(change))
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Println("Error:", err)
+		}
+	}
+}
+
+func main() {
+	configPath := flag.String("config", "rules.yaml", "alert rules file")
+	path := flag.String("path", "watch", "directory to watch")
+	sinkAddr := flag.String("smtp-sink", "", "run a local SMTP stand-in on this address and log what it receives")
+	addr := flag.String("addr", "", "serve recent changes as JSON at http://<addr>/changes")
+	flag.Parse()
+
+	cfg, err := alerting.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	notifiers, err := alerting.BuildNotifiers(cfg.Notifiers)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *sinkAddr != "" {
+		sink, err := alerting.NewSMTPSink(*sinkAddr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer sink.Close()
+		log.Println("SMTP stand-in listening on", sink.Addr())
+		go func() {
+			for m := range sink.Mails() {
+				log.Printf("Mail to %v:\n%s", m.To, m.Data)
+			}
+		}()
+	}
+
+	m := &Monitor{
+		History:    alerting.NewHistory(cfg.History),
+		Engine:     alerting.NewEngine(cfg.Rules),
+		Dispatcher: &alerting.Dispatcher{Notifiers: notifiers},
+	}
+
+	if *addr != "" {
+		http.HandleFunc("/changes", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{
+				"total":  m.History.Total(),
+				"recent": m.History.Recent(100),
+				"firing": m.Engine.Firing(),
+			})
+		})
+		go func() { log.Fatal(http.ListenAndServe(*addr, nil)) }()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	log.Printf("Watching %s with %d rules", *path, len(cfg.Rules))
+	if err := m.watchFileSystem(ctx, *path); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"Week_3/512415/turn3modela/imageconv"
+	"Week_3/512415/turn3modela/models"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// MaxUpload bounds the size of an uploaded image.
+const MaxUpload = 32 << 20
+
+// UploadDir holds uploads until their job finishes.
+var UploadDir = "uploads"
+
+// ConvertFile accepts a multipart upload ("uploadedfile") and queues its
+// conversion. Form fields: format (jpeg, png, gif, bmp; default png),
+// width, height, thumbnail (true to fit inside width×height) and quality.
+func ConvertFile(w http.ResponseWriter, r *http.Request, user string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, MaxUpload)
+
+	file, header, err := r.FormFile("uploadedfile")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	conversion, err := conversionFromForm(r, header.Filename)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	conversion.Owner = user
+
+	if err := os.MkdirAll(UploadDir, 0o755); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// never trust the client's file name for the path on disk
+	dst, err := os.CreateTemp(UploadDir, "upload-*"+strings.ToLower(filepath.Ext(header.Filename)))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, err = io.Copy(dst, file)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dst.Name())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	conversion.InputPath = dst.Name()
+
+	if err := queue.Enqueue(conversion); err != nil {
+		os.Remove(dst.Name())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/conversions/%d", conversion.ID))
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":           conversion.ID,
+		"status":       conversion.Status,
+		"status_url":   fmt.Sprintf("/conversions/%d", conversion.ID),
+		"download_url": fmt.Sprintf("/download/%d", conversion.ID),
+	})
+}
+
+func conversionFromForm(r *http.Request, filename string) (*models.Conversion, error) {
+	name := r.FormValue("format")
+	if name == "" {
+		name = "png"
+	}
+	format, err := imageconv.ParseFormat(name)
+	if err != nil {
+		return nil, err
+	}
+	c := &models.Conversion{
+		Source:      filepath.Base(filename),
+		Destination: strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename)) + format.Ext(),
+		Format:      string(format),
+	}
+	for _, f := range []struct {
+		name string
+		dst  *int
+		max  int
+	}{{"width", &c.Width, 10000}, {"height", &c.Height, 10000}, {"quality", &c.Quality, 100}} {
+		v := r.FormValue(f.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > f.max {
+			return nil, fmt.Errorf("%s must be an integer between 0 and %d", f.name, f.max)
+		}
+		*f.dst = n
+	}
+	c.Thumbnail, _ = strconv.ParseBool(r.FormValue("thumbnail"))
+	if c.Thumbnail && c.Width == 0 && c.Height == 0 {
+		c.Width, c.Height = 256, 256
+	}
+	return c, nil
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"Week_3/512415/turn3modela/jobs"
+	"Week_3/512415/turn3modela/models"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+)
+
+var (
+	db    *gorm.DB
+	queue *jobs.Queue
+)
+
+// InitDB opens the database and creates the conversions table.
+func InitDB(path string) (*gorm.DB, error) {
+	var err error
+	db, err = gorm.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	// one writer at a time; SQLite would otherwise report "database is locked"
+	db.DB().SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&models.Conversion{}).Error; err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// SetQueue sets the queue ConvertFile submits jobs to.
+func SetQueue(q *jobs.Queue) {
+	queue = q
+}
+
+// findOwned loads a conversion that belongs to owner. Someone else's job is
+// reported as missing so IDs cannot be probed.
+func findOwned(id int64, owner string) (*models.Conversion, bool) {
+	var c models.Conversion
+	if err := db.Where("id = ? AND owner = ?", id, owner).First(&c).Error; err != nil {
+		return nil, false
+	}
+	return &c, true
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"Week_3/512415/turn3modela/imageconv"
+	"Week_3/512415/turn3modela/models"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// DownloadFile serves a finished conversion to the user who submitted it.
+func DownloadFile(w http.ResponseWriter, r *http.Request, user string) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	c, ok := findOwned(id, user)
+	if !ok {
+		http.Error(w, "conversion not found", http.StatusNotFound)
+		return
+	}
+
+	switch c.Status {
+	case models.StatusCompleted:
+	case models.StatusExpired:
+		http.Error(w, "the converted file has expired", http.StatusGone)
+		return
+	case models.StatusFailed:
+		http.Error(w, "conversion failed: "+c.Error, http.StatusConflict)
+		return
+	default:
+		http.Error(w, fmt.Sprintf("conversion is %s (%d%%)", c.Status, c.Progress), http.StatusConflict)
+		return
+	}
+
+	file, err := os.Open(c.OutputPath)
+	if os.IsNotExist(err) {
+		http.Error(w, "the converted file has expired", http.StatusGone)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", imageconv.Format(c.Format).ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", c.Destination))
+	http.ServeContent(w, r, c.Destination, info.ModTime(), file)
+}
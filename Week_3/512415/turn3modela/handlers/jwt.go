@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// jwtSecret comes from JWT_SECRET; without it a random secret is used, so
+// tokens do not survive a restart.
+var jwtSecret = func() []byte {
+	if s := os.Getenv("JWT_SECRET"); s != "" {
+		return []byte(s)
+	}
+	b := make([]byte, 32)
+	rand.Read(b)
+	return b
+}()
+
+var users = map[string]string{
+	"alice": "password",
+	"bob":   "password",
+}
+
+func Login(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	username := r.FormValue("username")
+	password := r.FormValue("password")
+
+	if want, ok := users[username]; !ok || password != want {
+		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &jwt.StandardClaims{
+		ExpiresAt: time.Now().Add(time.Hour).Unix(),
+		Issuer:    "convert-app",
+		Subject:   username,
+	})
+
+	tokenString, err := token.SignedString(jwtSecret)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: "token", Value: tokenString, HttpOnly: true, Path: "/",
+		Expires: time.Now().Add(time.Hour), SameSite: http.SameSiteLaxMode})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"token": tokenString})
+}
+
+// CurrentUser returns the username from a valid token in the Authorization
+// header ("Bearer ...") or the token cookie.
+func CurrentUser(r *http.Request) (string, bool) {
+	raw := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if raw == "" {
+		if c, err := r.Cookie("token"); err == nil {
+			raw = c.Value
+		}
+	}
+	if raw == "" {
+		return "", false
+	}
+
+	claims := &jwt.StandardClaims{}
+	t, err := jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("Unexpected signing method: %v", token.Method)
+		}
+		return jwtSecret, nil
+	})
+	if err != nil || !t.Valid || claims.Subject == "" {
+		return "", false
+	}
+	return claims.Subject, true
+}
+
+// RequireUser rejects requests without a valid token.
+func RequireUser(next func(w http.ResponseWriter, r *http.Request, user string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := CurrentUser(r)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r, user)
+	}
+}
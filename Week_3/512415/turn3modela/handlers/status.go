@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"Week_3/512415/turn3modela/models"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// GetConversion reports a job's status, progress and error for polling.
+func GetConversion(w http.ResponseWriter, r *http.Request, user string) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	c, ok := findOwned(id, user)
+	if !ok {
+		http.Error(w, "conversion not found", http.StatusNotFound)
+		return
+	}
+	writeConversion(w, c)
+}
+
+// ListConversions returns the caller's 100 most recent jobs.
+func ListConversions(w http.ResponseWriter, r *http.Request, user string) {
+	var list []models.Conversion
+	if err := db.Where("owner = ?", user).Order("id desc").Limit(100).Find(&list).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	out := make([]conversionView, 0, len(list))
+	for i := range list {
+		out = append(out, view(&list[i]))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+func writeConversion(w http.ResponseWriter, c *models.Conversion) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(view(c))
+}
+
+// conversionView adds the download link once the output is ready.
+type conversionView struct {
+	*models.Conversion
+	DownloadURL string `json:"download_url,omitempty"`
+}
+
+func view(c *models.Conversion) conversionView {
+	v := conversionView{Conversion: c}
+	if c.Status == models.StatusCompleted {
+		v.DownloadURL = "/download/" + strconv.FormatInt(c.ID, 10)
+	}
+	return v
+}
@@ -0,0 +1,222 @@
+package imageconv
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+// The standard library has no BMP codec, so this file provides the common
+// subset: uncompressed 8-bit paletted, 24-bit and 32-bit bitmaps in either
+// row order. Encoding always writes 24-bit bottom-up BI_RGB.
+
+const (
+	biRGB       = 0
+	biBitfields = 3
+)
+
+func init() {
+	image.RegisterFormat("bmp", "BM", decodeBMP, decodeBMPConfig)
+}
+
+type bmpHeader struct {
+	offset   uint32
+	width    int
+	height   int
+	topDown  bool
+	bpp      uint16
+	compress uint32
+	colors   uint32
+	dibSize  uint32
+}
+
+func readBMPHeader(r io.Reader) (bmpHeader, error) {
+	var h bmpHeader
+	var file [14]byte
+	if _, err := io.ReadFull(r, file[:]); err != nil {
+		return h, err
+	}
+	if file[0] != 'B' || file[1] != 'M' {
+		return h, errors.New("bmp: not a BMP file")
+	}
+	h.offset = binary.LittleEndian.Uint32(file[10:])
+
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return h, err
+	}
+	h.dibSize = binary.LittleEndian.Uint32(size[:])
+	if h.dibSize < 40 || h.dibSize > 256 {
+		return h, fmt.Errorf("bmp: unsupported DIB header size %d", h.dibSize)
+	}
+	dib := make([]byte, h.dibSize-4)
+	if _, err := io.ReadFull(r, dib); err != nil {
+		return h, err
+	}
+	w := int32(binary.LittleEndian.Uint32(dib[0:]))
+	ht := int32(binary.LittleEndian.Uint32(dib[4:]))
+	h.bpp = binary.LittleEndian.Uint16(dib[10:])
+	h.compress = binary.LittleEndian.Uint32(dib[12:])
+	h.colors = binary.LittleEndian.Uint32(dib[28:])
+	if ht < 0 {
+		h.topDown, ht = true, -ht
+	}
+	if w <= 0 || ht <= 0 {
+		return h, errors.New("bmp: invalid dimensions")
+	}
+	h.width, h.height = int(w), int(ht)
+	switch {
+	case h.bpp == 8 && h.compress == biRGB:
+	case h.bpp == 24 && h.compress == biRGB:
+	case h.bpp == 32 && (h.compress == biRGB || h.compress == biBitfields):
+	default:
+		return h, fmt.Errorf("bmp: unsupported %d-bit bitmap with compression %d", h.bpp, h.compress)
+	}
+	return h, nil
+}
+
+func decodeBMPConfig(r io.Reader) (image.Config, error) {
+	h, err := readBMPHeader(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	cm := color.Model(color.RGBAModel)
+	if h.bpp == 8 {
+		cm = color.Palette{}
+	}
+	return image.Config{ColorModel: cm, Width: h.width, Height: h.height}, nil
+}
+
+func decodeBMP(r io.Reader) (image.Image, error) {
+	br := bufio.NewReader(r)
+	h, err := readBMPHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	read := int64(14 + h.dibSize)
+
+	var palette color.Palette
+	if h.bpp == 8 {
+		n := int(h.colors)
+		if n == 0 || n > 256 {
+			n = 256
+		}
+		raw := make([]byte, 4*n)
+		if _, err := io.ReadFull(br, raw); err != nil {
+			return nil, err
+		}
+		read += int64(len(raw))
+		palette = make(color.Palette, n)
+		for i := range palette {
+			palette[i] = color.RGBA{raw[4*i+2], raw[4*i+1], raw[4*i], 0xff}
+		}
+	}
+	if skip := int64(h.offset) - read; skip > 0 {
+		if _, err := br.Discard(int(skip)); err != nil {
+			return nil, err
+		}
+	}
+
+	stride := (h.width*int(h.bpp)/8 + 3) &^ 3
+	row := make([]byte, stride)
+	rect := image.Rect(0, 0, h.width, h.height)
+	var paletted *image.Paletted
+	var rgba *image.NRGBA
+	if palette != nil {
+		paletted = image.NewPaletted(rect, palette)
+	} else {
+		rgba = image.NewNRGBA(rect)
+	}
+	for i := 0; i < h.height; i++ {
+		y := h.height - 1 - i
+		if h.topDown {
+			y = i
+		}
+		if _, err := io.ReadFull(br, row); err != nil {
+			return nil, fmt.Errorf("bmp: row %d: %w", i, err)
+		}
+		switch h.bpp {
+		case 8:
+			for x := 0; x < h.width; x++ {
+				idx := row[x]
+				if int(idx) >= len(palette) {
+					idx = 0
+				}
+				paletted.SetColorIndex(x, y, idx)
+			}
+		case 24:
+			p := rgba.Pix[y*rgba.Stride:]
+			for x := 0; x < h.width; x++ {
+				p[4*x], p[4*x+1], p[4*x+2], p[4*x+3] = row[3*x+2], row[3*x+1], row[3*x], 0xff
+			}
+		case 32:
+			p := rgba.Pix[y*rgba.Stride:]
+			for x := 0; x < h.width; x++ {
+				// the fourth byte is alpha in practice, but many writers
+				// leave it zero for opaque images
+				p[4*x], p[4*x+1], p[4*x+2], p[4*x+3] = row[4*x+2], row[4*x+1], row[4*x], row[4*x+3]
+			}
+		}
+	}
+	if paletted != nil {
+		return paletted, nil
+	}
+	if h.bpp == 32 && allTransparent(rgba) {
+		for i := 3; i < len(rgba.Pix); i += 4 {
+			rgba.Pix[i] = 0xff
+		}
+	}
+	return rgba, nil
+}
+
+func allTransparent(img *image.NRGBA) bool {
+	for i := 3; i < len(img.Pix); i += 4 {
+		if img.Pix[i] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeBMP writes img as a 24-bit bitmap. Alpha is composited onto white.
+func encodeBMP(w io.Writer, img image.Image) error {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	stride := (3*width + 3) &^ 3
+	size := 54 + stride*height
+
+	var hdr [54]byte
+	hdr[0], hdr[1] = 'B', 'M'
+	binary.LittleEndian.PutUint32(hdr[2:], uint32(size))
+	binary.LittleEndian.PutUint32(hdr[10:], 54)
+	binary.LittleEndian.PutUint32(hdr[14:], 40)
+	binary.LittleEndian.PutUint32(hdr[18:], uint32(width))
+	binary.LittleEndian.PutUint32(hdr[22:], uint32(height))
+	binary.LittleEndian.PutUint16(hdr[26:], 1)
+	binary.LittleEndian.PutUint16(hdr[28:], 24)
+	binary.LittleEndian.PutUint32(hdr[34:], uint32(stride*height))
+	binary.LittleEndian.PutUint32(hdr[38:], 2835) // 72 dpi
+	binary.LittleEndian.PutUint32(hdr[42:], 2835)
+
+	bw := bufio.NewWriter(w)
+	if _, err := bw.Write(hdr[:]); err != nil {
+		return err
+	}
+	row := make([]byte, stride)
+	for y := b.Max.Y - 1; y >= b.Min.Y; y-- {
+		for x := 0; x < width; x++ {
+			c := color.NRGBAModel.Convert(img.At(b.Min.X+x, y)).(color.NRGBA)
+			a := uint32(c.A)
+			blend := func(v uint8) byte { return byte((uint32(v)*a + 255*(255-a)) / 255) }
+			row[3*x], row[3*x+1], row[3*x+2] = blend(c.B), blend(c.G), blend(c.R)
+		}
+		if _, err := bw.Write(row); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
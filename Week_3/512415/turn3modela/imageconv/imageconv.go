@@ -0,0 +1,186 @@
+// Package imageconv converts images among JPEG, PNG, GIF and BMP with the
+// standard image packages, optionally resizing or thumbnailing on the way.
+package imageconv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+)
+
+// Format is an image file format.
+type Format string
+
+const (
+	JPEG Format = "jpeg"
+	PNG  Format = "png"
+	GIF  Format = "gif"
+	BMP  Format = "bmp"
+)
+
+// Formats is the conversion matrix: every format can be decoded and every
+// format can be written, so any pair is a valid conversion.
+var Formats = []Format{JPEG, PNG, GIF, BMP}
+
+// Ext is the file extension written for f.
+func (f Format) Ext() string {
+	if f == JPEG {
+		return ".jpg"
+	}
+	return "." + string(f)
+}
+
+func (f Format) ContentType() string {
+	return "image/" + string(f)
+}
+
+// ParseFormat accepts a format name or extension, e.g. "jpg", ".PNG".
+func ParseFormat(s string) (Format, error) {
+	s = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), ".")
+	switch s {
+	case "jpg", "jpeg":
+		return JPEG, nil
+	case "png":
+		return PNG, nil
+	case "gif":
+		return GIF, nil
+	case "bmp":
+		return BMP, nil
+	}
+	return "", Permanent(fmt.Errorf("unsupported format %q (want one of jpeg, png, gif, bmp)", s))
+}
+
+// MaxPixels bounds the decoded size, the output size and the resize buffer
+// so a small, highly compressed upload or an extreme width or height
+// cannot exhaust memory.
+const MaxPixels = 50_000_000
+
+// Options controls a conversion.
+type Options struct {
+	Format    Format
+	Width     int  // 0 keeps the aspect ratio from Height, or the source size
+	Height    int  // 0 keeps the aspect ratio from Width, or the source size
+	Thumbnail bool // fit inside Width×Height without enlarging
+	Quality   int  // JPEG quality 1-100, default 90
+}
+
+// PermanentError marks a failure that retrying cannot fix: a corrupt or
+// unsupported input, or invalid options.
+type PermanentError struct{ Err error }
+
+func (e *PermanentError) Error() string { return e.Err.Error() }
+func (e *PermanentError) Unwrap() error { return e.Err }
+
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// IsPermanent reports whether err is marked permanent.
+func IsPermanent(err error) bool {
+	var pe *PermanentError
+	return errors.As(err, &pe)
+}
+
+// Convert decodes src, applies the size options and encodes the result to
+// dst. progress, if not nil, is called with a percentage as each stage
+// finishes. It returns the detected source format.
+func Convert(dst io.Writer, src io.Reader, opts Options, progress func(int)) (Format, error) {
+	report := func(p int) {
+		if progress != nil {
+			progress(p)
+		}
+	}
+	if opts.Width < 0 || opts.Height < 0 {
+		return "", Permanent(errors.New("width and height must not be negative"))
+	}
+	if opts.Width > MaxPixels || opts.Height > MaxPixels {
+		return "", Permanent(fmt.Errorf("width and height must not exceed %d", MaxPixels))
+	}
+	if opts.Quality == 0 {
+		opts.Quality = 90
+	}
+	if opts.Quality < 1 || opts.Quality > 100 {
+		return "", Permanent(fmt.Errorf("quality %d out of range 1-100", opts.Quality))
+	}
+	if _, err := ParseFormat(string(opts.Format)); err != nil {
+		return "", err
+	}
+
+	// DecodeConfig consumes the header, so buffer the input to read it twice
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return "", err
+	}
+	cfg, name, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", Permanent(fmt.Errorf("reading image header: %w", err))
+	}
+	srcFormat, err := ParseFormat(name)
+	if err != nil {
+		return "", err
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return srcFormat, Permanent(fmt.Errorf("image is %dx%d, larger than %d pixels", cfg.Width, cfg.Height, MaxPixels))
+	}
+	// A missing side follows the aspect ratio, so a thin source can ask for a
+	// huge output. Resize also buffers w×(source height) pixels.
+	w, h := TargetSize(cfg.Width, cfg.Height, opts.Width, opts.Height, opts.Thumbnail)
+	if w*h > MaxPixels || w*cfg.Height > MaxPixels {
+		return srcFormat, Permanent(fmt.Errorf("resizing %dx%d to %dx%d needs more than %d pixels", cfg.Width, cfg.Height, w, h, MaxPixels))
+	}
+	report(10)
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return srcFormat, Permanent(fmt.Errorf("decoding %s: %w", srcFormat, err))
+	}
+	report(40)
+
+	if b := img.Bounds(); w != b.Dx() || h != b.Dy() {
+		img = Resize(img, w, h)
+	}
+	report(70)
+
+	if err := Encode(dst, img, opts.Format, opts.Quality); err != nil {
+		return srcFormat, err
+	}
+	report(100)
+	return srcFormat, nil
+}
+
+// Encode writes img in format f.
+func Encode(w io.Writer, img image.Image, f Format, quality int) error {
+	switch f {
+	case JPEG:
+		// JPEG has no alpha; flatten onto white rather than black
+		return jpeg.Encode(w, flatten(img), &jpeg.Options{Quality: quality})
+	case PNG:
+		return png.Encode(w, img)
+	case GIF:
+		return gif.Encode(w, img, &gif.Options{NumColors: 256})
+	case BMP:
+		return encodeBMP(w, img)
+	}
+	return Permanent(fmt.Errorf("unsupported format %q", f))
+}
+
+func flatten(img image.Image) image.Image {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok && opaque.Opaque() {
+		return img
+	}
+	out := image.NewRGBA(img.Bounds())
+	draw.Draw(out, out.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(out, out.Bounds(), img, img.Bounds().Min, draw.Over)
+	return out
+}
@@ -0,0 +1,120 @@
+package imageconv
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// gradient is an opaque test image with enough structure to survive lossy
+// formats recognisably.
+func gradient(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{uint8(255 * x / w), uint8(255 * y / h), 128, 255})
+		}
+	}
+	return img
+}
+
+func TestConversionMatrix(t *testing.T) {
+	src := gradient(64, 48)
+	for _, from := range Formats {
+		var in bytes.Buffer
+		if err := Encode(&in, src, from, 95); err != nil {
+			t.Fatalf("encode %s: %v", from, err)
+		}
+		for _, to := range Formats {
+			var out bytes.Buffer
+			var progress []int
+			got, err := Convert(&out, bytes.NewReader(in.Bytes()), Options{Format: to}, func(p int) { progress = append(progress, p) })
+			if err != nil {
+				t.Errorf("%s -> %s: %v", from, to, err)
+				continue
+			}
+			if got != from {
+				t.Errorf("%s -> %s: detected source %s", from, to, got)
+			}
+			img, name, err := image.Decode(&out)
+			if err != nil || Format(name) != to {
+				t.Errorf("%s -> %s: output decodes as %q: %v", from, to, name, err)
+				continue
+			}
+			if img.Bounds().Dx() != 64 || img.Bounds().Dy() != 48 {
+				t.Errorf("%s -> %s: size %v", from, to, img.Bounds())
+			}
+			// the blue channel is constant, so any format should keep it close
+			_, _, b, _ := img.At(32, 24).RGBA()
+			if b>>8 < 100 || b>>8 > 156 {
+				t.Errorf("%s -> %s: blue drifted to %d", from, to, b>>8)
+			}
+			if len(progress) == 0 || progress[len(progress)-1] != 100 {
+				t.Errorf("%s -> %s: progress %v", from, to, progress)
+			}
+		}
+	}
+}
+
+func TestResizeAndThumbnail(t *testing.T) {
+	var in bytes.Buffer
+	Encode(&in, gradient(400, 200), PNG, 0)
+
+	cases := []struct {
+		opts Options
+		w, h int
+	}{
+		{Options{Format: PNG, Width: 100}, 100, 50},
+		{Options{Format: PNG, Height: 50}, 100, 50},
+		{Options{Format: PNG, Width: 30, Height: 30}, 30, 30},
+		{Options{Format: PNG, Width: 64, Height: 64, Thumbnail: true}, 64, 32},
+		{Options{Format: PNG, Width: 1000, Height: 1000, Thumbnail: true}, 400, 200},
+	}
+	for _, c := range cases {
+		var out bytes.Buffer
+		if _, err := Convert(&out, bytes.NewReader(in.Bytes()), c.opts, nil); err != nil {
+			t.Fatal(err)
+		}
+		cfg, _, err := image.DecodeConfig(&out)
+		if err != nil || cfg.Width != c.w || cfg.Height != c.h {
+			t.Errorf("%+v: got %dx%d (%v), want %dx%d", c.opts, cfg.Width, cfg.Height, err, c.w, c.h)
+		}
+	}
+}
+
+func TestPermanentErrors(t *testing.T) {
+	var out bytes.Buffer
+	if _, err := Convert(&out, bytes.NewReader([]byte("not an image")), Options{Format: PNG}, nil); !IsPermanent(err) {
+		t.Errorf("garbage input: %v", err)
+	}
+	if _, err := Convert(&out, bytes.NewReader(nil), Options{Format: "tiff"}, nil); !IsPermanent(err) {
+		t.Errorf("unknown format: %v", err)
+	}
+}
+
+func TestOutputSizeIsBounded(t *testing.T) {
+	var thin bytes.Buffer
+	if err := Encode(&thin, gradient(1, 10000), PNG, 0); err != nil {
+		t.Fatal(err)
+	}
+	cases := []Options{
+		// the height follows the aspect ratio to 10000×100000000
+		{Format: PNG, Width: 10000},
+		{Format: PNG, Width: 8000, Height: 8000},
+		{Format: PNG, Width: 1 << 40},
+		// a small output still needs a 6000×10000 resize buffer
+		{Format: PNG, Width: 6000, Height: 1},
+	}
+	for _, opts := range cases {
+		var out bytes.Buffer
+		if _, err := Convert(&out, bytes.NewReader(thin.Bytes()), opts, nil); !IsPermanent(err) {
+			t.Errorf("%+v: err = %v, want a permanent error", opts, err)
+		}
+	}
+	// fitting never enlarges, so a huge box is fine
+	var out bytes.Buffer
+	if _, err := Convert(&out, bytes.NewReader(thin.Bytes()), Options{Format: PNG, Width: 10000, Height: 10000, Thumbnail: true}, nil); err != nil {
+		t.Errorf("thumbnail: %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package imageconv
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// Resize scales src to exactly w×h with a separable triangle filter. When
+// shrinking, the filter widens with the scale factor so every source pixel
+// contributes, which avoids the aliasing of nearest-neighbour sampling.
+func Resize(src image.Image, w, h int) *image.RGBA {
+	b := src.Bounds()
+	in, ok := src.(*image.RGBA)
+	if !ok || b.Min != (image.Point{}) {
+		in = image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(in, in.Bounds(), src, b.Min, draw.Src)
+	}
+	sw, sh := in.Bounds().Dx(), in.Bounds().Dy()
+
+	// horizontal pass into a float buffer of sh rows by w columns
+	xw := weights(sw, w)
+	tmp := make([]float32, 4*w*sh)
+	for y := 0; y < sh; y++ {
+		srow := in.Pix[y*in.Stride:]
+		trow := tmp[4*w*y:]
+		for x, ws := range xw {
+			var r, g, bl, a float32
+			for _, t := range ws {
+				p := srow[4*t.i:]
+				r += t.w * float32(p[0])
+				g += t.w * float32(p[1])
+				bl += t.w * float32(p[2])
+				a += t.w * float32(p[3])
+			}
+			trow[4*x], trow[4*x+1], trow[4*x+2], trow[4*x+3] = r, g, bl, a
+		}
+	}
+
+	// vertical pass into the result
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	yw := weights(sh, h)
+	for y, ws := range yw {
+		drow := out.Pix[y*out.Stride:]
+		for x := 0; x < w; x++ {
+			var r, g, bl, a float32
+			for _, t := range ws {
+				p := tmp[4*(w*t.i+x):]
+				r += t.w * p[0]
+				g += t.w * p[1]
+				bl += t.w * p[2]
+				a += t.w * p[3]
+			}
+			drow[4*x], drow[4*x+1], drow[4*x+2], drow[4*x+3] = clamp8(r), clamp8(g), clamp8(bl), clamp8(a)
+		}
+	}
+	return out
+}
+
+type tap struct {
+	i int
+	w float32
+}
+
+// weights returns, for each destination index, the source indices and
+// normalised weights that contribute to it.
+func weights(src, dst int) [][]tap {
+	scale := float64(src) / float64(dst)
+	support := math.Max(scale, 1)
+	out := make([][]tap, dst)
+	for d := range out {
+		center := (float64(d)+0.5)*scale - 0.5
+		lo := int(math.Floor(center - support))
+		hi := int(math.Ceil(center + support))
+		var taps []tap
+		var sum float64
+		for s := lo; s <= hi; s++ {
+			wt := 1 - math.Abs(float64(s)-center)/support
+			if wt <= 0 {
+				continue
+			}
+			i := min(max(s, 0), src-1)
+			taps = append(taps, tap{i: i, w: float32(wt)})
+			sum += wt
+		}
+		for k := range taps {
+			taps[k].w /= float32(sum)
+		}
+		out[d] = taps
+	}
+	return out
+}
+
+func clamp8(v float32) uint8 {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 255:
+		return 255
+	}
+	return uint8(v + 0.5)
+}
+
+// TargetSize works out the output size for a w×h source. A zero width or
+// height keeps the aspect ratio from the other; with fit set the result
+// fits inside the box, keeps the aspect ratio and never enlarges.
+func TargetSize(w, h, maxW, maxH int, fit bool) (int, int) {
+	switch {
+	case maxW <= 0 && maxH <= 0:
+		return w, h
+	case fit:
+		if maxW <= 0 {
+			maxW = w
+		}
+		if maxH <= 0 {
+			maxH = h
+		}
+		scale := math.Min(1, math.Min(float64(maxW)/float64(w), float64(maxH)/float64(h)))
+		return max(1, int(math.Round(float64(w)*scale))), max(1, int(math.Round(float64(h)*scale)))
+	case maxW <= 0:
+		return max(1, int(math.Round(float64(w)*float64(maxH)/float64(h)))), maxH
+	case maxH <= 0:
+		return maxW, max(1, int(math.Round(float64(h)*float64(maxW)/float64(w))))
+	}
+	return maxW, maxH
+}
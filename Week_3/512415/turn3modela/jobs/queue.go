@@ -0,0 +1,290 @@
+// Package jobs runs conversions from a queue persisted in the conversions
+// table, so queued work survives restarts, with a fixed pool of workers,
+// retries with backoff and cleanup of expired outputs.
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"Week_3/512415/turn3modela/imageconv"
+	"Week_3/512415/turn3modela/models"
+)
+
+// Config tunes a Queue. Zero values select the defaults.
+type Config struct {
+	Workers         int           // default 4
+	OutputDir       string        // default "outputs"
+	MaxAttempts     int           // per job, default 3
+	Backoff         time.Duration // before the first retry, doubled each time; default 2s
+	Retention       time.Duration // how long outputs stay downloadable, default 24h
+	PollInterval    time.Duration // how often to look for due retries, default 1s
+	CleanupInterval time.Duration // default 10m
+	Logger          *log.Logger
+	Now             func() time.Time
+}
+
+// Queue hands queued conversions to workers.
+type Queue struct {
+	db   *gorm.DB
+	cfg  Config
+	wake chan struct{}
+
+	// convert is imageconv.Convert; tests replace it to inject failures.
+	convert func(io.Writer, io.Reader, imageconv.Options, func(int)) (imageconv.Format, error)
+}
+
+func New(db *gorm.DB, cfg Config) *Queue {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 4
+	}
+	if cfg.OutputDir == "" {
+		cfg.OutputDir = "outputs"
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 3
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = 2 * time.Second
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = 24 * time.Hour
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.CleanupInterval <= 0 {
+		cfg.CleanupInterval = 10 * time.Minute
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = log.Default()
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &Queue{db: db, cfg: cfg, wake: make(chan struct{}, 1), convert: imageconv.Convert}
+}
+
+func (q *Queue) now() time.Time { return q.cfg.Now().UTC() }
+
+// Enqueue stores c as queued and wakes the dispatcher. InputPath must point
+// at the uploaded file; the queue removes it once the job is finished.
+func (q *Queue) Enqueue(c *models.Conversion) error {
+	c.Status = models.StatusQueued
+	c.Progress = 0
+	c.Attempts = 0
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = q.cfg.MaxAttempts
+	}
+	c.NextAttemptAt = q.now()
+	if err := q.db.Create(c).Error; err != nil {
+		return err
+	}
+	q.notify()
+	return nil
+}
+
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run dispatches jobs until ctx is cancelled, then waits for running jobs
+// to finish. Jobs left running by a previous process are queued again.
+func (q *Queue) Run(ctx context.Context) error {
+	if err := os.MkdirAll(q.cfg.OutputDir, 0o755); err != nil {
+		return err
+	}
+	err := q.db.Model(&models.Conversion{}).Where("status = ?", models.StatusRunning).
+		Updates(map[string]interface{}{"status": models.StatusQueued, "progress": 0}).Error
+	if err != nil {
+		return err
+	}
+
+	jobs := make(chan int64)
+	var wg sync.WaitGroup
+	for i := 0; i < q.cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range jobs {
+				q.process(id)
+			}
+		}()
+	}
+	defer func() {
+		close(jobs)
+		wg.Wait()
+	}()
+
+	poll := time.NewTicker(q.cfg.PollInterval)
+	defer poll.Stop()
+	cleanup := time.NewTicker(q.cfg.CleanupInterval)
+	defer cleanup.Stop()
+	for {
+		for {
+			id, err := q.claim()
+			if err != nil {
+				q.cfg.Logger.Println("claim:", err)
+			}
+			if id == 0 {
+				break
+			}
+			select {
+			case jobs <- id:
+			case <-ctx.Done():
+				// give the claimed job back rather than leave it running
+				q.db.Model(&models.Conversion{ID: id}).Update("status", models.StatusQueued)
+				return nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-q.wake:
+		case <-poll.C:
+		case <-cleanup.C:
+			if n, err := q.Cleanup(); err != nil {
+				q.cfg.Logger.Println("cleanup:", err)
+			} else if n > 0 {
+				q.cfg.Logger.Printf("cleanup: expired %d outputs", n)
+			}
+		}
+	}
+}
+
+// claim marks the oldest due job running and returns its ID, or 0.
+// next_attempt_at is compared in Go because SQLite stores the timestamps
+// as text, which does not sort reliably across fractional seconds.
+func (q *Queue) claim() (int64, error) {
+	var queued []models.Conversion
+	err := q.db.Select("id, next_attempt_at").Where("status = ?", models.StatusQueued).
+		Order("id").Find(&queued).Error
+	if err != nil {
+		return 0, err
+	}
+	now := q.now()
+	for _, c := range queued {
+		if c.NextAttemptAt.After(now) {
+			continue
+		}
+		res := q.db.Model(&models.Conversion{}).
+			Where("id = ? AND status = ?", c.ID, models.StatusQueued).
+			Updates(map[string]interface{}{"status": models.StatusRunning, "progress": 0,
+				"attempts": gorm.Expr("attempts + 1")})
+		if res.Error != nil {
+			return 0, res.Error
+		}
+		if res.RowsAffected == 1 {
+			return c.ID, nil
+		}
+	}
+	return 0, nil
+}
+
+func (q *Queue) process(id int64) {
+	var c models.Conversion
+	if err := q.db.First(&c, id).Error; err != nil {
+		q.cfg.Logger.Printf("job %d: %v", id, err)
+		return
+	}
+	out, err := q.run(&c)
+	if err == nil {
+		now := q.now()
+		expires := now.Add(q.cfg.Retention)
+		q.db.Model(&c).Updates(map[string]interface{}{
+			"status": models.StatusCompleted, "progress": 100, "error": "",
+			"output_path": out, "completed_at": now, "expires_at": expires,
+		})
+		os.Remove(c.InputPath)
+		q.cfg.Logger.Printf("job %d: %s -> %s done", c.ID, c.Source, c.Destination)
+		return
+	}
+
+	if imageconv.IsPermanent(err) || c.Attempts >= c.MaxAttempts {
+		q.db.Model(&c).Updates(map[string]interface{}{"status": models.StatusFailed, "error": err.Error()})
+		os.Remove(c.InputPath)
+		q.cfg.Logger.Printf("job %d: failed after %d attempt(s): %v", c.ID, c.Attempts, err)
+		return
+	}
+	delay := q.cfg.Backoff << (c.Attempts - 1)
+	q.db.Model(&c).Updates(map[string]interface{}{
+		"status": models.StatusQueued, "progress": 0, "error": err.Error(),
+		"next_attempt_at": q.now().Add(delay),
+	})
+	q.cfg.Logger.Printf("job %d: attempt %d failed, retrying in %v: %v", c.ID, c.Attempts, delay, err)
+}
+
+// run converts into a temporary file and renames it into place, so a
+// crash never leaves a partial output behind.
+func (q *Queue) run(c *models.Conversion) (string, error) {
+	in, err := os.Open(c.InputPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", imageconv.Permanent(fmt.Errorf("input is gone: %w", err))
+		}
+		return "", err
+	}
+	defer in.Close()
+
+	format, err := imageconv.ParseFormat(c.Format)
+	if err != nil {
+		return "", err
+	}
+	final := filepath.Join(q.cfg.OutputDir, fmt.Sprintf("%d%s", c.ID, format.Ext()))
+	tmp, err := os.CreateTemp(q.cfg.OutputDir, fmt.Sprintf("%d-*.part", c.ID))
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	last := 0
+	progress := func(p int) {
+		if p != last {
+			last = p
+			q.db.Model(c).Update("progress", p)
+		}
+	}
+	opts := imageconv.Options{Format: format, Width: c.Width, Height: c.Height, Thumbnail: c.Thumbnail, Quality: c.Quality}
+	if _, err := q.convert(tmp, in, opts, progress); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	return final, os.Rename(tmp.Name(), final)
+}
+
+// Cleanup deletes outputs whose retention has passed and marks their jobs
+// expired. It returns how many it expired.
+func (q *Queue) Cleanup() (int, error) {
+	var done []models.Conversion
+	if err := q.db.Where("status = ?", models.StatusCompleted).Find(&done).Error; err != nil {
+		return 0, err
+	}
+	now := q.now()
+	n := 0
+	for _, c := range done {
+		if c.ExpiresAt == nil || c.ExpiresAt.After(now) {
+			continue
+		}
+		if err := os.Remove(c.OutputPath); err != nil && !os.IsNotExist(err) {
+			q.cfg.Logger.Printf("cleanup job %d: %v", c.ID, err)
+			continue
+		}
+		q.db.Model(&c).Updates(map[string]interface{}{"status": models.StatusExpired, "output_path": ""})
+		n++
+	}
+	return n, nil
+}
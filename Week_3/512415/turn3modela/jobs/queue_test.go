@@ -0,0 +1,144 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+
+	"Week_3/512415/turn3modela/imageconv"
+	"Week_3/512415/turn3modela/models"
+)
+
+func openDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open("sqlite3", filepath.Join(t.TempDir(), "jobs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.DB().SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&models.Conversion{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func writeInput(t *testing.T, dir string) string {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+	for i := range img.Pix {
+		img.Pix[i] = 200
+	}
+	img.SetNRGBA(0, 0, color.NRGBA{255, 0, 0, 255})
+	var buf bytes.Buffer
+	if err := imageconv.Encode(&buf, img, imageconv.PNG, 0); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "in.png")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func waitFor(t *testing.T, db *gorm.DB, id int64, status string) models.Conversion {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var c models.Conversion
+		db.First(&c, id)
+		if c.Status == status {
+			return c
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %d stuck in %q (%s), want %q", id, c.Status, c.Error, status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestQueueRetriesThenCompletes(t *testing.T) {
+	db := openDB(t)
+	dir := t.TempDir()
+	var mu sync.Mutex
+	var offset time.Duration
+	clock := func() time.Time { mu.Lock(); defer mu.Unlock(); return time.Now().Add(offset) }
+
+	q := New(db, Config{Workers: 2, OutputDir: filepath.Join(dir, "out"), Backoff: time.Millisecond,
+		PollInterval: 5 * time.Millisecond, Retention: time.Hour, Logger: log.New(io.Discard, "", 0), Now: clock})
+	fails := 1
+	q.convert = func(w io.Writer, r io.Reader, o imageconv.Options, p func(int)) (imageconv.Format, error) {
+		mu.Lock()
+		flaky := fails > 0
+		fails--
+		mu.Unlock()
+		if flaky {
+			return "", errors.New("disk hiccup")
+		}
+		return imageconv.Convert(w, r, o, p)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { q.Run(ctx); close(done) }()
+	defer func() { cancel(); <-done }()
+
+	job := &models.Conversion{Owner: "alice", Source: "in.png", InputPath: writeInput(t, dir), Format: "jpeg", Width: 20}
+	if err := q.Enqueue(job); err != nil {
+		t.Fatal(err)
+	}
+	c := waitFor(t, db, job.ID, models.StatusCompleted)
+	if c.Attempts != 2 || c.Progress != 100 || c.Error != "" || c.ExpiresAt == nil {
+		t.Fatalf("completed job %+v", c)
+	}
+	cfg, format, err := decodeConfig(c.OutputPath)
+	if err != nil || format != "jpeg" || cfg.Width != 20 || cfg.Height != 10 {
+		t.Fatalf("output %s: %v %s %v", c.OutputPath, cfg, format, err)
+	}
+	if _, err := os.Stat(job.InputPath); !os.IsNotExist(err) {
+		t.Error("input was not removed")
+	}
+
+	// a corrupt upload fails at once, without retries
+	bad := filepath.Join(dir, "bad.png")
+	os.WriteFile(bad, []byte("garbage"), 0o644)
+	badJob := &models.Conversion{Owner: "alice", Source: "bad.png", InputPath: bad, Format: "gif"}
+	q.Enqueue(badJob)
+	if f := waitFor(t, db, badJob.ID, models.StatusFailed); f.Attempts != 1 || f.Error == "" {
+		t.Errorf("failed job %+v", f)
+	}
+
+	// past the retention the output is deleted and the job expires
+	mu.Lock()
+	offset = 2 * time.Hour
+	mu.Unlock()
+	if n, err := q.Cleanup(); err != nil || n != 1 {
+		t.Fatalf("Cleanup = %d, %v", n, err)
+	}
+	if _, err := os.Stat(c.OutputPath); !os.IsNotExist(err) {
+		t.Error("output survived cleanup")
+	}
+	waitFor(t, db, job.ID, models.StatusExpired)
+}
+
+func decodeConfig(path string) (image.Config, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return image.Config{}, "", err
+	}
+	defer f.Close()
+	return image.DecodeConfig(f)
+}
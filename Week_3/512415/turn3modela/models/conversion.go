@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Conversion statuses.
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+	StatusExpired   = "expired"
+)
+
+// Conversion is a queued or finished conversion job. Owner is the username
+// from the token that submitted it; only that user can see or download it.
+type Conversion struct {
+	ID          int64  `gorm:"primary_key;auto_increment" json:"id"`
+	Owner       string `gorm:"index" json:"-"`
+	Source      string `json:"source"`      // uploaded file name
+	Destination string `json:"destination"` // download file name
+	InputPath   string `json:"-"`
+	OutputPath  string `json:"-"`
+
+	Format    string `json:"format"`
+	Width     int    `json:"width,omitempty"`
+	Height    int    `json:"height,omitempty"`
+	Thumbnail bool   `json:"thumbnail,omitempty"`
+	Quality   int    `json:"quality,omitempty"`
+
+	Status        string     `gorm:"index" json:"status"`
+	Progress      int        `json:"progress"` // percent
+	Error         string     `json:"error,omitempty"`
+	Attempts      int        `json:"attempts"`
+	MaxAttempts   int        `json:"max_attempts"`
+	NextAttemptAt time.Time  `json:"-"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	CompletedAt   *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+}
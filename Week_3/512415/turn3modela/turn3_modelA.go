@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"Week_3/512415/turn3modela/handlers"
+	"Week_3/512415/turn3modela/jobs"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "listen address")
+	dbPath := flag.String("db", "convert_app.db", "SQLite database")
+	workers := flag.Int("workers", 4, "conversion workers")
+	outputs := flag.String("outputs", "outputs", "directory for converted files")
+	retention := flag.Duration("retention", 24*time.Hour, "how long converted files stay downloadable")
+	flag.Parse()
+
+	db, err := handlers.InitDB(*dbPath)
+	if err != nil {
+		log.Fatal("Error connecting to the database: ", err)
+	}
+	defer db.Close()
+
+	queue := jobs.New(db, jobs.Config{Workers: *workers, OutputDir: *outputs, Retention: *retention})
+	handlers.SetQueue(queue)
+
+	r := mux.NewRouter()
+	r.HandleFunc("/login", handlers.Login).Methods(http.MethodPost)
+	r.HandleFunc("/convert", handlers.RequireUser(handlers.ConvertFile)).Methods(http.MethodPost)
+	r.HandleFunc("/conversions", handlers.RequireUser(handlers.ListConversions)).Methods(http.MethodGet)
+	r.HandleFunc("/conversions/{id:[0-9]+}", handlers.RequireUser(handlers.GetConversion)).Methods(http.MethodGet)
+	r.HandleFunc("/download/{id:[0-9]+}", handlers.RequireUser(handlers.DownloadFile)).Methods(http.MethodGet)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := queue.Run(ctx); err != nil {
+			log.Println("queue:", err)
+			stop()
+		}
+	}()
+
+	srv := &http.Server{Addr: *addr, Handler: r, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("Listening on %s with %d workers", *addr, *workers)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+	// let running conversions finish before closing the database
+	wg.Wait()
+}
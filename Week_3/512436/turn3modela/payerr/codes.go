@@ -0,0 +1,116 @@
+package payerr
+
+import "net/http"
+
+// Codes known to the default registry. The first six are the codes the
+// earlier PaymentError examples used.
+const (
+	CodeInvalidOrder           Code = "INVALID_ORDER"
+	CodeInsufficientFunds      Code = "INSUFFICIENT_FUNDS"
+	CodeCardDeclined           Code = "CARD_DECLINED"
+	CodeProcessing             Code = "PROCESSING_ERROR"
+	CodeDuplicateTransaction   Code = "DUPLICATE_TRANSACTION"
+	CodeAuthentication         Code = "AUTHENTICATION_ERROR"
+	CodeOutOfStock             Code = "OUT_OF_STOCK"
+	CodeBillingAddressMismatch Code = "BILLING_ADDRESS_MISMATCH"
+	CodeGatewayTimeout         Code = "GATEWAY_TIMEOUT"
+	CodeUnknown                Code = "UNKNOWN_ERROR"
+)
+
+// Default is the registry used by New and Wrap.
+var Default = NewRegistry("en")
+
+func init() {
+	Default.MustRegister(
+		Definition{
+			Code: CodeInvalidOrder, Severity: Minor, HTTPStatus: http.StatusBadRequest,
+			Internal: "order {order_id} failed validation",
+			Messages: map[string]string{
+				"en": "Some of your order details are invalid. Please check them and try again.",
+				"es": "Algunos datos de tu pedido no son válidos. Revísalos e inténtalo de nuevo.",
+				"de": "Einige Ihrer Bestelldaten sind ungültig. Bitte prüfen Sie sie und versuchen Sie es erneut.",
+			},
+		},
+		Definition{
+			Code: CodeInsufficientFunds, Severity: Major, HTTPStatus: http.StatusPaymentRequired,
+			Internal: "issuer reported insufficient funds for {amount} {currency}",
+			Messages: map[string]string{
+				"en": "Your payment of {amount} {currency} could not be completed because of insufficient funds.",
+				"es": "No se pudo completar tu pago de {amount} {currency} por fondos insuficientes.",
+				"de": "Ihre Zahlung über {amount} {currency} konnte mangels Deckung nicht durchgeführt werden.",
+			},
+		},
+		Definition{
+			Code: CodeCardDeclined, Severity: Critical, HTTPStatus: http.StatusPaymentRequired,
+			Internal: "card declined by issuer, decline code {decline_code}",
+			Messages: map[string]string{
+				"en": "Your card was declined. Please use a different payment method.",
+				"es": "Tu tarjeta fue rechazada. Utiliza otro método de pago.",
+				"de": "Ihre Karte wurde abgelehnt. Bitte verwenden Sie eine andere Zahlungsart.",
+			},
+		},
+		Definition{
+			Code: CodeProcessing, Severity: Major, Retryable: true, HTTPStatus: http.StatusServiceUnavailable,
+			Internal: "payment processing failed",
+			Messages: map[string]string{
+				"en": "We could not process your payment right now. Please try again in a moment.",
+				"es": "No pudimos procesar tu pago en este momento. Inténtalo de nuevo en unos instantes.",
+				"de": "Ihre Zahlung konnte gerade nicht verarbeitet werden. Bitte versuchen Sie es gleich noch einmal.",
+			},
+		},
+		Definition{
+			Code: CodeDuplicateTransaction, Severity: Critical, HTTPStatus: http.StatusConflict,
+			Internal: "transaction {transaction_id} was already submitted",
+			Messages: map[string]string{
+				"en": "This payment has already been submitted.",
+				"es": "Este pago ya se ha enviado.",
+				"de": "Diese Zahlung wurde bereits übermittelt.",
+			},
+		},
+		Definition{
+			Code: CodeAuthentication, Severity: Critical, HTTPStatus: http.StatusUnauthorized,
+			Internal: "payment method authentication failed",
+			Messages: map[string]string{
+				"en": "We could not verify your payment method. Please authenticate and try again.",
+				"es": "No pudimos verificar tu método de pago. Autentícate e inténtalo de nuevo.",
+				"de": "Ihre Zahlungsart konnte nicht verifiziert werden. Bitte authentifizieren Sie sich erneut.",
+			},
+		},
+		Definition{
+			Code: CodeOutOfStock, Severity: Minor, HTTPStatus: http.StatusConflict,
+			Internal: "insufficient stock for {sku}",
+			Messages: map[string]string{
+				"en": "Sorry, {item} is out of stock.",
+				"es": "Lo sentimos, {item} está agotado.",
+				"de": "Leider ist {item} nicht vorrätig.",
+			},
+		},
+		Definition{
+			Code: CodeBillingAddressMismatch, Severity: Minor, HTTPStatus: http.StatusUnprocessableEntity,
+			Internal: "billing address failed AVS check",
+			Messages: map[string]string{
+				"en": "The billing address does not match the one on file for your card.",
+				"es": "La dirección de facturación no coincide con la registrada para tu tarjeta.",
+				"de": "Die Rechnungsadresse stimmt nicht mit der für Ihre Karte hinterlegten überein.",
+			},
+		},
+		Definition{
+			Code: CodeGatewayTimeout, Severity: Major, Retryable: true, HTTPStatus: http.StatusGatewayTimeout,
+			Internal: "payment gateway {gateway} timed out",
+			Messages: map[string]string{
+				"en": "The payment provider is taking too long to respond. Please try again.",
+				"es": "El proveedor de pagos está tardando demasiado en responder. Inténtalo de nuevo.",
+				"de": "Der Zahlungsanbieter antwortet nicht rechtzeitig. Bitte versuchen Sie es erneut.",
+			},
+		},
+		Definition{
+			Code: CodeUnknown, Severity: Critical, HTTPStatus: http.StatusInternalServerError,
+			Internal: "unexpected error",
+			Messages: map[string]string{
+				"en": "Something went wrong on our side. Please try again later.",
+				"es": "Algo salió mal por nuestra parte. Inténtalo de nuevo más tarde.",
+				"de": "Bei uns ist ein Fehler aufgetreten. Bitte versuchen Sie es später erneut.",
+			},
+		},
+	)
+}
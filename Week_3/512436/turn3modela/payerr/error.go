@@ -0,0 +1,135 @@
+package payerr
+
+import (
+	"errors"
+	"strings"
+)
+
+// Error is a payment failure with a registered code. Params fill the
+// message templates; Cause is the underlying error, which is logged and
+// reachable through errors.Is/As but never shown to the customer.
+type Error struct {
+	Code   Code
+	Params map[string]any
+	Cause  error
+
+	def *Definition
+}
+
+// P builds a parameter map from alternating keys and values, e.g.
+// P("amount", 12.5, "currency", "EUR").
+func P(kv ...any) map[string]any {
+	m := make(map[string]any, len(kv)/2)
+	for i := 0; i+1 < len(kv); i += 2 {
+		if k, ok := kv[i].(string); ok {
+			m[k] = kv[i+1]
+		}
+	}
+	return m
+}
+
+// New creates an error for code from the Default registry.
+func New(code Code, params map[string]any) *Error {
+	return Default.New(code, params)
+}
+
+// Wrap creates an error for code that wraps cause.
+func Wrap(cause error, code Code, params map[string]any) *Error {
+	return Default.Wrap(cause, code, params)
+}
+
+// New creates an error for code. An unregistered code is kept for the
+// record but behaves like CodeUnknown.
+func (r *Registry) New(code Code, params map[string]any) *Error {
+	d, ok := r.Lookup(code)
+	if !ok {
+		d, _ = r.Lookup(CodeUnknown)
+	}
+	return &Error{Code: code, Params: params, def: d}
+}
+
+func (r *Registry) Wrap(cause error, code Code, params map[string]any) *Error {
+	e := r.New(code, params)
+	e.Cause = cause
+	return e
+}
+
+// Definition returns the registered definition, which may be nil for an
+// Error built by hand with an unknown code.
+func (e *Error) Definition() *Definition {
+	if e.def != nil {
+		return e.def
+	}
+	d, _ := Default.Lookup(e.Code)
+	return d
+}
+
+func (e *Error) Severity() Severity {
+	if d := e.Definition(); d != nil {
+		return d.Severity
+	}
+	return Critical
+}
+
+func (e *Error) Retryable() bool {
+	d := e.Definition()
+	return d != nil && d.Retryable
+}
+
+func (e *Error) HTTPStatus() int { return statusOr(e.Definition()) }
+
+// Internal renders the operator-facing message, without the cause.
+func (e *Error) Internal() string {
+	if d := e.Definition(); d != nil {
+		return render(d.Internal, e.Params)
+	}
+	return "unregistered payment error"
+}
+
+// Error is for logs: code, internal message and cause.
+func (e *Error) Error() string {
+	var sb strings.Builder
+	sb.WriteString("[")
+	sb.WriteString(string(e.Code))
+	sb.WriteString("] ")
+	sb.WriteString(e.Internal())
+	if e.Cause != nil {
+		sb.WriteString(": ")
+		sb.WriteString(e.Cause.Error())
+	}
+	return sb.String()
+}
+
+func (e *Error) Unwrap() error { return e.Cause }
+
+// Is matches another *Error with the same code, so a bare New(code, nil)
+// works as a sentinel with errors.Is.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Sentinels for errors.Is.
+var (
+	ErrInvalidOrder         = &Error{Code: CodeInvalidOrder}
+	ErrInsufficientFunds    = &Error{Code: CodeInsufficientFunds}
+	ErrCardDeclined         = &Error{Code: CodeCardDeclined}
+	ErrProcessing           = &Error{Code: CodeProcessing}
+	ErrDuplicateTransaction = &Error{Code: CodeDuplicateTransaction}
+	ErrAuthentication       = &Error{Code: CodeAuthentication}
+	ErrOutOfStock           = &Error{Code: CodeOutOfStock}
+	ErrGatewayTimeout       = &Error{Code: CodeGatewayTimeout}
+)
+
+// From finds the outermost *Error in err's chain. Any other error is
+// wrapped as CodeUnknown.
+func From(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	var pe *Error
+	if errors.As(err, &pe) {
+		return pe
+	}
+	return Wrap(err, CodeUnknown, nil)
+}
@@ -0,0 +1,135 @@
+package payerr
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// HandlerFunc is an HTTP handler that reports failure by returning an
+// error instead of writing it.
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// Response is the JSON body of every error response.
+type Response struct {
+	Error ResponseError `json:"error"`
+}
+
+type ResponseError struct {
+	Code      Code     `json:"code"`
+	Message   string   `json:"message"`
+	Severity  Severity `json:"severity"`
+	Retryable bool     `json:"retryable"`
+	RequestID string   `json:"request_id"`
+}
+
+// Middleware renders errors and panics from HandlerFuncs as Response JSON
+// in the client's preferred locale, logs the internal message with its
+// cause, and counts them.
+type Middleware struct {
+	Registry *Registry   // default Default
+	Metrics  *Metrics    // optional
+	Logger   *log.Logger // default log.Default()
+}
+
+// Handle adapts h into an http.Handler.
+func (m *Middleware) Handle(h HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if p := recover(); p != nil {
+				m.Write(w, r, Wrap(fmt.Errorf("panic: %v", p), CodeUnknown, nil))
+			}
+		}()
+		if err := h(w, r); err != nil {
+			m.Write(w, r, err)
+		}
+	})
+}
+
+// Write renders err as an error response. Handlers that do not return
+// errors can call it directly.
+func (m *Middleware) Write(w http.ResponseWriter, r *http.Request, err error) {
+	reg := m.Registry
+	if reg == nil {
+		reg = Default
+	}
+	logger := m.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	pe := From(err)
+	d := pe.Definition()
+	code := pe.Code
+	if _, ok := reg.Lookup(code); !ok {
+		code = CodeUnknown
+	}
+	msg, locale := reg.Message(code, pe.Params, ParseAcceptLanguage(r.Header.Get("Accept-Language"))...)
+
+	requestID := r.Header.Get("X-Request-ID")
+	if requestID == "" {
+		requestID = newRequestID()
+	}
+	logger.Printf("request_id=%s %s %s: %s (severity %s)", requestID, r.Method, r.URL.Path, pe.Error(), pe.Severity())
+	if m.Metrics != nil {
+		m.Metrics.Observe(pe)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Language", locale)
+	w.Header().Set("X-Request-ID", requestID)
+	w.WriteHeader(statusOr(d))
+	json.NewEncoder(w).Encode(Response{Error: ResponseError{
+		Code:      code,
+		Message:   msg,
+		Severity:  pe.Severity(),
+		Retryable: pe.Retryable(),
+		RequestID: requestID,
+	}})
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ParseAcceptLanguage returns the locales of an Accept-Language header in
+// order of preference, dropping "*" and q=0 entries.
+func ParseAcceptLanguage(header string) []string {
+	type pref struct {
+		tag string
+		q   float64
+		i   int
+	}
+	var prefs []pref
+	for i, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		prefs = append(prefs, pref{tag, q, i})
+	}
+	sort.SliceStable(prefs, func(a, b int) bool { return prefs[a].q > prefs[b].q })
+	out := make([]string, len(prefs))
+	for i, p := range prefs {
+		out[i] = p.tag
+	}
+	return out
+}
@@ -0,0 +1,93 @@
+package payerr
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Metrics counts payment errors per code, severity and retryability and
+// writes them in the Prometheus text exposition format.
+type Metrics struct {
+	mu     sync.Mutex
+	counts map[metricKey]uint64
+}
+
+type metricKey struct {
+	code      Code
+	severity  Severity
+	retryable bool
+}
+
+// NewMetrics creates counters pre-populated at zero for every code in reg,
+// so dashboards see each series before its first error.
+func NewMetrics(reg *Registry) *Metrics {
+	m := &Metrics{counts: map[metricKey]uint64{}}
+	if reg != nil {
+		for _, c := range reg.Codes() {
+			d, _ := reg.Lookup(c)
+			m.counts[metricKey{c, d.Severity, d.Retryable}] = 0
+		}
+	}
+	return m
+}
+
+// Observe counts one occurrence of err.
+func (m *Metrics) Observe(err *Error) {
+	k := metricKey{err.Code, err.Severity(), err.Retryable()}
+	if d := err.Definition(); d != nil {
+		// unregistered codes are counted under the code they resolved to
+		k.code = d.Code
+	}
+	m.mu.Lock()
+	m.counts[k]++
+	m.mu.Unlock()
+}
+
+// Count returns the number of errors observed for code.
+func (m *Metrics) Count(code Code) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n uint64
+	for k, v := range m.counts {
+		if k.code == code {
+			n += v
+		}
+	}
+	return n
+}
+
+// WriteMetrics writes payment_errors_total.
+func (m *Metrics) WriteMetrics(w io.Writer) error {
+	m.mu.Lock()
+	keys := make([]metricKey, 0, len(m.counts))
+	for k := range m.counts {
+		keys = append(keys, k)
+	}
+	counts := make(map[metricKey]uint64, len(m.counts))
+	for k, v := range m.counts {
+		counts[k] = v
+	}
+	m.mu.Unlock()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].code < keys[j].code })
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "# HELP payment_errors_total Payment errors returned to clients, by code.")
+	fmt.Fprintln(bw, "# TYPE payment_errors_total counter")
+	for _, k := range keys {
+		fmt.Fprintf(bw, "payment_errors_total{code=%q,severity=%q,retryable=\"%t\"} %d\n",
+			k.code, k.severity, k.retryable, counts[k])
+	}
+	return bw.Flush()
+}
+
+// Handler serves the counters for Prometheus to scrape.
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		m.WriteMetrics(w)
+	})
+}
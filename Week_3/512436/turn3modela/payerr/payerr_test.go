@@ -0,0 +1,146 @@
+package payerr
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRegistryValidation(t *testing.T) {
+	r := NewRegistry("en")
+	ok := Definition{Code: "X", Severity: Minor, HTTPStatus: 400, Messages: map[string]string{"en": "x"}}
+	if err := r.Register(ok); err != nil {
+		t.Fatal(err)
+	}
+	for name, d := range map[string]Definition{
+		"duplicate":  ok,
+		"no code":    {Severity: Minor, HTTPStatus: 400, Messages: map[string]string{"en": "x"}},
+		"200 status": {Code: "Y", Severity: Minor, HTTPStatus: 200, Messages: map[string]string{"en": "x"}},
+		"no default": {Code: "Z", Severity: Minor, HTTPStatus: 400, Messages: map[string]string{"es": "x"}},
+	} {
+		if err := r.Register(d); err == nil {
+			t.Errorf("%s: registered", name)
+		}
+	}
+}
+
+func TestLocalizedMessages(t *testing.T) {
+	params := P("amount", "12.50", "currency", "EUR")
+	cases := []struct {
+		locales []string
+		want    string
+		locale  string
+	}{
+		{nil, "Your payment of 12.50 EUR", "en"},
+		{[]string{"es-MX"}, "No se pudo completar tu pago de 12.50 EUR", "es"},
+		{[]string{"fr", "de_AT"}, "Ihre Zahlung über 12.50 EUR", "de"},
+		{[]string{"ja"}, "Your payment of 12.50 EUR", "en"},
+	}
+	for _, c := range cases {
+		msg, loc := Default.Message(CodeInsufficientFunds, params, c.locales...)
+		if !strings.HasPrefix(msg, c.want) || loc != c.locale {
+			t.Errorf("%v: got %q in %s", c.locales, msg, loc)
+		}
+	}
+	if got := ParseAcceptLanguage("fr;q=0.5, de-CH, en;q=0.8, *;q=0.1, it;q=0"); !reflect.DeepEqual(got, []string{"de-CH", "en", "fr"}) {
+		t.Errorf("ParseAcceptLanguage = %v", got)
+	}
+}
+
+func TestWrapping(t *testing.T) {
+	cause := errors.New("gateway: read tcp: i/o timeout")
+	err := fmt.Errorf("charging order 42: %w", Wrap(cause, CodeGatewayTimeout, P("gateway", "acme")))
+
+	if !errors.Is(err, ErrGatewayTimeout) || errors.Is(err, ErrCardDeclined) {
+		t.Error("errors.Is does not match by code")
+	}
+	if !errors.Is(err, cause) {
+		t.Error("cause is not reachable")
+	}
+	pe := From(err)
+	if pe.Code != CodeGatewayTimeout || !pe.Retryable() || pe.HTTPStatus() != http.StatusGatewayTimeout {
+		t.Errorf("From = %+v", pe)
+	}
+	if got := pe.Error(); got != "[GATEWAY_TIMEOUT] payment gateway acme timed out: gateway: read tcp: i/o timeout" {
+		t.Errorf("Error() = %q", got)
+	}
+	if From(errors.New("boom")).Code != CodeUnknown {
+		t.Error("plain errors should map to UNKNOWN_ERROR")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var logs bytes.Buffer
+	metrics := NewMetrics(Default)
+	mw := &Middleware{Metrics: metrics, Logger: log.New(&logs, "", 0)}
+	h := mw.Handle(func(w http.ResponseWriter, r *http.Request) error {
+		switch r.URL.Path {
+		case "/declined":
+			return Wrap(errors.New("issuer said 05"), CodeCardDeclined, P("decline_code", "05"))
+		case "/panic":
+			panic("nil map")
+		case "/plain":
+			return io.ErrUnexpectedEOF
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+
+	do := func(path, lang string) (*httptest.ResponseRecorder, Response) {
+		req := httptest.NewRequest("POST", path, nil)
+		req.Header.Set("Accept-Language", lang)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		var body Response
+		if rec.Code != http.StatusNoContent {
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("%s: %v: %s", path, err, rec.Body)
+			}
+		}
+		return rec, body
+	}
+
+	rec, body := do("/declined", "es-ES,es;q=0.9")
+	if rec.Code != http.StatusPaymentRequired || body.Error.Code != CodeCardDeclined ||
+		body.Error.Severity != Critical || body.Error.Retryable || rec.Header().Get("Content-Language") != "es" ||
+		!strings.Contains(body.Error.Message, "rechazada") || body.Error.RequestID == "" {
+		t.Errorf("declined: %d %+v %v", rec.Code, body, rec.Header())
+	}
+	if strings.Contains(rec.Body.String(), "issuer said") {
+		t.Error("cause leaked to the client")
+	}
+	if !strings.Contains(logs.String(), "issuer said 05") {
+		t.Errorf("cause not logged: %s", logs.String())
+	}
+
+	for _, path := range []string{"/panic", "/plain"} {
+		if rec, body := do(path, ""); rec.Code != 500 || body.Error.Code != CodeUnknown {
+			t.Errorf("%s: %d %+v", path, rec.Code, body)
+		}
+	}
+	if rec, _ := do("/ok", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("ok: %d", rec.Code)
+	}
+
+	if metrics.Count(CodeCardDeclined) != 1 || metrics.Count(CodeUnknown) != 2 {
+		t.Errorf("counts declined=%d unknown=%d", metrics.Count(CodeCardDeclined), metrics.Count(CodeUnknown))
+	}
+	var out bytes.Buffer
+	metrics.WriteMetrics(&out)
+	for _, want := range []string{
+		`payment_errors_total{code="CARD_DECLINED",severity="Critical",retryable="false"} 1`,
+		`payment_errors_total{code="GATEWAY_TIMEOUT",severity="Major",retryable="true"} 0`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("metrics missing %s:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,195 @@
+// Package payerr is the payment error domain: a registry of error codes,
+// each declaring its severity, retryability, HTTP status, customer-facing
+// messages per locale and an internal message; an error type that wraps
+// underlying causes; HTTP middleware that renders them as consistent JSON;
+// and per-code counters in the Prometheus text format.
+package payerr
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Code identifies a payment failure independently of its wording.
+type Code string
+
+// Severity ranks how urgently a failure needs attention.
+type Severity string
+
+const (
+	Informational Severity = "Informational"
+	Minor         Severity = "Minor"
+	Major         Severity = "Major"
+	Critical      Severity = "Critical"
+)
+
+// Definition is everything the registry knows about a code. Messages maps
+// a locale ("en", "es", "pt-BR") to a customer-facing template; Internal
+// is the operator-facing template used in logs and Error(). Templates may
+// reference parameters as {name}.
+type Definition struct {
+	Code       Code
+	Severity   Severity
+	Retryable  bool
+	HTTPStatus int
+	Messages   map[string]string
+	Internal   string
+}
+
+// Registry holds code definitions. It is safe for concurrent use; codes are
+// normally registered once at start-up.
+type Registry struct {
+	mu            sync.RWMutex
+	defs          map[Code]*Definition
+	defaultLocale string
+}
+
+// NewRegistry creates an empty registry whose fallback locale is
+// defaultLocale; every definition must provide a message in it.
+func NewRegistry(defaultLocale string) *Registry {
+	return &Registry{defs: map[Code]*Definition{}, defaultLocale: defaultLocale}
+}
+
+// Register adds a definition. It rejects duplicates, missing fields and
+// definitions without a message in the default locale.
+func (r *Registry) Register(d Definition) error {
+	switch {
+	case d.Code == "":
+		return fmt.Errorf("payerr: definition without a code")
+	case d.HTTPStatus < 400 || d.HTTPStatus > 599:
+		return fmt.Errorf("payerr: %s: HTTP status %d is not an error status", d.Code, d.HTTPStatus)
+	case d.Severity == "":
+		return fmt.Errorf("payerr: %s: severity is required", d.Code)
+	}
+	if _, ok := d.Messages[r.defaultLocale]; !ok {
+		return fmt.Errorf("payerr: %s: no %q message", d.Code, r.defaultLocale)
+	}
+	msgs := make(map[string]string, len(d.Messages))
+	for loc, m := range d.Messages {
+		msgs[canonicalLocale(loc)] = m
+	}
+	d.Messages = msgs
+	if d.Internal == "" {
+		d.Internal = d.Messages[r.defaultLocale]
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, dup := r.defs[d.Code]; dup {
+		return fmt.Errorf("payerr: %s registered twice", d.Code)
+	}
+	r.defs[d.Code] = &d
+	return nil
+}
+
+// MustRegister is Register for package initialisation.
+func (r *Registry) MustRegister(defs ...Definition) {
+	for _, d := range defs {
+		if err := r.Register(d); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Lookup returns the definition of code.
+func (r *Registry) Lookup(code Code) (*Definition, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	d, ok := r.defs[code]
+	return d, ok
+}
+
+// Codes lists every registered code, sorted.
+func (r *Registry) Codes() []Code {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]Code, 0, len(r.defs))
+	for c := range r.defs {
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// Locales lists every locale that has at least one message.
+func (r *Registry) Locales() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	seen := map[string]bool{}
+	for _, d := range r.defs {
+		for loc := range d.Messages {
+			seen[loc] = true
+		}
+	}
+	out := make([]string, 0, len(seen))
+	for loc := range seen {
+		out = append(out, loc)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Message renders the customer-facing message for code in the best of the
+// requested locales, falling back from "pt-BR" to "pt" and finally to the
+// registry's default locale.
+func (r *Registry) Message(code Code, params map[string]any, locales ...string) (string, string) {
+	d, ok := r.Lookup(code)
+	if !ok {
+		d, _ = r.Lookup(CodeUnknown)
+	}
+	if d == nil {
+		return string(code), r.defaultLocale
+	}
+	for _, loc := range locales {
+		loc = canonicalLocale(loc)
+		for loc != "" {
+			if tmpl, ok := d.Messages[loc]; ok {
+				return render(tmpl, params), loc
+			}
+			i := strings.LastIndexByte(loc, '-')
+			if i < 0 {
+				break
+			}
+			loc = loc[:i]
+		}
+	}
+	return render(d.Messages[r.defaultLocale], params), r.defaultLocale
+}
+
+// render substitutes {name} placeholders. Unknown placeholders are left as
+// they are, so a missing parameter is visible rather than silently blank.
+func render(tmpl string, params map[string]any) string {
+	if len(params) == 0 || !strings.Contains(tmpl, "{") {
+		return tmpl
+	}
+	pairs := make([]string, 0, 2*len(params))
+	for k, v := range params {
+		pairs = append(pairs, "{"+k+"}", fmt.Sprint(v))
+	}
+	return strings.NewReplacer(pairs...).Replace(tmpl)
+}
+
+// canonicalLocale normalises "pt_br" and "PT-br" to "pt-BR".
+func canonicalLocale(loc string) string {
+	parts := strings.Split(strings.ReplaceAll(strings.TrimSpace(loc), "_", "-"), "-")
+	parts[0] = strings.ToLower(parts[0])
+	for i := 1; i < len(parts); i++ {
+		if len(parts[i]) == 2 {
+			parts[i] = strings.ToUpper(parts[i])
+		} else {
+			parts[i] = strings.ToLower(parts[i])
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+// statusOr returns d's status, or 500 for unknown codes.
+func statusOr(d *Definition) int {
+	if d == nil {
+		return http.StatusInternalServerError
+	}
+	return d.HTTPStatus
+}
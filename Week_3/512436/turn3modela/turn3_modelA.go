@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"Week_3/512436/turn3modela/payerr"
+)
+
+type orderRequest struct {
+	OrderID  string  `json:"order_id"`
+	SKU      string  `json:"sku"`
+	Quantity int     `json:"quantity"`
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
+	Card     string  `json:"card"`
+}
+
+type item struct {
+	Name  string
+	Stock int
+}
+
+// shop is an in-memory order service. Test card numbers select the
+// gateway outcome, the way payment sandboxes do.
+type shop struct {
+	mu        sync.Mutex
+	inventory map[string]*item
+	paid      map[string]bool
+}
+
+var errGatewayIO = errors.New("read tcp 10.0.0.7:443: i/o timeout")
+
+func (s *shop) placeOrder(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return nil
+	}
+	var req orderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return payerr.Wrap(err, payerr.CodeInvalidOrder, payerr.P("order_id", "?"))
+	}
+	if req.OrderID == "" || req.Quantity <= 0 || req.Amount <= 0 || req.Currency == "" {
+		return payerr.New(payerr.CodeInvalidOrder, payerr.P("order_id", req.OrderID))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.paid[req.OrderID] {
+		return payerr.New(payerr.CodeDuplicateTransaction, payerr.P("transaction_id", req.OrderID))
+	}
+	it, ok := s.inventory[req.SKU]
+	if !ok {
+		return payerr.New(payerr.CodeInvalidOrder, payerr.P("order_id", req.OrderID))
+	}
+	if it.Stock < req.Quantity {
+		return payerr.New(payerr.CodeOutOfStock, payerr.P("sku", req.SKU, "item", it.Name))
+	}
+	if err := charge(req); err != nil {
+		return fmt.Errorf("charging order %s: %w", req.OrderID, err)
+	}
+
+	it.Stock -= req.Quantity
+	s.paid[req.OrderID] = true
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(map[string]string{"order_id": req.OrderID, "status": "paid"})
+}
+
+func charge(req orderRequest) error {
+	amount := fmt.Sprintf("%.2f", req.Amount)
+	switch req.Card {
+	case "4000000000000002":
+		return payerr.New(payerr.CodeCardDeclined, payerr.P("decline_code", "05"))
+	case "4000000000009995":
+		return payerr.New(payerr.CodeInsufficientFunds, payerr.P("amount", amount, "currency", req.Currency))
+	case "4000000000003220":
+		return payerr.New(payerr.CodeAuthentication, nil)
+	case "4000000000000010":
+		return payerr.New(payerr.CodeBillingAddressMismatch, nil)
+	case "4000000000000119":
+		return payerr.Wrap(errors.New("acquirer returned malformed response"), payerr.CodeProcessing, nil)
+	case "4000000000000408":
+		return payerr.Wrap(errGatewayIO, payerr.CodeGatewayTimeout, payerr.P("gateway", "sandbox"))
+	case "4000000000000500":
+		panic("gateway client not initialised")
+	}
+	return nil
+}
+
+func main() {
+	addr := flag.String("addr", ":8080", "listen address")
+	flag.Parse()
+
+	s := &shop{
+		inventory: map[string]*item{
+			"MUG-01":  {Name: "Coffee mug", Stock: 10},
+			"TEE-M":   {Name: "T-shirt (M)", Stock: 2},
+			"CAP-RED": {Name: "Red cap", Stock: 0},
+		},
+		paid: map[string]bool{},
+	}
+	metrics := payerr.NewMetrics(payerr.Default)
+	mw := &payerr.Middleware{Metrics: metrics}
+
+	mux := http.NewServeMux()
+	mux.Handle("/orders", mw.Handle(s.placeOrder))
+	mux.Handle("/metrics", metrics.Handler())
+
+	log.Printf("listening on %s (try Accept-Language: es or de)", *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
@@ -0,0 +1,119 @@
+package funnel
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Step is one stage of a funnel. A user completes it with an event named
+// Event whose properties include every Where pair, no later than MaxGap
+// after the previous completed step (zero means no limit). Optional steps
+// are counted when they happen but never block the user from moving on.
+type Step struct {
+	Name     string            `json:"name"`
+	Event    string            `json:"event"`
+	Where    map[string]string `json:"where,omitempty"`
+	MaxGap   Duration          `json:"max_gap,omitempty"`
+	Optional bool              `json:"optional,omitempty"`
+}
+
+// Definition is an ordered list of steps.
+type Definition struct {
+	Name  string `json:"name"`
+	Steps []Step `json:"steps"`
+}
+
+// Duration is a time.Duration that reads and writes JSON as "30m".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30m\": %s", b)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Validate checks that the funnel starts and ends with a required step
+// and that every step names an event.
+func (d *Definition) Validate() error {
+	if d.Name == "" {
+		return errors.New("funnel has no name")
+	}
+	if len(d.Steps) < 2 {
+		return fmt.Errorf("funnel %q: needs at least two steps", d.Name)
+	}
+	if d.Steps[0].Optional || d.Steps[len(d.Steps)-1].Optional {
+		return fmt.Errorf("funnel %q: first and last steps cannot be optional", d.Name)
+	}
+	seen := map[string]bool{}
+	for i, s := range d.Steps {
+		if s.Name == "" || s.Event == "" {
+			return fmt.Errorf("funnel %q: step %d needs a name and an event", d.Name, i+1)
+		}
+		if seen[s.Name] {
+			return fmt.Errorf("funnel %q: duplicate step %q", d.Name, s.Name)
+		}
+		seen[s.Name] = true
+		if s.MaxGap < 0 {
+			return fmt.Errorf("funnel %q: step %q has a negative max_gap", d.Name, s.Name)
+		}
+	}
+	return nil
+}
+
+// ReadDefinitions decodes a JSON array of funnels and validates each one.
+func ReadDefinitions(r io.Reader) ([]Definition, error) {
+	var defs []Definition
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&defs); err != nil {
+		return nil, fmt.Errorf("decoding funnels: %w", err)
+	}
+	names := map[string]bool{}
+	for i := range defs {
+		if err := defs[i].Validate(); err != nil {
+			return nil, err
+		}
+		if names[defs[i].Name] {
+			return nil, fmt.Errorf("duplicate funnel %q", defs[i].Name)
+		}
+		names[defs[i].Name] = true
+	}
+	return defs, nil
+}
+
+// LoadDefinitions reads funnels from a JSON file.
+func LoadDefinitions(path string) ([]Definition, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadDefinitions(f)
+}
+
+func (s *Step) matches(e Event) bool {
+	if e.Name != s.Event {
+		return false
+	}
+	for k, v := range s.Where {
+		if e.Properties[k] != v {
+			return false
+		}
+	}
+	return true
+}
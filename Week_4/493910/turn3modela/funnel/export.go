@@ -0,0 +1,50 @@
+package funnel
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+)
+
+// WriteJSON writes the report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// CSVHeader is the first row written by WriteCSV.
+var CSVHeader = []string{
+	"cohort", "step", "optional", "users", "conversion_from_start",
+	"conversion_from_previous", "drop_off", "median_seconds_to_convert",
+}
+
+// WriteCSV writes one row per step, first for all users (cohort "all")
+// and then for each cohort.
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write(CSVHeader)
+	writeSteps(cw, "all", r.Overall)
+	for _, c := range r.Cohorts {
+		writeSteps(cw, c.Cohort, c.Summary)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeSteps(cw *csv.Writer, cohort string, s Summary) {
+	for _, st := range s.Steps {
+		cw.Write([]string{
+			cohort,
+			st.Name,
+			strconv.FormatBool(st.Optional),
+			strconv.Itoa(st.Users),
+			strconv.FormatFloat(st.ConversionFromStart, 'f', 4, 64),
+			strconv.FormatFloat(st.ConversionFromPrevious, 'f', 4, 64),
+			strconv.Itoa(st.DropOff),
+			strconv.FormatFloat(time.Duration(st.MedianTimeToConvert).Seconds(), 'f', 0, 64),
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package funnel
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var t0 = time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC) // a Monday, ISO week 10
+
+const testFunnels = `[{
+	"name": "purchase",
+	"steps": [
+		{"name": "Visit", "event": "page_view", "where": {"page": "product"}},
+		{"name": "Click", "event": "product_click", "max_gap": "10m"},
+		{"name": "Wishlist", "event": "wishlist_add", "optional": true},
+		{"name": "Cart", "event": "add_to_cart", "max_gap": "1h"},
+		{"name": "Purchase", "event": "purchase", "max_gap": "24h"}
+	]
+}]`
+
+func ev(user, name string, after time.Duration, props ...string) Event {
+	e := Event{UserID: user, Name: name, Time: t0.Add(after)}
+	if len(props) == 2 {
+		e.Properties = map[string]string{props[0]: props[1]}
+	}
+	return e
+}
+
+func testStore(t *testing.T) (*Definition, *Store) {
+	defs, err := ReadDefinitions(strings.NewReader(testFunnels))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewStore()
+	err = s.Ingest(
+		// converts, with the optional step
+		ev("u1", "page_view", 0, "page", "product"),
+		ev("u1", "product_click", 2*time.Minute),
+		ev("u1", "wishlist_add", 3*time.Minute),
+		ev("u1", "add_to_cart", 10*time.Minute),
+		ev("u1", "purchase", 40*time.Minute),
+		// converts, skipping the optional step; events arrive out of order
+		ev("u2", "purchase", 2*time.Hour),
+		ev("u2", "page_view", 0, "page", "product"),
+		ev("u2", "add_to_cart", time.Hour),
+		ev("u2", "product_click", 4*time.Minute),
+		// clicks too late on the first visit, converts to cart on the second
+		ev("u3", "page_view", 0, "page", "product"),
+		ev("u3", "product_click", 30*time.Minute),
+		ev("u3", "page_view", 50*time.Hour, "page", "product"),
+		ev("u3", "product_click", 50*time.Hour+time.Minute),
+		ev("u3", "add_to_cart", 50*time.Hour+5*time.Minute),
+		// only views the home page, never enters
+		ev("u4", "page_view", 0, "page", "home"),
+		// drops after the visit
+		ev("u5", "page_view", time.Minute, "page", "product"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.UpsertUser(User{ID: "u1", SignupAt: t0.AddDate(0, 0, -7), Source: "ads"})
+	s.UpsertUser(User{ID: "u2", SignupAt: t0, Source: "organic"})
+	s.UpsertUser(User{ID: "u3", SignupAt: t0, Source: "ads"})
+	return &defs[0], s
+}
+
+func TestAnalyze(t *testing.T) {
+	def, s := testStore(t)
+	rep, err := Analyze(def, s, Options{CohortBy: "source"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := rep.Overall
+	if o.Entered != 4 || o.Converted != 2 || o.Rate != 0.5 {
+		t.Errorf("overall = %d entered, %d converted, rate %v", o.Entered, o.Converted, o.Rate)
+	}
+	if want := Duration(80 * time.Minute); o.MedianTimeToConvert != want {
+		t.Errorf("median total = %v, want %v", time.Duration(o.MedianTimeToConvert), time.Duration(want))
+	}
+	wantUsers := []int{4, 3, 1, 3, 2}
+	wantDrop := []int{0, 1, 0, 0, 1}
+	for i, st := range o.Steps {
+		if st.Users != wantUsers[i] || st.DropOff != wantDrop[i] {
+			t.Errorf("%s: users %d drop %d, want %d %d", st.Name, st.Users, st.DropOff, wantUsers[i], wantDrop[i])
+		}
+	}
+	if st := o.Steps[3]; st.ConversionFromPrevious != 1 {
+		t.Errorf("Cart conversion from Click = %v, want 1 (Wishlist is optional)", st.ConversionFromPrevious)
+	}
+	// Click gaps: 2m, 4m, 1m
+	if got := time.Duration(o.Steps[1].MedianTimeToConvert); got != 2*time.Minute {
+		t.Errorf("Click median = %v", got)
+	}
+
+	var cohorts []string
+	for _, c := range rep.Cohorts {
+		cohorts = append(cohorts, c.Cohort)
+	}
+	if strings.Join(cohorts, ",") != "ads,organic,unknown" {
+		t.Errorf("cohorts = %v", cohorts)
+	}
+	if ads := rep.Cohorts[0]; ads.Entered != 2 || ads.Converted != 1 {
+		t.Errorf("ads = %+v", ads.Summary)
+	}
+}
+
+func TestAnalyzeWindowAndWeeks(t *testing.T) {
+	def, s := testStore(t)
+	// only u3's second visit enters after the first day
+	rep, err := Analyze(def, s, Options{From: t0.Add(24 * time.Hour), CohortBy: "signup_week"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Overall.Entered != 1 || rep.Overall.Steps[3].Users != 1 {
+		t.Errorf("overall = %+v", rep.Overall)
+	}
+	if len(rep.Cohorts) != 1 || rep.Cohorts[0].Cohort != "2024-W10" {
+		t.Errorf("cohorts = %+v", rep.Cohorts)
+	}
+	if _, err := Analyze(def, s, Options{CohortBy: "country"}); err == nil {
+		t.Error("unknown cohort accepted")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, bad := range []string{
+		`[{"name": "x", "steps": [{"name": "a", "event": "a"}]}]`,
+		`[{"name": "x", "steps": [{"name": "a", "event": "a"}, {"name": "b", "event": "b", "optional": true}]}]`,
+		`[{"name": "x", "steps": [{"name": "a", "event": "a"}, {"name": "b", "event": "b", "max_gap": 5}]}]`,
+		`[{"name": "x", "steps": [{"name": "a", "event": "a"}, {"name": "a", "event": "b"}]}]`,
+	} {
+		if _, err := ReadDefinitions(strings.NewReader(bad)); err == nil {
+			t.Errorf("accepted %s", bad)
+		}
+	}
+}
+
+func TestServer(t *testing.T) {
+	defs, _ := ReadDefinitions(strings.NewReader(testFunnels))
+	srv := httptest.NewServer(NewServer(NewStore(), defs))
+	defer srv.Close()
+
+	post := func(path, body string) int {
+		res, err := http.Post(srv.URL+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+	events, _ := json.Marshal([]Event{
+		ev("a", "page_view", 0, "page", "product"),
+		ev("a", "product_click", time.Minute),
+		ev("b", "page_view", 0, "page", "product"),
+	})
+	if code := post("/events", string(events)); code != http.StatusAccepted {
+		t.Fatalf("POST /events = %d", code)
+	}
+	if code := post("/users", `{"user_id": "a", "source": "email"}`); code != http.StatusAccepted {
+		t.Fatalf("POST /users = %d", code)
+	}
+	if code := post("/events", `{"user_id": "a"}`); code != http.StatusBadRequest {
+		t.Errorf("event without a name = %d", code)
+	}
+
+	res, err := http.Get(srv.URL + "/funnels/purchase/report?cohort=source&format=csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	rows, err := csv.NewReader(res.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1+3*5 {
+		t.Fatalf("got %d rows", len(rows))
+	}
+	if got := strings.Join(rows[2], ","); got != "all,Click,false,1,0.5000,0.5000,1,60" {
+		t.Errorf("row = %s", got)
+	}
+
+	res, _ = http.Get(srv.URL + "/funnels/nope/report")
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown funnel = %d", res.StatusCode)
+	}
+	res.Body.Close()
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	def, s := testStore(t)
+	rep, _ := Analyze(def, s, Options{})
+	var buf bytes.Buffer
+	if err := rep.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var back Report
+	if err := json.Unmarshal(buf.Bytes(), &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.Overall.Steps[4].MedianTimeToConvert != rep.Overall.Steps[4].MedianTimeToConvert {
+		t.Errorf("round trip lost durations: %s", buf.String())
+	}
+}
@@ -0,0 +1,168 @@
+package funnel
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// MaxBodyBytes limits ingestion request bodies.
+const MaxBodyBytes = 4 << 20
+
+// Server exposes ingestion and reporting over HTTP:
+//
+//	POST /events                  one event or an array of events
+//	POST /users                   one user or an array of users
+//	GET  /funnels                 the configured funnels
+//	GET  /funnels/{name}/report   ?from=&to=&cohort=&format=json|csv
+type Server struct {
+	Store   *Store
+	funnels map[string]*Definition
+	mux     *http.ServeMux
+}
+
+func NewServer(store *Store, defs []Definition) *Server {
+	s := &Server{Store: store, funnels: map[string]*Definition{}, mux: http.NewServeMux()}
+	for i := range defs {
+		s.funnels[defs[i].Name] = &defs[i]
+	}
+	s.mux.HandleFunc("POST /events", s.postEvents)
+	s.mux.HandleFunc("POST /users", s.postUsers)
+	s.mux.HandleFunc("GET /funnels", s.listFunnels)
+	s.mux.HandleFunc("GET /funnels/{name}/report", s.report)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) postEvents(w http.ResponseWriter, r *http.Request) {
+	var events []Event
+	if err := decodeOneOrMany(w, r, &events); err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := s.Store.Ingest(events...); err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]int{"accepted": len(events)})
+}
+
+func (s *Server) postUsers(w http.ResponseWriter, r *http.Request) {
+	var users []User
+	if err := decodeOneOrMany(w, r, &users); err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+	for i, u := range users {
+		if u.ID == "" {
+			httpError(w, http.StatusBadRequest, fmt.Errorf("user %d: user_id is required", i))
+			return
+		}
+	}
+	for _, u := range users {
+		s.Store.UpsertUser(u)
+	}
+	writeJSON(w, http.StatusAccepted, map[string]int{"accepted": len(users)})
+}
+
+func (s *Server) listFunnels(w http.ResponseWriter, r *http.Request) {
+	defs := make([]*Definition, 0, len(s.funnels))
+	for _, d := range s.funnels {
+		defs = append(defs, d)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	writeJSON(w, http.StatusOK, defs)
+}
+
+func (s *Server) report(w http.ResponseWriter, r *http.Request) {
+	def, ok := s.funnels[r.PathValue("name")]
+	if !ok {
+		httpError(w, http.StatusNotFound, fmt.Errorf("no funnel %q", r.PathValue("name")))
+		return
+	}
+	q := r.URL.Query()
+	var opts Options
+	var err error
+	if opts.From, err = parseTime(q.Get("from")); err != nil {
+		httpError(w, http.StatusBadRequest, fmt.Errorf("from: %w", err))
+		return
+	}
+	if opts.To, err = parseTime(q.Get("to")); err != nil {
+		httpError(w, http.StatusBadRequest, fmt.Errorf("to: %w", err))
+		return
+	}
+	opts.CohortBy = q.Get("cohort")
+
+	rep, err := Analyze(def, s.Store, opts)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+	switch q.Get("format") {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		rep.WriteJSON(w)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", def.Name+".csv"))
+		rep.WriteCSV(w)
+	default:
+		httpError(w, http.StatusBadRequest, fmt.Errorf("unknown format %q", q.Get("format")))
+	}
+}
+
+// parseTime accepts RFC 3339 timestamps and plain dates.
+func parseTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, v)
+}
+
+// decodeOneOrMany decodes either a JSON object or an array of them into
+// the slice pointed to by v.
+func decodeOneOrMany[T any](w http.ResponseWriter, r *http.Request, v *[]T) error {
+	br := bufio.NewReader(http.MaxBytesReader(w, r.Body, MaxBodyBytes))
+	var first byte
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return fmt.Errorf("empty body")
+		}
+		if b != ' ' && b != '\n' && b != '\r' && b != '\t' {
+			first = b
+			br.UnreadByte()
+			break
+		}
+	}
+	dec := json.NewDecoder(br)
+	dec.DisallowUnknownFields()
+	if first == '[' {
+		return dec.Decode(v)
+	}
+	var one T
+	if err := dec.Decode(&one); err != nil {
+		return err
+	}
+	*v = []T{one}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func httpError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
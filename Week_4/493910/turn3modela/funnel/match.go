@@ -0,0 +1,82 @@
+package funnel
+
+import "time"
+
+// match finds the user's best pass through the funnel among events that
+// enter it within [from, to). The result holds the completion time of
+// each step, zero for steps not reached; nil means the user never
+// entered. A pass that gets further wins, ties go to the earlier entry.
+func (d *Definition) match(events []Event, from, to time.Time) []time.Time {
+	var best []time.Time
+	bestRequired, bestAll := -1, -1
+	for i, e := range events {
+		if !d.Steps[0].matches(e) || e.Time.Before(from) || (!to.IsZero() && !e.Time.Before(to)) {
+			continue
+		}
+		done := d.walk(events, i)
+		req, all := d.progress(done)
+		if req > bestRequired || (req == bestRequired && all > bestAll) {
+			best, bestRequired, bestAll = done, req, all
+		}
+		if bestAll == len(d.Steps) {
+			break
+		}
+	}
+	return best
+}
+
+// walk follows the funnel greedily from the entry event at events[start].
+func (d *Definition) walk(events []Event, start int) []time.Time {
+	n := len(d.Steps)
+	done := make([]time.Time, n)
+	done[0] = events[start].Time
+	last, lastAt := 0, events[start].Time
+
+	for _, e := range events[start+1:] {
+		if last == n-1 {
+			break
+		}
+		next := d.nextRequired(last)
+		gap := e.Time.Sub(lastAt)
+		reachable := false
+		for j := last + 1; j <= next; j++ {
+			max := time.Duration(d.Steps[j].MaxGap)
+			if max > 0 && gap > max {
+				continue
+			}
+			reachable = true
+			if d.Steps[j].matches(e) {
+				done[j] = e.Time
+				last, lastAt = j, e.Time
+				break
+			}
+		}
+		if !reachable {
+			// events are in time order, so every later one is too late as well
+			break
+		}
+	}
+	return done
+}
+
+func (d *Definition) nextRequired(after int) int {
+	for j := after + 1; j < len(d.Steps); j++ {
+		if !d.Steps[j].Optional {
+			return j
+		}
+	}
+	return len(d.Steps) - 1
+}
+
+func (d *Definition) progress(done []time.Time) (required, all int) {
+	for i, t := range done {
+		if t.IsZero() {
+			continue
+		}
+		all++
+		if !d.Steps[i].Optional {
+			required++
+		}
+	}
+	return required, all
+}
@@ -0,0 +1,226 @@
+package funnel
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// StepReport describes how many users completed a step. Conversion from
+// previous and drop-off are measured against the previous required step;
+// drop-off is always zero for optional steps. MedianTimeToConvert is the
+// median time from the previous step the user completed.
+type StepReport struct {
+	Name                   string   `json:"name"`
+	Optional               bool     `json:"optional,omitempty"`
+	Users                  int      `json:"users"`
+	ConversionFromStart    float64  `json:"conversion_from_start"`
+	ConversionFromPrevious float64  `json:"conversion_from_previous"`
+	DropOff                int      `json:"drop_off"`
+	MedianTimeToConvert    Duration `json:"median_time_to_convert"`
+}
+
+// Summary is the funnel for one group of users.
+type Summary struct {
+	Entered   int     `json:"entered"`
+	Converted int     `json:"converted"`
+	Rate      float64 `json:"conversion_rate"`
+	// MedianTimeToConvert is measured from the first step to the last.
+	MedianTimeToConvert Duration     `json:"median_time_to_convert"`
+	Steps               []StepReport `json:"steps"`
+}
+
+// CohortReport is the summary for the users in one cohort.
+type CohortReport struct {
+	Cohort string `json:"cohort"`
+	Summary
+}
+
+// Report is the result of Analyze.
+type Report struct {
+	Funnel      string         `json:"funnel"`
+	From        *time.Time     `json:"from,omitempty"`
+	To          *time.Time     `json:"to,omitempty"`
+	GeneratedAt time.Time      `json:"generated_at"`
+	CohortBy    string         `json:"cohort_by,omitempty"`
+	Overall     Summary        `json:"overall"`
+	Cohorts     []CohortReport `json:"cohorts,omitempty"`
+}
+
+// CohortFunc assigns a user to a cohort. entered is when the user entered
+// the funnel.
+type CohortFunc func(u User, entered time.Time) string
+
+// Unknown is the cohort for users missing the attribute being grouped on.
+const Unknown = "unknown"
+
+// Cohorts are the groupings Analyze accepts by name.
+var Cohorts = map[string]CohortFunc{
+	"signup_week": func(u User, _ time.Time) string { return isoWeek(u.SignupAt) },
+	"entry_week":  func(_ User, entered time.Time) string { return isoWeek(entered) },
+	"source": func(u User, _ time.Time) string {
+		if u.Source == "" {
+			return Unknown
+		}
+		return u.Source
+	},
+}
+
+func isoWeek(t time.Time) string {
+	if t.IsZero() {
+		return Unknown
+	}
+	y, w := t.UTC().ISOWeek()
+	return fmt.Sprintf("%04d-W%02d", y, w)
+}
+
+// Options narrow and group an analysis. Users are included when they enter
+// the funnel within [From, To); zero bounds are open.
+type Options struct {
+	From, To time.Time
+	CohortBy string // a key of Cohorts, or empty for no breakdown
+	Now      func() time.Time
+}
+
+// Analyze computes the funnel over every user in s.
+func Analyze(def *Definition, s *Store, opts Options) (*Report, error) {
+	var cohortOf CohortFunc
+	if opts.CohortBy != "" {
+		var ok bool
+		if cohortOf, ok = Cohorts[opts.CohortBy]; !ok {
+			return nil, fmt.Errorf("unknown cohort %q", opts.CohortBy)
+		}
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	overall := newTally(def)
+	cohorts := map[string]*tally{}
+	s.each(func(u User, events []Event) {
+		done := def.match(events, opts.From, opts.To)
+		if done == nil {
+			return
+		}
+		overall.add(done)
+		if cohortOf != nil {
+			key := cohortOf(u, done[0])
+			t := cohorts[key]
+			if t == nil {
+				t = newTally(def)
+				cohorts[key] = t
+			}
+			t.add(done)
+		}
+	})
+
+	r := &Report{
+		Funnel:      def.Name,
+		GeneratedAt: opts.Now(),
+		CohortBy:    opts.CohortBy,
+		Overall:     overall.summary(),
+	}
+	if !opts.From.IsZero() {
+		r.From = &opts.From
+	}
+	if !opts.To.IsZero() {
+		r.To = &opts.To
+	}
+	keys := make([]string, 0, len(cohorts))
+	for k := range cohorts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		r.Cohorts = append(r.Cohorts, CohortReport{Cohort: k, Summary: cohorts[k].summary()})
+	}
+	return r, nil
+}
+
+type tally struct {
+	def     *Definition
+	entered int
+	users   []int
+	gaps    [][]time.Duration
+	total   []time.Duration
+}
+
+func newTally(def *Definition) *tally {
+	return &tally{
+		def:   def,
+		users: make([]int, len(def.Steps)),
+		gaps:  make([][]time.Duration, len(def.Steps)),
+	}
+}
+
+func (t *tally) add(done []time.Time) {
+	t.entered++
+	prev := done[0]
+	for i, at := range done {
+		if at.IsZero() {
+			continue
+		}
+		t.users[i]++
+		if i > 0 {
+			t.gaps[i] = append(t.gaps[i], at.Sub(prev))
+		}
+		prev = at
+	}
+	if last := done[len(done)-1]; !last.IsZero() {
+		t.total = append(t.total, last.Sub(done[0]))
+	}
+}
+
+func (t *tally) summary() Summary {
+	n := len(t.def.Steps)
+	s := Summary{
+		Entered:             t.entered,
+		Converted:           t.users[n-1],
+		Rate:                ratio(t.users[n-1], t.entered),
+		MedianTimeToConvert: median(t.total),
+		Steps:               make([]StepReport, n),
+	}
+	prevRequired := 0
+	for i, step := range t.def.Steps {
+		sr := StepReport{
+			Name:                step.Name,
+			Optional:            step.Optional,
+			Users:               t.users[i],
+			ConversionFromStart: ratio(t.users[i], t.entered),
+			MedianTimeToConvert: median(t.gaps[i]),
+		}
+		if i == 0 {
+			sr.ConversionFromPrevious = ratio(t.users[0], t.entered)
+		} else {
+			sr.ConversionFromPrevious = ratio(t.users[i], t.users[prevRequired])
+			if !step.Optional {
+				sr.DropOff = t.users[prevRequired] - t.users[i]
+			}
+		}
+		if !step.Optional {
+			prevRequired = i
+		}
+		s.Steps[i] = sr
+	}
+	return s
+}
+
+func ratio(n, d int) float64 {
+	if d == 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
+}
+
+func median(ds []time.Duration) Duration {
+	if len(ds) == 0 {
+		return 0
+	}
+	s := append([]time.Duration(nil), ds...)
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+	m := len(s) / 2
+	if len(s)%2 == 1 {
+		return Duration(s[m])
+	}
+	return Duration((s[m-1] + s[m]) / 2)
+}
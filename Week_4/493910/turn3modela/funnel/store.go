@@ -0,0 +1,111 @@
+package funnel
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Event is something a user did. Time defaults to the ingestion time.
+type Event struct {
+	UserID     string            `json:"user_id"`
+	Name       string            `json:"event"`
+	Time       time.Time         `json:"time"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+// User holds the attributes cohorts are built from.
+type User struct {
+	ID       string    `json:"user_id"`
+	SignupAt time.Time `json:"signup_at"`
+	Source   string    `json:"source"`
+}
+
+// Store keeps every user's events in time order. It is safe for
+// concurrent use.
+type Store struct {
+	mu     sync.RWMutex
+	events map[string][]Event
+	users  map[string]User
+	total  int
+
+	// Now stamps events that arrive without a time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+func NewStore() *Store {
+	return &Store{events: map[string][]Event{}, users: map[string]User{}, Now: time.Now}
+}
+
+// Ingest validates and stores a batch of events. Nothing is stored if any
+// event is invalid.
+func (s *Store) Ingest(events ...Event) error {
+	for i, e := range events {
+		if e.UserID == "" || e.Name == "" {
+			return fmt.Errorf("event %d: user_id and event are required", i)
+		}
+	}
+	now := s.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range events {
+		if e.Time.IsZero() {
+			e.Time = now
+		}
+		evs := s.events[e.UserID]
+		// events mostly arrive in order, so this is usually an append
+		i := sort.Search(len(evs), func(i int) bool { return evs[i].Time.After(e.Time) })
+		evs = append(evs, Event{})
+		copy(evs[i+1:], evs[i:])
+		evs[i] = e
+		s.events[e.UserID] = evs
+		s.total++
+	}
+	return nil
+}
+
+// UpsertUser records or replaces a user's attributes.
+func (s *Store) UpsertUser(u User) error {
+	if u.ID == "" {
+		return fmt.Errorf("user_id is required")
+	}
+	s.mu.Lock()
+	s.users[u.ID] = u
+	s.mu.Unlock()
+	return nil
+}
+
+// User returns the stored attributes for id.
+func (s *Store) User(id string) (User, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	u, ok := s.users[id]
+	return u, ok
+}
+
+// Len returns the number of stored events.
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.total
+}
+
+// each calls fn for every user with events while holding the read lock;
+// fn must not keep the slice.
+func (s *Store) each(fn func(u User, events []Event)) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := make([]string, 0, len(s.events))
+	for id := range s.events {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		u, ok := s.users[id]
+		if !ok {
+			u = User{ID: id}
+		}
+		fn(u, s.events[id])
+	}
+}
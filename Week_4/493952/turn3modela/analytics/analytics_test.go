@@ -0,0 +1,187 @@
+package analytics
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var t0 = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func openTest(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(filepath.Join(t.TempDir(), "metrics.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func at(d time.Duration) time.Time { return t0.Add(d) }
+
+func TestCompute(t *testing.T) {
+	s := openTest(t)
+	ctx := context.Background()
+	err := s.Record(ctx, t0,
+		// converts after 5 minutes
+		Event{SessionID: "a", Type: PageView, Time: at(0)},
+		Event{SessionID: "a", Type: Impression, Time: at(0)},
+		Event{SessionID: "a", Type: Click, Time: at(time.Minute)},
+		Event{SessionID: "a", Type: PageView, Time: at(time.Minute)},
+		Event{SessionID: "a", Type: Order, Time: at(5 * time.Minute), Amount: 40},
+		// bounces: one page, an ignored impression
+		Event{SessionID: "b", Type: PageView, Time: at(2 * time.Minute)},
+		Event{SessionID: "b", Type: Impression, Time: at(2 * time.Minute)},
+		// browses for a minute without buying
+		Event{SessionID: "c", Type: PageView, Time: at(3 * time.Minute)},
+		Event{SessionID: "c", Type: PageView, Time: at(4 * time.Minute)},
+		// outside the window
+		Event{SessionID: "d", Type: Order, Time: at(-2 * time.Hour), Amount: 99},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := s.Compute(ctx, at(-time.Hour), at(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Snapshot{
+		Time:             at(time.Hour),
+		Sessions:         3,
+		PageViews:        5,
+		ConversionRate:   100.0 / 3,
+		BounceRate:       100.0 / 3,
+		SessionDuration:  (300 + 0 + 60) / 3.0,
+		ClickThroughRate: 50,
+		Orders:           1,
+		Revenue:          40,
+	}
+	if !approx(snap, want) {
+		t.Errorf("Compute =\n%+v, want\n%+v", snap, want)
+	}
+
+	empty, err := s.Compute(ctx, at(10*time.Hour), at(11*time.Hour))
+	if err != nil || empty.Sessions != 0 || empty.ConversionRate != 0 {
+		t.Errorf("empty window = %+v, %v", empty, err)
+	}
+
+	if err := s.Record(ctx, t0, Event{SessionID: "e", Type: PageView}, Event{SessionID: "e", Type: "scroll"}); err == nil {
+		t.Error("unknown type accepted")
+	}
+	if n, _ := s.Compute(ctx, at(-time.Hour), at(time.Hour)); n.Sessions != 3 {
+		t.Error("a rejected batch was partly stored")
+	}
+}
+
+func approx(a, b Snapshot) bool {
+	eq := func(x, y float64) bool { return math.Abs(x-y) < 1e-9 }
+	return a.Time.Equal(b.Time) && a.Sessions == b.Sessions && a.PageViews == b.PageViews &&
+		a.Orders == b.Orders && eq(a.Revenue, b.Revenue) && eq(a.ConversionRate, b.ConversionRate) &&
+		eq(a.BounceRate, b.BounceRate) && eq(a.SessionDuration, b.SessionDuration) &&
+		eq(a.ClickThroughRate, b.ClickThroughRate)
+}
+
+func TestSamplerAndHistory(t *testing.T) {
+	s := openTest(t)
+	ctx := context.Background()
+	reg := prometheus.NewRegistry()
+	now := t0
+	sampler := &Sampler{Store: s, Gauges: NewGauges(reg), Window: time.Hour, Now: func() time.Time { return now }}
+
+	for i := 0; i < 10; i++ {
+		s.Record(ctx, now, Event{SessionID: "s", Type: PageView, Time: now.Add(-time.Second)})
+		if _, err := sampler.Sample(ctx); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(time.Minute)
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		if f.GetName() == "ecommerce_page_views" {
+			if got := f.GetMetric()[0].GetGauge().GetValue(); got != 10 {
+				t.Errorf("page views gauge = %v", got)
+			}
+		}
+	}
+
+	all, err := s.History(ctx, t0, now, 0)
+	if err != nil || len(all) != 10 || all[3].PageViews != 4 {
+		t.Fatalf("History = %+v, %v", all, err)
+	}
+	few, _ := s.History(ctx, t0, now, 3)
+	if len(few) != 3 || few[0].PageViews != 3 || !few[2].Time.Equal(all[9].Time) {
+		t.Errorf("downsampled = %+v", few)
+	}
+
+	h := HistoryHandler(s, func() time.Time { return now })
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics/json?range=5m", nil))
+	var body HistoryResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || len(body.Points) != 5 {
+		t.Errorf("range=5m: %d %s", rec.Code, rec.Body)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics/json?range=-5m", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("negative range = %d", rec.Code)
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	cases := []struct {
+		query    string
+		from, to time.Time
+		ok       bool
+	}{
+		{"", at(-time.Hour), t0, true},
+		{"range=15m", at(-15 * time.Minute), t0, true},
+		{"from=2024-05-01T10:00:00Z", at(-2 * time.Hour), t0, true},
+		{"from=2024-05-01T10:00:00Z&to=2024-05-01T11:00:00Z", at(-2 * time.Hour), at(-time.Hour), true},
+		{"from=2024-05-01T13:00:00Z", time.Time{}, time.Time{}, false},
+		{"range=soon", time.Time{}, time.Time{}, false},
+	}
+	for _, c := range cases {
+		q, _ := url.ParseQuery(c.query)
+		from, to, err := ParseRange(q, t0)
+		if (err == nil) != c.ok || (c.ok && (!from.Equal(c.from) || !to.Equal(c.to))) {
+			t.Errorf("%q: %v %v %v", c.query, from, to, err)
+		}
+	}
+}
+
+func TestIngestHandler(t *testing.T) {
+	s := openTest(t)
+	h := IngestHandler(s, func() time.Time { return t0 })
+	post := func(body string) int {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("POST", "/events", strings.NewReader(body)))
+		return rec.Code
+	}
+	if code := post(`{"session_id": "x", "type": "page_view"}`); code != http.StatusAccepted {
+		t.Errorf("single = %d", code)
+	}
+	if code := post(`[{"session_id": "x", "type": "click"}, {"session_id": "x", "type": "order", "amount": 12.5}]`); code != http.StatusAccepted {
+		t.Errorf("batch = %d", code)
+	}
+	if code := post(`{"session_id": "x", "type": "order"}`); code != http.StatusBadRequest {
+		t.Errorf("order without amount = %d", code)
+	}
+	snap, _ := s.Compute(context.Background(), at(-time.Minute), at(time.Minute))
+	if snap.PageViews != 1 || snap.Orders != 1 || snap.Revenue != 12.5 {
+		t.Errorf("after ingest = %+v", snap)
+	}
+}
@@ -0,0 +1,133 @@
+package analytics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	maxBodyBytes     = 1 << 20
+	defaultMaxPoints = 500
+	maxMaxPoints     = 5000
+)
+
+// IngestHandler accepts POSTed events, either one JSON object or an array.
+func IngestHandler(store *Store, now func() time.Time) http.Handler {
+	if now == nil {
+		now = time.Now
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			httpError(w, http.StatusMethodNotAllowed, fmt.Errorf("use POST"))
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+		if err != nil {
+			httpError(w, http.StatusRequestEntityTooLarge, err)
+			return
+		}
+		var events []Event
+		body = bytes.TrimSpace(body)
+		if len(body) > 0 && body[0] == '[' {
+			err = json.Unmarshal(body, &events)
+		} else {
+			var e Event
+			err = json.Unmarshal(body, &e)
+			events = []Event{e}
+		}
+		if err != nil {
+			httpError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := store.Record(r.Context(), now(), events...); err != nil {
+			httpError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, map[string]int{"accepted": len(events)})
+	})
+}
+
+// HistoryResponse is the body served by HistoryHandler.
+type HistoryResponse struct {
+	From   time.Time  `json:"from"`
+	To     time.Time  `json:"to"`
+	Points []Snapshot `json:"points"`
+}
+
+// HistoryHandler serves snapshots for a time range; see ParseRange for the
+// query parameters. max_points (default 500) caps the number of points.
+func HistoryHandler(store *Store, now func() time.Time) http.Handler {
+	if now == nil {
+		now = time.Now
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		from, to, err := ParseRange(q, now())
+		if err != nil {
+			httpError(w, http.StatusBadRequest, err)
+			return
+		}
+		maxPoints := defaultMaxPoints
+		if v := q.Get("max_points"); v != "" {
+			if maxPoints, err = strconv.Atoi(v); err != nil || maxPoints < 1 || maxPoints > maxMaxPoints {
+				httpError(w, http.StatusBadRequest, fmt.Errorf("max_points must be between 1 and %d", maxMaxPoints))
+				return
+			}
+		}
+		points, err := store.History(r.Context(), from, to, maxPoints)
+		if err != nil {
+			httpError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if points == nil {
+			points = []Snapshot{}
+		}
+		writeJSON(w, http.StatusOK, HistoryResponse{From: from, To: to, Points: points})
+	})
+}
+
+// ParseRange reads a time range from the query: either range=<duration>
+// ending now (default 1h), or explicit RFC 3339 from and to, where a
+// missing to means now.
+func ParseRange(q url.Values, now time.Time) (from, to time.Time, err error) {
+	to = now
+	if v := q.Get("to"); v != "" {
+		if to, err = time.Parse(time.RFC3339, v); err != nil {
+			return from, to, fmt.Errorf("to: %w", err)
+		}
+	}
+	if v := q.Get("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			return from, to, fmt.Errorf("from: %w", err)
+		}
+	} else {
+		d := time.Hour
+		if v := q.Get("range"); v != "" {
+			if d, err = time.ParseDuration(v); err != nil || d <= 0 {
+				return from, to, fmt.Errorf("range must be a positive duration like 15m or 24h")
+			}
+		}
+		from = to.Add(-d)
+	}
+	if !from.Before(to) {
+		return from, to, fmt.Errorf("from must be before to")
+	}
+	return from, to, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func httpError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
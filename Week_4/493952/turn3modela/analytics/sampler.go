@@ -0,0 +1,117 @@
+package analytics
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Gauges are the ecommerce_* metrics exported to Prometheus.
+type Gauges struct {
+	ConversionRate   prometheus.Gauge
+	PageViews        prometheus.Gauge
+	BounceRate       prometheus.Gauge
+	SessionDuration  prometheus.Gauge
+	ClickThroughRate prometheus.Gauge
+	Sessions         prometheus.Gauge
+	Orders           prometheus.Gauge
+	Revenue          prometheus.Gauge
+}
+
+// NewGauges creates the gauges and registers them with reg.
+func NewGauges(reg prometheus.Registerer) *Gauges {
+	gauge := func(name, help string) prometheus.Gauge {
+		return prometheus.NewGauge(prometheus.GaugeOpts{Name: "ecommerce_" + name, Help: help})
+	}
+	g := &Gauges{
+		ConversionRate:   gauge("conversion_rate", "Percentage of sessions in the window that placed an order."),
+		PageViews:        gauge("page_views", "Page views in the window."),
+		BounceRate:       gauge("bounce_rate", "Percentage of sessions in the window that viewed one page without clicking or ordering."),
+		SessionDuration:  gauge("session_duration", "Mean session duration in the window, in seconds."),
+		ClickThroughRate: gauge("click_through_rate", "Clicks per impression in the window, as a percentage."),
+		Sessions:         gauge("sessions", "Sessions with activity in the window."),
+		Orders:           gauge("orders", "Orders placed in the window."),
+		Revenue:          gauge("revenue", "Order revenue in the window."),
+	}
+	reg.MustRegister(g.ConversionRate, g.PageViews, g.BounceRate, g.SessionDuration,
+		g.ClickThroughRate, g.Sessions, g.Orders, g.Revenue)
+	return g
+}
+
+// Set updates every gauge from snap.
+func (g *Gauges) Set(snap Snapshot) {
+	g.ConversionRate.Set(snap.ConversionRate)
+	g.PageViews.Set(float64(snap.PageViews))
+	g.BounceRate.Set(snap.BounceRate)
+	g.SessionDuration.Set(snap.SessionDuration)
+	g.ClickThroughRate.Set(snap.ClickThroughRate)
+	g.Sessions.Set(float64(snap.Sessions))
+	g.Orders.Set(float64(snap.Orders))
+	g.Revenue.Set(snap.Revenue)
+}
+
+// Sampler periodically computes a snapshot over the trailing Window,
+// publishes it to the gauges and appends it to the history.
+type Sampler struct {
+	Store     *Store
+	Gauges    *Gauges       // optional
+	Interval  time.Duration // default 10s
+	Window    time.Duration // default 1h
+	Retention time.Duration // events and snapshots older than this are pruned; default 30 days
+	Now       func() time.Time
+}
+
+// Sample takes one snapshot.
+func (s *Sampler) Sample(ctx context.Context) (Snapshot, error) {
+	s.defaults()
+	now := s.Now()
+	snap, err := s.Store.Compute(ctx, now.Add(-s.Window), now)
+	if err != nil {
+		return snap, err
+	}
+	if s.Gauges != nil {
+		s.Gauges.Set(snap)
+	}
+	return snap, s.Store.SaveSnapshot(ctx, snap)
+}
+
+// Run samples every Interval until ctx is done, pruning old data hourly.
+func (s *Sampler) Run(ctx context.Context) {
+	s.defaults()
+	tick := time.NewTicker(s.Interval)
+	defer tick.Stop()
+	lastPrune := time.Time{}
+	for {
+		if _, err := s.Sample(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("sampling metrics: %v", err)
+		}
+		if now := s.Now(); now.Sub(lastPrune) >= time.Hour {
+			if err := s.Store.Prune(ctx, now.Add(-s.Retention)); err != nil && ctx.Err() == nil {
+				log.Printf("pruning metrics: %v", err)
+			}
+			lastPrune = now
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+func (s *Sampler) defaults() {
+	if s.Interval <= 0 {
+		s.Interval = 10 * time.Second
+	}
+	if s.Window <= 0 {
+		s.Window = time.Hour
+	}
+	if s.Retention <= 0 {
+		s.Retention = 30 * 24 * time.Hour
+	}
+	if s.Now == nil {
+		s.Now = time.Now
+	}
+}
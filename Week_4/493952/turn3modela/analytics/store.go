@@ -0,0 +1,269 @@
+package analytics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+)
+
+// Event types accepted by Record.
+const (
+	PageView   = "page_view"
+	Impression = "impression" // a product or ad was shown
+	Click      = "click"      // an impression was clicked
+	Order      = "order"
+)
+
+var eventTypes = map[string]bool{PageView: true, Impression: true, Click: true, Order: true}
+
+// Event is one thing a visitor did during a session. Amount is the order
+// total and only meaningful for orders.
+type Event struct {
+	SessionID string    `json:"session_id"`
+	Type      string    `json:"type"`
+	Time      time.Time `json:"time"`
+	Amount    float64   `json:"amount,omitempty"`
+}
+
+// Snapshot is the set of dashboard metrics for one window of events.
+// Rates are percentages and SessionDuration is the mean in seconds.
+type Snapshot struct {
+	Time             time.Time `json:"time"`
+	Sessions         int       `json:"sessions"`
+	PageViews        int       `json:"page_views"`
+	ConversionRate   float64   `json:"conversion_rate"`
+	BounceRate       float64   `json:"bounce_rate"`
+	SessionDuration  float64   `json:"session_duration"`
+	ClickThroughRate float64   `json:"click_through_rate"`
+	Orders           int       `json:"orders"`
+	Revenue          float64   `json:"revenue"`
+}
+
+// Store keeps events and metric snapshots in SQLite. Times are stored as
+// Unix milliseconds so range queries use the index.
+type Store struct {
+	db *sql.DB
+}
+
+const schema = `
+CREATE TABLE IF NOT EXISTS events (
+	session_id TEXT NOT NULL,
+	type TEXT NOT NULL,
+	at INTEGER NOT NULL,
+	amount REAL NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS events_at ON events(at);
+
+CREATE TABLE IF NOT EXISTS snapshots (
+	at INTEGER PRIMARY KEY,
+	sessions INTEGER NOT NULL,
+	page_views INTEGER NOT NULL,
+	conversion_rate REAL NOT NULL,
+	bounce_rate REAL NOT NULL,
+	session_duration REAL NOT NULL,
+	click_through_rate REAL NOT NULL,
+	orders INTEGER NOT NULL,
+	revenue REAL NOT NULL
+);`
+
+// Open opens or creates the database at path.
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating tables: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error { return s.db.Close() }
+
+// Validate checks an event before it is recorded.
+func (e *Event) Validate() error {
+	switch {
+	case e.SessionID == "":
+		return errors.New("session_id is required")
+	case !eventTypes[e.Type]:
+		return fmt.Errorf("unknown event type %q", e.Type)
+	case e.Amount < 0:
+		return errors.New("amount cannot be negative")
+	case e.Type == Order && e.Amount == 0:
+		return errors.New("orders need an amount")
+	}
+	return nil
+}
+
+// Record stores events in one transaction. Events without a time are
+// stamped with now.
+func (s *Store) Record(ctx context.Context, now time.Time, events ...Event) error {
+	for i := range events {
+		if err := events[i].Validate(); err != nil {
+			return fmt.Errorf("event %d: %w", i, err)
+		}
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO events (session_id, type, at, amount) VALUES (?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, e := range events {
+		at := e.Time
+		if at.IsZero() {
+			at = now
+		}
+		if _, err := stmt.ExecContext(ctx, e.SessionID, e.Type, at.UnixMilli(), e.Amount); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Compute derives the metrics from the events in [from, to). A session
+// counts when it has any event in the window; it converts when it places
+// an order and bounces when it views at most one page without clicking
+// or ordering.
+func (s *Store) Compute(ctx context.Context, from, to time.Time) (Snapshot, error) {
+	snap := Snapshot{Time: to}
+	lo, hi := from.UnixMilli(), to.UnixMilli()
+
+	var impressions, clicks int
+	err := s.db.QueryRowContext(ctx, `
+		SELECT
+			COALESCE(SUM(type = 'page_view'), 0),
+			COALESCE(SUM(type = 'impression'), 0),
+			COALESCE(SUM(type = 'click'), 0),
+			COALESCE(SUM(type = 'order'), 0),
+			COALESCE(SUM(CASE WHEN type = 'order' THEN amount END), 0)
+		FROM events WHERE at >= ? AND at < ?`, lo, hi,
+	).Scan(&snap.PageViews, &impressions, &clicks, &snap.Orders, &snap.Revenue)
+	if err != nil {
+		return snap, err
+	}
+
+	var converted, bounced int
+	var duration float64
+	err = s.db.QueryRowContext(ctx, `
+		SELECT
+			COUNT(*),
+			COALESCE(SUM(orders > 0), 0),
+			COALESCE(SUM(page_views <= 1 AND clicks = 0 AND orders = 0), 0),
+			COALESCE(AVG(last - first), 0)
+		FROM (
+			SELECT MIN(at) AS first, MAX(at) AS last, SUM(type = 'page_view') AS page_views,
+				SUM(type = 'click') AS clicks, SUM(type = 'order') AS orders
+			FROM events WHERE at >= ? AND at < ?
+			GROUP BY session_id
+		)`, lo, hi,
+	).Scan(&snap.Sessions, &converted, &bounced, &duration)
+	if err != nil {
+		return snap, err
+	}
+
+	snap.ConversionRate = percent(converted, snap.Sessions)
+	snap.BounceRate = percent(bounced, snap.Sessions)
+	snap.ClickThroughRate = percent(clicks, impressions)
+	snap.SessionDuration = duration / 1000
+	return snap, nil
+}
+
+func percent(n, d int) float64 {
+	if d == 0 {
+		return 0
+	}
+	return 100 * float64(n) / float64(d)
+}
+
+// SaveSnapshot appends snap to the history, replacing one taken at the
+// same millisecond.
+func (s *Store) SaveSnapshot(ctx context.Context, snap Snapshot) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO snapshots (at, sessions, page_views, conversion_rate, bounce_rate,
+			session_duration, click_through_rate, orders, revenue)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		snap.Time.UnixMilli(), snap.Sessions, snap.PageViews, snap.ConversionRate, snap.BounceRate,
+		snap.SessionDuration, snap.ClickThroughRate, snap.Orders, snap.Revenue)
+	return err
+}
+
+// History returns the snapshots in [from, to), oldest first. When there
+// are more than maxPoints (and maxPoints > 0), consecutive snapshots are
+// averaged so the result has at most maxPoints entries.
+func (s *Store) History(ctx context.Context, from, to time.Time, maxPoints int) ([]Snapshot, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT at, sessions, page_views, conversion_rate, bounce_rate,
+			session_duration, click_through_rate, orders, revenue
+		FROM snapshots WHERE at >= ? AND at < ? ORDER BY at`, from.UnixMilli(), to.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Snapshot
+	for rows.Next() {
+		var sn Snapshot
+		var at int64
+		if err := rows.Scan(&at, &sn.Sessions, &sn.PageViews, &sn.ConversionRate, &sn.BounceRate,
+			&sn.SessionDuration, &sn.ClickThroughRate, &sn.Orders, &sn.Revenue); err != nil {
+			return nil, err
+		}
+		sn.Time = time.UnixMilli(at).UTC()
+		out = append(out, sn)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return downsample(out, maxPoints), nil
+}
+
+func downsample(snaps []Snapshot, max int) []Snapshot {
+	if max <= 0 || len(snaps) <= max {
+		return snaps
+	}
+	size := (len(snaps) + max - 1) / max
+	out := make([]Snapshot, 0, max)
+	for i := 0; i < len(snaps); i += size {
+		bucket := snaps[i:min(i+size, len(snaps))]
+		var avg Snapshot
+		n := float64(len(bucket))
+		for _, b := range bucket {
+			avg.Sessions += b.Sessions
+			avg.PageViews += b.PageViews
+			avg.ConversionRate += b.ConversionRate / n
+			avg.BounceRate += b.BounceRate / n
+			avg.SessionDuration += b.SessionDuration / n
+			avg.ClickThroughRate += b.ClickThroughRate / n
+			avg.Orders += b.Orders
+			avg.Revenue += b.Revenue / n
+		}
+		avg.Sessions = int(float64(avg.Sessions)/n + 0.5)
+		avg.PageViews = int(float64(avg.PageViews)/n + 0.5)
+		avg.Orders = int(float64(avg.Orders)/n + 0.5)
+		avg.Time = bucket[len(bucket)-1].Time
+		out = append(out, avg)
+	}
+	return out
+}
+
+// Prune deletes events and snapshots older than before.
+func (s *Store) Prune(ctx context.Context, before time.Time) error {
+	ms := before.UnixMilli()
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM events WHERE at < ?`, ms); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, `DELETE FROM snapshots WHERE at < ?`, ms)
+	return err
+}
@@ -0,0 +1,54 @@
+// Refreshes the server-rendered charts from the history endpoint and
+// wires up the range and refresh selectors.
+(function () {
+  "use strict";
+  const root = document.getElementById("dashboard");
+  const cfg = JSON.parse(root.dataset.config);
+  const status = document.getElementById("status");
+
+  document.querySelectorAll("#controls select").forEach(function (el) {
+    el.addEventListener("change", function () { el.form.submit(); });
+  });
+
+  function charts() {
+    if (typeof echarts === "undefined") {
+      return [];
+    }
+    return cfg.panels.map(function (p) {
+      return { panel: p, chart: echarts.getInstanceByDom(document.getElementById(p.id)) };
+    }).filter(function (c) { return c.chart; });
+  }
+
+  async function refresh() {
+    const url = cfg.history + "?range=" + encodeURIComponent(cfg.range) + "&max_points=" + cfg.maxPoints;
+    const res = await fetch(url, { cache: "no-store" });
+    if (!res.ok) {
+      throw new Error(res.status + " " + (await res.text()));
+    }
+    const data = await res.json();
+    charts().forEach(function (c) {
+      c.chart.setOption({
+        series: c.panel.series.map(function (s) {
+          return {
+            name: s.name,
+            data: data.points.map(function (pt) { return [Date.parse(pt.time), pt[s.field]]; }),
+          };
+        }),
+      });
+    });
+    status.textContent = "Updated " + new Date().toLocaleTimeString() + " (" + data.points.length + " points)";
+    status.className = "";
+  }
+
+  if (cfg.refresh > 0) {
+    setInterval(function () {
+      refresh().catch(function (err) {
+        status.textContent = "Refresh failed: " + err.message;
+        status.className = "error";
+      });
+    }, cfg.refresh * 1000);
+  }
+  window.addEventListener("resize", function () {
+    charts().forEach(function (c) { c.chart.resize(); });
+  });
+})();
@@ -0,0 +1,268 @@
+// Package dashboard renders the metrics history with go-echarts. Every
+// asset the page loads, including echarts.min.js, is served from the
+// binary, so the dashboard works without internet access. echarts.min.js
+// is checked in under assets at the version pinned in fetch_assets.sh,
+// which is only needed to update it.
+package dashboard
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"time"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+
+	"github.com/dm-turing/RLHF_production_batch_2025/Week_4/493952/turn3modela/analytics"
+)
+
+//go:embed assets
+var embedded embed.FS
+
+// Assets holds the static files served under Dashboard.AssetsPath.
+var Assets, _ = fs.Sub(embedded, "assets")
+
+// HasECharts reports whether echarts.min.js was embedded at build time. It
+// is false only in a tree where the checked-in file has been removed.
+func HasECharts() bool {
+	_, err := fs.Stat(Assets, opts.EchartsJS)
+	return err == nil
+}
+
+// Series is one line on a panel. Field is the snapshot's JSON field name,
+// used by the page to refresh the line.
+type Series struct {
+	Name  string                           `json:"name"`
+	Field string                           `json:"field"`
+	Value func(analytics.Snapshot) float64 `json:"-"`
+}
+
+// Panel is one chart.
+type Panel struct {
+	ID     string   `json:"id"`
+	Title  string   `json:"-"`
+	Unit   string   `json:"-"`
+	Series []Series `json:"series"`
+}
+
+// DefaultPanels chart every snapshot field.
+var DefaultPanels = []Panel{
+	{ID: "rates", Title: "Conversion, bounce and click-through", Unit: "%", Series: []Series{
+		{"Conversion rate", "conversion_rate", func(s analytics.Snapshot) float64 { return s.ConversionRate }},
+		{"Bounce rate", "bounce_rate", func(s analytics.Snapshot) float64 { return s.BounceRate }},
+		{"Click-through rate", "click_through_rate", func(s analytics.Snapshot) float64 { return s.ClickThroughRate }},
+	}},
+	{ID: "traffic", Title: "Traffic", Unit: "count", Series: []Series{
+		{"Page views", "page_views", func(s analytics.Snapshot) float64 { return float64(s.PageViews) }},
+		{"Sessions", "sessions", func(s analytics.Snapshot) float64 { return float64(s.Sessions) }},
+	}},
+	{ID: "duration", Title: "Mean session duration", Unit: "seconds", Series: []Series{
+		{"Session duration", "session_duration", func(s analytics.Snapshot) float64 { return s.SessionDuration }},
+	}},
+	{ID: "orders", Title: "Orders and revenue", Series: []Series{
+		{"Orders", "orders", func(s analytics.Snapshot) float64 { return float64(s.Orders) }},
+		{"Revenue", "revenue", func(s analytics.Snapshot) float64 { return s.Revenue }},
+	}},
+}
+
+// Ranges and RefreshIntervals are the choices offered on the page. Other
+// values still work when passed in the query string.
+var (
+	Ranges           = []string{"15m", "1h", "6h", "24h", "168h"}
+	RefreshIntervals = []int{0, 10, 30, 60}
+)
+
+// Dashboard serves the dashboard page. Mount AssetsHandler at AssetsPath
+// and the history endpoint at HistoryPath.
+type Dashboard struct {
+	Store       *analytics.Store
+	Panels      []Panel // default DefaultPanels
+	HistoryPath string  // default /metrics/json
+	AssetsPath  string  // default /assets/
+	MaxPoints   int     // default 500
+	Now         func() time.Time
+}
+
+// AssetsHandler serves the embedded files.
+func (d *Dashboard) AssetsHandler() http.Handler {
+	d.defaults()
+	return http.StripPrefix(d.AssetsPath, http.FileServer(http.FS(Assets)))
+}
+
+func (d *Dashboard) defaults() {
+	if d.Panels == nil {
+		d.Panels = DefaultPanels
+	}
+	if d.HistoryPath == "" {
+		d.HistoryPath = "/metrics/json"
+	}
+	if d.AssetsPath == "" {
+		d.AssetsPath = "/assets/"
+	}
+	if d.MaxPoints <= 0 {
+		d.MaxPoints = 500
+	}
+	if d.Now == nil {
+		d.Now = time.Now
+	}
+}
+
+type pageConfig struct {
+	History   string  `json:"history"`
+	Range     string  `json:"range"`
+	Refresh   int     `json:"refresh"`
+	MaxPoints int     `json:"maxPoints"`
+	Panels    []Panel `json:"panels"`
+}
+
+type renderedPanel struct {
+	Title   string
+	Element template.HTML
+	Script  template.HTML
+}
+
+type pageData struct {
+	Assets     string
+	HasECharts bool
+	Range      string
+	Refresh    int
+	Ranges     []string
+	Refreshes  []int
+	Panels     []renderedPanel
+	Config     string
+	Points     int
+	From, To   time.Time
+}
+
+func (d *Dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.defaults()
+	q := r.URL.Query()
+	rng := q.Get("range")
+	if rng == "" {
+		rng = "1h"
+	}
+	refresh := 30
+	if v := q.Get("refresh"); v != "" {
+		if _, err := fmt.Sscan(v, &refresh); err != nil || refresh < 0 {
+			http.Error(w, "refresh must be a number of seconds", http.StatusBadRequest)
+			return
+		}
+	}
+	q.Del("from")
+	q.Del("to")
+	q.Set("range", rng)
+	from, to, err := analytics.ParseRange(q, d.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	points, err := d.Store.History(r.Context(), from, to, d.MaxPoints)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	cfg, _ := json.Marshal(pageConfig{History: d.HistoryPath, Range: rng, Refresh: refresh, MaxPoints: d.MaxPoints, Panels: d.Panels})
+	data := pageData{
+		Assets:     d.AssetsPath,
+		HasECharts: HasECharts(),
+		Range:      rng,
+		Refresh:    refresh,
+		Ranges:     withCurrent(Ranges, rng),
+		Refreshes:  withCurrent(RefreshIntervals, refresh),
+		Config:     string(cfg),
+		Points:     len(points),
+		From:       from,
+		To:         to,
+	}
+	for _, p := range d.Panels {
+		snip := d.chart(p, points, from, to).RenderSnippet()
+		data.Panels = append(data.Panels, renderedPanel{
+			Title: p.Title,
+			// both come from go-echarts templates built from our own options
+			Element: template.HTML(snip.Element),
+			Script:  template.HTML(snip.Script),
+		})
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := page.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (d *Dashboard) chart(p Panel, points []analytics.Snapshot, from, to time.Time) *charts.Line {
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithInitializationOpts(opts.Initialization{
+			ChartID:    p.ID,
+			Width:      "100%",
+			Height:     "320px",
+			AssetsHost: d.AssetsPath,
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{Show: opts.Bool(true), Trigger: "axis"}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true), Top: "0"}),
+		charts.WithXAxisOpts(opts.XAxis{Type: "time", Min: from.UnixMilli(), Max: to.UnixMilli()}),
+		charts.WithYAxisOpts(opts.YAxis{Type: "value", Name: p.Unit}),
+		charts.WithAnimation(false),
+	)
+	for _, s := range p.Series {
+		data := make([]opts.LineData, len(points))
+		for i, pt := range points {
+			data[i] = opts.LineData{Value: []interface{}{pt.Time.UnixMilli(), s.Value(pt)}}
+		}
+		line.AddSeries(s.Name, data, charts.WithLineChartOpts(opts.LineChart{ShowSymbol: opts.Bool(false)}))
+	}
+	return line
+}
+
+// withCurrent makes sure the selected value is among the choices.
+func withCurrent[T comparable](choices []T, cur T) []T {
+	for _, c := range choices {
+		if c == cur {
+			return choices
+		}
+	}
+	return append(append([]T(nil), choices...), cur)
+}
+
+var page = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>E-commerce Metrics Dashboard</title>
+<link rel="stylesheet" href="{{.Assets}}dashboard.css">
+<script src="{{.Assets}}echarts.min.js"></script>
+</head>
+<body>
+<main id="dashboard" data-config="{{.Config}}">
+<header>
+<h1>E-commerce Metrics Dashboard</h1>
+<form id="controls" method="get">
+<label>Range
+<select name="range">{{range .Ranges}}<option value="{{.}}"{{if eq . $.Range}} selected{{end}}>{{.}}</option>{{end}}</select>
+</label>
+<label>Auto-refresh
+<select name="refresh">{{range .Refreshes}}<option value="{{.}}"{{if eq . $.Refresh}} selected{{end}}>{{if eq . 0}}off{{else}}{{.}}s{{end}}</option>{{end}}</select>
+</label>
+<noscript><button>Apply</button></noscript>
+</form>
+</header>
+<p id="status">{{.Points}} points from {{.From.Format "2006-01-02 15:04:05"}} to {{.To.Format "2006-01-02 15:04:05 MST"}}</p>
+{{if not .HasECharts}}<p class="warning">echarts.min.js is missing from the dashboard assets of this build, so the charts cannot be drawn.</p>{{end}}
+<div class="panels">
+{{range .Panels}}<section class="panel">
+<h2>{{.Title}}</h2>
+{{.Element}}
+{{if $.HasECharts}}{{.Script}}{{end}}
+</section>
+{{end}}</div>
+</main>
+<script src="{{.Assets}}dashboard.js"></script>
+</body>
+</html>
+`))
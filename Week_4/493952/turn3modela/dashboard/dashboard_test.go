@@ -0,0 +1,82 @@
+package dashboard
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dm-turing/RLHF_production_batch_2025/Week_4/493952/turn3modela/analytics"
+)
+
+func TestDashboard(t *testing.T) {
+	if !HasECharts() {
+		t.Fatal("assets/echarts.min.js is not embedded; the checked-in copy is missing")
+	}
+	store, err := analytics.Open(filepath.Join(t.TempDir(), "metrics.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		store.SaveSnapshot(context.Background(), analytics.Snapshot{Time: now.Add(-time.Duration(i) * time.Minute), PageViews: 100 + i})
+	}
+
+	d := &Dashboard{Store: store, Now: func() time.Time { return now }}
+	mux := http.NewServeMux()
+	mux.Handle("/", d)
+	mux.Handle("/assets/", d.AssetsHandler())
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	get := func(path string) (int, string) {
+		res, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		b, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(b)
+	}
+
+	code, body := get("/?range=15m&refresh=10")
+	if code != http.StatusOK {
+		t.Fatalf("GET / = %d: %s", code, body)
+	}
+	for _, p := range DefaultPanels {
+		if !strings.Contains(body, `id="`+p.ID+`"`) {
+			t.Errorf("panel %s missing", p.ID)
+		}
+	}
+	if !strings.Contains(body, "echarts.init(") {
+		t.Error("charts are not initialised")
+	}
+	if !strings.Contains(body, `<option value="15m" selected>`) || !strings.Contains(body, `<option value="10" selected>`) {
+		t.Error("selected range or refresh not marked")
+	}
+	// every script and stylesheet comes from our own assets
+	for _, m := range regexp.MustCompile(`(?:src|href)="([^"]+)"`).FindAllStringSubmatch(body, -1) {
+		if !strings.HasPrefix(m[1], "/assets/") {
+			t.Errorf("page loads %s from outside the binary", m[1])
+		}
+	}
+	if strings.Contains(body, "https://") {
+		t.Error("page references an external URL")
+	}
+
+	if code, js := get("/assets/dashboard.js"); code != http.StatusOK || !strings.Contains(js, "getInstanceByDom") {
+		t.Errorf("dashboard.js = %d", code)
+	}
+	if code, js := get("/assets/echarts.min.js"); code != http.StatusOK || !strings.Contains(js, "echarts") {
+		t.Errorf("echarts.min.js = %d", code)
+	}
+	if code, _ := get("/?refresh=soon"); code != http.StatusBadRequest {
+		t.Errorf("bad refresh = %d", code)
+	}
+}
@@ -0,0 +1,9 @@
+#!/bin/sh
+# Updates the ECharts build the dashboard embeds. assets/echarts.min.js is
+# checked in, so building never needs this script or network access; change
+# ECHARTS_VERSION, run it and commit the new file to move to another release.
+set -eu
+ECHARTS_VERSION=5.4.3
+cd "$(dirname "$0")"
+curl -fsSL -o assets/echarts.min.js.tmp "https://cdn.jsdelivr.net/npm/echarts@${ECHARTS_VERSION}/dist/echarts.min.js"
+mv assets/echarts.min.js.tmp assets/echarts.min.js
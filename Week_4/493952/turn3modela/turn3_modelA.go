@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/dm-turing/RLHF_production_batch_2025/Week_4/493952/turn3modela/analytics"
+	"github.com/dm-turing/RLHF_production_batch_2025/Week_4/493952/turn3modela/dashboard"
+)
+
+// simulate records made-up shopping sessions every tick so the dashboard
+// has data on a machine with no real traffic. Real clients POST to /events.
+func simulate(ctx context.Context, store *analytics.Store, perTick int, tick time.Duration) {
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	t := time.NewTicker(tick)
+	defer t.Stop()
+	n := 0
+	for {
+		now := time.Now()
+		var events []analytics.Event
+		for i := 0; i < perTick; i++ {
+			n++
+			id := fmt.Sprintf("sim-%d", n)
+			// lay the session out as offsets from its start, then place it
+			// so that it ends shortly before now
+			type step struct {
+				typ    string
+				offset time.Duration
+				amount float64
+			}
+			var steps []step
+			var at time.Duration
+			pages := 1 + rng.Intn(6)
+			for p := 0; p < pages; p++ {
+				if p > 0 {
+					at += time.Duration(5+rng.Intn(90)) * time.Second
+				}
+				steps = append(steps, step{analytics.PageView, at, 0}, step{analytics.Impression, at, 0})
+				if rng.Intn(100) < 12 {
+					steps = append(steps, step{analytics.Click, at + 2*time.Second, 0})
+				}
+			}
+			if pages > 2 && rng.Intn(100) < 15 {
+				at += 30 * time.Second
+				steps = append(steps, step{analytics.Order, at, float64(10+rng.Intn(190)) + 0.99})
+			}
+			start := now.Add(-at - 3*time.Second - time.Duration(rng.Int63n(int64(tick))))
+			for _, st := range steps {
+				events = append(events, analytics.Event{SessionID: id, Type: st.typ, Time: start.Add(st.offset), Amount: st.amount})
+			}
+		}
+		if err := store.Record(ctx, now, events...); err != nil && ctx.Err() == nil {
+			log.Printf("simulating traffic: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func main() {
+	addr := flag.String("addr", ":8080", "listen address")
+	dbPath := flag.String("db", "metrics.db", "SQLite database for events and metric history")
+	interval := flag.Duration("interval", 10*time.Second, "how often the gauges are recomputed")
+	window := flag.Duration("window", time.Hour, "trailing window the gauges are computed over")
+	retention := flag.Duration("retention", 30*24*time.Hour, "how long events and history are kept")
+	sim := flag.Int("simulate", 0, "record this many simulated sessions per interval (0 disables)")
+	flag.Parse()
+
+	store, err := analytics.Open(*dbPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer store.Close()
+	if !dashboard.HasECharts() {
+		log.Printf("warning: dashboard/assets/echarts.min.js is missing from this build; charts will not render")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	sampler := &analytics.Sampler{
+		Store:     store,
+		Gauges:    analytics.NewGauges(prometheus.DefaultRegisterer),
+		Interval:  *interval,
+		Window:    *window,
+		Retention: *retention,
+	}
+	go sampler.Run(ctx)
+	if *sim > 0 {
+		go simulate(ctx, store, *sim, *interval)
+	}
+
+	dash := &dashboard.Dashboard{Store: store}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.Handle("/metrics/json", analytics.HistoryHandler(store, nil))
+	mux.Handle("/events", analytics.IngestHandler(store, nil))
+	mux.Handle("/assets/", dash.AssetsHandler())
+	mux.Handle("/{$}", dash)
+
+	srv := &http.Server{Addr: *addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+	log.Printf("dashboard listening on %s", *addr)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+}
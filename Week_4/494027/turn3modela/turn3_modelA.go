@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"Week_3/512342/turn3modela/gesture"
+)
+
+// MouseEvent represents a generic mouse event. X, Y and At carry what the
+// gesture recognizer needs; a zero At means "now" on its clock.
+type MouseEvent struct {
+	Description string
+	TimeTaken   time.Duration
+	Error       error
+	X, Y        int
+	At          time.Time
+}
+
+// EventHandler is a type for event handling functions
+type EventHandler func(event MouseEvent, next func(MouseEvent))
+
+// ProcessEvent processes the event through a chain of callbacks
+func ProcessEvent(event MouseEvent, handlers ...EventHandler) {
+	if len(handlers) == 0 {
+		return
+	}
+
+	handlers[0](event, func(e MouseEvent) {
+		ProcessEvent(e, handlers[1:]...)
+	})
+}
+
+// rawEvents maps event descriptions onto the recognizer's raw events. A
+// MouseClick from a source that does not report presses and releases
+// separately counts as both.
+var rawEvents = map[string][]gesture.EventType{
+	"MouseDown":  {gesture.Down},
+	"MouseUp":    {gesture.Up},
+	"MouseMove":  {gesture.Move},
+	"MouseLeave": {gesture.Leave},
+	"MouseClick": {gesture.Down, gesture.Up},
+}
+
+// GestureHandler feeds every raw event passing through the chain to r and
+// then hands it on unchanged. Recognised gestures, including the timer
+// driven long-press and hover-intent, go to r's callbacks. Events the
+// recognizer does not know, such as a MouseDoubleClick synthesised by the
+// platform, are only passed on.
+func GestureHandler(r *gesture.Recognizer) EventHandler {
+	return func(event MouseEvent, next func(MouseEvent)) {
+		for _, typ := range rawEvents[event.Description] {
+			r.Feed(gesture.Event{Type: typ, X: event.X, Y: event.Y, Time: event.At})
+		}
+		next(event)
+	}
+}
+
+// LogEvent logs the event details
+func LogEvent(event MouseEvent, next func(MouseEvent)) {
+	fmt.Printf("Event: %s at (%d,%d)\n", event.Description, event.X, event.Y)
+	if event.Error != nil {
+		fmt.Printf("Error: %v\n", event.Error)
+	}
+	next(event)
+}
+
+func main() {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+
+	// A recorded session: a double-click, a drag and a long-press. The fake
+	// clock replays it instantly; a live source would use gesture.RealClock
+	// and leave At zero.
+	events := []MouseEvent{
+		{Description: "MouseClick", X: 100, Y: 100, At: at(0)},
+		{Description: "MouseClick", X: 101, Y: 100, At: at(250)},
+		{Description: "MouseDown", X: 50, Y: 50, At: at(1500)},
+		{Description: "MouseMove", X: 58, Y: 50, At: at(1600)},
+		{Description: "MouseMove", X: 90, Y: 60, At: at(1800)},
+		{Description: "MouseUp", X: 120, Y: 80, At: at(2300)},
+		{Description: "MouseDown", X: 200, Y: 200, At: at(3000)},
+		{Description: "MouseUp", X: 200, Y: 200, At: at(3800)},
+	}
+
+	clock := gesture.NewFakeClock(start)
+	recognizer := gesture.New(gesture.DefaultConfig, clock)
+	recognizer.AddCallback(func(g gesture.Gesture) {
+		fmt.Printf("  gesture %8v  %s\n", g.Time.Sub(start), g)
+	})
+
+	handlers := []EventHandler{
+		LogEvent,
+		GestureHandler(recognizer),
+	}
+	for _, event := range events {
+		clock.Set(event.At)
+		ProcessEvent(event, handlers...)
+	}
+	clock.Advance(time.Second)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"Week_3/512342/turn3modela/gesture"
+)
+
+func TestGestureHandler(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+	events := []MouseEvent{
+		{Description: "MouseClick", X: 10, Y: 10, At: at(0)},
+		{Description: "MouseClick", X: 11, Y: 10, At: at(200)},
+		{Description: "MouseDoubleClick", X: 11, Y: 10, At: at(200)},
+		{Description: "MouseDown", X: 50, Y: 50, At: at(1000)},
+		{Description: "MouseMove", X: 80, Y: 50, At: at(1100)},
+		{Description: "MouseUp", X: 90, Y: 50, At: at(1500)},
+		{Description: "MouseDown", X: 5, Y: 5, At: at(2000)},
+		{Description: "MouseUp", X: 5, Y: 5, At: at(2800)},
+	}
+
+	clock := gesture.NewFakeClock(start)
+	r := gesture.New(gesture.Config{}, clock)
+	var gestures []string
+	r.AddCallback(func(g gesture.Gesture) { gestures = append(gestures, string(g.Type)) })
+
+	var passed []string
+	handlers := []EventHandler{
+		GestureHandler(r),
+		func(e MouseEvent, next func(MouseEvent)) {
+			passed = append(passed, e.Description)
+			next(e)
+		},
+	}
+	for _, e := range events {
+		clock.Set(e.At)
+		ProcessEvent(e, handlers...)
+	}
+
+	want := "click click double_click drag_start drag_move drag_end long_press"
+	if got := strings.Join(gestures, " "); got != want {
+		t.Errorf("gestures = %s, want %s", got, want)
+	}
+	// every event reaches the rest of the chain, known to the recognizer or not
+	if len(passed) != len(events) || passed[2] != "MouseDoubleClick" {
+		t.Errorf("handlers after the recognizer saw %v", passed)
+	}
+}
@@ -3,8 +3,9 @@ module github.com/dm-turing/RLHF_production_batch_2025
 go 1.23.3
 
 require (
+	Week_3 v0.0.0
 	github.com/andlabs/ui v0.0.0-20200610043537-70a69d6ae31e
-	github.com/fsnotify/fsnotify v1.7.0
+	github.com/fsnotify/fsnotify v1.8.0
 	github.com/jmoiron/sqlx v1.4.0
 	github.com/juju/ratelimit v1.0.2
 	github.com/ncruces/go-sqlite3 v0.22.0
@@ -91,3 +92,5 @@ require (
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20240314234333-6e1732d8331c // indirect
 	google.golang.org/protobuf v1.34.2 // indirect
 )
+
+replace Week_3 => ./Week_3